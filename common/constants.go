@@ -118,6 +118,18 @@ var SyncFrequency int // unit is second
 
 var BatchUpdateEnabled = false
 var BatchUpdateInterval int
+var BatchUpdateWAL string    // 批量更新预写日志：file / redis / none
+var BatchUpdateWALDir string // file 模式下的日志目录
+var BatchUpdateWALFsync = false
+
+var UsageRollupEnabled = false
+var UsageRollupFlushInterval int // unit is second
+
+var MetricsEnabled = false
+var MetricsToken string
+
+var TracingEnabled = false
+var TracingPropagateUpstream = false
 
 var RelayTimeout int // unit is second
 
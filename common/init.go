@@ -89,6 +89,15 @@ func InitEnv() {
 	// Initialize variables with GetEnvOrDefault
 	SyncFrequency = GetEnvOrDefault("SYNC_FREQUENCY", 60)
 	BatchUpdateInterval = GetEnvOrDefault("BATCH_UPDATE_INTERVAL", 5)
+	BatchUpdateWAL = GetEnvOrDefaultString("BATCH_UPDATE_WAL", "file")
+	BatchUpdateWALDir = GetEnvOrDefaultString("BATCH_UPDATE_WAL_DIR", "./data/batch_update_wal")
+	BatchUpdateWALFsync = GetEnvOrDefaultBool("BATCH_UPDATE_WAL_FSYNC", false)
+	UsageRollupEnabled = GetEnvOrDefaultBool("USAGE_ROLLUP_ENABLED", false)
+	UsageRollupFlushInterval = GetEnvOrDefault("USAGE_ROLLUP_FLUSH_INTERVAL", 60)
+	MetricsEnabled = GetEnvOrDefaultBool("METRICS_ENABLED", false)
+	MetricsToken = GetEnvOrDefaultString("METRICS_TOKEN", "")
+	TracingEnabled = GetEnvOrDefaultBool("OTEL_TRACING_ENABLED", false)
+	TracingPropagateUpstream = GetEnvOrDefaultBool("OTEL_PROPAGATE_UPSTREAM", false)
 	RelayTimeout = GetEnvOrDefault("RELAY_TIMEOUT", 0)
 	RelayMaxIdleConns = GetEnvOrDefault("RELAY_MAX_IDLE_CONNS", 500)
 	RelayMaxIdleConnsPerHost = GetEnvOrDefault("RELAY_MAX_IDLE_CONNS_PER_HOST", 100)
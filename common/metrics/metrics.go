@@ -0,0 +1,334 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 一个不依赖第三方库的轻量 Prometheus 指标实现，输出 text exposition format (0.0.4)
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type collector interface {
+	writeTo(buf *bytes.Buffer)
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      []collector
+)
+
+func register(c collector) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry = append(registry, c)
+}
+
+// WriteText 按注册顺序输出所有指标
+func WriteText(w io.Writer) error {
+	registryMutex.RLock()
+	collectors := make([]collector, len(registry))
+	copy(collectors, registry)
+	registryMutex.RUnlock()
+
+	var buf bytes.Buffer
+	for _, c := range collectors {
+		c.writeTo(&buf)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeHeader(buf *bytes.Buffer, name string, help string, metricType string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, strings.ReplaceAll(help, "\n", " "), name, metricType)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeLabels(buf *bytes.Buffer, names []string, values []string, extraName string, extraValue string) {
+	if len(names) == 0 && extraName == "" {
+		return
+	}
+	buf.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(name)
+		buf.WriteString(`="`)
+		buf.WriteString(labelValueEscaper.Replace(values[i]))
+		buf.WriteByte('"')
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(extraName)
+		buf.WriteString(`="`)
+		buf.WriteString(extraValue)
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('}')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// vec 为 Counter 与 Gauge 共享的带标签数值存储
+type vec struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+	mutex      sync.Mutex
+	series     map[string]*series
+}
+
+func newVec(name string, help string, metricType string, labelNames []string) *vec {
+	v := &vec{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		series:     make(map[string]*series),
+	}
+	register(v)
+	return v
+}
+
+func (v *vec) update(labelValues []string, fn func(s *series)) {
+	if len(labelValues) != len(v.labelNames) {
+		return
+	}
+	key := labelKey(labelValues)
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.series[key] = s
+	}
+	fn(s)
+}
+
+func (v *vec) writeTo(buf *bytes.Buffer) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	writeHeader(buf, v.name, v.help, v.metricType)
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := v.series[key]
+		buf.WriteString(v.name)
+		writeLabels(buf, v.labelNames, s.labelValues, "", "")
+		buf.WriteByte(' ')
+		buf.WriteString(formatFloat(s.value))
+		buf.WriteByte('\n')
+	}
+}
+
+type CounterVec struct {
+	*vec
+}
+
+func NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{newVec(name, help, "counter", labelNames)}
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.update(labelValues, func(s *series) { s.value += delta })
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+type GaugeVec struct {
+	*vec
+}
+
+func NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{newVec(name, help, "gauge", labelNames)}
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.update(labelValues, func(s *series) { s.value = value })
+}
+
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.update(labelValues, func(s *series) { s.value += delta })
+}
+
+func (g *GaugeVec) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *GaugeVec) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+type HistogramVec struct {
+	name       string
+	help       string
+	labelNames []string
+	buckets    []float64
+	mutex      sync.Mutex
+	series     map[string]*histogramSeries
+}
+
+func NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &HistogramVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		buckets:    sorted,
+		series:     make(map[string]*histogramSeries),
+	}
+	register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	if len(labelValues) != len(h.labelNames) {
+		return
+	}
+	key := labelKey(labelValues)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+func (h *HistogramVec) writeTo(buf *bytes.Buffer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	writeHeader(buf, h.name, h.help, "histogram")
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		for i, bound := range h.buckets {
+			buf.WriteString(h.name)
+			buf.WriteString("_bucket")
+			writeLabels(buf, h.labelNames, s.labelValues, "le", formatFloat(bound))
+			buf.WriteByte(' ')
+			buf.WriteString(strconv.FormatUint(s.counts[i], 10))
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(h.name)
+		buf.WriteString("_bucket")
+		writeLabels(buf, h.labelNames, s.labelValues, "le", "+Inf")
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatUint(s.count, 10))
+		buf.WriteByte('\n')
+		buf.WriteString(h.name)
+		buf.WriteString("_sum")
+		writeLabels(buf, h.labelNames, s.labelValues, "", "")
+		buf.WriteByte(' ')
+		buf.WriteString(formatFloat(s.sum))
+		buf.WriteByte('\n')
+		buf.WriteString(h.name)
+		buf.WriteString("_count")
+		writeLabels(buf, h.labelNames, s.labelValues, "", "")
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatUint(s.count, 10))
+		buf.WriteByte('\n')
+	}
+}
+
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc 在抓取时才计算的指标，适合连接池、渠道状态等可直接读取的数据
+type GaugeFunc struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+	collect    func() []Sample
+}
+
+func NewGaugeFunc(name string, help string, labelNames []string, collect func() []Sample) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, metricType: "gauge", labelNames: labelNames, collect: collect}
+	register(g)
+	return g
+}
+
+// NewCounterFunc 与 NewGaugeFunc 相同，但以 counter 类型输出，用于单调递增的外部计数
+func NewCounterFunc(name string, help string, labelNames []string, collect func() []Sample) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, metricType: "counter", labelNames: labelNames, collect: collect}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) writeTo(buf *bytes.Buffer) {
+	samples := g.collect()
+	writeHeader(buf, g.name, g.help, g.metricType)
+	for _, sample := range samples {
+		if len(sample.LabelValues) != len(g.labelNames) {
+			continue
+		}
+		buf.WriteString(g.name)
+		writeLabels(buf, g.labelNames, sample.LabelValues, "", "")
+		buf.WriteByte(' ')
+		buf.WriteString(formatFloat(sample.Value))
+		buf.WriteByte('\n')
+	}
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20, 30, 60, 120, 300}
+
+var (
+	HttpRequestsTotal = NewCounterVec("new_api_http_requests_total",
+		"Total HTTP requests by route template and status code.", "method", "route", "status_code")
+	HttpActiveConnections = NewGaugeVec("new_api_http_active_connections",
+		"HTTP requests currently being handled.")
+
+	RelayRequestsTotal = NewCounterVec("new_api_relay_requests_total",
+		"Total relay requests by final status code.", "model", "group", "channel", "relay_format", "status_code")
+	RelayErrorsTotal = NewCounterVec("new_api_relay_errors_total",
+		"Total relay requests that ended with an error, by error code.", "model", "group", "channel", "relay_format", "error_code")
+	RelayRequestDuration = NewHistogramVec("new_api_relay_request_duration_seconds",
+		"End-to-end relay request latency.", latencyBuckets, "model", "group", "channel", "relay_format")
+	RelayFirstTokenLatency = NewHistogramVec("new_api_relay_first_token_seconds",
+		"Time to first token for streaming relay requests.", latencyBuckets, "model", "group", "channel", "relay_format")
+	RelayTokensTotal = NewCounterVec("new_api_relay_tokens_total",
+		"Tokens consumed by billed relay requests.", "model", "group", "channel", "type")
+	RelayQuotaTotal = NewCounterVec("new_api_relay_quota_total",
+		"Quota consumed by billed relay requests.", "model", "group", "channel")
+	RelayInFlightRequests = NewGaugeVec("new_api_relay_in_flight_requests",
+		"Relay requests currently in progress.")
+	RelayInFlightStreams = NewGaugeVec("new_api_relay_in_flight_streams",
+		"Streaming responses currently being forwarded to clients.")
+)
+
+// RelayLabels 请求级别的公共标签
+type RelayLabels struct {
+	Model       string
+	Group       string
+	ChannelId   int
+	RelayFormat string
+}
+
+func (l RelayLabels) values() []string {
+	return []string{l.Model, l.Group, strconv.Itoa(l.ChannelId), l.RelayFormat}
+}
+
+func ObserveRelayRequest(labels RelayLabels, statusCode int, duration time.Duration) {
+	RelayRequestsTotal.Inc(append(labels.values(), strconv.Itoa(statusCode))...)
+	RelayRequestDuration.Observe(duration.Seconds(), labels.values()...)
+}
+
+func ObserveRelayError(labels RelayLabels, errorCode string) {
+	RelayErrorsTotal.Inc(append(labels.values(), errorCode)...)
+}
+
+func ObserveFirstToken(labels RelayLabels, latency time.Duration) {
+	if latency <= 0 {
+		return
+	}
+	RelayFirstTokenLatency.Observe(latency.Seconds(), labels.values()...)
+}
+
+func ObserveConsume(model string, group string, channelId int, promptTokens int, completionTokens int, quota int) {
+	channel := strconv.Itoa(channelId)
+	RelayTokensTotal.Add(float64(promptTokens), model, group, channel, "prompt")
+	RelayTokensTotal.Add(float64(completionTokens), model, group, channel, "completion")
+	RelayQuotaTotal.Add(float64(quota), model, group, channel)
+}
@@ -18,6 +18,13 @@ const (
 	ContextKeyTokenSpecificChannelId ContextKey = "specific_channel_id"
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
+	ContextKeyTokenOrganizationId    ContextKey = "token_organization_id"
+	ContextKeyTokenBudgetPeriod      ContextKey = "token_budget_period"
+	ContextKeyTokenBudgetQuota       ContextKey = "token_budget_quota"
+	ContextKeyTokenBudgetSoftLimit   ContextKey = "token_budget_soft_limit"
+	ContextKeyDerivedTokenId         ContextKey = "derived_token_id"
+	ContextKeyDerivedTokenQuota      ContextKey = "derived_token_quota"
+	ContextKeyDerivedTokenExpiresAt  ContextKey = "derived_token_expires_at"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -50,4 +57,8 @@ const (
 	ContextKeyLocalCountTokens ContextKey = "local_count_tokens"
 
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
+
+	ContextKeyPayloadCapture ContextKey = "payload_capture"
+
+	ContextKeyInflightRequest ContextKey = "inflight_request"
 )
@@ -21,8 +21,8 @@ import (
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/relay"
+	"github.com/QuantumNous/new-api/relay/channel"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
-	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
@@ -40,17 +40,30 @@ type testResult struct {
 	newAPIError *types.NewAPIError
 }
 
+var unsupportedTestChannelTypes = []int{
+	constant.ChannelTypeMidjourney,
+	constant.ChannelTypeMidjourneyPlus,
+	constant.ChannelTypeSunoAPI,
+	constant.ChannelTypeKling,
+	constant.ChannelTypeJimeng,
+	constant.ChannelTypeDoubaoVideo,
+	constant.ChannelTypeVidu,
+}
+
+// getChannelTestModel 获取渠道默认的测试模型：优先使用设置的测试模型，其次为第一个模型
+func getChannelTestModel(channel *model.Channel) string {
+	if channel.TestModel != nil && *channel.TestModel != "" {
+		return strings.TrimSpace(*channel.TestModel)
+	}
+	models := channel.GetModels()
+	if len(models) > 0 && strings.TrimSpace(models[0]) != "" {
+		return strings.TrimSpace(models[0])
+	}
+	return "gpt-4o-mini"
+}
+
 func testChannel(channel *model.Channel, testModel string, endpointType string) testResult {
 	tik := time.Now()
-	var unsupportedTestChannelTypes = []int{
-		constant.ChannelTypeMidjourney,
-		constant.ChannelTypeMidjourneyPlus,
-		constant.ChannelTypeSunoAPI,
-		constant.ChannelTypeKling,
-		constant.ChannelTypeJimeng,
-		constant.ChannelTypeDoubaoVideo,
-		constant.ChannelTypeVidu,
-	}
 	if lo.Contains(unsupportedTestChannelTypes, channel.Type) {
 		channelTypeName := constant.GetChannelTypeName(channel.Type)
 		return testResult{
@@ -62,17 +75,7 @@ func testChannel(channel *model.Channel, testModel string, endpointType string)
 
 	testModel = strings.TrimSpace(testModel)
 	if testModel == "" {
-		if channel.TestModel != nil && *channel.TestModel != "" {
-			testModel = strings.TrimSpace(*channel.TestModel)
-		} else {
-			models := channel.GetModels()
-			if len(models) > 0 {
-				testModel = strings.TrimSpace(models[0])
-			}
-			if testModel == "" {
-				testModel = "gpt-4o-mini"
-			}
-		}
+		testModel = getChannelTestModel(channel)
 	}
 
 	requestPath := "/v1/chat/completions"
@@ -155,25 +158,7 @@ func testChannel(channel *model.Channel, testModel string, endpointType string)
 		}
 	} else {
 		// 根据请求路径自动检测
-		relayFormat = types.RelayFormatOpenAI
-		if c.Request.URL.Path == "/v1/embeddings" {
-			relayFormat = types.RelayFormatEmbedding
-		}
-		if c.Request.URL.Path == "/v1/images/generations" {
-			relayFormat = types.RelayFormatOpenAIImage
-		}
-		if c.Request.URL.Path == "/v1/messages" {
-			relayFormat = types.RelayFormatClaude
-		}
-		if strings.Contains(c.Request.URL.Path, "/v1beta/models") {
-			relayFormat = types.RelayFormatGemini
-		}
-		if c.Request.URL.Path == "/v1/rerank" || c.Request.URL.Path == "/rerank" {
-			relayFormat = types.RelayFormatRerank
-		}
-		if c.Request.URL.Path == "/v1/responses" {
-			relayFormat = types.RelayFormatOpenAIResponses
-		}
+		relayFormat = relayFormatFromPath(c.Request.URL.Path)
 	}
 
 	request := buildTestRequest(testModel, endpointType)
@@ -229,66 +214,7 @@ func testChannel(channel *model.Channel, testModel string, endpointType string)
 
 	adaptor.Init(info)
 
-	var convertedRequest any
-	// 根据 RelayMode 选择正确的转换函数
-	switch info.RelayMode {
-	case relayconstant.RelayModeEmbeddings:
-		// Embedding 请求 - request 已经是正确的类型
-		if embeddingReq, ok := request.(*dto.EmbeddingRequest); ok {
-			convertedRequest, err = adaptor.ConvertEmbeddingRequest(c, info, *embeddingReq)
-		} else {
-			return testResult{
-				context:     c,
-				localErr:    errors.New("invalid embedding request type"),
-				newAPIError: types.NewError(errors.New("invalid embedding request type"), types.ErrorCodeConvertRequestFailed),
-			}
-		}
-	case relayconstant.RelayModeImagesGenerations:
-		// 图像生成请求 - request 已经是正确的类型
-		if imageReq, ok := request.(*dto.ImageRequest); ok {
-			convertedRequest, err = adaptor.ConvertImageRequest(c, info, *imageReq)
-		} else {
-			return testResult{
-				context:     c,
-				localErr:    errors.New("invalid image request type"),
-				newAPIError: types.NewError(errors.New("invalid image request type"), types.ErrorCodeConvertRequestFailed),
-			}
-		}
-	case relayconstant.RelayModeRerank:
-		// Rerank 请求 - request 已经是正确的类型
-		if rerankReq, ok := request.(*dto.RerankRequest); ok {
-			convertedRequest, err = adaptor.ConvertRerankRequest(c, info.RelayMode, *rerankReq)
-		} else {
-			return testResult{
-				context:     c,
-				localErr:    errors.New("invalid rerank request type"),
-				newAPIError: types.NewError(errors.New("invalid rerank request type"), types.ErrorCodeConvertRequestFailed),
-			}
-		}
-	case relayconstant.RelayModeResponses:
-		// Response 请求 - request 已经是正确的类型
-		if responseReq, ok := request.(*dto.OpenAIResponsesRequest); ok {
-			convertedRequest, err = adaptor.ConvertOpenAIResponsesRequest(c, info, *responseReq)
-		} else {
-			return testResult{
-				context:     c,
-				localErr:    errors.New("invalid response request type"),
-				newAPIError: types.NewError(errors.New("invalid response request type"), types.ErrorCodeConvertRequestFailed),
-			}
-		}
-	default:
-		// Chat/Completion 等其他请求类型
-		if generalReq, ok := request.(*dto.GeneralOpenAIRequest); ok {
-			convertedRequest, err = adaptor.ConvertOpenAIRequest(c, info, generalReq)
-		} else {
-			return testResult{
-				context:     c,
-				localErr:    errors.New("invalid general request type"),
-				newAPIError: types.NewError(errors.New("invalid general request type"), types.ErrorCodeConvertRequestFailed),
-			}
-		}
-	}
-
+	convertedRequest, err := convertTestRequest(c, adaptor, info, request)
 	if err != nil {
 		return testResult{
 			context:     c,
@@ -389,6 +315,166 @@ func testChannel(channel *model.Channel, testModel string, endpointType string)
 	}
 }
 
+// relayFormatFromPath 根据请求路径判断中继格式
+func relayFormatFromPath(path string) types.RelayFormat {
+	switch {
+	case path == "/v1/embeddings":
+		return types.RelayFormatEmbedding
+	case path == "/v1/images/generations":
+		return types.RelayFormatOpenAIImage
+	case path == "/v1/messages":
+		return types.RelayFormatClaude
+	case strings.Contains(path, "/v1beta/models"):
+		return types.RelayFormatGemini
+	case path == "/v1/rerank" || path == "/rerank":
+		return types.RelayFormatRerank
+	case path == "/v1/responses":
+		return types.RelayFormatOpenAIResponses
+	}
+	return types.RelayFormatOpenAI
+}
+
+// convertTestRequest 根据请求类型选择适配器对应的转换函数
+func convertTestRequest(c *gin.Context, adaptor channel.Adaptor, info *relaycommon.RelayInfo, request dto.Request) (any, error) {
+	switch req := request.(type) {
+	case *dto.EmbeddingRequest:
+		return adaptor.ConvertEmbeddingRequest(c, info, *req)
+	case *dto.ImageRequest:
+		return adaptor.ConvertImageRequest(c, info, *req)
+	case *dto.RerankRequest:
+		return adaptor.ConvertRerankRequest(c, info.RelayMode, *req)
+	case *dto.OpenAIResponsesRequest:
+		return adaptor.ConvertOpenAIResponsesRequest(c, info, *req)
+	case *dto.ClaudeRequest:
+		return adaptor.ConvertClaudeRequest(c, info, req)
+	case *dto.GeneralOpenAIRequest:
+		// Chat/Completion 等其他请求类型
+		return adaptor.ConvertOpenAIRequest(c, info, req)
+	}
+	return nil, fmt.Errorf("unsupported request type: %T", request)
+}
+
+type channelRequestResult struct {
+	StatusCode       int
+	UseTimeMs        int64
+	FirstTokenMs     int64
+	IsStream         bool
+	UpstreamModel    string
+	UsingKey         string
+	PromptTokens     int
+	CompletionTokens int
+	Response         string
+	Err              error
+}
+
+// doChannelRequest 参考渠道测试的流程，将客户端格式的请求体直接发往指定渠道，不经过预扣费与结算
+func doChannelRequest(channel *model.Channel, modelName string, requestPath string, body []byte) (result channelRequestResult) {
+	fail := func(statusCode int, err error) channelRequestResult {
+		result.StatusCode = statusCode
+		result.Err = err
+		return result
+	}
+	if lo.Contains(unsupportedTestChannelTypes, channel.Type) {
+		return fail(http.StatusBadRequest, fmt.Errorf("%s channel test is not supported", constant.GetChannelTypeName(channel.Type)))
+	}
+	relayFormat := relayFormatFromPath(requestPath)
+	if relayFormat == types.RelayFormatGemini {
+		return fail(http.StatusBadRequest, errors.New("gemini format request is not supported"))
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = &http.Request{
+		Method: "POST",
+		URL:    &url.URL{Path: requestPath},
+		Body:   io.NopCloser(bytes.NewReader(body)),
+		Header: make(http.Header),
+	}
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	cache, err := model.GetUserCache(1)
+	if err != nil {
+		return fail(http.StatusInternalServerError, err)
+	}
+	cache.WriteContext(c)
+	c.Set("channel", channel.Type)
+	c.Set("base_url", channel.GetBaseURL())
+	group, _ := model.GetUserGroup(1, false)
+	c.Set("group", group)
+	common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
+
+	if newAPIError := middleware.SetupContextForSelectedChannel(c, channel, modelName); newAPIError != nil {
+		return fail(newAPIError.StatusCode, newAPIError)
+	}
+	result.UsingKey = common.GetContextKeyString(c, constant.ContextKeyChannelKey)
+	request, err := helper.GetAndValidateRequest(c, relayFormat)
+	if err != nil {
+		return fail(http.StatusBadRequest, err)
+	}
+	info, err := relaycommon.GenRelayInfo(c, relayFormat, request, nil)
+	if err != nil {
+		return fail(http.StatusInternalServerError, err)
+	}
+	info.InitChannelMeta(c)
+	if err = helper.ModelMappedHelper(c, info, request); err != nil {
+		return fail(http.StatusInternalServerError, err)
+	}
+	request.SetModelName(info.UpstreamModelName)
+	result.UpstreamModel = info.UpstreamModelName
+	if textRequest, ok := request.(*dto.GeneralOpenAIRequest); ok && textRequest.Stream {
+		info.ShouldIncludeUsage = true
+		if info.SupportStreamOptions {
+			textRequest.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+		}
+	}
+
+	apiType, _ := common.ChannelType2APIType(channel.Type)
+	adaptor := relay.GetAdaptor(apiType)
+	if adaptor == nil {
+		return fail(http.StatusInternalServerError, fmt.Errorf("invalid api type: %d, adaptor is nil", apiType))
+	}
+	adaptor.Init(info)
+	convertedRequest, err := convertTestRequest(c, adaptor, info, request)
+	if err != nil {
+		return fail(http.StatusInternalServerError, err)
+	}
+	jsonData, err := common.Marshal(convertedRequest)
+	if err != nil {
+		return fail(http.StatusInternalServerError, err)
+	}
+
+	resp, err := adaptor.DoRequest(c, info, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fail(http.StatusInternalServerError, err)
+	}
+	var httpResp *http.Response
+	if resp != nil {
+		httpResp = resp.(*http.Response)
+		if httpResp.StatusCode != http.StatusOK {
+			result.UseTimeMs = time.Since(info.StartTime).Milliseconds()
+			return fail(httpResp.StatusCode, service.RelayErrorHandler(c.Request.Context(), httpResp, true))
+		}
+	}
+	usageA, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	result.UseTimeMs = time.Since(info.StartTime).Milliseconds()
+	result.IsStream = info.IsStream
+	if info.IsStream && info.HasSendResponse() {
+		result.FirstTokenMs = info.FirstResponseTime.Sub(info.StartTime).Milliseconds()
+	}
+	if newAPIError != nil {
+		return fail(newAPIError.StatusCode, newAPIError)
+	}
+	result.StatusCode = w.Code
+	result.Response = w.Body.String()
+	if usage, ok := usageA.(*dto.Usage); ok && usage != nil {
+		result.PromptTokens = usage.PromptTokens
+		result.CompletionTokens = usage.CompletionTokens
+	} else {
+		result.PromptTokens, result.CompletionTokens = service.ExtractResponseUsage(result.Response)
+	}
+	return result
+}
+
 func buildTestRequest(model string, endpointType string) dto.Request {
 	// 根据端点类型构建不同的测试请求
 	if endpointType != "" {
@@ -591,6 +677,9 @@ func testAllChannels(notify bool) error {
 				service.EnableChannel(channel.Id, common.GetContextKeyString(result.context, constant.ContextKeyChannelKey), channel.Name)
 			}
 
+			if isChannelEnabled && result.localErr == nil {
+				recordChannelTestStatus(channel, result.newAPIError == nil, time.Duration(milliseconds)*time.Millisecond)
+			}
 			channel.UpdateResponseTime(milliseconds)
 			time.Sleep(common.RequestInterval)
 		}
@@ -622,6 +711,9 @@ func AutomaticallyTestChannels() {
 		return
 	}
 	autoTestChannelsOnce.Do(func() {
+		gopool.Go(automaticallyVerifyChannelModels)
+		gopool.Go(automaticallyTestChannelCapabilities)
+		gopool.Go(automaticallySyncChannelModels)
 		for {
 			if !operation_setting.GetMonitorSetting().AutoTestChannelEnabled {
 				time.Sleep(1 * time.Minute)
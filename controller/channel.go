@@ -178,6 +178,24 @@ func FetchUpstreamModels(c *gin.Context) {
 		return
 	}
 
+	ids, err := fetchChannelUpstreamModels(channel)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    ids,
+	})
+}
+
+// fetchChannelUpstreamModels 请求渠道上游的模型列表接口，channel 需包含密钥
+func fetchChannelUpstreamModels(channel *model.Channel) ([]string, error) {
 	baseURL := constant.ChannelBaseURLs[channel.Type]
 	if channel.GetBaseURL() != "" {
 		baseURL = channel.GetBaseURL()
@@ -215,16 +233,13 @@ func FetchUpstreamModels(c *gin.Context) {
 	// 获取用于请求的可用密钥（多密钥渠道优先使用启用状态的密钥）
 	key, _, apiErr := channel.GetNextEnabledKey()
 	if apiErr != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": fmt.Sprintf("获取渠道密钥失败: %s", apiErr.Error()),
-		})
-		return
+		return nil, fmt.Errorf("获取渠道密钥失败: %s", apiErr.Error())
 	}
 	key = strings.TrimSpace(key)
 
 	// 获取响应体 - 根据渠道类型决定是否添加 AuthHeader
 	var body []byte
+	var err error
 	switch channel.Type {
 	case constant.ChannelTypeAnthropic:
 		body, err = GetResponseBody("GET", url, channel, GetClaudeAuthHeader(key))
@@ -232,17 +247,12 @@ func FetchUpstreamModels(c *gin.Context) {
 		body, err = GetResponseBody("GET", url, channel, GetAuthHeader(key))
 	}
 	if err != nil {
-		common.ApiError(c, err)
-		return
+		return nil, err
 	}
 
 	var result OpenAIModelsResponse
 	if err = json.Unmarshal(body, &result); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": fmt.Sprintf("解析响应失败: %s", err.Error()),
-		})
-		return
+		return nil, fmt.Errorf("解析响应失败: %s", err.Error())
 	}
 
 	var ids []string
@@ -253,12 +263,7 @@ func FetchUpstreamModels(c *gin.Context) {
 		}
 		ids = append(ids, id)
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "",
-		"data":    ids,
-	})
+	return ids, nil
 }
 
 func FixChannelsAbilities(c *gin.Context) {
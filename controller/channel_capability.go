@@ -0,0 +1,267 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+var capabilityTestLock sync.Mutex
+var capabilityTestRunning bool = false
+
+type ChannelCapabilitySummary struct {
+	Capability string                   `json:"capability"`
+	InMatrix   bool                     `json:"in_matrix"`
+	Latest     *model.ChannelTestResult `json:"latest"`
+	Total      int64                    `json:"total"`
+	Passed     int64                    `json:"passed"`
+	Uptime     float64                  `json:"uptime"` // 通过率，百分比
+}
+
+// testChannelCapabilities 按测试矩阵逐项测试渠道能力并保存结果，没有适用模型的能力会被跳过
+func testChannelCapabilities(channel *model.Channel, capabilities []string) ([]*model.ChannelTestResult, error) {
+	testModel := getChannelTestModel(channel)
+	longContextTokens := operation_setting.GetMonitorSetting().CapabilityTestLongContextTokens
+	results := make([]*model.ChannelTestResult, 0, len(capabilities))
+	for _, capability := range capabilities {
+		probe := service.BuildChannelCapabilityProbe(capability, testModel, channel.GetModels(), longContextTokens)
+		if probe == nil {
+			continue
+		}
+		resp := doChannelRequest(channel, probe.ModelName, probe.Path, probe.Body)
+		result := &model.ChannelTestResult{
+			CreatedAt:  common.GetTimestamp(),
+			ChannelId:  channel.Id,
+			Capability: capability,
+			ModelName:  probe.ModelName,
+			StatusCode: resp.StatusCode,
+			UseTimeMs:  resp.UseTimeMs,
+		}
+		if resp.Err != nil {
+			result.Message = resp.Err.Error()
+		} else {
+			result.Success, result.Message = service.CheckChannelCapability(capability, resp.Response, resp.IsStream)
+		}
+		results = append(results, result)
+	}
+	if err := model.BatchInsertChannelTestResults(results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func testAllChannelCapabilities() error {
+	capabilityTestLock.Lock()
+	if capabilityTestRunning {
+		capabilityTestLock.Unlock()
+		return errors.New("能力测试已在运行中")
+	}
+	capabilityTestRunning = true
+	capabilityTestLock.Unlock()
+	channels, err := model.GetAllChannels(0, 0, true, false)
+	if err != nil {
+		capabilityTestLock.Lock()
+		capabilityTestRunning = false
+		capabilityTestLock.Unlock()
+		return err
+	}
+	gopool.Go(func() {
+		defer func() {
+			capabilityTestLock.Lock()
+			capabilityTestRunning = false
+			capabilityTestLock.Unlock()
+		}()
+
+		setting := operation_setting.GetMonitorSetting()
+		for _, channel := range channels {
+			if channel.Status != common.ChannelStatusEnabled {
+				continue
+			}
+			capabilities := setting.GetCapabilityTestMatrix(channel.Type)
+			if len(capabilities) == 0 {
+				continue
+			}
+			if _, err := testChannelCapabilities(channel, capabilities); err != nil {
+				common.SysError(fmt.Sprintf("failed to save capability test results of channel #%d: %s", channel.Id, err.Error()))
+			}
+			time.Sleep(common.RequestInterval)
+		}
+
+		if setting.CapabilityTestRetentionDays > 0 {
+			target := time.Now().AddDate(0, 0, -setting.CapabilityTestRetentionDays).Unix()
+			if count, err := model.DeleteOldChannelTestResults(context.Background(), target, 100); err != nil {
+				common.SysError("failed to delete old channel test results: " + err.Error())
+			} else if count > 0 {
+				common.SysLog(fmt.Sprintf("deleted %d old channel test results", count))
+			}
+		}
+	})
+	return nil
+}
+
+// automaticallyTestChannelCapabilities 按设置的间隔定时对所有渠道执行能力测试
+func automaticallyTestChannelCapabilities() {
+	for {
+		if !operation_setting.GetMonitorSetting().CapabilityTestEnabled {
+			time.Sleep(1 * time.Minute)
+			continue
+		}
+		for {
+			frequency := operation_setting.GetMonitorSetting().CapabilityTestMinutes
+			time.Sleep(time.Duration(int(math.Round(frequency))) * time.Minute)
+			common.SysLog(fmt.Sprintf("automatically testing channel capabilities with interval %f minutes", frequency))
+			_ = testAllChannelCapabilities()
+			if !operation_setting.GetMonitorSetting().CapabilityTestEnabled {
+				break
+			}
+		}
+	}
+}
+
+func getCapabilityQueryDays(c *gin.Context) int {
+	days, _ := strconv.Atoi(c.Query("days"))
+	if days <= 0 {
+		days = 7
+	}
+	if days > 90 {
+		days = 90
+	}
+	return days
+}
+
+// TestChannelCapabilities 立即测试单个渠道的能力，未指定 capability 时使用该渠道类型的测试矩阵
+func TestChannelCapabilities(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	capabilities := operation_setting.GetMonitorSetting().GetCapabilityTestMatrix(channel.Type)
+	if capability := strings.TrimSpace(c.Query("capability")); capability != "" {
+		capabilities = strings.Split(capability, ",")
+	}
+	results, err := testChannelCapabilities(channel, capabilities)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, results)
+}
+
+// TestAllChannelCapabilities 在后台对所有启用的渠道执行能力测试
+func TestAllChannelCapabilities(c *gin.Context) {
+	if err := testAllChannelCapabilities(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetChannelCapabilities 获取渠道各项能力最近的测试结果与通过率
+func GetChannelCapabilities(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(channelId, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	startTimestamp := time.Now().AddDate(0, 0, -getCapabilityQueryDays(c)).Unix()
+	stats, err := model.GetChannelCapabilityStats(channelId, startTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	latest, err := model.GetLatestChannelTestResults(channelId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	matrix := operation_setting.GetMonitorSetting().GetCapabilityTestMatrix(channel.Type)
+	summaries := make([]ChannelCapabilitySummary, 0, len(service.ChannelCapabilities))
+	for _, capability := range service.ChannelCapabilities {
+		summary := ChannelCapabilitySummary{
+			Capability: capability,
+			InMatrix:   common.StringsContains(matrix, capability),
+		}
+		for _, stat := range stats {
+			if stat.Capability == capability {
+				summary.Total = stat.Total
+				summary.Passed = stat.Passed
+			}
+		}
+		if summary.Total > 0 {
+			summary.Uptime = math.Round(float64(summary.Passed)/float64(summary.Total)*10000) / 100
+		}
+		for _, result := range latest {
+			if result.Capability == capability {
+				summary.Latest = result
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	common.ApiSuccess(c, summaries)
+}
+
+// GetChannelTestResults 分页查询渠道的能力测试历史
+func GetChannelTestResults(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	results, total, err := model.GetChannelTestResults(channelId, c.Query("capability"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(results)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetChannelCapabilityTrend 获取渠道各项能力的通过率趋势，2 天以内按小时统计，否则按天统计
+func GetChannelCapabilityTrend(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	days := getCapabilityQueryDays(c)
+	bucketSeconds := int64(86400)
+	if days <= 2 {
+		bucketSeconds = 3600
+	}
+	startTimestamp := time.Now().AddDate(0, 0, -days).Unix()
+	trend, err := model.GetChannelCapabilityTrend(channelId, c.Query("capability"), startTimestamp, bucketSeconds)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"bucket_seconds": bucketSeconds,
+		"items":          trend,
+	})
+}
@@ -0,0 +1,360 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+var modelSyncLock sync.Mutex
+var modelSyncRunning bool = false
+
+// ChannelModelSyncReport 渠道模型与上游模型的差异，ToAdd/ToRemove 为按当前设置会自动添加、移除的模型
+type ChannelModelSyncReport struct {
+	ChannelId   int      `json:"channel_id"`
+	ChannelName string   `json:"channel_name"`
+	Added       []string `json:"added"`
+	Removed     []string `json:"removed"`
+	ToAdd       []string `json:"to_add"`
+	ToRemove    []string `json:"to_remove"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// diffChannelModels 对比渠道模型与上游模型，渠道中配置了重定向的模型按重定向后的名称对比
+func diffChannelModels(channel *model.Channel, upstreamModels []string) (added []string, removed []string) {
+	upstream := make(map[string]bool, len(upstreamModels))
+	for _, m := range upstreamModels {
+		upstream[m] = true
+	}
+	covered := make(map[string]bool)
+	added = make([]string, 0)
+	removed = make([]string, 0)
+	for _, m := range channel.GetModels() {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		target := resolveUpstreamModel(channel, m)
+		covered[m] = true
+		covered[target] = true
+		if !upstream[m] && !upstream[target] {
+			removed = append(removed, m)
+		}
+	}
+	for _, m := range upstreamModels {
+		if !covered[m] {
+			covered[m] = true
+			added = append(added, m)
+		}
+	}
+	return added, removed
+}
+
+func compileModelSyncPatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			common.SysError(fmt.Sprintf("invalid model sync allow pattern %q: %s", pattern, err.Error()))
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// buildChannelModelSyncReport 拉取上游模型列表并计算差异，不修改渠道
+func buildChannelModelSyncReport(channel *model.Channel) *ChannelModelSyncReport {
+	report := &ChannelModelSyncReport{
+		ChannelId:   channel.Id,
+		ChannelName: channel.Name,
+		Added:       []string{},
+		Removed:     []string{},
+		ToAdd:       []string{},
+		ToRemove:    []string{},
+	}
+	if lo.Contains(unsupportedTestChannelTypes, channel.Type) {
+		report.Error = "该渠道类型不支持获取模型列表"
+		return report
+	}
+	upstreamModels, err := fetchChannelUpstreamModels(channel)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	if len(upstreamModels) == 0 {
+		report.Error = "上游返回的模型列表为空"
+		return report
+	}
+	report.Added, report.Removed = diffChannelModels(channel, upstreamModels)
+
+	setting := operation_setting.GetMonitorSetting()
+	if setting.ModelSyncAutoAdd {
+		patterns := compileModelSyncPatterns(setting.ModelSyncAllowPatterns)
+		for _, m := range report.Added {
+			if len(patterns) == 0 || lo.SomeBy(patterns, func(re *regexp.Regexp) bool { return re.MatchString(m) }) {
+				report.ToAdd = append(report.ToAdd, m)
+			}
+		}
+	}
+	// 不会把渠道的模型全部移除，避免上游接口异常时清空渠道
+	if setting.ModelSyncAutoRemove && len(report.Removed) < len(channel.GetModels())+len(report.ToAdd) {
+		report.ToRemove = report.Removed
+	}
+	return report
+}
+
+// applyChannelModelSync 按报告更新渠道模型并保存同步记录，与上一次记录相同且无需修改时不重复记录，返回 nil
+func applyChannelModelSync(channel *model.Channel, report *ChannelModelSyncReport) (*model.ChannelModelSync, error) {
+	record := &model.ChannelModelSync{
+		CreatedAt:     common.GetTimestamp(),
+		ChannelId:     channel.Id,
+		ChannelName:   channel.Name,
+		Status:        model.ChannelModelSyncStatusReported,
+		AddedModels:   strings.Join(report.Added, ","),
+		RemovedModels: strings.Join(report.Removed, ","),
+		Message:       report.Error,
+	}
+	if report.Error != "" {
+		record.Status = model.ChannelModelSyncStatusError
+	}
+	if len(report.ToAdd) > 0 || len(report.ToRemove) > 0 {
+		models := make([]string, 0)
+		for _, m := range channel.GetModels() {
+			if m = strings.TrimSpace(m); m != "" && !lo.Contains(report.ToRemove, m) {
+				models = append(models, m)
+			}
+		}
+		models = append(models, report.ToAdd...)
+		if err := channel.UpdateModels(models); err != nil {
+			return nil, err
+		}
+		record.Status = model.ChannelModelSyncStatusSynced
+		record.AppliedAdded = strings.Join(report.ToAdd, ",")
+		record.AppliedRemoved = strings.Join(report.ToRemove, ",")
+	} else {
+		if report.Error == "" && len(report.Added) == 0 && len(report.Removed) == 0 {
+			return nil, nil
+		}
+		latest, err := model.GetLatestChannelModelSync(channel.Id)
+		if err != nil {
+			return nil, err
+		}
+		if latest != nil && latest.Status == record.Status && latest.AddedModels == record.AddedModels &&
+			latest.RemovedModels == record.RemovedModels && latest.Message == record.Message {
+			return nil, nil
+		}
+	}
+	if err := record.Insert(); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func formatModelSyncRecord(record *model.ChannelModelSync) string {
+	lines := []string{fmt.Sprintf("通道「%s」（#%d）", record.ChannelName, record.ChannelId)}
+	if record.Status == model.ChannelModelSyncStatusError {
+		lines = append(lines, "获取上游模型失败："+record.Message)
+	}
+	if record.AddedModels != "" {
+		lines = append(lines, "上游新增："+record.AddedModels)
+	}
+	if record.RemovedModels != "" {
+		lines = append(lines, "上游已移除："+record.RemovedModels)
+	}
+	if record.AppliedAdded != "" {
+		lines = append(lines, "已自动添加："+record.AppliedAdded)
+	}
+	if record.AppliedRemoved != "" {
+		lines = append(lines, "已自动移除："+record.AppliedRemoved)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func syncAllChannelModels() error {
+	modelSyncLock.Lock()
+	if modelSyncRunning {
+		modelSyncLock.Unlock()
+		return errors.New("上游模型同步已在运行中")
+	}
+	modelSyncRunning = true
+	modelSyncLock.Unlock()
+	channels, err := model.GetAllChannels(0, 0, true, false)
+	if err != nil {
+		modelSyncLock.Lock()
+		modelSyncRunning = false
+		modelSyncLock.Unlock()
+		return err
+	}
+	gopool.Go(func() {
+		defer func() {
+			modelSyncLock.Lock()
+			modelSyncRunning = false
+			modelSyncLock.Unlock()
+		}()
+
+		changed := false
+		messages := make([]string, 0)
+		for _, channel := range channels {
+			if channel.Status != common.ChannelStatusEnabled || lo.Contains(unsupportedTestChannelTypes, channel.Type) {
+				continue
+			}
+			report := buildChannelModelSyncReport(channel)
+			record, err := applyChannelModelSync(channel, report)
+			if err != nil {
+				common.SysError(fmt.Sprintf("failed to sync models of channel #%d: %s", channel.Id, err.Error()))
+				continue
+			}
+			if record != nil {
+				changed = changed || record.Status == model.ChannelModelSyncStatusSynced
+				messages = append(messages, formatModelSyncRecord(record))
+			}
+			time.Sleep(common.RequestInterval)
+		}
+		if changed {
+			model.InitChannelCache()
+		}
+
+		setting := operation_setting.GetMonitorSetting()
+		if setting.ModelSyncNotify && len(messages) > 0 {
+			service.NotifyRootUser(dto.NotifyTypeModelSync,
+				fmt.Sprintf("%d 个通道的上游模型发生变化", len(messages)), strings.Join(messages, "\n\n"))
+		}
+		if setting.ModelSyncRetentionDays > 0 {
+			target := time.Now().AddDate(0, 0, -setting.ModelSyncRetentionDays).Unix()
+			if count, err := model.DeleteOldChannelModelSyncs(context.Background(), target, 100); err != nil {
+				common.SysError("failed to delete old channel model syncs: " + err.Error())
+			} else if count > 0 {
+				common.SysLog(fmt.Sprintf("deleted %d old channel model syncs", count))
+			}
+		}
+	})
+	return nil
+}
+
+// automaticallySyncChannelModels 按设置的间隔定时同步所有渠道的上游模型
+func automaticallySyncChannelModels() {
+	for {
+		if !operation_setting.GetMonitorSetting().ModelSyncEnabled {
+			time.Sleep(1 * time.Minute)
+			continue
+		}
+		for {
+			frequency := operation_setting.GetMonitorSetting().ModelSyncMinutes
+			time.Sleep(time.Duration(int(math.Round(frequency))) * time.Minute)
+			common.SysLog(fmt.Sprintf("automatically syncing channel models with interval %f minutes", frequency))
+			_ = syncAllChannelModels()
+			if !operation_setting.GetMonitorSetting().ModelSyncEnabled {
+				break
+			}
+		}
+	}
+}
+
+// GetChannelModelSyncReport 试运行：对比渠道与上游模型并返回按当前设置将要执行的变更，不修改任何数据，
+// 指定 channel_id 时只检查该渠道，否则检查所有启用的渠道
+func GetChannelModelSyncReport(c *gin.Context) {
+	channels := make([]*model.Channel, 0)
+	if channelId, _ := strconv.Atoi(c.Query("channel_id")); channelId != 0 {
+		channel, err := model.GetChannelById(channelId, true)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		channels = append(channels, channel)
+	} else {
+		allChannels, err := model.GetAllChannels(0, 0, true, false)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		for _, channel := range allChannels {
+			if channel.Status == common.ChannelStatusEnabled && !lo.Contains(unsupportedTestChannelTypes, channel.Type) {
+				channels = append(channels, channel)
+			}
+		}
+	}
+
+	reports := make([]*ChannelModelSyncReport, len(channels))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 5)
+	for i, channel := range channels {
+		wg.Add(1)
+		sem <- struct{}{}
+		gopool.Go(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			reports[i] = buildChannelModelSyncReport(channel)
+		})
+	}
+	wg.Wait()
+	common.ApiSuccess(c, reports)
+}
+
+// SyncChannelModels 立即按当前设置同步单个渠道的上游模型
+func SyncChannelModels(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	report := buildChannelModelSyncReport(channel)
+	record, err := applyChannelModelSync(channel, report)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if record != nil && record.Status == model.ChannelModelSyncStatusSynced {
+		model.InitChannelCache()
+	}
+	common.ApiSuccess(c, report)
+}
+
+// SyncAllChannelModels 在后台同步所有启用渠道的上游模型
+func SyncAllChannelModels(c *gin.Context) {
+	if err := syncAllChannelModels(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetChannelModelSyncs 分页查询上游模型同步记录
+func GetChannelModelSyncs(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	syncs, total, err := model.GetChannelModelSyncs(channelId, c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(syncs)
+	common.ApiSuccess(c, pageInfo)
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"io"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const inflightStreamInterval = time.Second
+
+// GetInflightRequests 获取当前所有正在处理的中继请求
+func GetInflightRequests(c *gin.Context) {
+	common.ApiSuccess(c, service.ListInflightRequests())
+}
+
+// StreamInflightRequests 以 SSE 方式每秒推送一次正在处理的请求列表
+func StreamInflightRequests(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(inflightStreamInterval)
+	defer ticker.Stop()
+	c.SSEvent("inflight", service.ListInflightRequests())
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-ticker.C:
+			c.SSEvent("inflight", service.ListInflightRequests())
+			return true
+		}
+	})
+}
+
+// CancelInflightRequests 按请求 id、令牌或用户取消正在处理的请求，已输出的部分照常结算
+func CancelInflightRequests(c *gin.Context) {
+	var filter service.InflightCancelFilter
+	if err := c.ShouldBindJSON(&filter); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if filter.IsEmpty() {
+		common.ApiErrorMsg(c, "请指定要取消的请求、令牌或用户")
+		return
+	}
+	count, err := service.CancelInflightRequests(filter)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"cancelled": count,
+	})
+}
@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InvoiceGenerateRequest struct {
+	UserId int    `json:"user_id"`
+	Month  string `json:"month"` // 账单月份，格式 2006-01
+}
+
+type InvoicePayRequest struct {
+	Remark string `json:"remark"`
+}
+
+type CreditSuspendRequest struct {
+	UserId    int  `json:"user_id"`
+	Suspended bool `json:"suspended"`
+}
+
+// GetInvoicesSelf 当前用户的账单列表
+func GetInvoicesSelf(c *gin.Context) {
+	userId := c.GetInt("id")
+	pageInfo := common.GetPageQuery(c)
+	invoices, total, err := model.GetInvoices(userId, c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(invoices)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetInvoiceSelf 当前用户的账单明细
+func GetInvoiceSelf(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	invoice, err := model.GetInvoiceById(id)
+	if err != nil || invoice.UserId != c.GetInt("id") {
+		common.ApiErrorMsg(c, "账单不存在")
+		return
+	}
+	common.ApiSuccess(c, invoice)
+}
+
+// GetUserStatementSelf 当前用户本月截至目前的消费汇总，用于出账前核对
+func GetUserStatementSelf(c *gin.Context) {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	items, err := model.GetPostpaidStatement(c.GetInt("id"), start.Unix(), now.Unix())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"period_start": start.Unix(),
+		"period_end":   now.Unix(),
+		"items":        items,
+	})
+}
+
+func GetAllInvoices(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	invoices, total, err := model.GetInvoices(userId, c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(invoices)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetInvoice(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	invoice, err := model.GetInvoiceById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, invoice)
+}
+
+// GenerateInvoice 为指定用户手动出具某个月的账单，已出具的账单直接返回
+func GenerateInvoice(c *gin.Context) {
+	var req InvoiceGenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserId == 0 {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	month, err := time.ParseInLocation("2006-01", req.Month, time.Local)
+	if err != nil {
+		common.ApiErrorMsg(c, "账单月份格式错误，应为 YYYY-MM")
+		return
+	}
+	end := month.AddDate(0, 1, 0)
+	if end.After(time.Now()) {
+		common.ApiErrorMsg(c, "只能出具已结束月份的账单")
+		return
+	}
+	dueTime := time.Now().AddDate(0, 0, operation_setting.GetPostpaidSetting().DueDays).Unix()
+	invoice, _, err := model.GenerateInvoice(req.UserId, month.Unix(), end.Unix(), dueTime)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if invoice == nil {
+		common.ApiErrorMsg(c, "该月份没有消费记录")
+		return
+	}
+	common.ApiSuccess(c, invoice)
+}
+
+// MarkInvoicePaid 确认线下收款
+func MarkInvoicePaid(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var req InvoicePayRequest
+	_ = c.ShouldBindJSON(&req)
+	invoice, err := model.GetInvoiceById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.MarkInvoicePaid(id, req.Remark); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(invoice.UserId, model.LogTypeManage, fmt.Sprintf("管理员 %s 确认账单 %s 已付款，恢复额度 %s", c.GetString("username"), invoice.InvoiceNo, logger.LogQuota(invoice.Quota)))
+	common.ApiSuccess(c, nil)
+}
+
+// SetUserCreditSuspended 手动暂停或恢复后付费用户的调用
+func SetUserCreditSuspended(c *gin.Context) {
+	var req CreditSuspendRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserId == 0 {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	if err := model.SetUserCreditSuspended(req.UserId, req.Suspended); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	action := "恢复"
+	if req.Suspended {
+		action = "暂停"
+	}
+	model.RecordLog(req.UserId, model.LogTypeManage, fmt.Sprintf("管理员 %s %s了后付费调用", c.GetString("username"), action))
+	common.ApiSuccess(c, nil)
+}
+
+// RunInvoiceJob 立即执行一次出账和逾期检查
+func RunInvoiceJob(c *gin.Context) {
+	now := time.Now()
+	generated, err := service.GenerateMonthlyInvoices(now)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = service.ProcessOverdueInvoices(now); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{"generated": generated})
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -258,3 +259,18 @@ func ClearErrorLogs(c *gin.Context) {
 		"data":    count,
 	})
 }
+
+// GetLogSinkStats 查看日志外部投递队列状态
+func GetLogSinkStats(c *gin.Context) {
+	common.ApiSuccess(c, model.GetLogSinkStats())
+}
+
+// GetPayloadCapture 查看日志关联的请求/响应抓取内容
+func GetPayloadCapture(c *gin.Context) {
+	detail, err := service.GetPayloadCaptureDetail(c.Param("request_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, detail)
+}
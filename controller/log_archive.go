@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+func GetLogArchives(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	archives, total, err := model.GetLogArchives(startTimestamp, endTimestamp, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(archives)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// RunLogArchive 立即在后台执行一次日志归档
+func RunLogArchive(c *gin.Context) {
+	gopool.Go(func() {
+		count, err := service.ArchiveOldLogs(context.Background())
+		if err != nil {
+			common.SysError("failed to archive logs: " + err.Error())
+			return
+		}
+		common.SysLog(fmt.Sprintf("manual log archive finished, %d partitions archived", count))
+	})
+	common.ApiSuccess(c, nil)
+}
+
+// QueryLogArchive 查询某个归档分区中的日志
+func QueryLogArchive(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	archive, err := model.GetLogArchiveById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	logType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	logs, total, err := service.QueryLogArchive(c.Request.Context(), archive, service.LogArchiveQuery{
+		LogType:        logType,
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+		Username:       c.Query("username"),
+		TokenName:      c.Query("token_name"),
+		ModelName:      c.Query("model_name"),
+		Channel:        channel,
+		Group:          c.Query("group"),
+	}, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(total)
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// RestoreLogArchive 将归档分区重新导入日志库
+func RestoreLogArchive(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	archive, err := model.GetLogArchiveById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if archive.Status == model.LogArchiveStatusRestored {
+		common.ApiError(c, errors.New("该归档已导入"))
+		return
+	}
+	count, err := service.RestoreLogArchive(c.Request.Context(), archive)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, count)
+}
@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 以 Prometheus 文本格式输出指标，配置 METRICS_TOKEN 后需携带 Bearer Token
+func Metrics(c *gin.Context) {
+	if common.MetricsToken != "" {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(common.MetricsToken)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+	}
+	c.Header("Content-Type", metrics.ContentType)
+	c.Status(http.StatusOK)
+	if err := metrics.WriteText(c.Writer); err != nil {
+		common.SysError("failed to write metrics: " + err.Error())
+	}
+}
@@ -177,12 +177,12 @@ func UpdateMidjourneyTaskBulk() {
 					logger.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
 				} else {
 					if shouldReturnQuota {
-						err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
+						err = model.IncreaseBillingQuota(task.UserId, task.OrganizationId, task.Quota, model.QuotaLedgerRef{Source: model.QuotaLedgerSourceTask, Reference: task.MjId, Remark: "构图失败补偿"})
 						if err != nil {
 							logger.LogError(ctx, "fail to increase user quota: "+err.Error())
 						}
 						logContent := fmt.Sprintf("构图失败 %s，补偿 %s", task.MjId, logger.LogQuota(task.Quota))
-						model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
+						model.RecordTaskRefundLog(task.UserId, task.OrganizationId, service.CoverActionToModelName(task.Action), task.Quota, logContent)
 					}
 				}
 			}
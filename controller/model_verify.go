@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+var modelVerifyLock sync.Mutex
+var modelVerifyRunning bool = false
+
+// resolveUpstreamModel 按渠道的模型重定向（支持链式）得到实际请求上游的模型名
+func resolveUpstreamModel(channel *model.Channel, modelName string) string {
+	modelMap := make(map[string]string)
+	if err := common.Unmarshal([]byte(channel.GetModelMapping()), &modelMap); err != nil {
+		return modelName
+	}
+	current := modelName
+	visited := map[string]bool{current: true}
+	for {
+		mapped, ok := modelMap[current]
+		if !ok || mapped == "" || visited[mapped] {
+			return current
+		}
+		visited[mapped] = true
+		current = mapped
+	}
+}
+
+// verifyChannelModel 向渠道的指定模型发送指纹探针，打分并保存验真记录，同时返回探针所用的渠道密钥
+func verifyChannelModel(channel *model.Channel, modelName string, threshold float64) (*model.ModelVerification, string, error) {
+	upstreamModel := resolveUpstreamModel(channel, modelName)
+	modelKey, fingerprint := service.GetModelFingerprint(upstreamModel)
+	if fingerprint == nil {
+		return nil, "", fmt.Errorf("模型 %s 暂无已知指纹特征，无法验真", upstreamModel)
+	}
+
+	usingKey := ""
+	responses := make(map[string]*service.ModelVerifyResponse)
+	for _, probe := range service.BuildModelVerifyProbes(modelName, fingerprint) {
+		result := doChannelRequest(channel, modelName, "/v1/chat/completions", probe.Body)
+		if usingKey == "" {
+			usingKey = result.UsingKey
+		}
+		responses[probe.Name] = &service.ModelVerifyResponse{
+			Body:         result.Response,
+			PromptTokens: result.PromptTokens,
+			Err:          result.Err,
+		}
+	}
+	report := service.EvaluateModelVerification(modelKey, fingerprint, responses)
+
+	details, _ := common.Marshal(report.Checks)
+	verification := &model.ModelVerification{
+		CreatedAt:         common.GetTimestamp(),
+		ChannelId:         channel.Id,
+		ModelName:         modelName,
+		UpstreamModel:     upstreamModel,
+		Score:             report.Score,
+		ResponseModel:     report.ResponseModel,
+		SystemFingerprint: report.SystemFingerprint,
+		Details:           string(details),
+	}
+	switch {
+	case report.AllFailed:
+		verification.Status = model.ModelVerificationStatusError
+	case report.Score < threshold:
+		verification.Status = model.ModelVerificationStatusFailed
+	default:
+		verification.Status = model.ModelVerificationStatusPassed
+	}
+	if err := verification.Insert(); err != nil {
+		return nil, "", err
+	}
+	return verification, usingKey, nil
+}
+
+// handleModelVerifyFailure 按设置对未通过验真的渠道发送告警或自动禁用
+func handleModelVerifyFailure(channel *model.Channel, verification *model.ModelVerification, usingKey string) {
+	setting := operation_setting.GetMonitorSetting()
+	reason := fmt.Sprintf("模型 %s 验真未通过，得分 %.2f（阈值 %.2f），响应模型 %q",
+		verification.ModelName, verification.Score, setting.ModelVerifyThreshold, verification.ResponseModel)
+	if setting.ModelVerifyNotify {
+		service.NotifyRootUser(dto.NotifyTypeModelVerify,
+			fmt.Sprintf("通道「%s」（#%d）模型验真未通过", channel.Name, channel.Id), reason)
+	}
+	if setting.ModelVerifyAutoDisable && channel.Status == common.ChannelStatusEnabled {
+		service.DisableChannel(*types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, usingKey, channel.GetAutoBan()), reason)
+	}
+}
+
+func verifyAllChannelModels() error {
+	modelVerifyLock.Lock()
+	if modelVerifyRunning {
+		modelVerifyLock.Unlock()
+		return errors.New("模型验真已在运行中")
+	}
+	modelVerifyRunning = true
+	modelVerifyLock.Unlock()
+	channels, err := model.GetAllChannels(0, 0, true, false)
+	if err != nil {
+		modelVerifyLock.Lock()
+		modelVerifyRunning = false
+		modelVerifyLock.Unlock()
+		return err
+	}
+	gopool.Go(func() {
+		defer func() {
+			modelVerifyLock.Lock()
+			modelVerifyRunning = false
+			modelVerifyLock.Unlock()
+		}()
+
+		threshold := operation_setting.GetMonitorSetting().ModelVerifyThreshold
+		for _, channel := range channels {
+			if channel.Status != common.ChannelStatusEnabled {
+				continue
+			}
+			for _, modelName := range channel.GetModels() {
+				modelName = strings.TrimSpace(modelName)
+				if _, fingerprint := service.GetModelFingerprint(resolveUpstreamModel(channel, modelName)); fingerprint == nil {
+					continue
+				}
+				verification, usingKey, err := verifyChannelModel(channel, modelName, threshold)
+				if err != nil {
+					common.SysError(fmt.Sprintf("failed to verify model %s of channel #%d: %s", modelName, channel.Id, err.Error()))
+					continue
+				}
+				if verification.Status == model.ModelVerificationStatusFailed {
+					handleModelVerifyFailure(channel, verification, usingKey)
+				}
+				time.Sleep(common.RequestInterval)
+			}
+		}
+
+		retentionDays := operation_setting.GetMonitorSetting().ModelVerifyRetentionDays
+		if retentionDays > 0 {
+			target := time.Now().AddDate(0, 0, -retentionDays).Unix()
+			if count, err := model.DeleteOldModelVerifications(context.Background(), target, 100); err != nil {
+				common.SysError("failed to delete old model verifications: " + err.Error())
+			} else if count > 0 {
+				common.SysLog(fmt.Sprintf("deleted %d old model verifications", count))
+			}
+		}
+	})
+	return nil
+}
+
+// automaticallyVerifyChannelModels 按设置的间隔定时对所有渠道执行模型验真
+func automaticallyVerifyChannelModels() {
+	for {
+		if !operation_setting.GetMonitorSetting().ModelVerifyEnabled {
+			time.Sleep(1 * time.Minute)
+			continue
+		}
+		for {
+			frequency := operation_setting.GetMonitorSetting().ModelVerifyMinutes
+			time.Sleep(time.Duration(int(math.Round(frequency))) * time.Minute)
+			common.SysLog(fmt.Sprintf("automatically verifying channel models with interval %f minutes", frequency))
+			_ = verifyAllChannelModels()
+			if !operation_setting.GetMonitorSetting().ModelVerifyEnabled {
+				break
+			}
+		}
+	}
+}
+
+// VerifyChannelModel 立即对单个渠道执行模型验真，未指定模型时验证渠道下所有已知模型
+func VerifyChannelModel(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	modelNames := channel.GetModels()
+	if modelName := strings.TrimSpace(c.Query("model")); modelName != "" {
+		modelNames = []string{modelName}
+	}
+	threshold := operation_setting.GetMonitorSetting().ModelVerifyThreshold
+	verifications := make([]*model.ModelVerification, 0)
+	for _, modelName := range modelNames {
+		modelName = strings.TrimSpace(modelName)
+		if len(modelNames) > 1 {
+			if _, fingerprint := service.GetModelFingerprint(resolveUpstreamModel(channel, modelName)); fingerprint == nil {
+				continue
+			}
+		}
+		verification, _, err := verifyChannelModel(channel, modelName, threshold)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		verifications = append(verifications, verification)
+	}
+	if len(verifications) == 0 {
+		common.ApiErrorMsg(c, "该渠道没有可验真的模型")
+		return
+	}
+	common.ApiSuccess(c, verifications)
+}
+
+// VerifyAllChannelModels 在后台对所有启用的渠道执行模型验真
+func VerifyAllChannelModels(c *gin.Context) {
+	if err := verifyAllChannelModels(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetModelVerifications 分页查询模型验真历史
+func GetModelVerifications(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	verifications, total, err := model.GetModelVerifications(channelId, c.Query("model_name"), c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(verifications)
+	common.ApiSuccess(c, pageInfo)
+}
@@ -164,6 +164,24 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "ModelPricingTiers":
+		err = ratio_setting.CheckModelPricingTiers(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "阶梯计价设置失败: " + err.Error(),
+			})
+			return
+		}
+	case "PricingTimeRules":
+		err = ratio_setting.CheckPricingTimeRules(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "时段定价设置失败: " + err.Error(),
+			})
+			return
+		}
 	case "ModelRequestRateLimitGroup":
 		err = setting.CheckModelRequestRateLimitGroup(option.Value.(string))
 		if err != nil {
@@ -215,6 +233,11 @@ func UpdateOption(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if common.StringsContains(model.PricingOptionKeys, option.Key) {
+		if _, err := model.RecordPricingVersion("修改 "+option.Key, c.GetInt("id")); err != nil {
+			common.SysError("failed to record pricing version: " + err.Error())
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -0,0 +1,439 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type OrganizationFundRequest struct {
+	Quota int `json:"quota"`
+}
+
+type OrganizationMemberRequest struct {
+	UserId      int    `json:"user_id"`
+	Role        string `json:"role"`
+	SpendingCap int    `json:"spending_cap"`
+	ResetUsed   bool   `json:"reset_used"`
+}
+
+type OrganizationInvitationRequest struct {
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	ValidDays int    `json:"valid_days"`
+}
+
+type OrganizationAcceptRequest struct {
+	Code string `json:"code"`
+}
+
+type OrganizationAdminRequest struct {
+	Quota  int `json:"quota"`
+	Status int `json:"status"`
+}
+
+const organizationInvitationMaxDays = 30
+
+func validateOrganizationName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("团队名称不能为空")
+	}
+	if len([]rune(name)) > 64 {
+		return "", errors.New("团队名称过长")
+	}
+	return name, nil
+}
+
+// getOrganizationMember 校验当前用户是路径中团队的成员，check 不为空时还需满足对应权限
+func getOrganizationMember(c *gin.Context, check func(*model.OrganizationMember) bool) (*model.OrganizationMember, bool) {
+	orgId, _ := strconv.Atoi(c.Param("id"))
+	member, err := model.GetOrganizationMember(orgId, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	if check != nil && !check(member) {
+		common.ApiErrorMsg(c, "当前团队角色无权进行此操作")
+		return nil, false
+	}
+	return member, true
+}
+
+// checkOrganizationTokenAccess 校验用户能否创建或启用团队令牌
+func checkOrganizationTokenAccess(orgId int, userId int) error {
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		return err
+	}
+	if org.Status != model.OrganizationStatusEnabled {
+		return errors.New("团队已被禁用")
+	}
+	member, err := model.GetOrganizationMember(orgId, userId)
+	if err != nil {
+		return err
+	}
+	if !member.CanUseTokens() {
+		return errors.New("当前团队角色不能使用团队令牌")
+	}
+	return nil
+}
+
+func GetSelfOrganizations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, orgs)
+}
+
+func CreateOrganization(c *gin.Context) {
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	name, err := validateOrganizationName(req.Name)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org, err := model.CreateOrganization(name, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+// GetOrganization 团队详情和成员列表
+func GetOrganization(c *gin.Context) {
+	member, ok := getOrganizationMember(c, nil)
+	if !ok {
+		return
+	}
+	org, err := model.GetOrganizationById(member.OrganizationId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	members, err := model.GetOrganizationMembers(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org.Role = member.Role
+	org.MemberCount = int64(len(members))
+	common.ApiSuccess(c, gin.H{
+		"organization": org,
+		"members":      members,
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	member, ok := getOrganizationMember(c, (*model.OrganizationMember).CanManageMembers)
+	if !ok {
+		return
+	}
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	name, err := validateOrganizationName(req.Name)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.UpdateOrganizationName(member.OrganizationId, name); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// DeleteOrganization 解散团队，只有 owner 可以操作
+func DeleteOrganization(c *gin.Context) {
+	member, ok := getOrganizationMember(c, func(m *model.OrganizationMember) bool {
+		return m.Role == model.OrganizationRoleOwner
+	})
+	if !ok {
+		return
+	}
+	if err := model.DeleteOrganization(member.OrganizationId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// FundOrganization 从个人额度划转到团队额度池
+func FundOrganization(c *gin.Context) {
+	member, ok := getOrganizationMember(c, (*model.OrganizationMember).CanManageBilling)
+	if !ok {
+		return
+	}
+	var req OrganizationFundRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Quota <= 0 {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	if err := model.FundOrganization(member.OrganizationId, member.UserId, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(member.UserId, model.LogTypeManage, fmt.Sprintf("划转 %s 到团队 #%d 额度池", logger.LogQuota(req.Quota), member.OrganizationId))
+	common.ApiSuccess(c, nil)
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	operator, ok := getOrganizationMember(c, (*model.OrganizationMember).CanManageMembers)
+	if !ok {
+		return
+	}
+	var req OrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserId == 0 || req.SpendingCap < 0 {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	if !model.IsValidOrganizationRole(req.Role) {
+		common.ApiErrorMsg(c, "无效的团队角色")
+		return
+	}
+	target, err := model.GetOrganizationMember(operator.OrganizationId, req.UserId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 只有 owner 可以授予或变更 owner 角色
+	if operator.Role != model.OrganizationRoleOwner && (req.Role == model.OrganizationRoleOwner || target.Role == model.OrganizationRoleOwner) {
+		common.ApiErrorMsg(c, "只有 owner 可以变更 owner 角色")
+		return
+	}
+	if err = model.UpdateOrganizationMember(operator.OrganizationId, req.UserId, req.Role, req.SpendingCap, req.ResetUsed); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func RemoveOrganizationMember(c *gin.Context) {
+	operator, ok := getOrganizationMember(c, (*model.OrganizationMember).CanManageMembers)
+	if !ok {
+		return
+	}
+	userId, _ := strconv.Atoi(c.Param("user_id"))
+	target, err := model.GetOrganizationMember(operator.OrganizationId, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if target.Role == model.OrganizationRoleOwner && operator.Role != model.OrganizationRoleOwner {
+		common.ApiErrorMsg(c, "只有 owner 可以移除 owner")
+		return
+	}
+	if err = model.RemoveOrganizationMember(operator.OrganizationId, userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// LeaveOrganization 退出团队，自己创建的团队令牌会被禁用
+func LeaveOrganization(c *gin.Context) {
+	member, ok := getOrganizationMember(c, nil)
+	if !ok {
+		return
+	}
+	if err := model.RemoveOrganizationMember(member.OrganizationId, member.UserId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetOrganizationInvitations(c *gin.Context) {
+	member, ok := getOrganizationMember(c, (*model.OrganizationMember).CanManageMembers)
+	if !ok {
+		return
+	}
+	invitations, err := model.GetOrganizationInvitations(member.OrganizationId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, invitations)
+}
+
+func CreateOrganizationInvitation(c *gin.Context) {
+	member, ok := getOrganizationMember(c, (*model.OrganizationMember).CanManageMembers)
+	if !ok {
+		return
+	}
+	var req OrganizationInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	if req.Role == "" {
+		req.Role = model.OrganizationRoleMember
+	}
+	if !model.IsValidOrganizationRole(req.Role) || req.Role == model.OrganizationRoleOwner {
+		common.ApiErrorMsg(c, "无效的团队角色")
+		return
+	}
+	if req.ValidDays <= 0 || req.ValidDays > organizationInvitationMaxDays {
+		req.ValidDays = 7
+	}
+	invitation, err := model.CreateOrganizationInvitation(member.OrganizationId, member.UserId, req.Email, req.Role, req.ValidDays)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, invitation)
+}
+
+func RevokeOrganizationInvitation(c *gin.Context) {
+	member, ok := getOrganizationMember(c, (*model.OrganizationMember).CanManageMembers)
+	if !ok {
+		return
+	}
+	invitationId, _ := strconv.Atoi(c.Param("invitation_id"))
+	if err := model.RevokeOrganizationInvitation(member.OrganizationId, invitationId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// AcceptOrganizationInvitation 凭邀请码加入团队
+func AcceptOrganizationInvitation(c *gin.Context) {
+	var req OrganizationAcceptRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org, err := model.AcceptOrganizationInvitation(strings.TrimSpace(req.Code), user.Id, user.Email)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+// GetOrganizationTokens 团队令牌，管理员可以查看全部，其他成员只能查看自己创建的
+func GetOrganizationTokens(c *gin.Context) {
+	member, ok := getOrganizationMember(c, nil)
+	if !ok {
+		return
+	}
+	userId := member.UserId
+	if member.CanManageMembers() {
+		userId = 0
+	}
+	tokens, err := model.GetOrganizationTokens(member.OrganizationId, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	for _, token := range tokens {
+		token.Clean()
+	}
+	common.ApiSuccess(c, tokens)
+}
+
+// GetOrganizationUsage 团队用量报表，默认统计最近 30 天。owner、admin 和 billing 可以查看全部成员，其他成员只能查看自己
+func GetOrganizationUsage(c *gin.Context) {
+	member, ok := getOrganizationMember(c, nil)
+	if !ok {
+		return
+	}
+	end, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	start, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	if start == 0 {
+		start = end - 30*86400
+	}
+	userId := member.UserId
+	if member.CanManageBilling() {
+		userId, _ = strconv.Atoi(c.Query("user_id"))
+	}
+	usages, err := model.GetOrganizationUsage(member.OrganizationId, userId, start, end)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"start_timestamp": start,
+		"end_timestamp":   end,
+		"items":           usages,
+	})
+}
+
+func GetAllOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(c.Query("keyword"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// AdminAdjustOrganizationQuota 管理员调整团队额度池，quota 为增减量
+func AdminAdjustOrganizationQuota(c *gin.Context) {
+	orgId, _ := strconv.Atoi(c.Param("id"))
+	var req OrganizationAdminRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Quota == 0 {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.AdjustOrganizationQuota(org.Id, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(org.OwnerId, model.LogTypeManage, fmt.Sprintf("管理员 %s 将团队 %s (ID: %d) 的额度调整 %s", c.GetString("username"), org.Name, org.Id, logger.LogQuota(req.Quota)))
+	common.ApiSuccess(c, nil)
+}
+
+func AdminUpdateOrganizationStatus(c *gin.Context) {
+	orgId, _ := strconv.Atoi(c.Param("id"))
+	var req OrganizationAdminRequest
+	if err := c.ShouldBindJSON(&req); err != nil ||
+		(req.Status != model.OrganizationStatusEnabled && req.Status != model.OrganizationStatusDisabled) {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	if err := model.UpdateOrganizationStatus(orgId, req.Status); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
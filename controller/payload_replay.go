@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+type payloadReplayRequest struct {
+	ChannelId int    `json:"channel_id"`
+	Model     string `json:"model"`
+}
+
+type PayloadReplayStats struct {
+	ChannelId        int    `json:"channel_id"`
+	ModelName        string `json:"model_name"`
+	StatusCode       int    `json:"status_code"`
+	UseTimeMs        int64  `json:"use_time_ms"`
+	FirstTokenMs     int64  `json:"first_token_ms"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	Output           string `json:"output"`
+	Error            string `json:"error,omitempty"`
+}
+
+type PayloadReplayResult struct {
+	RequestId            string             `json:"request_id"`
+	Original             PayloadReplayStats `json:"original"`
+	Replay               PayloadReplayStats `json:"replay"`
+	UseTimeDiffMs        int64              `json:"use_time_diff_ms"`
+	PromptTokensDiff     int                `json:"prompt_tokens_diff"`
+	CompletionTokensDiff int                `json:"completion_tokens_diff"`
+	OutputIdentical      bool               `json:"output_identical"`
+}
+
+// ReplayPayloadCapture 使用抓取的客户端请求在指定渠道/模型上重放，不计费，并与原始结果对比
+func ReplayPayloadCapture(c *gin.Context) {
+	var req payloadReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		common.ApiError(c, err)
+		return
+	}
+	detail, err := service.GetPayloadCaptureDetail(c.Param("request_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !gjson.Valid(detail.Data.ClientRequest) {
+		common.ApiErrorMsg(c, "原始请求内容不完整，无法重放")
+		return
+	}
+	channelId := req.ChannelId
+	if channelId == 0 {
+		channelId = detail.ChannelId
+	}
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	modelName := strings.TrimSpace(req.Model)
+	if modelName == "" {
+		modelName = detail.ModelName
+	}
+
+	result := PayloadReplayResult{
+		RequestId: detail.RequestId,
+		Original: PayloadReplayStats{
+			ChannelId:    detail.ChannelId,
+			ModelName:    detail.ModelName,
+			StatusCode:   detail.StatusCode,
+			UseTimeMs:    detail.UseTimeMs,
+			FirstTokenMs: detail.FirstTokenMs,
+			Output:       service.ExtractResponseOutput(detail.Data.Response, detail.IsStream),
+		},
+		Replay: replayPayload(channel, modelName, detail),
+	}
+	result.Original.PromptTokens, result.Original.CompletionTokens = service.ExtractResponseUsage(detail.Data.Response)
+	result.UseTimeDiffMs = result.Replay.UseTimeMs - result.Original.UseTimeMs
+	result.PromptTokensDiff = result.Replay.PromptTokens - result.Original.PromptTokens
+	result.CompletionTokensDiff = result.Replay.CompletionTokens - result.Original.CompletionTokens
+	result.OutputIdentical = result.Replay.Error == "" && result.Replay.Output == result.Original.Output
+	common.ApiSuccess(c, result)
+}
+
+// replayPayload 将抓取的客户端请求改写模型后在指定渠道上重放
+func replayPayload(channel *model.Channel, modelName string, detail *service.PayloadCaptureDetail) PayloadReplayStats {
+	stats := PayloadReplayStats{
+		ChannelId: channel.Id,
+		ModelName: modelName,
+	}
+	requestPath := detail.RequestPath
+	if requestPath == "" {
+		requestPath = "/v1/chat/completions"
+	}
+	body, err := sjson.SetBytes([]byte(detail.Data.ClientRequest), "model", modelName)
+	if err != nil {
+		stats.StatusCode = http.StatusBadRequest
+		stats.Error = err.Error()
+		return stats
+	}
+	common.SysLog(fmt.Sprintf("replaying request %s on channel #%d with model %s", detail.RequestId, channel.Id, modelName))
+
+	result := doChannelRequest(channel, modelName, requestPath, body)
+	stats.StatusCode = result.StatusCode
+	stats.UseTimeMs = result.UseTimeMs
+	stats.FirstTokenMs = result.FirstTokenMs
+	stats.PromptTokens = result.PromptTokens
+	stats.CompletionTokens = result.CompletionTokens
+	if result.Err != nil {
+		stats.Error = result.Err.Error()
+		return stats
+	}
+	stats.Output = service.ExtractResponseOutput(result.Response, result.IsStream)
+	return stats
+}
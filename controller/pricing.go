@@ -1,6 +1,9 @@
 package controller
 
 import (
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
@@ -8,6 +11,12 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// pricingTimeRuleInfo 时段定价规则及其当前是否生效
+type pricingTimeRuleInfo struct {
+	*ratio_setting.PricingTimeRule
+	Active bool `json:"active"`
+}
+
 func GetPricing(c *gin.Context) {
 	pricing := model.GetPricing()
 	userId, exists := c.Get("id")
@@ -38,6 +47,12 @@ func GetPricing(c *gin.Context) {
 		}
 	}
 
+	now := time.Now()
+	timeRules := make([]pricingTimeRuleInfo, 0)
+	for _, rule := range ratio_setting.GetPricingTimeRules() {
+		timeRules = append(timeRules, pricingTimeRuleInfo{PricingTimeRule: rule, Active: rule.ActiveAt(now)})
+	}
+
 	c.JSON(200, gin.H{
 		"success":            true,
 		"data":               pricing,
@@ -46,6 +61,7 @@ func GetPricing(c *gin.Context) {
 		"usable_group":       usableGroup,
 		"supported_endpoint": model.GetSupportedEndpointMap(),
 		"auto_groups":        service.GetUserAutoGroup(group),
+		"pricing_time_rules": timeRules,
 	})
 }
 
@@ -67,6 +83,9 @@ func ResetModelRatio(c *gin.Context) {
 		})
 		return
 	}
+	if _, err := model.RecordPricingVersion("重置模型倍率", c.GetInt("id")); err != nil {
+		common.SysError("failed to record pricing version: " + err.Error())
+	}
 	c.JSON(200, gin.H{
 		"success": true,
 		"message": "重置模型倍率成功",
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPricingVersions 分页查询定价版本
+func GetPricingVersions(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	versions, total, err := model.GetPricingVersions(c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(versions)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetPricingVersion 获取定价版本详情与变更内容：已生效的版本对比上一个生效的版本，计划中的版本对比当前配置
+func GetPricingVersion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	version, err := model.GetPricingVersionById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	changes := make([]*model.PricingVersionChange, 0)
+	if version.Status == model.PricingVersionStatusApplied {
+		previous, err := model.GetPreviousPricingVersion(version)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if previous != nil {
+			changes = model.DiffPricingContent(previous.GetContent(), version.GetContent())
+		}
+	} else {
+		preview, err := model.PreviewPricingVersion(version)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		changes = model.DiffPricingContent(model.SnapshotPricingOptions(), preview)
+	}
+	common.ApiSuccess(c, gin.H{
+		"version": version,
+		"changes": changes,
+	})
+}
+
+// GetPricingAt 查询指定时间生效的定价，指定 model 时只返回该模型的各项配置
+func GetPricingAt(c *gin.Context) {
+	timestamp, _ := strconv.ParseInt(c.Query("timestamp"), 10, 64)
+	if timestamp == 0 {
+		timestamp = common.GetTimestamp()
+	}
+	version, err := model.GetPricingVersionAt(timestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if version == nil {
+		common.ApiErrorMsg(c, "该时间点没有定价版本记录")
+		return
+	}
+	modelName := strings.TrimSpace(c.Query("model"))
+	values := make(map[string]map[string]float64)
+	for key, value := range version.GetContent() {
+		parsed, err := model.ParsePricingOption(value)
+		if err != nil {
+			continue
+		}
+		if modelName != "" && key != "GroupRatio" {
+			filtered := make(map[string]float64)
+			if v, ok := parsed[modelName]; ok {
+				filtered[modelName] = v
+			}
+			parsed = filtered
+		}
+		values[key] = parsed
+	}
+	version.Content = ""
+	common.ApiSuccess(c, gin.H{
+		"version": version,
+		"values":  values,
+	})
+}
+
+type pricingVersionRequest struct {
+	Name        string            `json:"name"`
+	EffectiveAt int64             `json:"effective_at"`
+	Content     map[string]string `json:"content"`
+}
+
+func validatePricingContent(content map[string]string) error {
+	if len(content) == 0 {
+		return errors.New("至少需要修改一项计价配置")
+	}
+	for key, value := range content {
+		if !common.StringsContains(model.PricingOptionKeys, key) {
+			return fmt.Errorf("不支持的计价配置：%s", key)
+		}
+		if _, err := model.ParsePricingOption(value); err != nil {
+			return fmt.Errorf("%s 不是合法的 JSON：%s", key, err.Error())
+		}
+	}
+	return nil
+}
+
+// createAndMaybeApplyPricingVersion 创建计划中的版本，生效时间已到时立即应用
+func createAndMaybeApplyPricingVersion(version *model.PricingVersion, content map[string]string) error {
+	if err := model.CreatePricingVersion(version, content); err != nil {
+		return err
+	}
+	if version.EffectiveAt <= common.GetTimestamp() {
+		return model.ApplyPricingVersion(version)
+	}
+	return nil
+}
+
+// CreatePricingVersion 计划调价，content 只需包含要修改的配置项，生效时间为空或已过时立即生效
+func CreatePricingVersion(c *gin.Context) {
+	req := pricingVersionRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validatePricingContent(req.Content); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.EffectiveAt == 0 {
+		req.EffectiveAt = common.GetTimestamp()
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "计划调价"
+	}
+	version := &model.PricingVersion{
+		Name:        name,
+		EffectiveAt: req.EffectiveAt,
+		CreatedBy:   c.GetInt("id"),
+	}
+	if err := createAndMaybeApplyPricingVersion(version, req.Content); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	version.Content = ""
+	common.ApiSuccess(c, version)
+}
+
+// RestorePricingVersion 以历史版本的配置创建新版本并立即生效
+func RestorePricingVersion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	origin, err := model.GetPricingVersionById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if origin.Status != model.PricingVersionStatusApplied {
+		common.ApiErrorMsg(c, "只能恢复已生效过的定价版本")
+		return
+	}
+	version := &model.PricingVersion{
+		Name:        fmt.Sprintf("恢复至版本 #%d", origin.Id),
+		EffectiveAt: common.GetTimestamp(),
+		CreatedBy:   c.GetInt("id"),
+	}
+	if err := createAndMaybeApplyPricingVersion(version, origin.GetContent()); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	version.Content = ""
+	common.ApiSuccess(c, version)
+}
+
+// CancelPricingVersion 取消尚未生效的定价版本
+func CancelPricingVersion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.CancelPricingVersion(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+func GetQuotaLedgerEntries(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	accountId, _ := strconv.Atoi(c.Query("account_id"))
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	entries, total, err := model.GetQuotaLedgerEntries(model.QuotaLedgerQuery{
+		AccountType:    c.Query("account_type"),
+		AccountId:      accountId,
+		UserId:         userId,
+		Source:         c.Query("source"),
+		Reference:      c.Query("reference"),
+		TransactionId:  c.Query("transaction_id"),
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+	}, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(entries)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetQuotaLedgerBalance 查询账户在指定时刻的流水余额，timestamp 为空表示当前
+func GetQuotaLedgerBalance(c *gin.Context) {
+	accountType := c.Query("account_type")
+	accountId, _ := strconv.Atoi(c.Query("account_id"))
+	timestamp, _ := strconv.ParseInt(c.Query("timestamp"), 10, 64)
+	balance, err := model.GetQuotaLedgerBalance(accountType, accountId, timestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"account_type": accountType,
+		"account_id":   accountId,
+		"timestamp":    timestamp,
+		"balance":      balance,
+	})
+}
+
+func GetQuotaReconcileReports(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	reports, total, err := model.GetQuotaReconcileReports(pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(reports)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// RunQuotaReconcile 立即在后台执行一次额度对账
+func RunQuotaReconcile(c *gin.Context) {
+	gopool.Go(func() {
+		report, err := service.ReconcileQuotaLedger()
+		if err != nil {
+			if !errors.Is(err, service.ErrQuotaReconcileRunning) {
+				common.SysError("failed to reconcile quota ledger: " + err.Error())
+			}
+			return
+		}
+		common.SysLog(fmt.Sprintf("manual quota reconciliation finished, %d drifts", report.DriftCount))
+	})
+	common.ApiSuccess(c, nil)
+}
@@ -63,10 +63,41 @@ func FetchUpstreamRatios(c *gin.Context) {
 		req.Timeout = defaultTimeoutSeconds
 	}
 
+	upstreams, err := resolveRatioUpstreams(req.Upstreams, req.ChannelIDs)
+	if err != nil {
+		logger.LogError(c.Request.Context(), "failed to query channels: "+err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "查询渠道失败"})
+		return
+	}
+
+	if len(upstreams) == 0 {
+		c.JSON(http.StatusOK, gin.H{"success": false, "message": "无有效上游渠道"})
+		return
+	}
+
+	results := fetchUpstreamRatioData(c.Request.Context(), upstreams, req.Timeout)
+
+	localData := ratio_setting.GetExposedData()
+
+	testResults, successfulChannels := splitUpstreamResults(results)
+
+	differences := buildDifferences(localData, successfulChannels)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"differences":  differences,
+			"test_results": testResults,
+		},
+	})
+}
+
+// resolveRatioUpstreams 根据手动填写的上游或渠道 ID 生成待拉取的上游列表
+func resolveRatioUpstreams(requestUpstreams []dto.UpstreamDTO, channelIDs []int64) ([]dto.UpstreamDTO, error) {
 	var upstreams []dto.UpstreamDTO
 
-	if len(req.Upstreams) > 0 {
-		for _, u := range req.Upstreams {
+	if len(requestUpstreams) > 0 {
+		for _, u := range requestUpstreams {
 			if strings.HasPrefix(u.BaseURL, "http") {
 				if u.Endpoint == "" {
 					u.Endpoint = defaultEndpoint
@@ -75,16 +106,14 @@ func FetchUpstreamRatios(c *gin.Context) {
 				upstreams = append(upstreams, u)
 			}
 		}
-	} else if len(req.ChannelIDs) > 0 {
-		intIds := make([]int, 0, len(req.ChannelIDs))
-		for _, id64 := range req.ChannelIDs {
+	} else if len(channelIDs) > 0 {
+		intIds := make([]int, 0, len(channelIDs))
+		for _, id64 := range channelIDs {
 			intIds = append(intIds, int(id64))
 		}
 		dbChannels, err := model.GetChannelsByIds(intIds)
 		if err != nil {
-			logger.LogError(c.Request.Context(), "failed to query channels: "+err.Error())
-			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "查询渠道失败"})
-			return
+			return nil, err
 		}
 		for _, ch := range dbChannels {
 			if base := ch.GetBaseURL(); strings.HasPrefix(base, "http") {
@@ -98,13 +127,16 @@ func FetchUpstreamRatios(c *gin.Context) {
 		}
 	}
 
-	if len(upstreams) == 0 {
-		c.JSON(http.StatusOK, gin.H{"success": false, "message": "无有效上游渠道"})
-		return
-	}
+	return upstreams, nil
+}
 
+// fetchUpstreamRatioData 并发拉取各上游的倍率配置，结果顺序与 upstreams 一致
+func fetchUpstreamRatioData(parentCtx context.Context, upstreams []dto.UpstreamDTO, timeout int) []upstreamResult {
+	if timeout <= 0 {
+		timeout = defaultTimeoutSeconds
+	}
 	var wg sync.WaitGroup
-	ch := make(chan upstreamResult, len(upstreams))
+	results := make([]upstreamResult, len(upstreams))
 
 	sem := make(chan struct{}, maxConcurrentFetches)
 
@@ -126,9 +158,9 @@ func FetchUpstreamRatios(c *gin.Context) {
 	}
 	client := &http.Client{Transport: transport}
 
-	for _, chn := range upstreams {
+	for i, chn := range upstreams {
 		wg.Add(1)
-		go func(chItem dto.UpstreamDTO) {
+		go func(i int, chItem dto.UpstreamDTO) {
 			defer wg.Done()
 
 			sem <- struct{}{}
@@ -152,13 +184,13 @@ func FetchUpstreamRatios(c *gin.Context) {
 				uniqueName = fmt.Sprintf("%s(%d)", chItem.Name, chItem.ID)
 			}
 
-			ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(req.Timeout)*time.Second)
+			ctx, cancel := context.WithTimeout(parentCtx, time.Duration(timeout)*time.Second)
 			defer cancel()
 
 			httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
 			if err != nil {
-				logger.LogWarn(c.Request.Context(), "build request failed: "+err.Error())
-				ch <- upstreamResult{Name: uniqueName, Err: err.Error()}
+				logger.LogWarn(parentCtx, "build request failed: "+err.Error())
+				results[i] = upstreamResult{Name: uniqueName, Err: err.Error()}
 				return
 			}
 
@@ -173,20 +205,20 @@ func FetchUpstreamRatios(c *gin.Context) {
 				time.Sleep(time.Duration(200*(1<<attempt)) * time.Millisecond)
 			}
 			if lastErr != nil {
-				logger.LogWarn(c.Request.Context(), "http error on "+chItem.Name+": "+lastErr.Error())
-				ch <- upstreamResult{Name: uniqueName, Err: lastErr.Error()}
+				logger.LogWarn(parentCtx, "http error on "+chItem.Name+": "+lastErr.Error())
+				results[i] = upstreamResult{Name: uniqueName, Err: lastErr.Error()}
 				return
 			}
 			defer resp.Body.Close()
 			if resp.StatusCode != http.StatusOK {
-				logger.LogWarn(c.Request.Context(), "non-200 from "+chItem.Name+": "+resp.Status)
-				ch <- upstreamResult{Name: uniqueName, Err: resp.Status}
+				logger.LogWarn(parentCtx, "non-200 from "+chItem.Name+": "+resp.Status)
+				results[i] = upstreamResult{Name: uniqueName, Err: resp.Status}
 				return
 			}
 
 			// Content-Type 和响应体大小校验
 			if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(strings.ToLower(ct), "application/json") {
-				logger.LogWarn(c.Request.Context(), "unexpected content-type from "+chItem.Name+": "+ct)
+				logger.LogWarn(parentCtx, "unexpected content-type from "+chItem.Name+": "+ct)
 			}
 			limited := io.LimitReader(resp.Body, maxRatioConfigBytes)
 			// 兼容两种上游接口格式：
@@ -199,13 +231,13 @@ func FetchUpstreamRatios(c *gin.Context) {
 			}
 
 			if err := json.NewDecoder(limited).Decode(&body); err != nil {
-				logger.LogWarn(c.Request.Context(), "json decode failed from "+chItem.Name+": "+err.Error())
-				ch <- upstreamResult{Name: uniqueName, Err: err.Error()}
+				logger.LogWarn(parentCtx, "json decode failed from "+chItem.Name+": "+err.Error())
+				results[i] = upstreamResult{Name: uniqueName, Err: err.Error()}
 				return
 			}
 
 			if !body.Success {
-				ch <- upstreamResult{Name: uniqueName, Err: body.Message}
+				results[i] = upstreamResult{Name: uniqueName, Err: body.Message}
 				return
 			}
 
@@ -223,7 +255,7 @@ func FetchUpstreamRatios(c *gin.Context) {
 					}
 				}
 				if isType1 {
-					ch <- upstreamResult{Name: uniqueName, Data: type1Data}
+					results[i] = upstreamResult{Name: uniqueName, Data: type1Data}
 					return
 				}
 			}
@@ -237,8 +269,8 @@ func FetchUpstreamRatios(c *gin.Context) {
 				CompletionRatio float64 `json:"completion_ratio"`
 			}
 			if err := json.Unmarshal(body.Data, &pricingItems); err != nil {
-				logger.LogWarn(c.Request.Context(), "unrecognized data format from "+chItem.Name+": "+err.Error())
-				ch <- upstreamResult{Name: uniqueName, Err: "无法解析上游返回数据"}
+				logger.LogWarn(parentCtx, "unrecognized data format from "+chItem.Name+": "+err.Error())
+				results[i] = upstreamResult{Name: uniqueName, Err: "无法解析上游返回数据"}
 				return
 			}
 
@@ -282,22 +314,26 @@ func FetchUpstreamRatios(c *gin.Context) {
 				converted["model_price"] = priceAny
 			}
 
-			ch <- upstreamResult{Name: uniqueName, Data: converted}
-		}(chn)
+			results[i] = upstreamResult{Name: uniqueName, Data: converted}
+		}(i, chn)
 	}
 
 	wg.Wait()
-	close(ch)
-
-	localData := ratio_setting.GetExposedData()
+	return results
+}
 
+// splitUpstreamResults 将拉取结果拆分为连通性结果与成功拉取的数据
+func splitUpstreamResults(results []upstreamResult) ([]dto.TestResult, []struct {
+	name string
+	data map[string]any
+}) {
 	var testResults []dto.TestResult
 	var successfulChannels []struct {
 		name string
 		data map[string]any
 	}
 
-	for r := range ch {
+	for _, r := range results {
 		if r.Err != "" {
 			testResults = append(testResults, dto.TestResult{
 				Name:   r.Name,
@@ -315,16 +351,7 @@ func FetchUpstreamRatios(c *gin.Context) {
 			}{name: r.Name, data: r.Data})
 		}
 	}
-
-	differences := buildDifferences(localData, successfulChannels)
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data": gin.H{
-			"differences":  differences,
-			"test_results": testResults,
-		},
-	})
+	return testResults, successfulChannels
 }
 
 func buildDifferences(localData map[string]any, successfulChannels []struct {
@@ -0,0 +1,486 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+var ratioOptionKeys = map[string]string{
+	"model_ratio":      "ModelRatio",
+	"completion_ratio": "CompletionRatio",
+	"cache_ratio":      "CacheRatio",
+	"model_price":      "ModelPrice",
+}
+
+var ratioSyncLock sync.Mutex
+var ratioSyncRunning bool
+
+// ratioApplyLock 保证同一时间只有一处在修改倍率配置
+var ratioApplyLock sync.Mutex
+
+func ratioChangeKey(item *model.RatioChangeItem) string {
+	return item.Model + "|" + item.RatioType
+}
+
+// buildRatioChangeItems 从差异中为每个模型的每种倍率选出建议值：按上游顺序取第一个给出可信数值的上游，
+// 同一模型同时有模型价格与倍率变更时以模型价格为准
+func buildRatioChangeItems(differences map[string]map[string]dto.DifferenceItem, upstreamNames []string, maxChangePercent float64) []*model.RatioChangeItem {
+	items := make([]*model.RatioChangeItem, 0)
+	for modelName, ratioMap := range differences {
+		_, hasPrice := ratioMap["model_price"]
+		for ratioType, diff := range ratioMap {
+			if hasPrice && ratioType != "model_price" {
+				continue
+			}
+			var proposed *float64
+			var upstream string
+			for _, name := range upstreamNames {
+				if value, ok := diff.Upstreams[name].(float64); ok && diff.Confidence[name] {
+					proposed = &value
+					upstream = name
+					break
+				}
+			}
+			if proposed == nil {
+				continue
+			}
+			item := &model.RatioChangeItem{
+				Model:     modelName,
+				RatioType: ratioType,
+				Proposed:  *proposed,
+				Upstream:  upstream,
+			}
+			if current, ok := diff.Current.(float64); ok {
+				item.Current = &current
+				if current != 0 {
+					item.ChangePercent = math.Round(math.Abs(*proposed-current)/current*10000) / 100
+				} else {
+					item.ChangePercent = 100
+				}
+				item.ExceedsLimit = maxChangePercent > 0 && item.ChangePercent > maxChangePercent
+			}
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Model != items[j].Model {
+			return items[i].Model < items[j].Model
+		}
+		return items[i].RatioType < items[j].RatioType
+	})
+	return items
+}
+
+// applyRatioChanges 应用倍率变更并写入审计日志。与手动同步一致，设置模型价格时移除该模型的各项倍率，
+// 设置倍率时移除该模型的价格
+func applyRatioChanges(items []*model.RatioChangeItem, changeSetId int, source string, operatorId int) error {
+	if len(items) == 0 {
+		return nil
+	}
+	ratioApplyLock.Lock()
+	defer ratioApplyLock.Unlock()
+
+	ratioMaps := map[string]map[string]float64{
+		"model_ratio":      ratio_setting.GetModelRatioCopy(),
+		"completion_ratio": ratio_setting.GetCompletionRatioCopy(),
+		"cache_ratio":      ratio_setting.GetCacheRatioCopy(),
+		"model_price":      ratio_setting.GetModelPriceCopy(),
+	}
+	changedTypes := make(map[string]bool)
+	logs := make([]*model.RatioChangeLog, 0)
+	setValue := func(ratioType string, modelName string, value *float64, upstream string) {
+		ratios := ratioMaps[ratioType]
+		var oldValue *float64
+		if v, ok := ratios[modelName]; ok {
+			oldValue = &v
+		}
+		if value == nil {
+			if oldValue == nil {
+				return
+			}
+			delete(ratios, modelName)
+		} else {
+			ratios[modelName] = *value
+		}
+		changedTypes[ratioType] = true
+		logs = append(logs, &model.RatioChangeLog{
+			ChangeSetId: changeSetId,
+			ModelName:   modelName,
+			RatioType:   ratioType,
+			OldValue:    oldValue,
+			NewValue:    value,
+			Source:      source,
+			Upstream:    upstream,
+			OperatorId:  operatorId,
+		})
+	}
+	for _, item := range items {
+		proposed := item.Proposed
+		setValue(item.RatioType, item.Model, &proposed, item.Upstream)
+		if item.RatioType == "model_price" {
+			for _, ratioType := range []string{"model_ratio", "completion_ratio", "cache_ratio"} {
+				setValue(ratioType, item.Model, nil, item.Upstream)
+			}
+		} else {
+			setValue("model_price", item.Model, nil, item.Upstream)
+		}
+		item.Applied = true
+	}
+	for _, ratioType := range ratioTypes {
+		if !changedTypes[ratioType] {
+			continue
+		}
+		data, err := common.Marshal(ratioMaps[ratioType])
+		if err != nil {
+			return err
+		}
+		if err := model.UpdateOption(ratioOptionKeys[ratioType], string(data)); err != nil {
+			return err
+		}
+	}
+	if _, err := model.RecordPricingVersion(fmt.Sprintf("上游倍率同步 #%d", changeSetId), operatorId); err != nil {
+		common.SysError("failed to record pricing version: " + err.Error())
+	}
+	return model.InsertRatioChangeLogs(logs)
+}
+
+// isRatioChangeAutoApplicable 变动未超过限制，且为新模型（开启自动添加时）或属于自动应用的供应商
+func isRatioChangeAutoApplicable(item *model.RatioChangeItem, vendorName string, syncSetting *ratio_setting.RatioSyncSetting) bool {
+	if item.ExceedsLimit {
+		return false
+	}
+	if item.Current == nil && syncSetting.AutoApplyNewModels {
+		return true
+	}
+	return vendorName != "" && common.StringsContains(syncSetting.AutoApplyVendors, vendorName)
+}
+
+func formatRatioValue(value *float64) string {
+	if value == nil {
+		return "未设置"
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+func formatRatioChangeItem(item *model.RatioChangeItem) string {
+	line := fmt.Sprintf("%s %s：%s → %s", item.Model, item.RatioType, formatRatioValue(item.Current), formatRatioValue(&item.Proposed))
+	if item.Current != nil {
+		line += fmt.Sprintf("（%.2f%%）", item.ChangePercent)
+	}
+	if item.Applied {
+		line += " [已自动应用]"
+	} else if item.ExceedsLimit {
+		line += " [超出变动限制]"
+	}
+	return line
+}
+
+// sameRatioChanges 判断两组未应用的变更是否一致，用于避免每次同步都生成重复的待审核变更集
+func sameRatioChanges(a []*model.RatioChangeItem, b []*model.RatioChangeItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	proposed := make(map[string]float64, len(a))
+	for _, item := range a {
+		proposed[ratioChangeKey(item)] = item.Proposed
+	}
+	for _, item := range b {
+		if value, ok := proposed[ratioChangeKey(item)]; !ok || !nearlyEqual(value, item.Proposed) {
+			return false
+		}
+	}
+	return true
+}
+
+func pendingRatioChanges(items []*model.RatioChangeItem) []*model.RatioChangeItem {
+	pending := make([]*model.RatioChangeItem, 0)
+	for _, item := range items {
+		if !item.Applied {
+			pending = append(pending, item)
+		}
+	}
+	return pending
+}
+
+// runRatioSync 拉取配置的上游倍率，自动应用满足规则的变更，其余变更生成待审核的变更集
+func runRatioSync() (*model.RatioChangeSet, error) {
+	syncSetting := ratio_setting.GetRatioSyncSetting()
+	requestUpstreams := make([]dto.UpstreamDTO, 0, len(syncSetting.Upstreams))
+	for _, upstream := range syncSetting.Upstreams {
+		requestUpstreams = append(requestUpstreams, dto.UpstreamDTO{
+			Name:     upstream.Name,
+			BaseURL:  upstream.BaseURL,
+			Endpoint: upstream.Endpoint,
+		})
+	}
+	upstreams, err := resolveRatioUpstreams(requestUpstreams, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(upstreams) == 0 {
+		return nil, errors.New("未配置有效的上游倍率来源")
+	}
+
+	results := fetchUpstreamRatioData(context.Background(), upstreams, defaultTimeoutSeconds)
+	testResults, successfulChannels := splitUpstreamResults(results)
+	if len(successfulChannels) == 0 {
+		messages := make([]string, 0, len(testResults))
+		for _, result := range testResults {
+			messages = append(messages, result.Name+"："+result.Error)
+		}
+		return nil, errors.New("所有上游倍率拉取失败：" + strings.Join(messages, "；"))
+	}
+	upstreamNames := make([]string, 0, len(successfulChannels))
+	for _, channel := range successfulChannels {
+		upstreamNames = append(upstreamNames, channel.name)
+	}
+
+	differences := buildDifferences(ratio_setting.GetExposedData(), successfulChannels)
+	items := buildRatioChangeItems(differences, upstreamNames, syncSetting.MaxChangePercent)
+
+	latestPending, _, err := model.GetRatioChangeSets(model.RatioChangeSetStatusPending, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		// 本地已与上游一致，之前的待审核变更不再有意义
+		return nil, model.SupersedePendingRatioChangeSets()
+	}
+
+	modelNames := make([]string, 0, len(items))
+	for _, item := range items {
+		modelNames = append(modelNames, item.Model)
+	}
+	vendorNames, err := model.GetModelVendorNames(modelNames)
+	if err != nil {
+		return nil, err
+	}
+	autoItems := make([]*model.RatioChangeItem, 0)
+	for _, item := range items {
+		if isRatioChangeAutoApplicable(item, vendorNames[item.Model], syncSetting) {
+			autoItems = append(autoItems, item)
+		}
+	}
+	if len(autoItems) == 0 && len(latestPending) > 0 && sameRatioChanges(pendingRatioChanges(latestPending[0].GetChanges()), items) {
+		return nil, nil
+	}
+
+	changeSet := &model.RatioChangeSet{
+		Status: model.RatioChangeSetStatusPending,
+		Source: strings.Join(upstreamNames, ","),
+	}
+	changeSet.SetChanges(items)
+	if err := model.SupersedePendingRatioChangeSets(); err != nil {
+		return nil, err
+	}
+	if err := changeSet.Insert(); err != nil {
+		return nil, err
+	}
+	if err := applyRatioChanges(autoItems, changeSet.Id, model.RatioChangeSourceAuto, 0); err != nil {
+		return nil, err
+	}
+	pending := pendingRatioChanges(items)
+	changeSet.SetChanges(items)
+	if len(pending) == 0 {
+		if err := changeSet.Review(model.RatioChangeSetStatusApproved, 0, "全部变更已自动应用"); err != nil {
+			return nil, err
+		}
+	} else if len(autoItems) > 0 {
+		if err := changeSet.UpdateChanges(); err != nil {
+			return nil, err
+		}
+	}
+	return changeSet, nil
+}
+
+func syncUpstreamRatios() error {
+	ratioSyncLock.Lock()
+	if ratioSyncRunning {
+		ratioSyncLock.Unlock()
+		return errors.New("上游倍率同步已在运行中")
+	}
+	ratioSyncRunning = true
+	ratioSyncLock.Unlock()
+	gopool.Go(func() {
+		defer func() {
+			ratioSyncLock.Lock()
+			ratioSyncRunning = false
+			ratioSyncLock.Unlock()
+		}()
+
+		syncSetting := ratio_setting.GetRatioSyncSetting()
+		changeSet, err := runRatioSync()
+		if err != nil {
+			common.SysError("failed to sync upstream ratios: " + err.Error())
+			if syncSetting.Notify {
+				service.NotifyRootUser(dto.NotifyTypeRatioSync, "上游倍率同步失败", err.Error())
+			}
+		} else if changeSet != nil && syncSetting.Notify {
+			items := changeSet.GetChanges()
+			lines := make([]string, 0, len(items))
+			for _, item := range items {
+				lines = append(lines, formatRatioChangeItem(item))
+			}
+			pendingCount := len(pendingRatioChanges(items))
+			service.NotifyRootUser(dto.NotifyTypeRatioSync,
+				fmt.Sprintf("上游倍率发生 %d 项变化，%d 项待审核", len(items), pendingCount), strings.Join(lines, "\n"))
+		}
+
+		if syncSetting.RetentionDays > 0 {
+			target := time.Now().AddDate(0, 0, -syncSetting.RetentionDays).Unix()
+			if count, err := model.DeleteOldRatioChangeSets(context.Background(), target, 100); err != nil {
+				common.SysError("failed to delete old ratio change sets: " + err.Error())
+			} else if count > 0 {
+				common.SysLog(fmt.Sprintf("deleted %d old ratio change sets", count))
+			}
+		}
+	})
+	return nil
+}
+
+// AutomaticallySyncUpstreamRatios 按设置的间隔定时同步上游倍率，只在 Master 节点运行
+func AutomaticallySyncUpstreamRatios() {
+	if !common.IsMasterNode {
+		return
+	}
+	for {
+		if !ratio_setting.GetRatioSyncSetting().Enabled {
+			time.Sleep(1 * time.Minute)
+			continue
+		}
+		for {
+			frequency := ratio_setting.GetRatioSyncSetting().IntervalMinutes
+			time.Sleep(time.Duration(int(math.Round(frequency))) * time.Minute)
+			common.SysLog(fmt.Sprintf("automatically syncing upstream ratios with interval %f minutes", frequency))
+			_ = syncUpstreamRatios()
+			if !ratio_setting.GetRatioSyncSetting().Enabled {
+				break
+			}
+		}
+	}
+}
+
+// RunRatioSync 立即在后台执行一次上游倍率同步
+func RunRatioSync(c *gin.Context) {
+	if err := syncUpstreamRatios(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetRatioChangeSets 分页查询倍率变更集
+func GetRatioChangeSets(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	sets, total, err := model.GetRatioChangeSets(c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(sets)
+	common.ApiSuccess(c, pageInfo)
+}
+
+type ratioChangeReviewRequest struct {
+	Keys   []string `json:"keys"` // 只审核通过指定的变更，格式为 模型|倍率类型，为空时通过全部未应用的变更
+	Remark string   `json:"remark"`
+}
+
+func getPendingRatioChangeSet(c *gin.Context) (*model.RatioChangeSet, *ratioChangeReviewRequest, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, nil, err
+	}
+	req := &ratioChangeReviewRequest{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(req); err != nil {
+			return nil, nil, err
+		}
+	}
+	changeSet, err := model.GetRatioChangeSetById(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if changeSet.Status != model.RatioChangeSetStatusPending {
+		return nil, nil, errors.New("变更集已被处理")
+	}
+	return changeSet, req, nil
+}
+
+// ApproveRatioChangeSet 审核通过并应用变更集中未应用的变更，未选中的变更将被丢弃
+func ApproveRatioChangeSet(c *gin.Context) {
+	changeSet, req, err := getPendingRatioChangeSet(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	items := changeSet.GetChanges()
+	selected := make([]*model.RatioChangeItem, 0)
+	for _, item := range items {
+		if item.Applied {
+			continue
+		}
+		if len(req.Keys) == 0 || common.StringsContains(req.Keys, ratioChangeKey(item)) {
+			selected = append(selected, item)
+		}
+	}
+	if len(selected) == 0 {
+		common.ApiErrorMsg(c, "没有可应用的变更")
+		return
+	}
+	if err := applyRatioChanges(selected, changeSet.Id, model.RatioChangeSourceApproved, c.GetInt("id")); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	changeSet.SetChanges(items)
+	if err := changeSet.Review(model.RatioChangeSetStatusApproved, c.GetInt("id"), req.Remark); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, changeSet)
+}
+
+// RejectRatioChangeSet 拒绝变更集，其中未应用的变更均被丢弃
+func RejectRatioChangeSet(c *gin.Context) {
+	changeSet, req, err := getPendingRatioChangeSet(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := changeSet.Review(model.RatioChangeSetStatusRejected, c.GetInt("id"), req.Remark); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, changeSet)
+}
+
+// GetRatioChangeLogs 分页查询倍率变更审计日志
+func GetRatioChangeLogs(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	changeSetId, _ := strconv.Atoi(c.Query("change_set_id"))
+	logs, total, err := model.GetRatioChangeLogs(c.Query("model_name"), changeSetId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
@@ -7,8 +7,11 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/metrics"
+	"github.com/QuantumNous/new-api/common/tracing"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
@@ -26,6 +29,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func relayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
@@ -70,8 +74,19 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	var (
 		newAPIError *types.NewAPIError
 		ws          *websocket.Conn
+		relayInfo   *relaycommon.RelayInfo
 	)
 
+	metrics.RelayInFlightRequests.Inc()
+	payloadCapture := service.StartPayloadCapture(c)
+	inflight := service.StartInflightRequest(c)
+	defer func(startTime time.Time) {
+		inflight.Finish()
+		metrics.RelayInFlightRequests.Dec()
+		observeRelayMetrics(c, relayFormat, relayInfo, newAPIError, time.Since(startTime))
+		service.FinishPayloadCapture(c, payloadCapture, relayInfo, c.Writer.Status())
+	}(time.Now())
+
 	if relayFormat == types.RelayFormatOpenAIRealtime {
 		var err error
 		ws, err = upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -108,11 +123,12 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
-	relayInfo, err := relaycommon.GenRelayInfo(c, relayFormat, request, ws)
+	relayInfo, err = relaycommon.GenRelayInfo(c, relayFormat, request, ws)
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeGenRelayInfoFailed)
 		return
 	}
+	inflight.SetStream(relayInfo.IsStream)
 
 	meta := request.GetTokenCountMeta()
 
@@ -125,7 +141,11 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}
 
+	estimateSpan := tracing.Start(c, "estimate_tokens")
 	tokens, err := service.EstimateRequestToken(c, meta, relayInfo)
+	tracing.RecordError(estimateSpan, err)
+	estimateSpan.SetAttributes(attribute.Int("prompt_tokens", tokens))
+	estimateSpan.End()
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeCountTokenFailed)
 		return
@@ -144,7 +164,12 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	if priceData.FreeModel {
 		logger.LogInfo(c, fmt.Sprintf("模型 %s 免费，跳过预扣费", relayInfo.OriginModelName))
 	} else {
+		preConsumeSpan := tracing.Start(c, "pre_consume", attribute.Int("quota", priceData.QuotaToPreConsume))
 		newAPIError = service.PreConsumeQuota(c, priceData.QuotaToPreConsume, relayInfo)
+		if newAPIError != nil {
+			tracing.RecordError(preConsumeSpan, newAPIError)
+		}
+		preConsumeSpan.End()
 		if newAPIError != nil {
 			return
 		}
@@ -166,6 +191,13 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 
 		addUsedChannel(c, channel.Id)
+		inflight.SetChannel(channel.Id, channel.Name)
+		attemptSpan, endAttempt := tracing.StartScope(c, "relay_attempt",
+			attribute.Int("retry", i),
+			attribute.Int("channel.id", channel.Id),
+			attribute.Int("channel.type", channel.Type),
+			attribute.String("channel.name", channel.Name),
+		)
 
 		// 渠道审核检查
 		channelSetting, _ := common.GetContextKeyType[dto.ChannelSettings](c, constant.ContextKeyChannelSetting)
@@ -191,6 +223,8 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 				}
 				newAPIError.StatusCode = http.StatusForbidden
 				// 记录错误日志
+				tracing.RecordError(attemptSpan, newAPIError)
+				endAttempt()
 				processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
 				return
 			}
@@ -209,11 +243,22 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		default:
 			newAPIError = relayHandler(c, relayInfo)
 		}
+		if newAPIError != nil {
+			attemptSpan.SetAttributes(attribute.Int("http.response.status_code", newAPIError.StatusCode))
+			tracing.RecordError(attemptSpan, newAPIError)
+		}
+		endAttempt()
 
 		if newAPIError == nil {
 			return
 		}
 
+		// 管理员取消的请求不计入渠道错误，也不再重试
+		if cause := inflight.CancelCause(); cause != nil {
+			newAPIError = types.NewErrorWithStatusCode(cause, types.ErrorCodeRequestCancelled, http.StatusServiceUnavailable, types.ErrOptionWithSkipRetry())
+			break
+		}
+
 		processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
 
 		if !shouldRetry(c, newAPIError, common.RetryTimes-i) {
@@ -228,6 +273,28 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	}
 }
 
+// observeRelayMetrics 记录请求最终结果与状态页样本，渠道取重试后实际使用的渠道
+func observeRelayMetrics(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo, newAPIError *types.NewAPIError, duration time.Duration) {
+	labels := metrics.RelayLabels{
+		Model:       common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
+		Group:       common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
+		ChannelId:   c.GetInt("channel_id"),
+		RelayFormat: string(relayFormat),
+	}
+	statusCode := c.Writer.Status()
+	if newAPIError != nil {
+		statusCode = newAPIError.StatusCode
+		metrics.ObserveRelayError(labels, string(newAPIError.GetErrorCode()))
+	}
+	metrics.ObserveRelayRequest(labels, statusCode, duration)
+	latency := duration
+	if relayInfo != nil && relayInfo.IsStream && relayInfo.HasSendResponse() {
+		latency = relayInfo.FirstResponseTime.Sub(relayInfo.StartTime)
+		metrics.ObserveFirstToken(labels, latency)
+	}
+	service.RecordRelayStatus(labels.Model, labels.Group, newAPIError, latency)
+}
+
 var upgrader = websocket.Upgrader{
 	Subprotocols: []string{"realtime"}, // WS 握手支持的协议，如果有使用 Sec-WebSocket-Protocol，则必须在此声明对应的 Protocol TODO add other protocol
 	CheckOrigin: func(r *http.Request) bool {
@@ -390,6 +457,7 @@ func processChannelError(c *gin.Context, channelError types.ChannelError, err *t
 			adminInfo["is_multi_key"] = true
 			adminInfo["multi_key_index"] = common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
 		}
+		service.AppendPayloadCaptureInfo(c, adminInfo)
 		other["admin_info"] = adminInfo
 		model.RecordErrorLog(c, userId, channelId, modelName, tokenName, err.MaskSensitiveError(), tokenId, 0, false, userGroup, other)
 	}
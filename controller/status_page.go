@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+var statusIncidentSeverities = []string{"minor", "major", "critical"}
+var statusIncidentStatuses = []string{"investigating", "identified", "monitoring", model.StatusIncidentResolved}
+
+// recordChannelTestStatus 将渠道测试结果计入测试模型与渠道所属分组的可用性
+func recordChannelTestStatus(channel *model.Channel, success bool, latency time.Duration) {
+	if !operation_setting.GetStatusPageSetting().Enabled {
+		return
+	}
+	service.RecordStatusSample(model.StatusSampleSourceTest, getChannelTestModel(channel), "", success, latency)
+	for _, group := range channel.GetGroups() {
+		service.RecordStatusSample(model.StatusSampleSourceTest, "", group, success, latency)
+	}
+}
+
+// GetStatusPage 公开的状态页数据
+func GetStatusPage(c *gin.Context) {
+	if !operation_setting.GetStatusPageSetting().Enabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "状态页未启用",
+		})
+		return
+	}
+	page, err := service.GetStatusPage()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, page)
+}
+
+func GetStatusIncidents(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	incidents, total, err := model.GetStatusIncidents(c.Query("type"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(incidents)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func validateStatusIncident(incident *model.StatusIncident) error {
+	incident.Title = strings.TrimSpace(incident.Title)
+	if incident.Title == "" {
+		return errors.New("标题不能为空")
+	}
+	switch incident.Type {
+	case model.StatusIncidentTypeIncident:
+		if incident.Severity == "" {
+			incident.Severity = "major"
+		}
+		if !common.StringsContains(statusIncidentSeverities, incident.Severity) {
+			return errors.New("无效的严重程度")
+		}
+		if incident.Status == "" {
+			incident.Status = "investigating"
+		}
+		if !common.StringsContains(statusIncidentStatuses, incident.Status) {
+			return errors.New("无效的故障状态")
+		}
+	case model.StatusIncidentTypeMaintenance:
+		incident.Severity = ""
+		incident.Status = ""
+		if incident.StartTime == 0 || incident.EndTime == 0 {
+			return errors.New("维护需要设置开始与结束时间")
+		}
+	default:
+		return errors.New("无效的公告类型")
+	}
+	if incident.StartTime == 0 {
+		incident.StartTime = common.GetTimestamp()
+	}
+	if incident.EndTime != 0 && incident.EndTime < incident.StartTime {
+		return errors.New("结束时间不能早于开始时间")
+	}
+	// 故障标记为已解决时自动记录恢复时间
+	if incident.Type == model.StatusIncidentTypeIncident {
+		if incident.Status == model.StatusIncidentResolved && incident.EndTime == 0 {
+			incident.EndTime = common.GetTimestamp()
+		} else if incident.Status != model.StatusIncidentResolved {
+			incident.EndTime = 0
+		}
+	}
+	return nil
+}
+
+func AddStatusIncident(c *gin.Context) {
+	incident := model.StatusIncident{}
+	if err := c.ShouldBindJSON(&incident); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	incident.Id = 0
+	if err := validateStatusIncident(&incident); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := incident.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.InvalidateStatusPage()
+	common.ApiSuccess(c, incident)
+}
+
+func UpdateStatusIncident(c *gin.Context) {
+	incident := model.StatusIncident{}
+	if err := c.ShouldBindJSON(&incident); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	origin, err := model.GetStatusIncidentById(incident.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	incident.CreatedAt = origin.CreatedAt
+	if err := validateStatusIncident(&incident); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := incident.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.InvalidateStatusPage()
+	common.ApiSuccess(c, incident)
+}
+
+func DeleteStatusIncident(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.DeleteStatusIncidentById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.InvalidateStatusPage()
+	common.ApiSuccess(c, nil)
+}
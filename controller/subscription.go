@@ -0,0 +1,350 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thanhpk/randstr"
+)
+
+type SubscriptionPayRequest struct {
+	PlanId        int    `json:"plan_id"`
+	PaymentMethod string `json:"payment_method"`
+}
+
+type SubscriptionGrantRequest struct {
+	UserId int `json:"user_id"`
+	PlanId int `json:"plan_id"`
+}
+
+func isStripeSubscriptionEnabled() bool {
+	return setting.StripeApiSecret != "" && setting.StripeWebhookSecret != ""
+}
+
+func isCreemSubscriptionEnabled() bool {
+	return setting.CreemApiKey != ""
+}
+
+func getEnabledSubscriptionPlan(planId int) (*model.SubscriptionPlan, error) {
+	plan, err := model.GetSubscriptionPlanById(planId)
+	if err != nil || !plan.Enabled {
+		return nil, errors.New("套餐不存在或已下架")
+	}
+	return plan, nil
+}
+
+// GetSubscriptionSelf 当前用户的订阅和可订阅的套餐
+func GetSubscriptionSelf(c *gin.Context) {
+	if !operation_setting.GetSubscriptionSetting().Enabled {
+		common.ApiSuccess(c, gin.H{"enabled": false})
+		return
+	}
+	sub, err := model.GetUserActiveSubscription(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	plans, err := model.GetSubscriptionPlans(true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"enabled":        true,
+		"subscription":   sub,
+		"plans":          plans,
+		"enable_stripe":  isStripeSubscriptionEnabled(),
+		"enable_creem":   isCreemSubscriptionEnabled(),
+		"prorate_change": operation_setting.GetSubscriptionSetting().ProrateOnChange,
+	})
+}
+
+func RequestSubscriptionPay(c *gin.Context) {
+	if !operation_setting.GetSubscriptionSetting().Enabled {
+		common.ApiErrorMsg(c, "订阅功能未开启")
+		return
+	}
+	var req SubscriptionPayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	plan, err := getEnabledSubscriptionPlan(req.PlanId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	id := c.GetInt("id")
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	reference := fmt.Sprintf("sub-ref-%d-%d-%s", user.Id, time.Now().UnixMilli(), randstr.String(4))
+	referenceId := "sub_" + common.Sha1([]byte(reference))
+
+	switch req.PaymentMethod {
+	case PaymentMethodStripe:
+		if !isStripeSubscriptionEnabled() || plan.StripePriceId == "" {
+			common.ApiErrorMsg(c, "该套餐不支持 Stripe 支付")
+			return
+		}
+	case PaymentMethodCreem:
+		if !isCreemSubscriptionEnabled() || plan.CreemProductId == "" {
+			common.ApiErrorMsg(c, "该套餐不支持 Creem 支付")
+			return
+		}
+	default:
+		common.ApiErrorMsg(c, "不支持的支付渠道")
+		return
+	}
+	if _, err = model.CreatePendingSubscription(id, plan.Id, req.PaymentMethod, referenceId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	var payLink string
+	if req.PaymentMethod == PaymentMethodStripe {
+		payLink, err = genStripeSubscriptionLink(referenceId, user.StripeCustomer, user.Email, plan)
+	} else {
+		payLink, err = genCreemLink(referenceId, &CreemProduct{
+			ProductId: plan.CreemProductId,
+			Name:      plan.Name,
+			Price:     plan.Price,
+			Currency:  plan.Currency,
+			Quota:     int64(plan.Quota),
+		}, user.Email, user.Username)
+	}
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to create subscription checkout, plan %d, method %s: %s", plan.Id, req.PaymentMethod, err.Error()))
+		common.ApiErrorMsg(c, "拉起支付失败")
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"pay_link": payLink,
+		"order_id": referenceId,
+	})
+}
+
+// ChangeSubscriptionPlan 升级或降级套餐，支付平台按剩余周期折算差价，包含额度按配置折算
+func ChangeSubscriptionPlan(c *gin.Context) {
+	if !operation_setting.GetSubscriptionSetting().Enabled {
+		common.ApiErrorMsg(c, "订阅功能未开启")
+		return
+	}
+	var req SubscriptionPayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	sub, err := model.GetUserActiveSubscription(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if sub == nil {
+		common.ApiErrorMsg(c, "没有生效中的订阅")
+		return
+	}
+	plan, err := getEnabledSubscriptionPlan(req.PlanId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if sub.PlanId == plan.Id {
+		common.ApiErrorMsg(c, "已是该套餐")
+		return
+	}
+	switch sub.PaymentMethod {
+	case PaymentMethodStripe:
+		if plan.StripePriceId == "" {
+			common.ApiErrorMsg(c, "该套餐不支持 Stripe 支付")
+			return
+		}
+		err = stripeChangeSubscriptionPrice(sub.ProviderSubscriptionId, plan.StripePriceId)
+	case PaymentMethodCreem:
+		if plan.CreemProductId == "" {
+			common.ApiErrorMsg(c, "该套餐不支持 Creem 支付")
+			return
+		}
+		err = creemUpgradeSubscription(sub.ProviderSubscriptionId, plan.CreemProductId)
+	default:
+		common.ApiErrorMsg(c, "该订阅由管理员开通，请联系管理员变更")
+		return
+	}
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to change subscription %d at provider: %s", sub.Id, err.Error()))
+		common.ApiErrorMsg(c, "支付平台变更订阅失败")
+		return
+	}
+	delta, err := model.ChangeSubscriptionPlan(sub.Id, plan.Id, operation_setting.GetSubscriptionSetting().ProrateOnChange)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{"quota_delta": delta})
+}
+
+// CancelSubscriptionSelf 取消续费，订阅在本周期结束时到期
+func CancelSubscriptionSelf(c *gin.Context) {
+	sub, err := model.GetUserActiveSubscription(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if sub == nil {
+		common.ApiErrorMsg(c, "没有生效中的订阅")
+		return
+	}
+	switch sub.PaymentMethod {
+	case PaymentMethodStripe:
+		err = stripeCancelSubscription(sub.ProviderSubscriptionId, false)
+	case PaymentMethodCreem:
+		err = creemCancelSubscription(sub.ProviderSubscriptionId)
+	}
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to cancel subscription %d at provider: %s", sub.Id, err.Error()))
+		common.ApiErrorMsg(c, "支付平台取消订阅失败")
+		return
+	}
+	if err = model.SetSubscriptionCancelAtPeriodEnd(sub.Id, true); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetSubscriptionPlans(c *gin.Context) {
+	plans, err := model.GetSubscriptionPlans(false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plans)
+}
+
+func validateSubscriptionPlan(plan *model.SubscriptionPlan) error {
+	plan.Name = strings.TrimSpace(plan.Name)
+	if plan.Name == "" {
+		return errors.New("套餐名称不能为空")
+	}
+	if plan.Price < 0 || plan.Quota < 0 || plan.RateLimitCount < 0 || plan.RateLimitSuccessCount < 0 {
+		return errors.New("价格、额度和限流次数不能为负数")
+	}
+	if plan.Currency == "" {
+		plan.Currency = "USD"
+	}
+	return nil
+}
+
+func AddSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validateSubscriptionPlan(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	plan.Id = 0
+	if err := plan.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plan)
+}
+
+func UpdateSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validateSubscriptionPlan(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if _, err := model.GetSubscriptionPlanById(plan.Id); err != nil {
+		common.ApiErrorMsg(c, "套餐不存在")
+		return
+	}
+	if err := plan.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plan)
+}
+
+func DeleteSubscriptionPlan(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteSubscriptionPlanById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetAllSubscriptions(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	subs, total, err := model.GetUserSubscriptions(userId, c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(subs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func AdminGrantSubscription(c *gin.Context) {
+	var req SubscriptionGrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserId == 0 || req.PlanId == 0 {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	if err := model.GrantSubscription(req.UserId, req.PlanId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// AdminExpireSubscription 立即结束订阅，同时取消支付平台的自动续费
+func AdminExpireSubscription(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	sub, err := model.GetUserSubscriptionById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if sub.Status != model.SubscriptionStatusActive {
+		common.ApiErrorMsg(c, "订阅未生效")
+		return
+	}
+	switch sub.PaymentMethod {
+	case PaymentMethodStripe:
+		err = stripeCancelSubscription(sub.ProviderSubscriptionId, true)
+	case PaymentMethodCreem:
+		err = creemCancelSubscription(sub.ProviderSubscriptionId)
+	}
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to cancel subscription %d at provider: %s", sub.Id, err.Error()))
+	}
+	if err = model.ExpireSubscription(sub.Id, "管理员结束订阅"); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
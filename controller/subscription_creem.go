@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// creemObjectRef Creem 回调中的关联对象可能是 ID 字符串，也可能是展开后的对象
+type creemObjectRef struct {
+	Id string `json:"id"`
+}
+
+func (r *creemObjectRef) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &r.Id)
+	}
+	if string(data) == "null" {
+		return nil
+	}
+	var obj struct {
+		Id string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	r.Id = obj.Id
+	return nil
+}
+
+func creemApiBase() string {
+	if setting.CreemTestMode {
+		return "https://test-api.creem.io"
+	}
+	return "https://api.creem.io"
+}
+
+func creemSubscriptionRequest(subscriptionId string, action string, body any) error {
+	if setting.CreemApiKey == "" {
+		return fmt.Errorf("未配置Creem API密钥")
+	}
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	apiUrl := fmt.Sprintf("%s/v1/subscriptions/%s/%s", creemApiBase(), subscriptionId, action)
+	req, err := http.NewRequest("POST", apiUrl, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", setting.CreemApiKey)
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		log.Printf("Creem订阅请求失败 - %s %s, status code: %d, resp: %s", action, subscriptionId, resp.StatusCode, string(respBody))
+		return fmt.Errorf("Creem API http status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// creemUpgradeSubscription 更换订阅产品，差价由 Creem 按剩余周期折算并立即扣款
+func creemUpgradeSubscription(subscriptionId string, productId string) error {
+	return creemSubscriptionRequest(subscriptionId, "upgrade", map[string]string{
+		"product_id":      productId,
+		"update_behavior": "proration-charge-immediately",
+	})
+}
+
+func creemCancelSubscription(subscriptionId string) error {
+	return creemSubscriptionRequest(subscriptionId, "cancel", map[string]string{})
+}
+
+func parseCreemTime(s string) int64 {
+	if s == "" {
+		return 0
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+// cancelDuplicateCreemSubscription 支付完成时用户已有生效中的订阅，立即取消 Creem 上重复的订阅，
+// 已扣的首期款项需要管理员在 Creem 后台退款
+func cancelDuplicateCreemSubscription(subscriptionId string, referenceId string, transactionId string) error {
+	if err := creemCancelSubscription(subscriptionId); err != nil {
+		return err
+	}
+	common.SysError(fmt.Sprintf("duplicate creem subscription %s canceled, order %s, transaction %s needs a refund in the Creem dashboard",
+		subscriptionId, referenceId, transactionId))
+	return nil
+}
+
+func handleCreemSubscriptionCheckout(c *gin.Context, event *CreemWebhookEvent, referenceId string) {
+	subscriptionId := event.Object.Subscription.Id
+	err := model.ActivateSubscription(referenceId, subscriptionId, event.Object.Order.Transaction, 0)
+	if errors.Is(err, model.ErrSubscriptionAlreadyActive) {
+		err = cancelDuplicateCreemSubscription(subscriptionId, referenceId, event.Object.Order.Transaction)
+	}
+	if err != nil {
+		log.Printf("Creem订阅激活失败: %s, 订单号: %s", err.Error(), referenceId)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Creem订阅已激活 - 订单号: %s, 订阅ID: %s", referenceId, subscriptionId)
+	c.Status(http.StatusOK)
+}
+
+// handleCreemSubscriptionPaid 订阅扣款成功。首期扣款可能先于 checkout.completed 到达，此时按元数据中的订单号激活
+func handleCreemSubscriptionPaid(c *gin.Context, event *CreemWebhookEvent) {
+	subscriptionId := event.Object.Id
+	paymentId := event.Object.LastTransactionId
+	periodEnd := parseCreemTime(event.Object.CurrentPeriodEndDate)
+	err := model.RenewSubscription(subscriptionId, paymentId, periodEnd)
+	if errors.Is(err, model.ErrSubscriptionNotFound) {
+		referenceId := event.Object.Metadata["reference_id"]
+		if referenceId == "" {
+			log.Printf("Creem订阅不存在: %s", subscriptionId)
+			c.Status(http.StatusOK)
+			return
+		}
+		err = model.ActivateSubscription(referenceId, subscriptionId, paymentId, periodEnd)
+		if errors.Is(err, model.ErrSubscriptionAlreadyActive) {
+			err = cancelDuplicateCreemSubscription(subscriptionId, referenceId, paymentId)
+		}
+	}
+	if err != nil {
+		log.Printf("Creem订阅续费失败: %s, 订阅ID: %s", err.Error(), subscriptionId)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Creem订阅扣款已处理 - 订阅ID: %s, 交易ID: %s", subscriptionId, paymentId)
+	c.Status(http.StatusOK)
+}
+
+// handleCreemSubscriptionCanceled 订阅取消后不再续费，本周期结束时到期
+func handleCreemSubscriptionCanceled(c *gin.Context, event *CreemWebhookEvent) {
+	sub, err := model.GetUserSubscriptionByProviderId(event.Object.Id)
+	if err != nil {
+		log.Printf("Creem订阅不存在: %s", event.Object.Id)
+		c.Status(http.StatusOK)
+		return
+	}
+	if err = model.SetSubscriptionCancelAtPeriodEnd(sub.Id, true); err != nil {
+		log.Printf("Creem订阅取消处理失败: %s, 订阅ID: %s", err.Error(), event.Object.Id)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func handleCreemSubscriptionExpired(c *gin.Context, event *CreemWebhookEvent) {
+	sub, err := model.GetUserSubscriptionByProviderId(event.Object.Id)
+	if err != nil {
+		log.Printf("Creem订阅不存在: %s", event.Object.Id)
+		c.Status(http.StatusOK)
+		return
+	}
+	if err = model.ExpireSubscription(sub.Id, "Creem订阅已到期"); err != nil {
+		log.Printf("Creem订阅到期处理失败: %s, 订阅ID: %s", err.Error(), event.Object.Id)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/checkout/session"
+	"github.com/stripe/stripe-go/v81/invoice"
+	"github.com/stripe/stripe-go/v81/refund"
+	"github.com/stripe/stripe-go/v81/subscription"
+)
+
+func setStripeKey() error {
+	if !strings.HasPrefix(setting.StripeApiSecret, "sk_") && !strings.HasPrefix(setting.StripeApiSecret, "rk_") {
+		return fmt.Errorf("无效的Stripe API密钥")
+	}
+	stripe.Key = setting.StripeApiSecret
+	return nil
+}
+
+func genStripeSubscriptionLink(referenceId string, customerId string, email string, plan *model.SubscriptionPlan) (string, error) {
+	if err := setStripeKey(); err != nil {
+		return "", err
+	}
+
+	params := &stripe.CheckoutSessionParams{
+		ClientReferenceID: stripe.String(referenceId),
+		SuccessURL:        stripe.String(system_setting.ServerAddress + "/console/topup"),
+		CancelURL:         stripe.String(system_setting.ServerAddress + "/console/topup"),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				Price:    stripe.String(plan.StripePriceId),
+				Quantity: stripe.Int64(1),
+			},
+		},
+		Mode: stripe.String(string(stripe.CheckoutSessionModeSubscription)),
+		SubscriptionData: &stripe.CheckoutSessionSubscriptionDataParams{
+			Metadata: map[string]string{"reference_id": referenceId},
+		},
+		AllowPromotionCodes: stripe.Bool(setting.StripePromotionCodesEnabled),
+		// 支付页面过期前不允许再次下单，见 model.SubscriptionCheckoutTTL
+		ExpiresAt: stripe.Int64(time.Now().Unix() + model.SubscriptionCheckoutTTL),
+	}
+	// 订阅模式下 Stripe 会自动创建客户，不支持 customer_creation 参数
+	if customerId != "" {
+		params.Customer = stripe.String(customerId)
+	} else if email != "" {
+		params.CustomerEmail = stripe.String(email)
+	}
+
+	result, err := session.New(params)
+	if err != nil {
+		return "", err
+	}
+	return result.URL, nil
+}
+
+// stripeChangeSubscriptionPrice 更换订阅价格，差价由 Stripe 按剩余周期折算并立即开票
+func stripeChangeSubscriptionPrice(subscriptionId string, priceId string) error {
+	if err := setStripeKey(); err != nil {
+		return err
+	}
+	sub, err := subscription.Get(subscriptionId, nil)
+	if err != nil {
+		return err
+	}
+	if sub.Items == nil || len(sub.Items.Data) == 0 {
+		return errors.New("Stripe订阅没有订阅项")
+	}
+	_, err = subscription.Update(subscriptionId, &stripe.SubscriptionParams{
+		Items: []*stripe.SubscriptionItemsParams{
+			{
+				ID:    stripe.String(sub.Items.Data[0].ID),
+				Price: stripe.String(priceId),
+			},
+		},
+		ProrationBehavior: stripe.String("always_invoice"),
+	})
+	return err
+}
+
+func stripeCancelSubscription(subscriptionId string, immediately bool) error {
+	if err := setStripeKey(); err != nil {
+		return err
+	}
+	var err error
+	if immediately {
+		_, err = subscription.Cancel(subscriptionId, nil)
+	} else {
+		_, err = subscription.Update(subscriptionId, &stripe.SubscriptionParams{CancelAtPeriodEnd: stripe.Bool(true)})
+	}
+	return err
+}
+
+// stripeRefundSubscription 立即取消订阅并全额退回指定账单的扣款
+func stripeRefundSubscription(subscriptionId string, invoiceId string) error {
+	if err := stripeCancelSubscription(subscriptionId, true); err != nil {
+		return err
+	}
+	if invoiceId == "" {
+		return nil
+	}
+	inv, err := invoice.Get(invoiceId, nil)
+	if err != nil {
+		return err
+	}
+	if inv.PaymentIntent == nil {
+		return nil
+	}
+	_, err = refund.New(&stripe.RefundParams{PaymentIntent: stripe.String(inv.PaymentIntent.ID)})
+	return err
+}
+
+func stripeSubscriptionCheckoutCompleted(event stripe.Event) {
+	referenceId := event.GetObjectValue("client_reference_id")
+	status := event.GetObjectValue("status")
+	if "complete" != status {
+		log.Println("错误的Stripe订阅Checkout完成状态:", status, ",", referenceId)
+		return
+	}
+	subscriptionId := event.GetObjectValue("subscription")
+	invoiceId := event.GetObjectValue("invoice")
+	err := model.ActivateSubscription(referenceId, subscriptionId, invoiceId, 0)
+	if errors.Is(err, model.ErrSubscriptionAlreadyActive) {
+		if err = stripeRefundSubscription(subscriptionId, invoiceId); err != nil {
+			common.SysError(fmt.Sprintf("failed to refund duplicate stripe subscription %s, order %s: %s", subscriptionId, referenceId, err.Error()))
+			return
+		}
+		log.Println("Stripe重复订阅已取消并退款", referenceId, subscriptionId)
+		return
+	}
+	if err != nil {
+		log.Println("Stripe订阅激活失败", referenceId, err.Error())
+		return
+	}
+	if sub, err := model.GetUserSubscriptionByTradeNo(referenceId); err == nil {
+		model.UpdateUserStripeCustomer(sub.UserId, event.GetObjectValue("customer"))
+	}
+	log.Println("Stripe订阅已激活", referenceId, subscriptionId)
+}
+
+// stripeInvoicePaid 只处理周期续费的账单，首期账单在 Checkout 完成时处理，升降级的差价账单在变更套餐时处理
+func stripeInvoicePaid(event stripe.Event) {
+	var invoice stripe.Invoice
+	if err := json.Unmarshal(event.Data.Raw, &invoice); err != nil {
+		log.Println("解析Stripe账单失败", err.Error())
+		return
+	}
+	if invoice.BillingReason != stripe.InvoiceBillingReasonSubscriptionCycle || invoice.Subscription == nil {
+		return
+	}
+	var periodEnd int64
+	if invoice.Lines != nil && len(invoice.Lines.Data) > 0 && invoice.Lines.Data[0].Period != nil {
+		periodEnd = invoice.Lines.Data[0].Period.End
+	}
+	err := model.RenewSubscription(invoice.Subscription.ID, invoice.ID, periodEnd)
+	if err != nil {
+		log.Println("Stripe订阅续费失败", invoice.Subscription.ID, err.Error())
+		return
+	}
+	log.Println("Stripe订阅已续费", invoice.Subscription.ID, invoice.ID)
+}
+
+func stripeSubscriptionUpdated(event stripe.Event) {
+	var stripeSub stripe.Subscription
+	if err := json.Unmarshal(event.Data.Raw, &stripeSub); err != nil {
+		log.Println("解析Stripe订阅失败", err.Error())
+		return
+	}
+	sub, err := model.GetUserSubscriptionByProviderId(stripeSub.ID)
+	if err != nil || sub.Status != model.SubscriptionStatusActive {
+		return
+	}
+	if sub.CancelAtPeriodEnd != stripeSub.CancelAtPeriodEnd {
+		if err = model.SetSubscriptionCancelAtPeriodEnd(sub.Id, stripeSub.CancelAtPeriodEnd); err != nil {
+			log.Println("同步Stripe订阅取消状态失败", stripeSub.ID, err.Error())
+		}
+	}
+}
+
+func stripeSubscriptionDeleted(event stripe.Event) {
+	subscriptionId := event.GetObjectValue("id")
+	sub, err := model.GetUserSubscriptionByProviderId(subscriptionId)
+	if err != nil {
+		log.Println("Stripe订阅不存在", subscriptionId)
+		return
+	}
+	if err = model.ExpireSubscription(sub.Id, "Stripe订阅已取消"); err != nil {
+		log.Println("Stripe订阅到期处理失败", subscriptionId, err.Error())
+		return
+	}
+	log.Println("Stripe订阅已结束", subscriptionId)
+}
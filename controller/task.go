@@ -17,6 +17,7 @@ import (
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/relay"
+	"github.com/QuantumNous/new-api/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/samber/lo"
@@ -164,12 +165,12 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 			} else {
 				quota := task.Quota
 				if quota != 0 {
-					err = model.IncreaseUserQuota(task.UserId, quota, false)
+					err = model.IncreaseBillingQuota(task.UserId, task.OrganizationId, quota, model.QuotaLedgerRef{Source: model.QuotaLedgerSourceTask, Reference: task.TaskID, Remark: "异步任务失败补偿"})
 					if err != nil {
 						logger.LogError(ctx, "fail to increase user quota: "+err.Error())
 					}
 					logContent := fmt.Sprintf("异步任务执行失败 %s，补偿 %s", task.TaskID, logger.LogQuota(quota))
-					model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
+					model.RecordTaskRefundLog(task.UserId, task.OrganizationId, taskModelName(task), quota, logContent)
 				}
 			}
 		}
@@ -186,6 +187,14 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 	return nil
 }
 
+// taskModelName 任务提交时计费使用的模型名称
+func taskModelName(task *model.Task) string {
+	if task.Properties.OriginModelName != "" {
+		return task.Properties.OriginModelName
+	}
+	return service.CoverTaskActionToModelName(task.Platform, task.Action)
+}
+
 func checkTaskNeedUpdate(oldTask *model.Task, newTask dto.SunoDataResponse) bool {
 
 	if oldTask.SubmitTime != newTask.SubmitTime {
@@ -187,7 +187,7 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 									logger.LogQuota(preConsumedQuota),
 									taskResult.TotalTokens,
 								))
-								if err := model.DecreaseUserQuota(task.UserId, quotaDelta); err != nil {
+								if err := model.DecreaseBillingQuota(task.UserId, task.OrganizationId, quotaDelta, model.QuotaLedgerRef{Source: model.QuotaLedgerSourceTask, Reference: task.TaskID, Remark: "视频任务补扣费"}); err != nil {
 									logger.LogError(ctx, fmt.Sprintf("补扣费失败: %s", err.Error()))
 								} else {
 									model.UpdateUserUsedQuotaAndRequestCount(task.UserId, quotaDelta)
@@ -210,7 +210,7 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 									logger.LogQuota(preConsumedQuota),
 									taskResult.TotalTokens,
 								))
-								if err := model.IncreaseUserQuota(task.UserId, refundQuota, false); err != nil {
+								if err := model.IncreaseBillingQuota(task.UserId, task.OrganizationId, refundQuota, model.QuotaLedgerRef{Source: model.QuotaLedgerSourceTask, Reference: task.TaskID, Remark: "视频任务退还多扣费用"}); err != nil {
 									logger.LogError(ctx, fmt.Sprintf("退还预扣费失败: %s", err.Error()))
 								} else {
 									task.Quota = actualQuota // 更新任务记录的实际扣费额度
@@ -219,7 +219,7 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 									logContent := fmt.Sprintf("视频任务成功退还多扣费用，模型倍率 %.2f，分组倍率 %.2f，tokens %d，预扣费 %s，实际扣费 %s，退还 %s",
 										modelRatio, finalGroupRatio, taskResult.TotalTokens,
 										logger.LogQuota(preConsumedQuota), logger.LogQuota(actualQuota), logger.LogQuota(refundQuota))
-									model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
+									model.RecordTaskRefundLog(task.UserId, task.OrganizationId, taskModelName(task), refundQuota, logContent)
 								}
 							} else {
 								// quotaDelta == 0, 预扣费刚好准确
@@ -261,11 +261,11 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 
 	if shouldRefund {
 		// 任务失败且之前状态不是失败才退还额度，防止重复退还
-		if err := model.IncreaseUserQuota(task.UserId, quota, false); err != nil {
+		if err := model.IncreaseBillingQuota(task.UserId, task.OrganizationId, quota, model.QuotaLedgerRef{Source: model.QuotaLedgerSourceTask, Reference: task.TaskID, Remark: "视频任务失败退款"}); err != nil {
 			logger.LogWarn(ctx, "Failed to increase user quota: "+err.Error())
 		}
 		logContent := fmt.Sprintf("Video async task failed %s, refund %s", task.TaskID, logger.LogQuota(quota))
-		model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
+		model.RecordTaskRefundLog(task.UserId, task.OrganizationId, taskModelName(task), quota, logContent)
 	}
 
 	return nil
@@ -1,12 +1,18 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,6 +25,7 @@ func GetAllTokens(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.FillTokenBudgetUsed(tokens)
 	total, _ := model.CountUserTokens(userId)
 	pageInfo.SetTotal(int(total))
 	pageInfo.SetItems(tokens)
@@ -35,6 +42,7 @@ func SearchTokens(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.FillTokenBudgetUsed(tokens)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -55,6 +63,7 @@ func GetToken(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.FillTokenBudgetUsed([]*model.Token{token})
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -117,6 +126,7 @@ func GetTokenUsage(c *gin.Context) {
 	if expiredAt == -1 {
 		expiredAt = 0
 	}
+	model.FillTokenBudgetUsed([]*model.Token{token})
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    true,
@@ -131,6 +141,11 @@ func GetTokenUsage(c *gin.Context) {
 			"model_limits":         token.GetModelLimitsMap(),
 			"model_limits_enabled": token.ModelLimitsEnabled,
 			"expires_at":           expiredAt,
+			"budget_period":        token.BudgetPeriod,
+			"budget_quota":         token.BudgetQuota,
+			"budget_used":          token.BudgetUsedQuota,
+			"budget_reset_at":      token.BudgetResetTime,
+			"scopes":               token.GetScopes(),
 		},
 	})
 }
@@ -149,6 +164,20 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if err = validateTokenBudget(&token); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = validateTokenScopes(&token); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if token.OrganizationId != 0 {
+		if err = checkOrganizationTokenAccess(token.OrganizationId, c.GetInt("id")); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
 	key, err := common.GenerateKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -171,6 +200,14 @@ func AddToken(c *gin.Context) {
 		ModelLimits:        token.ModelLimits,
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
+		OrganizationId:     token.OrganizationId,
+		BudgetPeriod:       token.BudgetPeriod,
+		BudgetQuota:        token.BudgetQuota,
+		BudgetSoftLimit:    token.BudgetSoftLimit,
+		Scopes:             token.Scopes,
+		MaxTokensLimit:     token.MaxTokensLimit,
+		DisableStream:      token.DisableStream,
+		DisableTools:       token.DisableTools,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -199,6 +236,103 @@ func DeleteToken(c *gin.Context) {
 	return
 }
 
+// RotateToken 为令牌生成新密钥，旧密钥和由其签发的派生令牌全部失效
+func RotateToken(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userId := c.GetInt("id")
+	token, err := model.GetTokenByIds(id, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	key, err := common.GenerateKey()
+	if err != nil {
+		common.ApiErrorMsg(c, "生成令牌失败")
+		common.SysLog("failed to generate token key: " + err.Error())
+		return
+	}
+	if err = token.RotateKey(key); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(userId, model.LogTypeManage, fmt.Sprintf("轮换令牌 %s (ID: %d) 的密钥", token.Name, token.Id))
+	common.ApiSuccess(c, token)
+}
+
+type DeriveTokenRequest struct {
+	Models    []string `json:"models"`
+	Quota     int      `json:"quota"`
+	ExpiresIn int64    `json:"expires_in"` // 有效期，单位秒，默认 1 小时
+}
+
+// DeriveToken 使用令牌签发短期派生令牌，派生令牌的模型和额度只能是父令牌的子集，消费计入父令牌
+func DeriveToken(c *gin.Context) {
+	if common.GetContextKeyString(c, constant.ContextKeyDerivedTokenId) != "" {
+		common.ApiErrorMsg(c, "派生令牌不能再签发派生令牌")
+		return
+	}
+	var req DeriveTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	parent, err := model.GetTokenByIds(c.GetInt("token_id"), c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Quota <= 0 {
+		common.ApiErrorMsg(c, "派生令牌的额度必须大于 0")
+		return
+	}
+	if !parent.UnlimitedQuota && req.Quota > parent.RemainQuota {
+		common.ApiErrorMsg(c, fmt.Sprintf("派生令牌的额度不能超过父令牌剩余额度 %s", logger.LogQuota(parent.RemainQuota)))
+		return
+	}
+	ttl := service.DerivedTokenDefaultTTL
+	if req.ExpiresIn < 0 {
+		common.ApiErrorMsg(c, "有效期不能为负数")
+		return
+	}
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if ttl > service.DerivedTokenMaxTTL {
+		common.ApiErrorMsg(c, fmt.Sprintf("派生令牌的有效期不能超过 %d 秒", int64(service.DerivedTokenMaxTTL.Seconds())))
+		return
+	}
+	if parent.ExpiredTime != -1 && time.Now().Add(ttl).Unix() > parent.ExpiredTime {
+		common.ApiErrorMsg(c, "派生令牌的有效期不能晚于父令牌的过期时间")
+		return
+	}
+	models := make([]string, 0, len(req.Models))
+	seen := make(map[string]bool)
+	parentLimits := parent.GetModelLimitsMap()
+	for _, m := range req.Models {
+		m = strings.TrimSpace(m)
+		if m == "" || seen[m] {
+			continue
+		}
+		if parent.ModelLimitsEnabled && !parentLimits[m] {
+			common.ApiErrorMsg(c, fmt.Sprintf("父令牌无权访问模型 %s", m))
+			return
+		}
+		seen[m] = true
+		models = append(models, m)
+	}
+	signed, expiresAt, err := service.MintDerivedToken(parent, models, req.Quota, ttl)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"token":      signed,
+		"expires_at": expiresAt,
+		"quota":      req.Quota,
+		"models":     models,
+	})
+}
+
 func UpdateToken(c *gin.Context) {
 	userId := c.GetInt("id")
 	statusOnly := c.Query("status_only")
@@ -220,6 +354,16 @@ func UpdateToken(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if statusOnly == "" {
+		if err = validateTokenBudget(&token); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if err = validateTokenScopes(&token); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
 	if token.Status == common.TokenStatusEnabled {
 		if cleanToken.Status == common.TokenStatusExpired && cleanToken.ExpiredTime <= common.GetTimestamp() && cleanToken.ExpiredTime != -1 {
 			c.JSON(http.StatusOK, gin.H{
@@ -235,6 +379,12 @@ func UpdateToken(c *gin.Context) {
 			})
 			return
 		}
+		if cleanToken.OrganizationId != 0 {
+			if err = checkOrganizationTokenAccess(cleanToken.OrganizationId, userId); err != nil {
+				common.ApiError(c, err)
+				return
+			}
+		}
 	}
 	if statusOnly != "" {
 		cleanToken.Status = token.Status
@@ -248,6 +398,13 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.ModelLimits = token.ModelLimits
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
+		cleanToken.BudgetPeriod = token.BudgetPeriod
+		cleanToken.BudgetQuota = token.BudgetQuota
+		cleanToken.BudgetSoftLimit = token.BudgetSoftLimit
+		cleanToken.Scopes = token.Scopes
+		cleanToken.MaxTokensLimit = token.MaxTokensLimit
+		cleanToken.DisableStream = token.DisableStream
+		cleanToken.DisableTools = token.DisableTools
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -262,6 +419,36 @@ func UpdateToken(c *gin.Context) {
 	return
 }
 
+// validateTokenBudget 校验令牌的周期预算设置，未设置周期时清空预算额度
+func validateTokenBudget(token *model.Token) error {
+	if err := model.ValidateTokenBudgetPeriod(token.BudgetPeriod); err != nil {
+		return err
+	}
+	if token.BudgetQuota < 0 {
+		return errors.New("周期预算不能为负数")
+	}
+	if token.BudgetPeriod == model.TokenBudgetPeriodNone {
+		token.BudgetQuota = 0
+		token.BudgetSoftLimit = false
+	} else if token.BudgetQuota == 0 {
+		return errors.New("请设置每个周期的预算额度")
+	}
+	return nil
+}
+
+// validateTokenScopes 校验令牌的作用域和请求参数限制
+func validateTokenScopes(token *model.Token) error {
+	scopes, err := model.NormalizeTokenScopes(token.Scopes)
+	if err != nil {
+		return err
+	}
+	token.Scopes = scopes
+	if token.MaxTokensLimit < 0 {
+		return errors.New("最大 max_tokens 不能为负数")
+	}
+	return nil
+}
+
 type TokenBatch struct {
 	Ids []int `json:"ids"`
 }
@@ -224,6 +224,10 @@ type CreemWebhookEvent struct {
 		Status   string            `json:"status"`
 		Metadata map[string]string `json:"metadata"`
 		Mode     string            `json:"mode"`
+		// 订阅相关字段：checkout.completed 中的 subscription，以及 subscription.* 事件中的订阅对象字段
+		Subscription         creemObjectRef `json:"subscription"`
+		LastTransactionId    string         `json:"last_transaction_id"`
+		CurrentPeriodEndDate string         `json:"current_period_end_date"`
 	} `json:"object"`
 }
 
@@ -285,6 +289,12 @@ func CreemWebhook(c *gin.Context) {
 	switch webhookEvent.EventType {
 	case "checkout.completed":
 		handleCheckoutCompleted(c, &webhookEvent)
+	case "subscription.paid":
+		handleCreemSubscriptionPaid(c, &webhookEvent)
+	case "subscription.canceled":
+		handleCreemSubscriptionCanceled(c, &webhookEvent)
+	case "subscription.expired":
+		handleCreemSubscriptionExpired(c, &webhookEvent)
 	default:
 		log.Printf("忽略Creem Webhook事件类型: %s", webhookEvent.EventType)
 		c.Status(http.StatusOK)
@@ -308,6 +318,12 @@ func handleCheckoutCompleted(c *gin.Context, event *CreemWebhookEvent) {
 		return
 	}
 
+	// 循环扣款的订单为订阅
+	if event.Object.Order.Type == "recurring" {
+		handleCreemSubscriptionCheckout(c, event, referenceId)
+		return
+	}
+
 	// 验证订单类型，目前只处理一次性付款
 	if event.Object.Order.Type != "onetime" {
 		log.Printf("暂不支持的订单类型: %s, 跳过处理", event.Object.Order.Type)
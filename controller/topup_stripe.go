@@ -150,6 +150,12 @@ func StripeWebhook(c *gin.Context) {
 		sessionCompleted(event)
 	case stripe.EventTypeCheckoutSessionExpired:
 		sessionExpired(event)
+	case stripe.EventTypeInvoicePaid:
+		stripeInvoicePaid(event)
+	case stripe.EventTypeCustomerSubscriptionUpdated:
+		stripeSubscriptionUpdated(event)
+	case stripe.EventTypeCustomerSubscriptionDeleted:
+		stripeSubscriptionDeleted(event)
 	default:
 		log.Printf("不支持的Stripe Webhook事件类型: %s\n", event.Type)
 	}
@@ -158,6 +164,10 @@ func StripeWebhook(c *gin.Context) {
 }
 
 func sessionCompleted(event stripe.Event) {
+	if event.GetObjectValue("mode") == string(stripe.CheckoutSessionModeSubscription) {
+		stripeSubscriptionCheckoutCompleted(event)
+		return
+	}
 	customerId := event.GetObjectValue("customer")
 	referenceId := event.GetObjectValue("client_reference_id")
 	status := event.GetObjectValue("status")
@@ -178,6 +188,10 @@ func sessionCompleted(event stripe.Event) {
 }
 
 func sessionExpired(event stripe.Event) {
+	// 未支付的订阅订单由定时任务关闭
+	if event.GetObjectValue("mode") == string(stripe.CheckoutSessionModeSubscription) {
+		return
+	}
 	referenceId := event.GetObjectValue("client_reference_id")
 	status := event.GetObjectValue("status")
 	if "expired" != status {
@@ -211,12 +225,10 @@ func sessionExpired(event stripe.Event) {
 }
 
 func genStripeLink(referenceId string, customerId string, email string, amount int64) (string, error) {
-	if !strings.HasPrefix(setting.StripeApiSecret, "sk_") && !strings.HasPrefix(setting.StripeApiSecret, "rk_") {
-		return "", fmt.Errorf("无效的Stripe API密钥")
+	if err := setStripeKey(); err != nil {
+		return "", err
 	}
 
-	stripe.Key = setting.StripeApiSecret
-
 	params := &stripe.CheckoutSessionParams{
 		ClientReferenceID: stripe.String(referenceId),
 		SuccessURL:        stripe.String(system_setting.ServerAddress + "/console/log"),
@@ -9,7 +9,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/console_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/sync/errgroup"
@@ -131,7 +134,7 @@ func fetchGroupData(ctx context.Context, client *http.Client, groupConfig map[st
 func GetUptimeKumaStatus(c *gin.Context) {
 	groups := console_setting.GetUptimeKumaGroups()
 	if len(groups) == 0 {
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "", "data": []UptimeGroupResult{}})
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "", "data": getNativeUptimeGroups()})
 		return
 	}
 
@@ -153,3 +156,44 @@ func GetUptimeKumaStatus(c *gin.Context) {
 	g.Wait()
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "", "data": results})
 }
+
+// getNativeUptimeGroups 未配置 Uptime Kuma 时使用内置状态页数据，状态码与 Uptime Kuma 保持一致
+func getNativeUptimeGroups() []UptimeGroupResult {
+	if !operation_setting.GetStatusPageSetting().Enabled {
+		return []UptimeGroupResult{}
+	}
+	page, err := service.GetStatusPage()
+	if err != nil {
+		common.SysError("failed to get status page: " + err.Error())
+		return []UptimeGroupResult{}
+	}
+	toMonitors := func(components []*service.StatusComponent) []Monitor {
+		monitors := make([]Monitor, 0, len(components))
+		for _, component := range components {
+			monitor := Monitor{Name: component.Name}
+			if component.Uptime24h >= 0 {
+				monitor.Uptime = component.Uptime24h / 100
+			}
+			switch component.Status {
+			case service.StatusOperational:
+				monitor.Status = 1
+			case service.StatusDown:
+				monitor.Status = 0
+			case service.StatusMaintenance:
+				monitor.Status = 3
+			default:
+				monitor.Status = 2
+			}
+			monitors = append(monitors, monitor)
+		}
+		return monitors
+	}
+	results := make([]UptimeGroupResult, 0, 2)
+	if len(page.Models) > 0 {
+		results = append(results, UptimeGroupResult{CategoryName: "模型", Monitors: toMonitors(page.Models)})
+	}
+	if len(page.Groups) > 0 {
+		results = append(results, UptimeGroupResult{CategoryName: "分组", Monitors: toMonitors(page.Groups)})
+	}
+	return results
+}
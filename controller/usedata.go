@@ -3,6 +3,7 @@ package controller
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
@@ -51,3 +52,78 @@ func GetUserQuotaDates(c *gin.Context) {
 	})
 	return
 }
+
+func parseUsageRollupQuery(c *gin.Context) (string, model.UsageRollupFilter, []string) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	filter := model.UsageRollupFilter{
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+		Username:       c.Query("username"),
+		TokenName:      c.Query("token_name"),
+		ModelName:      c.Query("model_name"),
+		Channel:        channel,
+		Group:          c.Query("group"),
+	}
+	var groupBy []string
+	if c.Query("group_by") != "" {
+		groupBy = strings.Split(c.Query("group_by"), ",")
+	}
+	granularity := c.DefaultQuery("granularity", model.UsageRollupGranularityHour)
+	return granularity, filter, groupBy
+}
+
+func GetUsageRollups(c *gin.Context) {
+	if !common.UsageRollupEnabled {
+		common.ApiErrorMsg(c, "用量预聚合未启用")
+		return
+	}
+	granularity, filter, groupBy := parseUsageRollupQuery(c)
+	stats, err := model.GetUsageRollupStats(granularity, filter, groupBy)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, stats)
+}
+
+func GetUserUsageRollups(c *gin.Context) {
+	if !common.UsageRollupEnabled {
+		common.ApiErrorMsg(c, "用量预聚合未启用")
+		return
+	}
+	granularity, filter, groupBy := parseUsageRollupQuery(c)
+	filter.UserId = c.GetInt("id")
+	filter.Username = ""
+	filter.Channel = 0
+	for _, dimension := range groupBy {
+		if strings.TrimSpace(dimension) == "channel" {
+			common.ApiErrorMsg(c, "不支持按渠道分组")
+			return
+		}
+	}
+	stats, err := model.GetUsageRollupStats(granularity, filter, groupBy)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, stats)
+}
+
+func BackfillUsageRollups(c *gin.Context) {
+	var req struct {
+		StartTimestamp int64 `json:"start_timestamp"`
+		EndTimestamp   int64 `json:"end_timestamp"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.StartTimestamp <= 0 || req.EndTimestamp <= req.StartTimestamp {
+		common.ApiErrorMsg(c, "invalid time range")
+		return
+	}
+	count, err := model.BackfillUsageRollups(c.Request.Context(), req.StartTimestamp, req.EndTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, count)
+}
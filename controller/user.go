@@ -618,6 +618,13 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
+	if updatedUser.CreditLimit < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "授信额度不能为负数",
+		})
+		return
+	}
 	originUser, err := model.GetUserById(updatedUser.Id, false)
 	if err != nil {
 		common.ApiError(c, err)
@@ -650,6 +657,10 @@ func UpdateUser(c *gin.Context) {
 		adminUsername := c.GetString("username")
 		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员 %s 将用户 %s (ID: %d) 的额度从 %s 修改为 %s", adminUsername, originUser.Username, originUser.Id, logger.LogQuota(originUser.Quota), logger.LogQuota(updatedUser.Quota)))
 	}
+	if originUser.CreditLimit != updatedUser.CreditLimit {
+		adminUsername := c.GetString("username")
+		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员 %s 将用户 %s (ID: %d) 的授信额度从 %s 修改为 %s", adminUsername, originUser.Username, originUser.Id, logger.LogQuota(originUser.CreditLimit), logger.LogQuota(updatedUser.CreditLimit)))
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
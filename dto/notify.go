@@ -10,9 +10,14 @@ type Notify struct {
 const ContentValueParam = "{{value}}"
 
 const (
-	NotifyTypeQuotaExceed   = "quota_exceed"
-	NotifyTypeChannelUpdate = "channel_update"
-	NotifyTypeChannelTest   = "channel_test"
+	NotifyTypeQuotaExceed    = "quota_exceed"
+	NotifyTypeChannelUpdate  = "channel_update"
+	NotifyTypeChannelTest    = "channel_test"
+	NotifyTypeModelVerify    = "model_verify"
+	NotifyTypeModelSync      = "model_sync"
+	NotifyTypeRatioSync      = "ratio_sync"
+	NotifyTypeQuotaDrift     = "quota_drift"
+	NotifyTypeBatchUpdateWAL = "batch_update_wal"
 )
 
 func NewNotify(t string, title string, content string, values []interface{}) Notify {
@@ -2,16 +2,21 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/tracing"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/controller"
 	"github.com/QuantumNous/new-api/logger"
@@ -92,6 +97,30 @@ func main() {
 	// 数据看板
 	go model.UpdateQuotaData()
 
+	// 用量预聚合
+	go model.UpdateUsageRollups()
+
+	// 状态页可用性样本
+	go service.SyncStatusSamples()
+
+	// 日志外部投递
+	model.InitLogSinkDispatcher()
+
+	// 多节点共享进行中的请求
+	service.InitInflightMonitor()
+
+	if common.MetricsEnabled {
+		service.InitMetrics()
+	}
+
+	var shutdownTracing func(context.Context) error
+	if common.TracingEnabled {
+		var err error
+		if shutdownTracing, err = tracing.Init(context.Background(), common.Version); err != nil {
+			common.SysError("failed to init tracing: " + err.Error())
+		}
+	}
+
 	if os.Getenv("CHANNEL_UPDATE_FREQUENCY") != "" {
 		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_UPDATE_FREQUENCY"))
 		if err != nil {
@@ -102,6 +131,17 @@ func main() {
 
 	go controller.AutomaticallyTestChannels()
 
+	go controller.AutomaticallySyncUpstreamRatios()
+
+	// 定价版本定时生效
+	go model.AutomaticallyApplyPricingVersions()
+
+	go service.AutomaticallyArchiveLogs()
+	go service.AutomaticallyReconcileQuotaLedger()
+	go service.AutomaticallyExpireSubscriptions()
+	go service.AutomaticallyProcessPostpaidInvoices()
+	go service.AutomaticallyCleanPayloadCaptures()
+
 	if common.IsMasterNode && constant.UpdateTask {
 		gopool.Go(func() {
 			controller.UpdateMidjourneyTaskBulk()
@@ -113,7 +153,12 @@ func main() {
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		common.BatchUpdateEnabled = true
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
+	}
+	// 重放上次崩溃或重启前未落库的额度增量
+	model.RecoverBatchUpdates()
+	if common.BatchUpdateEnabled {
 		model.InitBatchUpdater()
+		go service.MonitorBatchUpdateWAL()
 	}
 
 	if os.Getenv("ENABLE_PPROF") == "true" {
@@ -163,10 +208,39 @@ func main() {
 	// Log startup success message
 	common.LogStartupSuccess(startTime, port)
 
-	err = server.Run(":" + port)
-	if err != nil {
-		common.FatalLog("failed to start HTTP server: " + err.Error())
+	httpServer := &http.Server{
+		Addr:    ":" + port,
+		Handler: server,
+	}
+	go func() {
+		err := httpServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			common.FatalLog("failed to start HTTP server: " + err.Error())
+		}
+	}()
+
+	// 优雅退出：停止接收新请求，等待进行中的请求结束后刷新暂存的批量更新
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	common.SysLog("shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(common.GetEnvOrDefault("SHUTDOWN_TIMEOUT", 30))*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		common.SysError("failed to shutdown HTTP server gracefully: " + err.Error())
+	}
+	model.FlushBatchUpdates()
+	model.FlushUsageRollups()
+	model.FlushLogSinks(10 * time.Second)
+	if shutdownTracing != nil {
+		// 导出批处理器中尚未发送的 span
+		tracingCtx, tracingCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := shutdownTracing(tracingCtx); err != nil {
+			common.SysError("failed to shutdown tracing: " + err.Error())
+		}
+		tracingCancel()
 	}
+	common.SysLog("server exited")
 }
 
 func InjectUmamiAnalytics() {
@@ -178,6 +178,8 @@ func WssAuth(c *gin.Context) {
 
 func TokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		_, endSpan := startMiddlewareSpan(c, "auth")
+		defer endSpan()
 		// 先检测是否为ws
 		if c.Request.Header.Get("Sec-WebSocket-Protocol") != "" {
 			// Sec-WebSocket-Protocol: realtime, openai-insecure-api-key.sk-xxx, openai-beta.realtime-v1
@@ -217,7 +219,16 @@ func TokenAuth() func(c *gin.Context) {
 		key := c.Request.Header.Get("Authorization")
 		parts := make([]string, 0)
 		key = strings.TrimPrefix(key, "Bearer ")
-		if key == "" || key == "midjourney-proxy" {
+		var derivedClaims *service.DerivedTokenClaims
+		if service.IsDerivedToken(key) {
+			// 派生令牌校验签名后使用父令牌鉴权和计费
+			claims, parentKey, err := service.ParseDerivedToken(key)
+			if err != nil {
+				abortWithOpenAiMessage(c, http.StatusUnauthorized, err.Error())
+				return
+			}
+			derivedClaims, key = claims, parentKey
+		} else if key == "" || key == "midjourney-proxy" {
 			key = c.Request.Header.Get("mj-api-secret")
 			key = strings.TrimPrefix(key, "Bearer ")
 			key = strings.TrimPrefix(key, "sk-")
@@ -239,6 +250,9 @@ func TokenAuth() func(c *gin.Context) {
 			abortWithOpenAiMessage(c, http.StatusUnauthorized, err.Error())
 			return
 		}
+		if derivedClaims != nil {
+			token = service.ApplyDerivedTokenClaims(token, derivedClaims)
+		}
 
 		allowIpsMap := token.GetIpLimitsMap()
 		if len(allowIpsMap) != 0 {
@@ -249,6 +263,11 @@ func TokenAuth() func(c *gin.Context) {
 			}
 		}
 
+		if err = checkTokenScope(c, token); err != nil {
+			abortWithOpenAiMessage(c, http.StatusForbidden, err.Error())
+			return
+		}
+
 		userCache, err := model.GetUserCache(token.UserId)
 		if err != nil {
 			abortWithOpenAiMessage(c, http.StatusInternalServerError, err.Error())
@@ -285,6 +304,12 @@ func TokenAuth() func(c *gin.Context) {
 		if err != nil {
 			return
 		}
+		if derivedClaims != nil {
+			common.SetContextKey(c, constant.ContextKeyDerivedTokenId, derivedClaims.ID)
+			common.SetContextKey(c, constant.ContextKeyDerivedTokenQuota, derivedClaims.Quota)
+			common.SetContextKey(c, constant.ContextKeyDerivedTokenExpiresAt, derivedClaims.ExpiresAt.Unix())
+		}
+		endSpan()
 		c.Next()
 	}
 }
@@ -308,6 +333,12 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 		c.Set("token_model_limit_enabled", false)
 	}
 	c.Set("token_group", token.Group)
+	common.SetContextKey(c, constant.ContextKeyTokenOrganizationId, token.OrganizationId)
+	if token.HasBudget() {
+		common.SetContextKey(c, constant.ContextKeyTokenBudgetPeriod, token.BudgetPeriod)
+		common.SetContextKey(c, constant.ContextKeyTokenBudgetQuota, token.BudgetQuota)
+		common.SetContextKey(c, constant.ContextKeyTokenBudgetSoftLimit, token.BudgetSoftLimit)
+	}
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
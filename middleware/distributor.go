@@ -19,6 +19,7 @@ import (
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type ModelRequest struct {
@@ -28,6 +29,8 @@ type ModelRequest struct {
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		span, endSpan := startMiddlewareSpan(c, "distribute")
+		defer endSpan()
 		var channel *model.Channel
 		channelId, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId)
 		modelRequest, shouldSelectChannel, err := getModelRequest(c)
@@ -73,6 +76,15 @@ func Distribute() func(c *gin.Context) {
 				}
 			}
 
+			entitlement := service.GetSubscriptionEntitlement(c.GetInt("id"))
+			if entitlement != nil && len(entitlement.AllowedModels) > 0 && modelRequest.Model != "" {
+				matchName := ratio_setting.FormatMatchingModelName(modelRequest.Model)
+				if !entitlement.AllowedModels[matchName] && !entitlement.AllowedModels[modelRequest.Model] {
+					abortWithOpenAiMessage(c, http.StatusForbidden, "当前订阅套餐无权访问模型 "+modelRequest.Model)
+					return
+				}
+			}
+
 			if shouldSelectChannel {
 				if modelRequest.Model == "" {
 					abortWithOpenAiMessage(c, http.StatusBadRequest, "未指定模型名称，模型名称不能为空")
@@ -116,10 +128,22 @@ func Distribute() func(c *gin.Context) {
 					abortWithOpenAiMessage(c, http.StatusServiceUnavailable, fmt.Sprintf("分组 %s 下模型 %s 无可用渠道（distributor）", usingGroup, modelRequest.Model), string(types.ErrorCodeModelNotFound))
 					return
 				}
+				if entitlement != nil && len(entitlement.AllowedGroups) > 0 && !entitlement.AllowedGroups[selectGroup] {
+					abortWithOpenAiMessage(c, http.StatusForbidden, fmt.Sprintf("当前订阅套餐无权使用分组 %s", selectGroup))
+					return
+				}
 			}
 		}
 		common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
 		SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+		if channel != nil {
+			span.SetAttributes(
+				attribute.Int("channel.id", channel.Id),
+				attribute.Int("channel.type", channel.Type),
+				attribute.String("model", modelRequest.Model),
+			)
+		}
+		endSpan()
 		c.Next()
 	}
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/common/limiter"
 	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
 
 	"github.com/gin-gonic/gin"
@@ -166,8 +167,12 @@ func memoryRateLimitHandler(duration int64, totalMaxCount, successMaxCount int)
 // ModelRequestRateLimit 模型请求限流中间件
 func ModelRequestRateLimit() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		// 订阅套餐配置了限流时，即使未启用全局限流也按套餐限流
+		entitlement := service.GetSubscriptionEntitlement(c.GetInt("id"))
+		planRateLimited := entitlement != nil && (entitlement.RateLimitCount > 0 || entitlement.RateLimitSuccessCount > 0)
+
 		// 在每个请求时检查是否启用限流
-		if !setting.ModelRequestRateLimitEnabled {
+		if !setting.ModelRequestRateLimitEnabled && !planRateLimited {
 			c.Next()
 			return
 		}
@@ -189,6 +194,14 @@ func ModelRequestRateLimit() func(c *gin.Context) {
 			totalMaxCount = groupTotalCount
 			successMaxCount = groupSuccessCount
 		}
+		if planRateLimited {
+			if entitlement.RateLimitCount > 0 {
+				totalMaxCount = entitlement.RateLimitCount
+			}
+			if entitlement.RateLimitSuccessCount > 0 {
+				successMaxCount = entitlement.RateLimitSuccessCount
+			}
+		}
 
 		// 根据存储类型选择并执行限流处理器
 		if common.RedisEnabled {
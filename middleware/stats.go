@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"strconv"
 	"sync/atomic"
 
+	"github.com/QuantumNous/new-api/common/metrics"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,10 +21,18 @@ func StatsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 增加活跃连接数
 		atomic.AddInt64(&globalStats.activeConnections, 1)
+		metrics.HttpActiveConnections.Inc()
 
 		// 确保在请求结束时减少连接数
 		defer func() {
 			atomic.AddInt64(&globalStats.activeConnections, -1)
+			metrics.HttpActiveConnections.Dec()
+			// 使用路由模板而不是原始路径，避免标签基数过高
+			route := c.FullPath()
+			if route == "" {
+				route = "unmatched"
+			}
+			metrics.HttpRequestsTotal.Inc(c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
 		}()
 
 		c.Next()
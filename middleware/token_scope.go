@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenScopeRequest 校验令牌请求参数限制时需要读取的字段，覆盖 OpenAI、Claude、Responses 和 Gemini 格式
+type tokenScopeRequest struct {
+	Stream              bool  `json:"stream"`
+	MaxTokens           *uint `json:"max_tokens"`
+	MaxCompletionTokens *uint `json:"max_completion_tokens"`
+	MaxOutputTokens     *uint `json:"max_output_tokens"`
+	Tools               []any `json:"tools"`
+	Functions           []any `json:"functions"`
+	GenerationConfig    *struct {
+		MaxOutputTokens *uint `json:"maxOutputTokens"`
+	} `json:"generationConfig"`
+}
+
+func (r *tokenScopeRequest) maxTokens() *uint {
+	switch {
+	case r.MaxTokens != nil:
+		return r.MaxTokens
+	case r.MaxCompletionTokens != nil:
+		return r.MaxCompletionTokens
+	case r.MaxOutputTokens != nil:
+		return r.MaxOutputTokens
+	case r.GenerationConfig != nil:
+		return r.GenerationConfig.MaxOutputTokens
+	}
+	return nil
+}
+
+// getRequestTokenScope 根据路由对应的中转格式判断请求所需的作用域，ok 为 false 表示无法识别的接口
+func getRequestTokenScope(c *gin.Context) (scope string, ok bool) {
+	path := c.Request.URL.Path
+	switch {
+	case strings.Contains(path, "/dashboard/billing/") || strings.HasPrefix(path, "/api/usage/token"):
+		return model.TokenScopeReadUsage, true
+	case strings.HasPrefix(path, "/api/token/derive"):
+		// 派生令牌继承父令牌的作用域，签发时不需要额外作用域
+		return "", true
+	case c.Request.Method == http.MethodGet && (strings.HasPrefix(path, "/v1/models") ||
+		strings.HasPrefix(path, "/v1beta/models") || strings.HasPrefix(path, "/v1beta/openai/models")):
+		// 模型列表不需要作用域
+		return "", true
+	case strings.HasPrefix(path, "/v1/files"):
+		return model.TokenScopeFiles, true
+	case strings.HasPrefix(path, "/v1/messages"):
+		return model.TokenScopeChat, true
+	case strings.Contains(path, "/mj/") || strings.HasPrefix(path, "/suno/") ||
+		strings.HasPrefix(path, "/v1/video") || strings.HasPrefix(path, "/kling/") ||
+		strings.HasPrefix(path, "/jimeng"):
+		return model.TokenScopeTasks, true
+	}
+	switch relayconstant.Path2RelayMode(path) {
+	case relayconstant.RelayModeChatCompletions, relayconstant.RelayModeCompletions,
+		relayconstant.RelayModeModerations, relayconstant.RelayModeResponses:
+		return model.TokenScopeChat, true
+	case relayconstant.RelayModeEmbeddings, relayconstant.RelayModeRerank:
+		return model.TokenScopeEmbeddings, true
+	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits, relayconstant.RelayModeEdits:
+		return model.TokenScopeImages, true
+	case relayconstant.RelayModeAudioSpeech, relayconstant.RelayModeAudioTranscription, relayconstant.RelayModeAudioTranslation:
+		return model.TokenScopeAudio, true
+	case relayconstant.RelayModeRealtime:
+		return model.TokenScopeRealtime, true
+	case relayconstant.RelayModeGemini:
+		if strings.Contains(strings.ToLower(path), "embedcontent") {
+			return model.TokenScopeEmbeddings, true
+		}
+		return model.TokenScopeChat, true
+	}
+	return "", false
+}
+
+// checkTokenScope 校验令牌作用域和请求参数限制
+func checkTokenScope(c *gin.Context, token *model.Token) error {
+	if token.Scopes == "" && !token.HasRequestRestrictions() {
+		return nil
+	}
+	scope, ok := getRequestTokenScope(c)
+	if token.Scopes != "" {
+		if !ok {
+			return fmt.Errorf("该令牌设置了作用域，无法调用此接口")
+		}
+		if scope != "" && !token.HasScope(scope) {
+			return fmt.Errorf("该令牌没有 %s 作用域，可用作用域: %s", scope, token.Scopes)
+		}
+	}
+	// 请求参数限制只作用于对话类接口
+	if scope != model.TokenScopeChat || !token.HasRequestRestrictions() {
+		return nil
+	}
+	if token.DisableStream && strings.Contains(c.Request.URL.Path, "streamGenerateContent") {
+		return fmt.Errorf("该令牌不允许流式请求")
+	}
+	if !strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+		return nil
+	}
+	var req tokenScopeRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		return fmt.Errorf("无效的请求, %s", err.Error())
+	}
+	if token.DisableStream && req.Stream {
+		return fmt.Errorf("该令牌不允许流式请求")
+	}
+	if token.DisableTools && (len(req.Tools) > 0 || len(req.Functions) > 0) {
+		return fmt.Errorf("该令牌不允许工具调用")
+	}
+	if token.MaxTokensLimit > 0 {
+		maxTokens := req.maxTokens()
+		if maxTokens == nil || *maxTokens == 0 {
+			return fmt.Errorf("该令牌要求请求设置 max_tokens，且不超过 %d", token.MaxTokensLimit)
+		}
+		if *maxTokens > uint(token.MaxTokensLimit) {
+			return fmt.Errorf("max_tokens 超出令牌限制，最大允许 %d", token.MaxTokensLimit)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/tracing"
+	"github.com/QuantumNous/new-api/constant"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing 为中继请求创建根 span，若客户端携带 traceparent 则接入其调用链
+func Tracing() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !common.TracingEnabled {
+			c.Next()
+			return
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.StartServerSpan(ctx, c.Request.Method+" "+route,
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("request_id", c.GetString(common.RequestIdKey)),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", status),
+			attribute.Int("user.id", c.GetInt("id")),
+			attribute.Int("token.id", c.GetInt("token_id")),
+			attribute.String("model", common.GetContextKeyString(c, constant.ContextKeyOriginalModel)),
+			attribute.String("group", common.GetContextKeyString(c, constant.ContextKeyUsingGroup)),
+			attribute.Int("channel.id", c.GetInt("channel_id")),
+		)
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("status code %d", status))
+		}
+	}
+}
+
+// startMiddlewareSpan 为鉴权、分发等中间件创建 span，返回的函数需在 c.Next() 之前调用一次，
+// 并通过 defer 兜底提前返回的情况，重复调用不会产生副作用
+func startMiddlewareSpan(c *gin.Context, name string) (trace.Span, func()) {
+	span := tracing.Start(c, name)
+	return span, func() {
+		if c.IsAborted() {
+			span.SetStatus(codes.Error, fmt.Sprintf("aborted with status code %d", c.Writer.Status()))
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,500 @@
+package model
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	BatchUpdateWALFile  = "file"
+	BatchUpdateWALRedis = "redis"
+	BatchUpdateWALNone  = "none"
+
+	batchUpdateWALFileExt          = ".wal"
+	batchUpdateWALRedisPrefix      = "batch_update_wal:"
+	batchUpdateWALNodeLeaseTTL     = 30 * time.Second
+	batchUpdateWALClaimTTL         = 5 * time.Minute
+	batchUpdateCheckpointRetention = 7 * 24 * time.Hour
+)
+
+// batchUpdateWALRecord 一条尚未落库的增量
+type batchUpdateWALRecord struct {
+	Type  int
+	Id    int
+	Value int
+}
+
+// batchUpdateWAL 批量更新的预写日志。日志按段组织，每次刷新时封存当前段，
+// 该段的增量与检查点在同一事务中落库后删除，重放时已有检查点的段直接跳过，保证每段只生效一次
+type batchUpdateWAL interface {
+	Name() string
+	Append(record batchUpdateWALRecord) error
+	// Rotate 封存当前段并开启新段，返回被封存的段
+	Rotate() (string, error)
+	// RecoverableSegments 返回需要重放的段，不包括当前段
+	RecoverableSegments() ([]string, error)
+	Read(segment string) ([]batchUpdateWALRecord, error)
+	Remove(segment string) error
+	Close() error
+}
+
+// BatchUpdateCheckpoint 已落库的预写日志段
+type BatchUpdateCheckpoint struct {
+	Segment   string `json:"segment" gorm:"primaryKey;type:varchar(128)"`
+	Records   int    `json:"records"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func newBatchUpdateWALSegment(prefix string) string {
+	return fmt.Sprintf("%s%019d-%s", prefix, time.Now().UnixNano(), common.GetRandomString(6))
+}
+
+func newBatchUpdateWAL() (batchUpdateWAL, error) {
+	switch common.BatchUpdateWAL {
+	case BatchUpdateWALFile, "":
+		return newFileBatchUpdateWAL(common.BatchUpdateWALDir, common.BatchUpdateWALFsync)
+	case BatchUpdateWALRedis:
+		if !common.RedisEnabled {
+			return nil, errors.New("redis batch update wal requires REDIS_CONN_STRING")
+		}
+		return newRedisBatchUpdateWAL(batchUpdateWALNodeName()), nil
+	case BatchUpdateWALNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported batch update wal %q", common.BatchUpdateWAL)
+	}
+}
+
+func batchUpdateWALNodeName() string {
+	if name := os.Getenv("NODE_NAME"); name != "" {
+		return name
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "default"
+	}
+	return hostname
+}
+
+func formatBatchUpdateWALRecord(record batchUpdateWALRecord) string {
+	return fmt.Sprintf("%d %d %d", record.Type, record.Id, record.Value)
+}
+
+func parseBatchUpdateWALRecord(line string) (batchUpdateWALRecord, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return batchUpdateWALRecord{}, false
+	}
+	var values [3]int
+	for i, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return batchUpdateWALRecord{}, false
+		}
+		values[i] = value
+	}
+	if values[0] < 0 || values[0] >= BatchUpdateTypeCount {
+		return batchUpdateWALRecord{}, false
+	}
+	return batchUpdateWALRecord{Type: values[0], Id: values[1], Value: values[2]}, true
+}
+
+// fileBatchUpdateWAL 本地追加写文件，每段一个文件
+type fileBatchUpdateWAL struct {
+	mutex   sync.Mutex
+	dir     string
+	fsync   bool
+	segment string
+	file    *os.File
+	writer  *bufio.Writer
+	closed  bool
+}
+
+func newFileBatchUpdateWAL(dir string, fsync bool) (*fileBatchUpdateWAL, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	wal := &fileBatchUpdateWAL{dir: dir, fsync: fsync}
+	if err := wal.open(); err != nil {
+		return nil, err
+	}
+	return wal, nil
+}
+
+func (w *fileBatchUpdateWAL) Name() string {
+	return BatchUpdateWALFile
+}
+
+func (w *fileBatchUpdateWAL) path(segment string) string {
+	return filepath.Join(w.dir, segment+batchUpdateWALFileExt)
+}
+
+func (w *fileBatchUpdateWAL) open() error {
+	segment := newBatchUpdateWALSegment("")
+	file, err := os.OpenFile(w.path(segment), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w.segment = segment
+	w.file = file
+	w.writer = bufio.NewWriter(file)
+	return nil
+}
+
+func (w *fileBatchUpdateWAL) Append(record batchUpdateWALRecord) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return errors.New("batch update wal is closed")
+	}
+	if w.file == nil {
+		// 上次封存后未能开启新段，写入前重新打开
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	if _, err := w.writer.WriteString(formatBatchUpdateWALRecord(record) + "\n"); err != nil {
+		return err
+	}
+	// 每条都写入操作系统缓冲，进程崩溃不会丢失；fsync 用于防止机器掉电
+	if err := w.writer.Flush(); err != nil {
+		return err
+	}
+	if w.fsync {
+		return w.file.Sync()
+	}
+	return nil
+}
+
+func (w *fileBatchUpdateWAL) closeCurrent() (string, error) {
+	if w.file == nil {
+		return "", nil
+	}
+	segment := w.segment
+	err := w.writer.Flush()
+	if syncErr := w.file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	w.writer = nil
+	w.segment = ""
+	return segment, err
+}
+
+// Rotate 封存当前段失败时仍会尝试开启新段，开启失败时下次写入会再次重试，不会静默退化为仅内存暂存
+func (w *fileBatchUpdateWAL) Rotate() (string, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	segment, closeErr := w.closeCurrent()
+	var openErr error
+	for attempt := 0; attempt < 3; attempt++ {
+		if openErr = w.open(); openErr == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if closeErr != nil {
+		return segment, closeErr
+	}
+	return segment, openErr
+}
+
+func (w *fileBatchUpdateWAL) RecoverableSegments() ([]string, error) {
+	w.mutex.Lock()
+	current := w.segment
+	w.mutex.Unlock()
+	files, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]string, 0)
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, batchUpdateWALFileExt) {
+			continue
+		}
+		segment := strings.TrimSuffix(name, batchUpdateWALFileExt)
+		if segment != current {
+			segments = append(segments, segment)
+		}
+	}
+	sort.Strings(segments)
+	return segments, nil
+}
+
+func (w *fileBatchUpdateWAL) Read(segment string) ([]batchUpdateWALRecord, error) {
+	file, err := os.Open(w.path(segment))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records := make([]batchUpdateWALRecord, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 崩溃时最后一行可能只写了一半，无法解析的行直接跳过
+		if record, ok := parseBatchUpdateWALRecord(scanner.Text()); ok {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+func (w *fileBatchUpdateWAL) Remove(segment string) error {
+	err := os.Remove(w.path(segment))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (w *fileBatchUpdateWAL) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.closed = true
+	segment, err := w.closeCurrent()
+	if err != nil {
+		return err
+	}
+	// 正常关闭时当前段已刷新为空，直接删除
+	if segment != "" {
+		if info, statErr := os.Stat(w.path(segment)); statErr == nil && info.Size() == 0 {
+			return w.Remove(segment)
+		}
+	}
+	return nil
+}
+
+// redisBatchUpdateWAL 每段一个 Redis Stream，段名以节点名开头。
+// 节点存活期间定期续期租约，租约过期的节点留下的段可以被任意节点接管重放
+type redisBatchUpdateWAL struct {
+	mutex   sync.Mutex
+	node    string
+	segment string
+	stop    chan struct{}
+}
+
+func newRedisBatchUpdateWAL(node string) *redisBatchUpdateWAL {
+	wal := &redisBatchUpdateWAL{node: node, stop: make(chan struct{})}
+	wal.segment = newBatchUpdateWALSegment(node + ":")
+	wal.renewLease()
+	go func() {
+		ticker := time.NewTicker(batchUpdateWALNodeLeaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				wal.renewLease()
+			case <-wal.stop:
+				return
+			}
+		}
+	}()
+	return wal
+}
+
+func (w *redisBatchUpdateWAL) Name() string {
+	return BatchUpdateWALRedis
+}
+
+func (w *redisBatchUpdateWAL) leaseKey(node string) string {
+	return batchUpdateWALRedisPrefix + "node:" + node
+}
+
+func (w *redisBatchUpdateWAL) segmentsKey() string {
+	return batchUpdateWALRedisPrefix + "segments"
+}
+
+func (w *redisBatchUpdateWAL) streamKey(segment string) string {
+	return batchUpdateWALRedisPrefix + "segment:" + segment
+}
+
+func (w *redisBatchUpdateWAL) renewLease() {
+	err := common.RDB.Set(context.Background(), w.leaseKey(w.node), time.Now().Unix(), batchUpdateWALNodeLeaseTTL).Err()
+	if err != nil {
+		common.SysError("failed to renew batch update wal lease: " + err.Error())
+	}
+}
+
+func (w *redisBatchUpdateWAL) Append(record batchUpdateWALRecord) error {
+	w.mutex.Lock()
+	segment := w.segment
+	w.mutex.Unlock()
+	if segment == "" {
+		return errors.New("batch update wal is closed")
+	}
+	ctx := context.Background()
+	pipe := common.RDB.TxPipeline()
+	pipe.ZAddNX(ctx, w.segmentsKey(), &redis.Z{Score: float64(time.Now().UnixMilli()), Member: segment})
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: w.streamKey(segment),
+		Values: map[string]interface{}{"r": formatBatchUpdateWALRecord(record)},
+	})
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (w *redisBatchUpdateWAL) Rotate() (string, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	segment := w.segment
+	w.segment = newBatchUpdateWALSegment(w.node + ":")
+	return segment, nil
+}
+
+func (w *redisBatchUpdateWAL) RecoverableSegments() ([]string, error) {
+	ctx := context.Background()
+	w.mutex.Lock()
+	current := w.segment
+	w.mutex.Unlock()
+	all, err := common.RDB.ZRange(ctx, w.segmentsKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]string, 0)
+	for _, segment := range all {
+		if segment == current {
+			continue
+		}
+		separator := strings.LastIndex(segment, ":")
+		if separator < 0 {
+			continue
+		}
+		node := segment[:separator]
+		if node != w.node {
+			alive, err := common.RDB.Exists(ctx, w.leaseKey(node)).Result()
+			if err != nil {
+				return nil, err
+			}
+			if alive > 0 {
+				continue
+			}
+		}
+		// 多个节点同时接管时只有一个能拿到认领锁
+		claimed, err := common.RDB.SetNX(ctx, w.streamKey(segment)+":claim", w.node, batchUpdateWALClaimTTL).Result()
+		if err != nil {
+			return nil, err
+		}
+		if claimed {
+			segments = append(segments, segment)
+		}
+	}
+	return segments, nil
+}
+
+func (w *redisBatchUpdateWAL) Read(segment string) ([]batchUpdateWALRecord, error) {
+	messages, err := common.RDB.XRange(context.Background(), w.streamKey(segment), "-", "+").Result()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]batchUpdateWALRecord, 0, len(messages))
+	for _, message := range messages {
+		value, _ := message.Values["r"].(string)
+		if record, ok := parseBatchUpdateWALRecord(value); ok {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (w *redisBatchUpdateWAL) Remove(segment string) error {
+	ctx := context.Background()
+	pipe := common.RDB.TxPipeline()
+	pipe.Del(ctx, w.streamKey(segment), w.streamKey(segment)+":claim")
+	pipe.ZRem(ctx, w.segmentsKey(), segment)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (w *redisBatchUpdateWAL) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.segment == "" {
+		return nil
+	}
+	w.segment = ""
+	close(w.stop)
+	return common.RDB.Del(context.Background(), w.leaseKey(w.node)).Err()
+}
+
+func isBatchUpdateSegmentApplied(segment string) (bool, error) {
+	var count int64
+	err := DB.Model(&BatchUpdateCheckpoint{}).Where("segment = ?", segment).Count(&count).Error
+	return count > 0, err
+}
+
+func cleanBatchUpdateCheckpoints() {
+	cutoff := time.Now().Add(-batchUpdateCheckpointRetention).Unix()
+	if err := DB.Where("created_at < ?", cutoff).Delete(&BatchUpdateCheckpoint{}).Error; err != nil {
+		common.SysError("failed to clean batch update checkpoints: " + err.Error())
+	}
+}
+
+// replayBatchUpdateWAL 重放上次未能落库的预写日志段
+// newRecoveredQuotaLedgers 日志段只保存增量，逐笔流水随进程退出丢失，重放时按账户各补一条汇总流水
+func newRecoveredQuotaLedgers(stores []map[int]int, segment string) []map[int][]*QuotaLedgerEntry {
+	ledgers := make([]map[int][]*QuotaLedgerEntry, BatchUpdateTypeCount)
+	for i := range ledgers {
+		ledgers[i] = make(map[int][]*QuotaLedgerEntry)
+	}
+	ref := QuotaLedgerRef{Source: QuotaLedgerSourceRecovery, Reference: segment, Remark: "批量更新预写日志重放"}
+	for id, value := range stores[BatchUpdateTypeUserQuota] {
+		ledgers[BatchUpdateTypeUserQuota][id] = newPendingQuotaLedgerEntries(QuotaLedgerAccountUser, id, id, value, ref)
+	}
+	for id, value := range stores[BatchUpdateTypeTokenQuota] {
+		ledgers[BatchUpdateTypeTokenQuota][id] = newPendingQuotaLedgerEntries(QuotaLedgerAccountToken, id, 0, value, ref)
+	}
+	return ledgers
+}
+
+func replayBatchUpdateWAL(wal batchUpdateWAL) {
+	segments, err := wal.RecoverableSegments()
+	if err != nil {
+		common.SysError("failed to list batch update wal segments: " + err.Error())
+		return
+	}
+	for _, segment := range segments {
+		applied, err := isBatchUpdateSegmentApplied(segment)
+		if err != nil {
+			common.SysError("failed to check batch update checkpoint: " + err.Error())
+			continue
+		}
+		if !applied {
+			records, err := wal.Read(segment)
+			if err != nil {
+				common.SysError(fmt.Sprintf("failed to read batch update wal segment %s: %s", segment, err.Error()))
+				continue
+			}
+			stores := make([]map[int]int, BatchUpdateTypeCount)
+			for i := range stores {
+				stores[i] = make(map[int]int)
+			}
+			for _, record := range records {
+				stores[record.Type][record.Id] += record.Value
+			}
+			if err = applyBatchUpdateSegment(stores, newRecoveredQuotaLedgers(stores, segment), segment, len(records)); err != nil {
+				common.SysError(fmt.Sprintf("failed to replay batch update wal segment %s: %s", segment, err.Error()))
+				continue
+			}
+			common.SysLog(fmt.Sprintf("replayed %d batch update records from wal segment %s", len(records), segment))
+		}
+		if err = wal.Remove(segment); err != nil {
+			common.SysError(fmt.Sprintf("failed to remove batch update wal segment %s: %s", segment, err.Error()))
+		}
+	}
+}
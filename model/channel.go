@@ -1003,3 +1003,17 @@ func CountChannelsGroupByType() (map[int64]int64, error) {
 	}
 	return counts, nil
 }
+
+type ChannelStatusInfo struct {
+	Id     int    `json:"id"`
+	Name   string `json:"name"`
+	Type   int    `json:"type"`
+	Status int    `json:"status"`
+}
+
+// GetChannelStatusList 只查询监控需要的字段，避免读取渠道密钥
+func GetChannelStatusList() ([]ChannelStatusInfo, error) {
+	var channels []ChannelStatusInfo
+	err := DB.Model(&Channel{}).Select("id, name, type, status").Order("id").Scan(&channels).Error
+	return channels, err
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	ChannelModelSyncStatusSynced   = "synced"   // 已按差异更新渠道模型
+	ChannelModelSyncStatusReported = "reported" // 仅记录差异，未修改渠道
+	ChannelModelSyncStatusError    = "error"
+)
+
+// ChannelModelSync 上游模型同步记录，模型列表均以逗号分隔
+type ChannelModelSync struct {
+	Id             int    `json:"id"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint;index"`
+	ChannelId      int    `json:"channel_id" gorm:"index"`
+	ChannelName    string `json:"channel_name" gorm:"type:varchar(255)"`
+	Status         string `json:"status" gorm:"type:varchar(16)"`
+	AddedModels    string `json:"added_models" gorm:"type:text"`    // 上游新增、渠道中没有的模型
+	RemovedModels  string `json:"removed_models" gorm:"type:text"`  // 渠道中有、上游已不存在的模型
+	AppliedAdded   string `json:"applied_added" gorm:"type:text"`   // 实际自动添加的模型
+	AppliedRemoved string `json:"applied_removed" gorm:"type:text"` // 实际自动移除的模型
+	Message        string `json:"message" gorm:"type:text"`
+}
+
+func (s *ChannelModelSync) Insert() error {
+	return DB.Create(s).Error
+}
+
+func GetChannelModelSyncs(channelId int, status string, startIdx int, num int) (syncs []*ChannelModelSync, total int64, err error) {
+	tx := DB.Model(&ChannelModelSync{})
+	if channelId != 0 {
+		tx = tx.Where("channel_id = ?", channelId)
+	}
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&syncs).Error
+	return syncs, total, err
+}
+
+// GetLatestChannelModelSync 获取渠道最近一次同步记录，没有记录时返回 nil
+func GetLatestChannelModelSync(channelId int) (*ChannelModelSync, error) {
+	var syncs []*ChannelModelSync
+	if err := DB.Where("channel_id = ?", channelId).Order("id desc").Limit(1).Find(&syncs).Error; err != nil {
+		return nil, err
+	}
+	if len(syncs) == 0 {
+		return nil, nil
+	}
+	return syncs[0], nil
+}
+
+// DeleteOldChannelModelSyncs 分批删除早于 targetTimestamp 的记录
+func DeleteOldChannelModelSyncs(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
+	var total int64 = 0
+	for {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		result := DB.Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&ChannelModelSync{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+	return total, nil
+}
+
+// UpdateModels 只更新渠道的模型列表并重建 abilities
+func (channel *Channel) UpdateModels(models []string) error {
+	channel.Models = strings.Join(models, ",")
+	if err := DB.Model(channel).Update("models", channel.Models).Error; err != nil {
+		return err
+	}
+	return channel.UpdateAbilities(nil)
+}
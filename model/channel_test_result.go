@@ -0,0 +1,99 @@
+package model
+
+import (
+	"context"
+)
+
+// ChannelTestResult 渠道单项能力的测试结果
+type ChannelTestResult struct {
+	Id         int    `json:"id"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+	ChannelId  int    `json:"channel_id" gorm:"index"`
+	Capability string `json:"capability" gorm:"type:varchar(32);index"`
+	ModelName  string `json:"model_name" gorm:"type:varchar(128)"`
+	Success    bool   `json:"success"`
+	StatusCode int    `json:"status_code"`
+	UseTimeMs  int64  `json:"use_time_ms"`
+	Message    string `json:"message" gorm:"type:text"`
+}
+
+// ChannelCapabilityStat 渠道单项能力在一段时间内的测试统计
+type ChannelCapabilityStat struct {
+	Capability string `json:"capability"`
+	Total      int64  `json:"total"`
+	Passed     int64  `json:"passed"`
+}
+
+// ChannelCapabilityTrend 按时间分桶的测试统计
+type ChannelCapabilityTrend struct {
+	Bucket     int64  `json:"bucket"`
+	Capability string `json:"capability"`
+	Total      int64  `json:"total"`
+	Passed     int64  `json:"passed"`
+}
+
+func BatchInsertChannelTestResults(results []*ChannelTestResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	return DB.Create(&results).Error
+}
+
+func GetChannelTestResults(channelId int, capability string, startIdx int, num int) (results []*ChannelTestResult, total int64, err error) {
+	tx := DB.Model(&ChannelTestResult{}).Where("channel_id = ?", channelId)
+	if capability != "" {
+		tx = tx.Where("capability = ?", capability)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&results).Error
+	return results, total, err
+}
+
+// GetLatestChannelTestResults 获取渠道每项能力最近一次的测试结果
+func GetLatestChannelTestResults(channelId int) (results []*ChannelTestResult, err error) {
+	subQuery := DB.Model(&ChannelTestResult{}).Select("MAX(id)").Where("channel_id = ?", channelId).Group("capability")
+	err = DB.Where("id IN (?)", subQuery).Find(&results).Error
+	return results, err
+}
+
+func GetChannelCapabilityStats(channelId int, startTimestamp int64) (stats []*ChannelCapabilityStat, err error) {
+	err = DB.Model(&ChannelTestResult{}).
+		Select("capability, COUNT(*) AS total, SUM(CASE WHEN success = ? THEN 1 ELSE 0 END) AS passed", true).
+		Where("channel_id = ? AND created_at >= ?", channelId, startTimestamp).
+		Group("capability").
+		Scan(&stats).Error
+	return stats, err
+}
+
+// GetChannelCapabilityTrend 以 bucketSeconds 为粒度统计各能力的通过次数
+func GetChannelCapabilityTrend(channelId int, capability string, startTimestamp int64, bucketSeconds int64) (trend []*ChannelCapabilityTrend, err error) {
+	tx := DB.Model(&ChannelTestResult{}).
+		Select("created_at - created_at % ? AS bucket, capability, COUNT(*) AS total, SUM(CASE WHEN success = ? THEN 1 ELSE 0 END) AS passed", bucketSeconds, true).
+		Where("channel_id = ? AND created_at >= ?", channelId, startTimestamp)
+	if capability != "" {
+		tx = tx.Where("capability = ?", capability)
+	}
+	err = tx.Group("bucket, capability").Order("bucket").Scan(&trend).Error
+	return trend, err
+}
+
+// DeleteOldChannelTestResults 分批删除早于 targetTimestamp 的记录
+func DeleteOldChannelTestResults(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
+	var total int64 = 0
+	for {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		result := DB.Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&ChannelTestResult{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+	return total, nil
+}
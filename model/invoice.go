@@ -0,0 +1,299 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"gorm.io/gorm"
+)
+
+const (
+	InvoiceStatusIssued  = "issued"
+	InvoiceStatusPaid    = "paid"
+	InvoiceStatusOverdue = "overdue"
+)
+
+// InvoiceItem 账单明细，按令牌和模型汇总
+type InvoiceItem struct {
+	TokenName        string `json:"token_name"`
+	ModelName        string `json:"model_name"`
+	RequestCount     int    `json:"request_count"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	Quota            int    `json:"quota"`
+}
+
+// Invoice 后付费用户的月度账单，付款后按账单额度恢复用户余额
+type Invoice struct {
+	Id           int            `json:"id"`
+	InvoiceNo    string         `json:"invoice_no" gorm:"type:varchar(32);uniqueIndex"`
+	UserId       int            `json:"user_id" gorm:"uniqueIndex:idx_invoice_user_period,priority:1"`
+	PeriodStart  int64          `json:"period_start" gorm:"bigint;uniqueIndex:idx_invoice_user_period,priority:2"`
+	PeriodEnd    int64          `json:"period_end" gorm:"bigint"`
+	Quota        int            `json:"quota"`
+	Amount       float64        `json:"amount"`
+	RequestCount int            `json:"request_count"`
+	Items        string         `json:"-" gorm:"type:text"`
+	Status       string         `json:"status" gorm:"type:varchar(16);index"`
+	DueTime      int64          `json:"due_time" gorm:"bigint;index"`
+	PaidTime     int64          `json:"paid_time" gorm:"bigint"`
+	Remark       string         `json:"remark" gorm:"type:varchar(255)"`
+	CreatedTime  int64          `json:"created_time" gorm:"bigint"`
+	UpdatedTime  int64          `json:"updated_time" gorm:"bigint"`
+	ItemList     []*InvoiceItem `json:"items,omitempty" gorm:"-"`
+}
+
+func (invoice *Invoice) fillItems() {
+	if invoice.Items == "" {
+		return
+	}
+	if err := common.UnmarshalJsonStr(invoice.Items, &invoice.ItemList); err != nil {
+		common.SysError(fmt.Sprintf("failed to unmarshal invoice %d items: %s", invoice.Id, err.Error()))
+	}
+}
+
+// ErrPostpaidStatementIncomplete 额度流水未覆盖账单周期，且消费日志不完整，无法出账
+var ErrPostpaidStatementIncomplete = errors.New("账单周期内的消费日志不完整且没有可用的额度流水，无法生成账单")
+
+// postpaidUnlistedModelName 流水中有消费但日志中没有明细的部分，作为单独条目列入账单
+const postpaidUnlistedModelName = "未记录明细的消费"
+
+// GetPostpaidStatement 按令牌和模型汇总用户在 [start, end) 内的个人消费，退款从对应条目中扣除。
+// 团队令牌的消费从团队额度池扣除，不计入个人账单。
+// 账单总额以额度流水为准，日志缺失的部分单列一条；流水不可用时只有消费日志完整才能出账
+func GetPostpaidStatement(userId int, start int64, end int64) ([]*InvoiceItem, error) {
+	var orgTokenIds []int
+	err := DB.Unscoped().Model(&Token{}).Where("user_id = ? AND organization_id <> 0", userId).Pluck("id", &orgTokenIds).Error
+	if err != nil {
+		return nil, err
+	}
+	var items []*InvoiceItem
+	tx := LOG_DB.Table("logs").
+		Select("token_name, model_name, "+
+			"SUM(CASE WHEN type = ? THEN 1 ELSE 0 END) AS request_count, "+
+			"SUM(CASE WHEN type = ? THEN prompt_tokens ELSE 0 END) AS prompt_tokens, "+
+			"SUM(CASE WHEN type = ? THEN completion_tokens ELSE 0 END) AS completion_tokens, "+
+			"SUM(CASE WHEN type = ? THEN -quota ELSE quota END) AS quota",
+			LogTypeConsume, LogTypeConsume, LogTypeConsume, LogTypeRefund).
+		Where("user_id = ? AND type IN ? AND created_at >= ? AND created_at < ?",
+			userId, []int{LogTypeConsume, LogTypeRefund}, start, end)
+	if len(orgTokenIds) > 0 {
+		tx = tx.Where("token_id NOT IN ?", orgTokenIds)
+	}
+	err = tx.Group("token_name, model_name").
+		Order("quota desc").
+		Scan(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ledgerQuota, ok, err := getPostpaidLedgerQuota(userId, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		complete, err := isConsumeLogComplete(start, end)
+		if err != nil {
+			return nil, err
+		}
+		if !complete {
+			return nil, ErrPostpaidStatementIncomplete
+		}
+		return items, nil
+	}
+	logQuota := int64(0)
+	for _, item := range items {
+		logQuota += int64(item.Quota)
+	}
+	if diff := ledgerQuota - logQuota; diff != 0 {
+		items = append(items, &InvoiceItem{
+			ModelName: postpaidUnlistedModelName,
+			Quota:     int(diff),
+		})
+	}
+	return items, nil
+}
+
+// getPostpaidLedgerQuota 根据用户账户的额度流水计算 [start, end) 内的净消费，ok 为 false 表示流水未覆盖该周期
+func getPostpaidLedgerQuota(userId int, start int64, end int64) (quota int64, ok bool, err error) {
+	if !operation_setting.GetQuotaLedgerSetting().Enabled {
+		return 0, false, nil
+	}
+	var firstCreatedAt []int64
+	err = DB.Model(&QuotaLedgerEntry{}).Order("id asc").Limit(1).Pluck("created_at", &firstCreatedAt).Error
+	if err != nil || len(firstCreatedAt) == 0 || firstCreatedAt[0] > start {
+		return 0, false, err
+	}
+	err = DB.Model(&QuotaLedgerEntry{}).Select("COALESCE(SUM(-delta), 0)").
+		Where("account_type = ? AND account_id = ? AND source IN ? AND created_at >= ? AND created_at < ?",
+			QuotaLedgerAccountUser, userId, []string{
+				QuotaLedgerSourceConsume,
+				QuotaLedgerSourcePreConsume,
+				QuotaLedgerSourceRefund,
+				QuotaLedgerSourceTask,
+				QuotaLedgerSourceRecovery,
+			}, start, end).
+		Scan(&quota).Error
+	return quota, err == nil, err
+}
+
+// isConsumeLogComplete 周期内的消费日志是否完整：未关闭消费日志、未停止写入数据库，且没有被归档删除
+func isConsumeLogComplete(start int64, end int64) (bool, error) {
+	if !common.LogConsumeEnabled || !shouldWriteLogToDB() {
+		return false, nil
+	}
+	var archived int64
+	err := LOG_DB.Model(&LogArchive{}).
+		Where("status = ? AND start_timestamp < ? AND end_timestamp >= ?", LogArchiveStatusArchived, end, start).
+		Count(&archived).Error
+	return archived == 0, err
+}
+
+// GenerateInvoice 为用户生成 [start, end) 的账单，created 表示是否新生成。同一周期重复生成直接返回已有账单，没有消费时不生成
+func GenerateInvoice(userId int, start int64, end int64, dueTime int64) (invoice *Invoice, created bool, err error) {
+	var existing Invoice
+	err = DB.Where("user_id = ? AND period_start = ?", userId, start).First(&existing).Error
+	if err == nil {
+		return &existing, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	items, err := GetPostpaidStatement(userId, start, end)
+	if err != nil {
+		return nil, false, err
+	}
+	quota, requestCount := 0, 0
+	for _, item := range items {
+		quota += item.Quota
+		requestCount += item.RequestCount
+	}
+	if quota <= 0 {
+		return nil, false, nil
+	}
+	itemsJson, err := common.Marshal(items)
+	if err != nil {
+		return nil, false, err
+	}
+	now := common.GetTimestamp()
+	invoice = &Invoice{
+		InvoiceNo:    fmt.Sprintf("INV%d%06d", start, userId),
+		UserId:       userId,
+		PeriodStart:  start,
+		PeriodEnd:    end,
+		Quota:        quota,
+		Amount:       float64(quota) / common.QuotaPerUnit,
+		RequestCount: requestCount,
+		Items:        string(itemsJson),
+		Status:       InvoiceStatusIssued,
+		DueTime:      dueTime,
+		CreatedTime:  now,
+		UpdatedTime:  now,
+		ItemList:     items,
+	}
+	if err = DB.Create(invoice).Error; err != nil {
+		return nil, false, err
+	}
+	return invoice, true, nil
+}
+
+func GetInvoiceById(id int) (*Invoice, error) {
+	var invoice Invoice
+	if err := DB.First(&invoice, id).Error; err != nil {
+		return nil, err
+	}
+	invoice.fillItems()
+	return &invoice, nil
+}
+
+func GetInvoices(userId int, status string, startIdx int, num int) (invoices []*Invoice, total int64, err error) {
+	tx := DB.Model(&Invoice{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&invoices).Error
+	return invoices, total, err
+}
+
+// GetPostpaidUserIds 设置了授信额度的用户
+func GetPostpaidUserIds() ([]int, error) {
+	var ids []int
+	err := DB.Model(&User{}).Where("credit_limit > 0").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// MarkOverdueInvoices 将超过付款期限的账单标记为逾期，返回涉及的用户
+func MarkOverdueInvoices(now int64) ([]int, error) {
+	var userIds []int
+	err := DB.Model(&Invoice{}).
+		Where("status = ? AND due_time < ?", InvoiceStatusIssued, now).
+		Distinct().Pluck("user_id", &userIds).Error
+	if err != nil || len(userIds) == 0 {
+		return nil, err
+	}
+	err = DB.Model(&Invoice{}).
+		Where("status = ? AND due_time < ?", InvoiceStatusIssued, now).
+		Updates(map[string]interface{}{"status": InvoiceStatusOverdue, "updated_time": now}).Error
+	return userIds, err
+}
+
+// MarkInvoicePaid 确认收款，按账单额度恢复用户余额。没有其他逾期账单时解除暂停
+func MarkInvoicePaid(id int, remark string) error {
+	var invoice Invoice
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&invoice, id).Error; err != nil {
+			return err
+		}
+		// 以未付款状态作为更新条件，并发确认收款时只有一次能更新成功并恢复余额
+		now := common.GetTimestamp()
+		result := tx.Model(&Invoice{}).Where("id = ? AND status <> ?", id, InvoiceStatusPaid).Updates(map[string]interface{}{
+			"status":       InvoiceStatusPaid,
+			"paid_time":    now,
+			"remark":       remark,
+			"updated_time": now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("账单已付款")
+		}
+		if err := tx.Model(&User{}).Where("id = ?", invoice.UserId).Update("quota", gorm.Expr("quota + ?", invoice.Quota)).Error; err != nil {
+			return err
+		}
+		return RecordUserQuotaLedger(tx, invoice.UserId, invoice.Quota, QuotaLedgerRef{
+			Source:    QuotaLedgerSourceInvoice,
+			Reference: invoice.InvoiceNo,
+			Remark:    "账单付款",
+		})
+	})
+	if err != nil {
+		return err
+	}
+	var overdue int64
+	if err = DB.Model(&Invoice{}).Where("user_id = ? AND status = ?", invoice.UserId, InvoiceStatusOverdue).Count(&overdue).Error; err != nil {
+		return err
+	}
+	if overdue == 0 {
+		return SetUserCreditSuspended(invoice.UserId, false)
+	}
+	return invalidateUserCache(invoice.UserId)
+}
+
+// SetUserCreditSuspended 因账单逾期暂停或恢复用户调用
+func SetUserCreditSuspended(userId int, suspended bool) error {
+	if err := DB.Model(&User{}).Where("id = ?", userId).Update("credit_suspended", suspended).Error; err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
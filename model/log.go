@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/metrics"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/types"
 
@@ -96,6 +97,27 @@ func RecordLog(userId int, logType int, content string) {
 	}
 }
 
+// RecordTaskRefundLog 记录异步任务失败或多扣费用的退还，额度为正数，账单汇总时从对应模型的消费中扣除。
+// 团队令牌提交的任务退回团队额度池，不计入个人账单，仍按系统日志记录
+func RecordTaskRefundLog(userId int, organizationId int, modelName string, quota int, content string) {
+	if organizationId != 0 {
+		RecordLog(userId, LogTypeSystem, content)
+		return
+	}
+	username, _ := GetUsernameById(userId, false)
+	log := &Log{
+		UserId:    userId,
+		Username:  username,
+		CreatedAt: common.GetTimestamp(),
+		Type:      LogTypeRefund,
+		Content:   content,
+		ModelName: modelName,
+		Quota:     quota,
+	}
+	// 退款日志参与账单和用量统计，与消费日志一样投递到 sink 并计入用量汇总
+	persistLog(nil, log)
+}
+
 func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string, tokenName string, content string, tokenId int, useTimeSeconds int,
 	isStream bool, group string, other map[string]interface{}) {
 	logger.LogInfo(c, fmt.Sprintf("record error log: userId=%d, channelId=%d, modelName=%s, tokenName=%s, content=%s", userId, channelId, modelName, tokenName, content))
@@ -121,10 +143,23 @@ func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string,
 		Ip:               c.ClientIP(),
 		Other:            otherStr,
 	}
-	err := LOG_DB.Create(log).Error
-	if err != nil {
-		logger.LogError(c, "failed to record log: "+err.Error())
+	persistLog(c, log)
+}
+
+// persistLog 写入日志库并投递到外部 sink，启用 sink 时可以关闭数据库写入。后台任务没有请求上下文时 c 为 nil
+func persistLog(c *gin.Context, log *Log) {
+	defer emitLogToSinks(log)
+	if shouldWriteLogToDB() {
+		if err := LOG_DB.Create(log).Error; err != nil {
+			if c == nil {
+				common.SysLog("failed to record log: " + err.Error())
+			} else {
+				logger.LogError(c, "failed to record log: "+err.Error())
+			}
+			return
+		}
 	}
+	recordUsageRollup(log)
 }
 
 type RecordConsumeLogParams struct {
@@ -143,6 +178,7 @@ type RecordConsumeLogParams struct {
 }
 
 func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams) {
+	metrics.ObserveConsume(params.ModelName, params.Group, params.ChannelId, params.PromptTokens, params.CompletionTokens, params.Quota)
 	if !common.LogConsumeEnabled {
 		return
 	}
@@ -169,10 +205,7 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		Ip:               c.ClientIP(),
 		Other:            otherStr,
 	}
-	err := LOG_DB.Create(log).Error
-	if err != nil {
-		logger.LogError(c, "failed to record log: "+err.Error())
-	}
+	persistLog(c, log)
 	if common.DataExportEnabled {
 		gopool.Go(func() {
 			LogQuotaData(userId, username, params.ModelName, params.Quota, common.GetTimestamp(), params.PromptTokens+params.CompletionTokens)
@@ -352,7 +385,8 @@ type Stat struct {
 }
 
 func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string) (stat Stat) {
-	tx := LOG_DB.Table("logs").Select("sum(quota) quota")
+	// 退款从消费额度中扣除
+	tx := LOG_DB.Table("logs").Select("sum(CASE WHEN type = ? THEN -quota ELSE quota END) quota", LogTypeRefund)
 
 	// 为rpm和tpm创建单独的查询
 	rpmTpmQuery := LOG_DB.Table("logs").Select("count(*) rpm, sum(prompt_tokens) + sum(completion_tokens) tpm")
@@ -384,13 +418,27 @@ func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelNa
 		rpmTpmQuery = rpmTpmQuery.Where(logGroupCol+" = ?", group)
 	}
 
-	tx = tx.Where("type = ?", LogTypeConsume)
+	tx = tx.Where("type IN ?", []int{LogTypeConsume, LogTypeRefund})
 	rpmTpmQuery = rpmTpmQuery.Where("type = ?", LogTypeConsume)
 
 	// 只统计最近60秒的rpm和tpm
 	rpmTpmQuery = rpmTpmQuery.Where("created_at >= ?", time.Now().Add(-60*time.Second).Unix())
 
 	// 执行查询
+	if common.UsageRollupEnabled {
+		filter := UsageRollupFilter{
+			StartTimestamp: startTimestamp,
+			EndTimestamp:   endTimestamp,
+			Username:       username,
+			TokenName:      tokenName,
+			ModelName:      modelName,
+			Channel:        channel,
+			Group:          group,
+		}
+		stat.Quota = int(sumUsageWithRollup(filter).Quota)
+		stat.Rpm, stat.Tpm = getRecentUsage(filter)
+		return stat
+	}
 	tx.Scan(&stat)
 	rpmTpmQuery.Scan(&stat)
 
@@ -398,6 +446,15 @@ func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelNa
 }
 
 func SumUsedToken(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string) (token int) {
+	if common.UsageRollupEnabled {
+		return int(sumUsageWithRollup(UsageRollupFilter{
+			StartTimestamp: startTimestamp,
+			EndTimestamp:   endTimestamp,
+			Username:       username,
+			TokenName:      tokenName,
+			ModelName:      modelName,
+		}).Tokens)
+	}
 	tx := LOG_DB.Table("logs").Select("ifnull(sum(prompt_tokens),0) + ifnull(sum(completion_tokens),0)")
 	if username != "" {
 		tx = tx.Where("username = ?", username)
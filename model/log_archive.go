@@ -0,0 +1,132 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	LogArchiveStatusArchived = "archived" // 已归档，日志库中的原始数据已删除
+	LogArchiveStatusExported = "exported" // 已导出，日志库中仍保留原始数据
+	LogArchiveStatusRestored = "restored" // 已重新导入日志库
+)
+
+// LogArchive 记录一个已导出到冷存储的日志分区
+type LogArchive struct {
+	Id             int    `json:"id"`
+	StartTimestamp int64  `json:"start_timestamp" gorm:"bigint;index"`
+	EndTimestamp   int64  `json:"end_timestamp" gorm:"bigint;index"`
+	Storage        string `json:"storage" gorm:"type:varchar(16)"`
+	ObjectKey      string `json:"object_key" gorm:"type:varchar(512)"`
+	Format         string `json:"format" gorm:"type:varchar(16)"`
+	LogCount       int64  `json:"log_count"`
+	MinLogId       int    `json:"min_log_id"`
+	MaxLogId       int    `json:"max_log_id"`
+	Size           int64  `json:"size"`
+	Checksum       string `json:"checksum" gorm:"type:varchar(64)"` // 压缩文件的 sha256
+	Status         string `json:"status" gorm:"type:varchar(16);index"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint"`
+	RestoredAt     int64  `json:"restored_at" gorm:"bigint"`
+}
+
+func (a *LogArchive) Insert() error {
+	a.CreatedAt = common.GetTimestamp()
+	return LOG_DB.Create(a).Error
+}
+
+func (a *LogArchive) Update() error {
+	return LOG_DB.Save(a).Error
+}
+
+func GetLogArchiveById(id int) (*LogArchive, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	archive := LogArchive{Id: id}
+	err := LOG_DB.First(&archive, "id = ?", id).Error
+	return &archive, err
+}
+
+func GetLogArchives(startTimestamp int64, endTimestamp int64, startIdx int, num int) (archives []*LogArchive, total int64, err error) {
+	tx := LOG_DB.Model(&LogArchive{})
+	if startTimestamp != 0 {
+		tx = tx.Where("end_timestamp > ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("start_timestamp <= ?", endTimestamp)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("start_timestamp desc").Limit(num).Offset(startIdx).Find(&archives).Error
+	return archives, total, err
+}
+
+// GetLogArchiveByRange 返回 [startTimestamp, endTimestamp) 最近一次的归档记录，包括已恢复的归档，没有则返回 nil
+func GetLogArchiveByRange(startTimestamp int64, endTimestamp int64) (*LogArchive, error) {
+	var archives []*LogArchive
+	err := LOG_DB.Where("start_timestamp = ? and end_timestamp = ?", startTimestamp, endTimestamp).
+		Order("id desc").Limit(1).Find(&archives).Error
+	if err != nil || len(archives) == 0 {
+		return nil, err
+	}
+	return archives[0], nil
+}
+
+// GetOldestLogTimestamp 返回早于 beforeTimestamp 的最早一条日志的时间，没有则返回 0
+func GetOldestLogTimestamp(beforeTimestamp int64) (int64, error) {
+	var log Log
+	err := LOG_DB.Select("id, created_at").Where("created_at < ?", beforeTimestamp).Order("created_at asc").Limit(1).Find(&log).Error
+	return log.CreatedAt, err
+}
+
+// GetLogsInRangeAfterId 按 id 升序分批读取 [startTimestamp, endTimestamp) 内的日志，用于导出
+func GetLogsInRangeAfterId(startTimestamp int64, endTimestamp int64, afterId int, limit int) (logs []*Log, err error) {
+	err = LOG_DB.Where("created_at >= ? and created_at < ? and id > ?", startTimestamp, endTimestamp, afterId).
+		Order("id asc").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+// DeleteLogsInRange 删除 [startTimestamp, endTimestamp) 内 id 不超过 maxId 的日志，确保只删除已经导出的数据
+func DeleteLogsInRange(ctx context.Context, startTimestamp int64, endTimestamp int64, maxId int, limit int) (int64, error) {
+	var total int64 = 0
+
+	for {
+		if nil != ctx.Err() {
+			return total, ctx.Err()
+		}
+
+		result := LOG_DB.Where("created_at >= ? and created_at < ? and id <= ?", startTimestamp, endTimestamp, maxId).Limit(limit).Delete(&Log{})
+		if nil != result.Error {
+			return total, result.Error
+		}
+
+		total += result.RowsAffected
+
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+
+	return total, nil
+}
+
+// ImportLogs 将归档中的日志重新写回日志库，保留原始 id，已存在的记录会被跳过
+func ImportLogs(logs []*Log) (int64, error) {
+	if len(logs) == 0 {
+		return 0, nil
+	}
+	result := LOG_DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(logs, 100)
+	return result.RowsAffected, result.Error
+}
+
+func MarkLogArchiveRestored(archive *LogArchive) error {
+	return LOG_DB.Model(archive).Updates(map[string]interface{}{
+		"status":      LogArchiveStatusRestored,
+		"restored_at": common.GetTimestamp(),
+	}).Error
+}
@@ -0,0 +1,258 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// LogSink 日志外部投递目标，例如 Kafka、ClickHouse、Loki 或通用 HTTP 接口
+type LogSink interface {
+	Name() string
+	Send(ctx context.Context, logs []*Log) error
+}
+
+type LogSinkFactory func(config operation_setting.LogSinkConfig) (LogSink, error)
+
+var logSinkFactories = make(map[string]LogSinkFactory)
+
+// RegisterLogSinkFactory 注册一种 sink 类型，由具体实现所在的包在 init 中调用
+func RegisterLogSinkFactory(sinkType string, factory LogSinkFactory) {
+	logSinkFactories[sinkType] = factory
+}
+
+type logSinkEntry struct {
+	config operation_setting.LogSinkConfig
+	sink   LogSink
+}
+
+type LogSinkStats struct {
+	Queued  int   `json:"queued"`
+	Sent    int64 `json:"sent"`
+	Dropped int64 `json:"dropped"`
+	Failed  int64 `json:"failed"`
+}
+
+var (
+	logSinkQueue     chan *Log
+	logSinkFlushCh   = make(chan chan struct{})
+	logSinkStartOnce sync.Once
+	logSinkSent      atomic.Int64
+	logSinkDropped   atomic.Int64
+	logSinkFailed    atomic.Int64
+
+	logSinkEntries    []logSinkEntry
+	logSinkConfigHash string
+)
+
+// InitLogSinkDispatcher 启动日志投递协程，队列大小在启动时确定
+func InitLogSinkDispatcher() {
+	logSinkStartOnce.Do(func() {
+		size := operation_setting.GetLogSinkSetting().QueueSize
+		if size <= 0 {
+			size = 10000
+		}
+		logSinkQueue = make(chan *Log, size)
+		go runLogSinkDispatcher()
+	})
+}
+
+func GetLogSinkStats() LogSinkStats {
+	return LogSinkStats{
+		Queued:  len(logSinkQueue),
+		Sent:    logSinkSent.Load(),
+		Dropped: logSinkDropped.Load(),
+		Failed:  logSinkFailed.Load(),
+	}
+}
+
+func isLogSinkEnabled() bool {
+	return logSinkQueue != nil && operation_setting.GetLogSinkSetting().Enabled
+}
+
+// shouldWriteLogToDB 启用 sink 且配置了 disable_db_write 时，消费与错误日志不再写入数据库
+func shouldWriteLogToDB() bool {
+	return !isLogSinkEnabled() || !operation_setting.GetLogSinkSetting().DisableDBWrite
+}
+
+// emitLogToSinks 将日志放入投递队列；队列满时按配置阻塞等待或直接丢弃，避免拖慢请求
+func emitLogToSinks(log *Log) {
+	if !isLogSinkEnabled() {
+		return
+	}
+	select {
+	case logSinkQueue <- log:
+		return
+	default:
+	}
+	setting := operation_setting.GetLogSinkSetting()
+	if setting.BlockOnFull && setting.BlockTimeoutMs > 0 {
+		timer := time.NewTimer(time.Duration(setting.BlockTimeoutMs) * time.Millisecond)
+		defer timer.Stop()
+		select {
+		case logSinkQueue <- log:
+			return
+		case <-timer.C:
+		}
+	}
+	if logSinkDropped.Add(1)%1000 == 1 {
+		common.SysError(fmt.Sprintf("log sink queue is full, %d logs dropped so far", logSinkDropped.Load()))
+	}
+}
+
+func runLogSinkDispatcher() {
+	batch := make([]*Log, 0, 500)
+	for {
+		setting := operation_setting.GetLogSinkSetting()
+		interval := time.Duration(setting.FlushIntervalMs) * time.Millisecond
+		if interval <= 0 {
+			interval = time.Second
+		}
+		batchSize := setting.BatchSize
+		if batchSize <= 0 {
+			batchSize = 500
+		}
+		timer := time.NewTimer(interval)
+	collect:
+		for len(batch) < batchSize {
+			select {
+			case log := <-logSinkQueue:
+				batch = append(batch, log)
+			case done := <-logSinkFlushCh:
+				batch = drainLogSinkQueue(batch, batchSize)
+				close(done)
+				break collect
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		if len(batch) == 0 {
+			continue
+		}
+		flushLogSinkBatch(batch)
+		batch = make([]*Log, 0, batchSize)
+	}
+}
+
+// drainLogSinkQueue 投递当前批次和队列中的全部日志，返回新的空批次
+func drainLogSinkQueue(batch []*Log, batchSize int) []*Log {
+	for {
+		select {
+		case log := <-logSinkQueue:
+			batch = append(batch, log)
+			if len(batch) < batchSize {
+				continue
+			}
+		default:
+		}
+		if len(batch) == 0 {
+			return batch
+		}
+		flushLogSinkBatch(batch)
+		if len(logSinkQueue) == 0 {
+			return make([]*Log, 0, batchSize)
+		}
+		batch = make([]*Log, 0, batchSize)
+	}
+}
+
+// FlushLogSinks 投递队列中剩余的日志，用于优雅退出，超时后放弃等待
+func FlushLogSinks(timeout time.Duration) {
+	if logSinkQueue == nil {
+		return
+	}
+	done := make(chan struct{})
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case logSinkFlushCh <- done:
+	case <-timer.C:
+		common.SysError(fmt.Sprintf("timed out flushing log sinks, %d logs not sent", len(logSinkQueue)))
+		return
+	}
+	select {
+	case <-done:
+	case <-timer.C:
+		common.SysError(fmt.Sprintf("timed out flushing log sinks, %d logs not sent", len(logSinkQueue)))
+	}
+}
+
+// currentLogSinks 按需根据配置重建 sink，配置变化后自动生效
+func currentLogSinks() []logSinkEntry {
+	sinks := operation_setting.GetLogSinkSetting().Sinks
+	hash := common.GetJsonString(sinks)
+	if hash == logSinkConfigHash {
+		return logSinkEntries
+	}
+	entries := make([]logSinkEntry, 0, len(sinks))
+	for _, config := range sinks {
+		if !config.Enabled {
+			continue
+		}
+		factory, ok := logSinkFactories[config.Type]
+		if !ok {
+			common.SysError(fmt.Sprintf("unsupported log sink type %q for sink %s", config.Type, config.Name))
+			continue
+		}
+		sink, err := factory(config)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to create log sink %s: %s", config.Name, err.Error()))
+			continue
+		}
+		entries = append(entries, logSinkEntry{config: config, sink: sink})
+	}
+	logSinkEntries = entries
+	logSinkConfigHash = hash
+	return entries
+}
+
+func flushLogSinkBatch(batch []*Log) {
+	setting := operation_setting.GetLogSinkSetting()
+	var wg sync.WaitGroup
+	for _, entry := range currentLogSinks() {
+		logs := batch
+		if len(entry.config.LogTypes) > 0 {
+			logs = make([]*Log, 0, len(batch))
+			for _, log := range batch {
+				if slices.Contains(entry.config.LogTypes, log.Type) {
+					logs = append(logs, log)
+				}
+			}
+			if len(logs) == 0 {
+				continue
+			}
+		}
+		wg.Add(1)
+		go func(entry logSinkEntry, logs []*Log) {
+			defer wg.Done()
+			timeout := time.Duration(entry.config.TimeoutSeconds) * time.Second
+			if timeout <= 0 {
+				timeout = 10 * time.Second
+			}
+			var err error
+			for attempt := 0; attempt <= setting.MaxRetries; attempt++ {
+				if attempt > 0 {
+					time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				err = entry.sink.Send(ctx, logs)
+				cancel()
+				if err == nil {
+					logSinkSent.Add(int64(len(logs)))
+					return
+				}
+			}
+			logSinkFailed.Add(int64(len(logs)))
+			common.SysError(fmt.Sprintf("failed to send %d logs to sink %s: %s", len(logs), entry.sink.Name(), err.Error()))
+		}(entry, logs)
+	}
+	// 等待本批次投递完成后再处理下一批，队列积压时由 emitLogToSinks 施加背压
+	wg.Wait()
+}
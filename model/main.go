@@ -248,6 +248,9 @@ func InitLogDB() (err error) {
 }
 
 func migrateDB() error {
+	if err := mergeDuplicateUsageRollups(DB); err != nil {
+		return err
+	}
 	err := DB.AutoMigrate(
 		&Channel{},
 		&Token{},
@@ -257,6 +260,10 @@ func migrateDB() error {
 		&Redemption{},
 		&Ability{},
 		&Log{},
+		&UsageRollupHourly{},
+		&UsageRollupDaily{},
+		&LogArchive{},
+		&PayloadCapture{},
 		&Midjourney{},
 		&TopUp{},
 		&QuotaData{},
@@ -267,6 +274,25 @@ func migrateDB() error {
 		&Setup{},
 		&TwoFA{},
 		&TwoFABackupCode{},
+		&ModelVerification{},
+		&ChannelTestResult{},
+		&ChannelModelSync{},
+		&RatioChangeSet{},
+		&RatioChangeLog{},
+		&PricingVersion{},
+		&UserMonthlyUsage{},
+		&TokenBudgetUsage{},
+		&QuotaLedgerEntry{},
+		&QuotaReconcileReport{},
+		&BatchUpdateCheckpoint{},
+		&SubscriptionPlan{},
+		&UserSubscription{},
+		&Invoice{},
+		&Organization{},
+		&OrganizationMember{},
+		&OrganizationInvitation{},
+		&StatusSample{},
+		&StatusIncident{},
 	)
 	if err != nil {
 		return err
@@ -275,6 +301,9 @@ func migrateDB() error {
 }
 
 func migrateDBFast() error {
+	if err := mergeDuplicateUsageRollups(DB); err != nil {
+		return err
+	}
 
 	var wg sync.WaitGroup
 
@@ -290,6 +319,10 @@ func migrateDBFast() error {
 		{&Redemption{}, "Redemption"},
 		{&Ability{}, "Ability"},
 		{&Log{}, "Log"},
+		{&UsageRollupHourly{}, "UsageRollupHourly"},
+		{&UsageRollupDaily{}, "UsageRollupDaily"},
+		{&LogArchive{}, "LogArchive"},
+		{&PayloadCapture{}, "PayloadCapture"},
 		{&Midjourney{}, "Midjourney"},
 		{&TopUp{}, "TopUp"},
 		{&QuotaData{}, "QuotaData"},
@@ -300,6 +333,25 @@ func migrateDBFast() error {
 		{&Setup{}, "Setup"},
 		{&TwoFA{}, "TwoFA"},
 		{&TwoFABackupCode{}, "TwoFABackupCode"},
+		{&ModelVerification{}, "ModelVerification"},
+		{&ChannelTestResult{}, "ChannelTestResult"},
+		{&ChannelModelSync{}, "ChannelModelSync"},
+		{&RatioChangeSet{}, "RatioChangeSet"},
+		{&RatioChangeLog{}, "RatioChangeLog"},
+		{&PricingVersion{}, "PricingVersion"},
+		{&UserMonthlyUsage{}, "UserMonthlyUsage"},
+		{&TokenBudgetUsage{}, "TokenBudgetUsage"},
+		{&QuotaLedgerEntry{}, "QuotaLedgerEntry"},
+		{&QuotaReconcileReport{}, "QuotaReconcileReport"},
+		{&BatchUpdateCheckpoint{}, "BatchUpdateCheckpoint"},
+		{&SubscriptionPlan{}, "SubscriptionPlan"},
+		{&UserSubscription{}, "UserSubscription"},
+		{&Invoice{}, "Invoice"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
+		{&OrganizationInvitation{}, "OrganizationInvitation"},
+		{&StatusSample{}, "StatusSample"},
+		{&StatusIncident{}, "StatusIncident"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -330,7 +382,10 @@ func migrateDBFast() error {
 
 func migrateLOGDB() error {
 	var err error
-	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
+	if err = mergeDuplicateUsageRollups(LOG_DB); err != nil {
+		return err
+	}
+	if err = LOG_DB.AutoMigrate(&Log{}, &UsageRollupHourly{}, &UsageRollupDaily{}, &LogArchive{}, &PayloadCapture{}, &StatusSample{}); err != nil {
 		return err
 	}
 	return nil
@@ -2,6 +2,7 @@ package model
 
 import (
 	"strconv"
+	"strings"
 
 	"github.com/QuantumNous/new-api/common"
 
@@ -146,3 +147,49 @@ func SearchModels(keyword string, vendor string, offset int, limit int) ([]*Mode
 	}
 	return models, total, nil
 }
+
+// GetModelVendorNames 按模型元数据的匹配规则获取模型所属供应商名称，
+// 精确匹配优先，其次依次为前缀、后缀、包含，未关联供应商的模型不返回
+func GetModelVendorNames(modelNames []string) (map[string]string, error) {
+	var metas []*Model
+	if err := DB.Where("vendor_id > 0").Find(&metas).Error; err != nil {
+		return nil, err
+	}
+	var vendors []*Vendor
+	if err := DB.Find(&vendors).Error; err != nil {
+		return nil, err
+	}
+	vendorNames := make(map[int]string, len(vendors))
+	for _, v := range vendors {
+		vendorNames[v.Id] = v.Name
+	}
+	result := make(map[string]string)
+	for _, rule := range []int{NameRuleExact, NameRulePrefix, NameRuleSuffix, NameRuleContains} {
+		for _, meta := range metas {
+			vendorName, ok := vendorNames[meta.VendorID]
+			if meta.NameRule != rule || !ok {
+				continue
+			}
+			for _, name := range modelNames {
+				if _, exists := result[name]; exists {
+					continue
+				}
+				matched := false
+				switch rule {
+				case NameRuleExact:
+					matched = name == meta.ModelName
+				case NameRulePrefix:
+					matched = strings.HasPrefix(name, meta.ModelName)
+				case NameRuleSuffix:
+					matched = strings.HasSuffix(name, meta.ModelName)
+				case NameRuleContains:
+					matched = strings.Contains(name, meta.ModelName)
+				}
+				if matched {
+					result[name] = vendorName
+				}
+			}
+		}
+	}
+	return result, nil
+}
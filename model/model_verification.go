@@ -0,0 +1,66 @@
+package model
+
+import (
+	"context"
+)
+
+const (
+	ModelVerificationStatusPassed = "passed"
+	ModelVerificationStatusFailed = "failed"
+	ModelVerificationStatusError  = "error" // 所有探针请求均失败，无法评估
+)
+
+// ModelVerification 渠道模型验真记录，Details 为各探针得分的 JSON
+type ModelVerification struct {
+	Id                int     `json:"id"`
+	CreatedAt         int64   `json:"created_at" gorm:"bigint;index"`
+	ChannelId         int     `json:"channel_id" gorm:"index"`
+	ModelName         string  `json:"model_name" gorm:"type:varchar(128);index"`
+	UpstreamModel     string  `json:"upstream_model" gorm:"type:varchar(128)"`
+	Status            string  `json:"status" gorm:"type:varchar(16)"`
+	Score             float64 `json:"score"`
+	ResponseModel     string  `json:"response_model" gorm:"type:varchar(128)"`
+	SystemFingerprint string  `json:"system_fingerprint" gorm:"type:varchar(64)"`
+	Details           string  `json:"details" gorm:"type:text"`
+}
+
+func (v *ModelVerification) Insert() error {
+	return DB.Create(v).Error
+}
+
+func GetModelVerifications(channelId int, modelName string, status string, startIdx int, num int) (verifications []*ModelVerification, total int64, err error) {
+	tx := DB.Model(&ModelVerification{})
+	if channelId != 0 {
+		tx = tx.Where("channel_id = ?", channelId)
+	}
+	if modelName != "" {
+		tx = tx.Where("model_name = ?", modelName)
+	}
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&verifications).Error
+	return verifications, total, err
+}
+
+// DeleteOldModelVerifications 分批删除早于 targetTimestamp 的记录
+func DeleteOldModelVerifications(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
+	var total int64 = 0
+	for {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		result := DB.Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&ModelVerification{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,651 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+)
+
+// 团队成员角色：owner 拥有全部权限，admin 管理成员和令牌，billing 负责充值和查看用量，member 只能使用团队令牌
+const (
+	OrganizationRoleOwner   = "owner"
+	OrganizationRoleAdmin   = "admin"
+	OrganizationRoleMember  = "member"
+	OrganizationRoleBilling = "billing"
+)
+
+const (
+	OrganizationInvitationPending  = "pending"
+	OrganizationInvitationAccepted = "accepted"
+	OrganizationInvitationRevoked  = "revoked"
+)
+
+var (
+	ErrOrganizationNotFound          = errors.New("团队不存在")
+	ErrOrganizationNotMember         = errors.New("不是该团队的成员")
+	ErrOrganizationInvitationInvalid = errors.New("邀请码无效或已过期")
+	ErrOrganizationQuotaInsufficient = errors.New("团队额度不足")
+)
+
+// Organization 团队，拥有共享额度池，团队令牌产生的费用从额度池扣除
+type Organization struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64)"`
+	OwnerId     int    `json:"owner_id" gorm:"index"`
+	Quota       int    `json:"quota" gorm:"default:0"`
+	UsedQuota   int    `json:"used_quota" gorm:"default:0"`
+	Status      int    `json:"status" gorm:"default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+	MemberCount int64  `json:"member_count,omitempty" gorm:"-"`
+	Role        string `json:"role,omitempty" gorm:"-"` // 当前用户在团队中的角色
+}
+
+// OrganizationMember 团队成员，SpendingCap 为 0 表示不限制成员使用团队额度
+type OrganizationMember struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"uniqueIndex:idx_org_member,priority:1"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex:idx_org_member,priority:2;index"`
+	Role           string `json:"role" gorm:"type:varchar(16)"`
+	SpendingCap    int    `json:"spending_cap" gorm:"default:0"`
+	UsedQuota      int    `json:"used_quota" gorm:"default:0"` // 成员使用团队额度的累计消费
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	Username       string `json:"username,omitempty" gorm:"-"`
+	DisplayName    string `json:"display_name,omitempty" gorm:"-"`
+}
+
+// OrganizationInvitation 团队邀请，受邀用户凭邀请码加入团队，Email 不为空时只有该邮箱的用户可以接受
+type OrganizationInvitation struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"index"`
+	Code           string `json:"code" gorm:"type:varchar(32);uniqueIndex"`
+	Email          string `json:"email" gorm:"type:varchar(64)"`
+	Role           string `json:"role" gorm:"type:varchar(16)"`
+	InviterId      int    `json:"inviter_id"`
+	Status         string `json:"status" gorm:"type:varchar(16);index"`
+	AcceptedUserId int    `json:"accepted_user_id"`
+	ExpiredTime    int64  `json:"expired_time" gorm:"bigint"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+}
+
+// OrganizationUsage 团队用量，按成员和模型汇总
+type OrganizationUsage struct {
+	UserId           int    `json:"user_id"`
+	Username         string `json:"username" gorm:"-"`
+	ModelName        string `json:"model_name"`
+	RequestCount     int    `json:"request_count"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	Quota            int    `json:"quota"`
+}
+
+func IsValidOrganizationRole(role string) bool {
+	switch role {
+	case OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember, OrganizationRoleBilling:
+		return true
+	}
+	return false
+}
+
+// CanManageMembers 能否管理成员、邀请和团队令牌
+func (member *OrganizationMember) CanManageMembers() bool {
+	return member.Role == OrganizationRoleOwner || member.Role == OrganizationRoleAdmin
+}
+
+// CanManageBilling 能否为团队充值和查看团队用量
+func (member *OrganizationMember) CanManageBilling() bool {
+	return member.Role == OrganizationRoleOwner || member.Role == OrganizationRoleAdmin || member.Role == OrganizationRoleBilling
+}
+
+// CanUseTokens 能否创建和使用团队令牌，billing 角色只负责付费
+func (member *OrganizationMember) CanUseTokens() bool {
+	return member.Role != OrganizationRoleBilling
+}
+
+// CreateOrganization 创建团队，创建者成为 owner
+func CreateOrganization(name string, ownerId int) (*Organization, error) {
+	now := common.GetTimestamp()
+	org := &Organization{
+		Name:        name,
+		OwnerId:     ownerId,
+		Status:      OrganizationStatusEnabled,
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: org.Id,
+			UserId:         ownerId,
+			Role:           OrganizationRoleOwner,
+			CreatedTime:    now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	org.Role = OrganizationRoleOwner
+	return org, nil
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	var org Organization
+	err := DB.First(&org, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrganizationNotFound
+	}
+	return &org, err
+}
+
+func UpdateOrganizationName(id int, name string) error {
+	return DB.Model(&Organization{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"name":         name,
+		"updated_time": common.GetTimestamp(),
+	}).Error
+}
+
+func UpdateOrganizationStatus(id int, status int) error {
+	return DB.Model(&Organization{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       status,
+		"updated_time": common.GetTimestamp(),
+	}).Error
+}
+
+// GetUserOrganizations 用户加入的所有团队，附带用户在团队中的角色
+func GetUserOrganizations(userId int) ([]*Organization, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("user_id = ?", userId).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return []*Organization{}, nil
+	}
+	roles := make(map[int]string, len(members))
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		roles[member.OrganizationId] = member.Role
+		ids = append(ids, member.OrganizationId)
+	}
+	var orgs []*Organization
+	if err := DB.Where("id IN ?", ids).Order("id").Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	for _, org := range orgs {
+		org.Role = roles[org.Id]
+	}
+	return orgs, nil
+}
+
+func GetAllOrganizations(keyword string, startIdx int, num int) (orgs []*Organization, total int64, err error) {
+	tx := DB.Model(&Organization{})
+	if keyword != "" {
+		tx = tx.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&orgs).Error; err != nil {
+		return nil, 0, err
+	}
+	ids := make([]int, 0, len(orgs))
+	for _, org := range orgs {
+		ids = append(ids, org.Id)
+	}
+	var counts []struct {
+		OrganizationId int
+		Count          int64
+	}
+	err = DB.Model(&OrganizationMember{}).Select("organization_id, COUNT(*) AS count").
+		Where("organization_id IN ?", ids).Group("organization_id").Scan(&counts).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	countMap := make(map[int]int64, len(counts))
+	for _, count := range counts {
+		countMap[count.OrganizationId] = count.Count
+	}
+	for _, org := range orgs {
+		org.MemberCount = countMap[org.Id]
+	}
+	return orgs, total, nil
+}
+
+func GetOrganizationMember(orgId int, userId int) (*OrganizationMember, error) {
+	var member OrganizationMember
+	err := DB.Where("organization_id = ? AND user_id = ?", orgId, userId).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrganizationNotMember
+	}
+	return &member, err
+}
+
+func GetOrganizationMembers(orgId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("organization_id = ?", orgId).Order("id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	userIds := make([]int, 0, len(members))
+	for _, member := range members {
+		userIds = append(userIds, member.UserId)
+	}
+	var users []*User
+	if err := DB.Select("id", "username", "display_name").Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	userMap := make(map[int]*User, len(users))
+	for _, user := range users {
+		userMap[user.Id] = user
+	}
+	for _, member := range members {
+		if user, ok := userMap[member.UserId]; ok {
+			member.Username = user.Username
+			member.DisplayName = user.DisplayName
+		}
+	}
+	return members, nil
+}
+
+// UpdateOrganizationMember 修改成员角色和消费上限，resetUsed 为 true 时清零成员已用额度。团队必须保留一个 owner
+func UpdateOrganizationMember(orgId int, userId int, role string, spendingCap int, resetUsed bool) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var member OrganizationMember
+		if err := tx.Where("organization_id = ? AND user_id = ?", orgId, userId).First(&member).Error; err != nil {
+			return ErrOrganizationNotMember
+		}
+		if member.Role == OrganizationRoleOwner && role != OrganizationRoleOwner {
+			if err := ensureAnotherOwner(tx, orgId, userId); err != nil {
+				return err
+			}
+		}
+		updates := map[string]interface{}{
+			"role":         role,
+			"spending_cap": spendingCap,
+		}
+		if resetUsed {
+			updates["used_quota"] = 0
+		}
+		return tx.Model(&member).Updates(updates).Error
+	})
+}
+
+func ensureAnotherOwner(tx *gorm.DB, orgId int, userId int) error {
+	var owners int64
+	err := tx.Model(&OrganizationMember{}).
+		Where("organization_id = ? AND role = ? AND user_id <> ?", orgId, OrganizationRoleOwner, userId).
+		Count(&owners).Error
+	if err != nil {
+		return err
+	}
+	if owners == 0 {
+		return errors.New("团队至少需要保留一个 owner")
+	}
+	return nil
+}
+
+// RemoveOrganizationMember 移除成员并禁用其创建的团队令牌
+func RemoveOrganizationMember(orgId int, userId int) error {
+	var keys []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var member OrganizationMember
+		if err := tx.Where("organization_id = ? AND user_id = ?", orgId, userId).First(&member).Error; err != nil {
+			return ErrOrganizationNotMember
+		}
+		if member.Role == OrganizationRoleOwner {
+			if err := ensureAnotherOwner(tx, orgId, userId); err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&member).Error; err != nil {
+			return err
+		}
+		tokenQuery := tx.Model(&Token{}).Where("organization_id = ? AND user_id = ?", orgId, userId)
+		if err := tokenQuery.Pluck(commonKeyCol, &keys).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Token{}).Where("organization_id = ? AND user_id = ?", orgId, userId).
+			Update("status", common.TokenStatusDisabled).Error
+	})
+	if err != nil {
+		return err
+	}
+	invalidateTokenCaches(keys)
+	return nil
+}
+
+func invalidateTokenCaches(keys []string) {
+	if !common.RedisEnabled {
+		return
+	}
+	for _, key := range keys {
+		if err := cacheDeleteToken(key); err != nil {
+			common.SysError("failed to delete token cache: " + err.Error())
+		}
+	}
+}
+
+// CreateOrganizationInvitation 生成邀请码，validDays 天后过期
+func CreateOrganizationInvitation(orgId int, inviterId int, email string, role string, validDays int) (*OrganizationInvitation, error) {
+	now := common.GetTimestamp()
+	invitation := &OrganizationInvitation{
+		OrganizationId: orgId,
+		Code:           common.GetRandomString(24),
+		Email:          strings.TrimSpace(email),
+		Role:           role,
+		InviterId:      inviterId,
+		Status:         OrganizationInvitationPending,
+		ExpiredTime:    now + int64(validDays)*86400,
+		CreatedTime:    now,
+	}
+	return invitation, DB.Create(invitation).Error
+}
+
+func GetOrganizationInvitations(orgId int) (invitations []*OrganizationInvitation, err error) {
+	err = DB.Where("organization_id = ?", orgId).Order("id desc").Find(&invitations).Error
+	return invitations, err
+}
+
+func RevokeOrganizationInvitation(orgId int, id int) error {
+	result := DB.Model(&OrganizationInvitation{}).
+		Where("id = ? AND organization_id = ? AND status = ?", id, orgId, OrganizationInvitationPending).
+		Update("status", OrganizationInvitationRevoked)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("邀请不存在或已失效")
+	}
+	return nil
+}
+
+// AcceptOrganizationInvitation 接受邀请加入团队，已是成员时直接返回团队
+func AcceptOrganizationInvitation(code string, userId int, email string) (*Organization, error) {
+	var org Organization
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var invitation OrganizationInvitation
+		err := tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("code = ? AND status = ?", code, OrganizationInvitationPending).First(&invitation).Error
+		if err != nil || invitation.ExpiredTime < common.GetTimestamp() {
+			return ErrOrganizationInvitationInvalid
+		}
+		if invitation.Email != "" && !strings.EqualFold(invitation.Email, email) {
+			return errors.New("该邀请仅限指定邮箱的用户接受")
+		}
+		if err = tx.First(&org, invitation.OrganizationId).Error; err != nil || org.Status != OrganizationStatusEnabled {
+			return ErrOrganizationNotFound
+		}
+		var count int64
+		if err = tx.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", org.Id, userId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			err = tx.Create(&OrganizationMember{
+				OrganizationId: org.Id,
+				UserId:         userId,
+				Role:           invitation.Role,
+				CreatedTime:    common.GetTimestamp(),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Model(&invitation).Updates(map[string]interface{}{
+			"status":           OrganizationInvitationAccepted,
+			"accepted_user_id": userId,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// FundOrganization 从用户个人额度划转到团队额度池
+func FundOrganization(orgId int, userId int, quota int) error {
+	if quota <= 0 {
+		return errors.New("划转额度必须大于 0")
+	}
+	reference := fmt.Sprintf("organization:%d", orgId)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 以余额作为更新条件，并发划转时不会扣成负数
+		result := tx.Model(&User{}).Where("id = ? AND quota >= ?", userId, quota).Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("个人额度不足")
+		}
+		result = tx.Model(&Organization{}).Where("id = ?", orgId).Update("quota", gorm.Expr("quota + ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationNotFound
+		}
+		ref := QuotaLedgerRef{Source: QuotaLedgerSourceOrganization, Reference: reference, Remark: "划转到团队额度池"}
+		if err := RecordUserQuotaLedger(tx, userId, -quota, ref); err != nil {
+			return err
+		}
+		return RecordOrganizationQuotaLedger(tx, orgId, userId, quota, ref)
+	})
+	if err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
+
+// AdjustOrganizationQuota 管理员直接调整团队额度池，delta 可以为负
+func AdjustOrganizationQuota(orgId int, delta int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Organization{}).Where("id = ?", orgId).Update("quota", gorm.Expr("quota + ?", delta)).Error; err != nil {
+			return err
+		}
+		return RecordOrganizationQuotaLedger(tx, orgId, 0, delta, QuotaLedgerRef{Source: QuotaLedgerSourceAdmin, Remark: "管理员调整团队额度"})
+	})
+}
+
+// DeleteOrganization 解散团队，剩余额度退回 owner，团队令牌全部禁用
+func DeleteOrganization(orgId int) error {
+	var keys []string
+	var ownerId int
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var org Organization
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&org, orgId).Error; err != nil {
+			return ErrOrganizationNotFound
+		}
+		ownerId = org.OwnerId
+		if org.Quota != 0 {
+			if err := tx.Model(&User{}).Where("id = ?", org.OwnerId).Update("quota", gorm.Expr("quota + ?", org.Quota)).Error; err != nil {
+				return err
+			}
+			ref := QuotaLedgerRef{Source: QuotaLedgerSourceOrganization, Reference: fmt.Sprintf("organization:%d", orgId), Remark: "团队解散退回额度"}
+			if err := RecordOrganizationQuotaLedger(tx, orgId, org.OwnerId, -org.Quota, ref); err != nil {
+				return err
+			}
+			if err := RecordUserQuotaLedger(tx, org.OwnerId, org.Quota, ref); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&Token{}).Where("organization_id = ?", orgId).Pluck(commonKeyCol, &keys).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Token{}).Where("organization_id = ?", orgId).Update("status", common.TokenStatusDisabled).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", orgId).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", orgId).Delete(&OrganizationInvitation{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&org).Error
+	})
+	if err != nil {
+		return err
+	}
+	invalidateTokenCaches(keys)
+	return invalidateUserCache(ownerId)
+}
+
+// DecreaseOrganizationQuota 从团队额度池扣费，同时累计成员已用额度
+func DecreaseOrganizationQuota(orgId int, userId int, quota int, ref QuotaLedgerRef) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return updateOrganizationQuota(orgId, userId, -quota, false, ref)
+}
+
+// PreConsumeOrganizationQuota 预扣团队额度池，额度池余额不足时返回 ErrOrganizationQuotaInsufficient。
+// 结算时请求已经完成，仍使用 DecreaseOrganizationQuota 无条件扣除，额度池可以和用户额度一样扣成负数
+func PreConsumeOrganizationQuota(orgId int, userId int, quota int, ref QuotaLedgerRef) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return updateOrganizationQuota(orgId, userId, -quota, true, ref)
+}
+
+// IncreaseOrganizationQuota 退还团队额度池，同时扣减成员已用额度
+func IncreaseOrganizationQuota(orgId int, userId int, quota int, ref QuotaLedgerRef) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return updateOrganizationQuota(orgId, userId, quota, false, ref)
+}
+
+func updateOrganizationQuota(orgId int, userId int, delta int, requireBalance bool, ref QuotaLedgerRef) error {
+	if delta == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		// 预扣费时以额度池余额作为更新条件，余额不足则不更新，避免并发预扣把额度池扣成负数
+		query := tx.Model(&Organization{}).Where("id = ?", orgId)
+		requireBalance = requireBalance && delta < 0
+		if requireBalance {
+			query = query.Where("quota >= ?", -delta)
+		}
+		result := query.Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota + ?", delta),
+			"used_quota": gorm.Expr("used_quota - ?", delta),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if requireBalance {
+				return ErrOrganizationQuotaInsufficient
+			}
+			return ErrOrganizationNotFound
+		}
+		err := tx.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", orgId, userId).
+			Update("used_quota", gorm.Expr("used_quota - ?", delta)).Error
+		if err != nil {
+			return err
+		}
+		return RecordOrganizationQuotaLedger(tx, orgId, userId, delta, ref)
+	})
+}
+
+// DecreaseBillingQuota 扣除请求费用，团队令牌从团队额度池扣除，否则从用户额度扣除
+func DecreaseBillingQuota(userId int, orgId int, quota int, ref QuotaLedgerRef) error {
+	if orgId != 0 {
+		return DecreaseOrganizationQuota(orgId, userId, quota, ref)
+	}
+	return DecreaseUserQuota(userId, quota, ref)
+}
+
+// PreConsumeBillingQuota 预扣请求费用，团队令牌要求团队额度池余额充足，否则从用户额度扣除
+func PreConsumeBillingQuota(userId int, orgId int, quota int, ref QuotaLedgerRef) error {
+	if orgId != 0 {
+		return PreConsumeOrganizationQuota(orgId, userId, quota, ref)
+	}
+	return DecreaseUserQuota(userId, quota, ref)
+}
+
+// IncreaseBillingQuota 退还请求费用，团队令牌退回团队额度池，否则退回用户额度
+func IncreaseBillingQuota(userId int, orgId int, quota int, ref QuotaLedgerRef) error {
+	if orgId != 0 {
+		return IncreaseOrganizationQuota(orgId, userId, quota, ref)
+	}
+	return IncreaseUserQuota(userId, quota, false, ref)
+}
+
+// GetOrganizationTokens 团队的所有令牌，userId 不为 0 时只返回该成员创建的令牌
+func GetOrganizationTokens(orgId int, userId int) (tokens []*Token, err error) {
+	tx := DB.Where("organization_id = ?", orgId)
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	err = tx.Order("id desc").Find(&tokens).Error
+	return tokens, err
+}
+
+// GetOrganizationUsage 按成员和模型汇总团队令牌在 [start, end) 内的消费，userId 不为 0 时只统计该成员
+func GetOrganizationUsage(orgId int, userId int, start int64, end int64) ([]*OrganizationUsage, error) {
+	var tokenIds []int
+	tokenQuery := DB.Unscoped().Model(&Token{}).Where("organization_id = ?", orgId)
+	if userId != 0 {
+		tokenQuery = tokenQuery.Where("user_id = ?", userId)
+	}
+	if err := tokenQuery.Pluck("id", &tokenIds).Error; err != nil {
+		return nil, err
+	}
+	usages := make([]*OrganizationUsage, 0)
+	if len(tokenIds) == 0 {
+		return usages, nil
+	}
+	err := LOG_DB.Table("logs").
+		Select("user_id, model_name, "+
+			"SUM(CASE WHEN type = ? THEN 1 ELSE 0 END) AS request_count, "+
+			"SUM(CASE WHEN type = ? THEN prompt_tokens ELSE 0 END) AS prompt_tokens, "+
+			"SUM(CASE WHEN type = ? THEN completion_tokens ELSE 0 END) AS completion_tokens, "+
+			"SUM(CASE WHEN type = ? THEN -quota ELSE quota END) AS quota",
+			LogTypeConsume, LogTypeConsume, LogTypeConsume, LogTypeRefund).
+		Where("token_id IN ? AND type IN ? AND created_at >= ? AND created_at < ?",
+			tokenIds, []int{LogTypeConsume, LogTypeRefund}, start, end).
+		Group("user_id, model_name").
+		Order("quota desc").
+		Scan(&usages).Error
+	if err != nil {
+		return nil, err
+	}
+	userIds := make([]int, 0, len(usages))
+	for _, usage := range usages {
+		userIds = append(userIds, usage.UserId)
+	}
+	var users []*User
+	if err = DB.Select("id", "username").Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	usernames := make(map[int]string, len(users))
+	for _, user := range users {
+		usernames[user.Id] = user.Username
+	}
+	for _, usage := range usages {
+		usage.Username = usernames[usage.UserId]
+	}
+	return usages, nil
+}
+
+// RemoveUserOrganizationMemberships 删除用户时移出其加入的团队，用户是唯一 owner 的团队保持不变
+func RemoveUserOrganizationMemberships(userId int) error {
+	var members []*OrganizationMember
+	if err := DB.Where("user_id = ?", userId).Find(&members).Error; err != nil {
+		return err
+	}
+	for _, member := range members {
+		if err := RemoveOrganizationMember(member.OrganizationId, userId); err != nil {
+			common.SysError(fmt.Sprintf("failed to remove user %d from organization %d: %s", userId, member.OrganizationId, err.Error()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// PayloadCapture 抓取的请求/响应内容，Payload 为加密后的数据，仅管理员可查看
+type PayloadCapture struct {
+	Id           int    `json:"id"`
+	RequestId    string `json:"request_id" gorm:"type:varchar(64);uniqueIndex"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	TokenId      int    `json:"token_id"`
+	ChannelId    int    `json:"channel_id"`
+	ModelName    string `json:"model_name" gorm:"type:varchar(128)"`
+	RequestPath  string `json:"request_path" gorm:"type:varchar(255)"`
+	IsStream     bool   `json:"is_stream"`
+	StatusCode   int    `json:"status_code"`
+	UseTimeMs    int64  `json:"use_time_ms"`
+	FirstTokenMs int64  `json:"first_token_ms"`
+	Truncated    bool   `json:"truncated"`
+	Size         int    `json:"size"` // 加密前的原始大小
+	Payload      string `json:"-"`
+}
+
+func (p *PayloadCapture) Insert() error {
+	p.CreatedAt = common.GetTimestamp()
+	return LOG_DB.Create(p).Error
+}
+
+func GetPayloadCaptureByRequestId(requestId string) (*PayloadCapture, error) {
+	if requestId == "" {
+		return nil, errors.New("request id 为空！")
+	}
+	var capture PayloadCapture
+	err := LOG_DB.First(&capture, "request_id = ?", requestId).Error
+	return &capture, err
+}
+
+// DeleteOldPayloadCaptures 分批删除早于 targetTimestamp 的记录
+func DeleteOldPayloadCaptures(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
+	var total int64 = 0
+	for {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		result := LOG_DB.Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&PayloadCapture{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+	return total, nil
+}
+
+// TrimPayloadCaptures 只保留最新的 maxRecords 条记录
+func TrimPayloadCaptures(ctx context.Context, maxRecords int, limit int) (int64, error) {
+	if maxRecords <= 0 {
+		return 0, nil
+	}
+	var boundary PayloadCapture
+	err := LOG_DB.Select("id").Order("id desc").Offset(maxRecords).Limit(1).Find(&boundary).Error
+	if err != nil || boundary.Id == 0 {
+		return 0, err
+	}
+	var total int64 = 0
+	for {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		result := LOG_DB.Where("id <= ?", boundary.Id).Limit(limit).Delete(&PayloadCapture{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,402 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+)
+
+const (
+	PricingVersionStatusScheduled = "scheduled"
+	PricingVersionStatusApplied   = "applied"
+	PricingVersionStatusCancelled = "cancelled"
+)
+
+// PricingOptionKeys 纳入版本管理的计价配置，值均为 模型/分组 -> 数值 的 JSON
+var PricingOptionKeys = []string{
+	"ModelRatio",
+	"ModelPrice",
+	"CompletionRatio",
+	"CacheRatio",
+	"ImageRatio",
+	"AudioRatio",
+	"AudioCompletionRatio",
+	"GroupRatio",
+}
+
+// PricingVersion 定价版本。已生效的版本保存生效时全部计价配置的快照，
+// 计划中的版本只保存相对创建时配置逐个模型（或分组）的修改，到达生效时间后合并到当时的配置并保存完整快照，
+// 这样计划期间对其他模型的修改（包括上游倍率自动同步）不会在生效时被覆盖
+type PricingVersion struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(255)"`
+	Status      string `json:"status" gorm:"type:varchar(16);index"`
+	EffectiveAt int64  `json:"effective_at" gorm:"bigint;index"` // 计划生效时间
+	AppliedAt   int64  `json:"applied_at" gorm:"bigint;index"`   // 实际生效时间
+	Content     string `json:"content,omitempty" gorm:"type:text"`
+	Patch       string `json:"-" gorm:"type:text"`
+	CreatedBy   int    `json:"created_by"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+}
+
+// PricingVersionPatch 计价配置项 -> 模型（或分组） -> 新值，值为 nil 表示删除该项
+type PricingVersionPatch map[string]map[string]*float64
+
+// PricingVersionChange 两个版本之间某个模型（或分组）的配置变化，nil 表示未配置
+type PricingVersionChange struct {
+	Key      string   `json:"key"`
+	Name     string   `json:"name"`
+	OldValue *float64 `json:"old_value"`
+	NewValue *float64 `json:"new_value"`
+}
+
+var pricingVersionLock sync.Mutex
+
+func (version *PricingVersion) GetContent() map[string]string {
+	content := make(map[string]string)
+	if version.Content == "" {
+		return content
+	}
+	if err := common.UnmarshalJsonStr(version.Content, &content); err != nil {
+		common.SysError("failed to unmarshal pricing version content: " + err.Error())
+	}
+	return content
+}
+
+func (version *PricingVersion) SetContent(content map[string]string) {
+	data, _ := common.Marshal(content)
+	version.Content = string(data)
+}
+
+func (version *PricingVersion) GetPatch() PricingVersionPatch {
+	patch := make(PricingVersionPatch)
+	if version.Patch == "" {
+		return patch
+	}
+	if err := common.UnmarshalJsonStr(version.Patch, &patch); err != nil {
+		common.SysError("failed to unmarshal pricing version patch: " + err.Error())
+	}
+	return patch
+}
+
+func (version *PricingVersion) SetPatch(patch PricingVersionPatch) {
+	data, _ := common.Marshal(patch)
+	version.Patch = string(data)
+}
+
+// NewPricingVersionPatch 计算 content 相对 base 的逐项修改，content 中缺少的配置项视为未修改
+func NewPricingVersionPatch(base map[string]string, content map[string]string) PricingVersionPatch {
+	patch := make(PricingVersionPatch)
+	for _, change := range DiffPricingContent(base, content) {
+		if _, ok := patch[change.Key]; !ok {
+			patch[change.Key] = make(map[string]*float64)
+		}
+		patch[change.Key][change.Name] = change.NewValue
+	}
+	return patch
+}
+
+// Apply 将修改合并到 content 上，返回被修改的配置项的新值
+func (patch PricingVersionPatch) Apply(content map[string]string) (map[string]string, error) {
+	merged := make(map[string]string, len(patch))
+	for key, changes := range patch {
+		if !common.StringsContains(PricingOptionKeys, key) {
+			continue
+		}
+		values, err := ParsePricingOption(content[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", key, err)
+		}
+		for name, value := range changes {
+			if value == nil {
+				delete(values, name)
+			} else {
+				values[name] = *value
+			}
+		}
+		data, err := common.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		merged[key] = string(data)
+	}
+	return merged, nil
+}
+
+// PreviewPricingVersion 计划中的版本如果现在生效，修改后的配置项取值
+func PreviewPricingVersion(version *PricingVersion) (map[string]string, error) {
+	return version.GetPatch().Apply(SnapshotPricingOptions())
+}
+
+// ParsePricingOption 解析计价配置的值
+func ParsePricingOption(value string) (map[string]float64, error) {
+	values := make(map[string]float64)
+	if value == "" {
+		return values, nil
+	}
+	err := common.UnmarshalJsonStr(value, &values)
+	return values, err
+}
+
+// SnapshotPricingOptions 获取当前全部计价配置
+func SnapshotPricingOptions() map[string]string {
+	common.OptionMapRWMutex.RLock()
+	defer common.OptionMapRWMutex.RUnlock()
+	content := make(map[string]string, len(PricingOptionKeys))
+	for _, key := range PricingOptionKeys {
+		content[key] = common.OptionMap[key]
+	}
+	return content
+}
+
+func samePricingContent(a map[string]string, b map[string]string) bool {
+	for _, key := range PricingOptionKeys {
+		aValues, errA := ParsePricingOption(a[key])
+		bValues, errB := ParsePricingOption(b[key])
+		if errA != nil || errB != nil {
+			if a[key] != b[key] {
+				return false
+			}
+			continue
+		}
+		if len(DiffPricingOption(key, aValues, bValues)) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffPricingOption 对比同一配置项的两个取值，结果按名称排序
+func DiffPricingOption(key string, oldValues map[string]float64, newValues map[string]float64) []*PricingVersionChange {
+	changes := make([]*PricingVersionChange, 0)
+	for name, oldValue := range oldValues {
+		oldValue := oldValue
+		if newValue, ok := newValues[name]; ok {
+			if newValue != oldValue {
+				newValue := newValue
+				changes = append(changes, &PricingVersionChange{Key: key, Name: name, OldValue: &oldValue, NewValue: &newValue})
+			}
+		} else {
+			changes = append(changes, &PricingVersionChange{Key: key, Name: name, OldValue: &oldValue})
+		}
+	}
+	for name, newValue := range newValues {
+		if _, ok := oldValues[name]; !ok {
+			newValue := newValue
+			changes = append(changes, &PricingVersionChange{Key: key, Name: name, NewValue: &newValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// DiffPricingContent 对比两份计价配置，newContent 中缺少的配置项视为未修改
+func DiffPricingContent(oldContent map[string]string, newContent map[string]string) []*PricingVersionChange {
+	changes := make([]*PricingVersionChange, 0)
+	for _, key := range PricingOptionKeys {
+		newValue, ok := newContent[key]
+		if !ok {
+			continue
+		}
+		oldValues, _ := ParsePricingOption(oldContent[key])
+		newValues, _ := ParsePricingOption(newValue)
+		changes = append(changes, DiffPricingOption(key, oldValues, newValues)...)
+	}
+	return changes
+}
+
+// RecordPricingVersion 计价配置被直接修改后记录一个已生效的版本，配置与当前版本一致时不记录
+func RecordPricingVersion(name string, operatorId int) (*PricingVersion, error) {
+	pricingVersionLock.Lock()
+	defer pricingVersionLock.Unlock()
+	content := SnapshotPricingOptions()
+	latest, err := GetPricingVersionAt(common.GetTimestamp())
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && samePricingContent(latest.GetContent(), content) {
+		return nil, nil
+	}
+	now := common.GetTimestamp()
+	version := &PricingVersion{
+		Name:        name,
+		Status:      PricingVersionStatusApplied,
+		EffectiveAt: now,
+		AppliedAt:   now,
+		CreatedBy:   operatorId,
+		CreatedAt:   now,
+	}
+	version.SetContent(content)
+	if err := DB.Create(version).Error; err != nil {
+		return nil, err
+	}
+	ratio_setting.SetPricingVersion(version.Id)
+	return version, nil
+}
+
+// CreatePricingVersion 创建计划中的定价版本，content 只需包含要修改的配置项，
+// 保存的是 content 相对当前配置的逐项修改
+func CreatePricingVersion(version *PricingVersion, content map[string]string) error {
+	pricingVersionLock.Lock()
+	defer pricingVersionLock.Unlock()
+	patch := NewPricingVersionPatch(SnapshotPricingOptions(), content)
+	if len(patch) == 0 {
+		return errors.New("计价配置与当前配置相同，没有需要修改的内容")
+	}
+	version.SetPatch(patch)
+	version.Content = ""
+	version.Status = PricingVersionStatusScheduled
+	version.AppliedAt = 0
+	version.CreatedAt = common.GetTimestamp()
+	return DB.Create(version).Error
+}
+
+// ApplyPricingVersion 将计划中版本的逐项修改合并到当前计价配置，并将版本内容替换为生效后的完整快照
+func ApplyPricingVersion(version *PricingVersion) error {
+	pricingVersionLock.Lock()
+	defer pricingVersionLock.Unlock()
+	patch := version.GetPatch()
+	if len(patch) == 0 && version.Content != "" {
+		// 兼容只保存了完整配置项的旧计划
+		patch = NewPricingVersionPatch(SnapshotPricingOptions(), version.GetContent())
+	}
+	merged, err := patch.Apply(SnapshotPricingOptions())
+	if err != nil {
+		return err
+	}
+	for _, key := range PricingOptionKeys {
+		value, ok := merged[key]
+		if !ok {
+			continue
+		}
+		if err := UpdateOption(key, value); err != nil {
+			return fmt.Errorf("failed to apply %s: %w", key, err)
+		}
+	}
+	now := common.GetTimestamp()
+	version.SetContent(SnapshotPricingOptions())
+	result := DB.Model(&PricingVersion{}).
+		Where("id = ? AND status = ?", version.Id, PricingVersionStatusScheduled).
+		Updates(map[string]interface{}{
+			"status":     PricingVersionStatusApplied,
+			"applied_at": now,
+			"content":    version.Content,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("定价版本不是计划中的状态")
+	}
+	version.Status = PricingVersionStatusApplied
+	version.AppliedAt = now
+	ratio_setting.SetPricingVersion(version.Id)
+	return nil
+}
+
+// CancelPricingVersion 取消尚未生效的定价版本
+func CancelPricingVersion(id int) error {
+	result := DB.Model(&PricingVersion{}).
+		Where("id = ? AND status = ?", id, PricingVersionStatusScheduled).
+		Update("status", PricingVersionStatusCancelled)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("只能取消计划中的定价版本")
+	}
+	return nil
+}
+
+func GetPricingVersionById(id int) (*PricingVersion, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	var version PricingVersion
+	err := DB.First(&version, "id = ?", id).Error
+	return &version, err
+}
+
+// GetPricingVersions 分页查询定价版本，不返回配置内容
+func GetPricingVersions(status string, startIdx int, num int) (versions []*PricingVersion, total int64, err error) {
+	tx := DB.Model(&PricingVersion{})
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Omit("content").Order("id desc").Limit(num).Offset(startIdx).Find(&versions).Error
+	return versions, total, err
+}
+
+// GetPricingVersionAt 获取 timestamp 时刻生效的定价版本，没有时返回 nil
+func GetPricingVersionAt(timestamp int64) (*PricingVersion, error) {
+	var versions []*PricingVersion
+	err := DB.Where("status = ? AND applied_at <= ?", PricingVersionStatusApplied, timestamp).
+		Order("applied_at desc, id desc").Limit(1).Find(&versions).Error
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return versions[0], nil
+}
+
+// GetPreviousPricingVersion 获取指定版本之前生效的版本，没有时返回 nil
+func GetPreviousPricingVersion(version *PricingVersion) (*PricingVersion, error) {
+	var versions []*PricingVersion
+	err := DB.Where("status = ? AND (applied_at < ? OR (applied_at = ? AND id < ?))",
+		PricingVersionStatusApplied, version.AppliedAt, version.AppliedAt, version.Id).
+		Order("applied_at desc, id desc").Limit(1).Find(&versions).Error
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return versions[0], nil
+}
+
+// loadPricingVersion 从数据库加载当前生效的定价版本，多节点部署时随配置同步
+func loadPricingVersion() {
+	version, err := GetPricingVersionAt(common.GetTimestamp())
+	if err != nil {
+		common.SysError("failed to load pricing version: " + err.Error())
+		return
+	}
+	if version != nil {
+		ratio_setting.SetPricingVersion(version.Id)
+	}
+}
+
+// AutomaticallyApplyPricingVersions 每分钟检查并应用到达生效时间的定价版本，只在 Master 节点运行。
+// 首次运行时如果还没有任何版本，会记录当前配置作为初始版本
+func AutomaticallyApplyPricingVersions() {
+	if !common.IsMasterNode {
+		return
+	}
+	if latest, err := GetPricingVersionAt(common.GetTimestamp()); err == nil && latest == nil {
+		if _, err := RecordPricingVersion("初始版本", 0); err != nil {
+			common.SysError("failed to record initial pricing version: " + err.Error())
+		}
+	}
+	for {
+		var versions []*PricingVersion
+		err := DB.Where("status = ? AND effective_at <= ?", PricingVersionStatusScheduled, common.GetTimestamp()).
+			Order("effective_at asc, id asc").Find(&versions).Error
+		if err != nil {
+			common.SysError("failed to query scheduled pricing versions: " + err.Error())
+		}
+		for _, version := range versions {
+			if err := ApplyPricingVersion(version); err != nil {
+				common.SysError(fmt.Sprintf("failed to apply pricing version #%d: %s", version.Id, err.Error()))
+				continue
+			}
+			common.SysLog(fmt.Sprintf("pricing version #%d %s applied", version.Id, version.Name))
+		}
+		time.Sleep(1 * time.Minute)
+	}
+}
@@ -0,0 +1,291 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"gorm.io/gorm"
+)
+
+const (
+	QuotaLedgerAccountUser         = "user"
+	QuotaLedgerAccountToken        = "token"
+	QuotaLedgerAccountSystem       = "system"
+	QuotaLedgerAccountOrganization = "organization"
+	QuotaLedgerAccountCheckin      = "checkin" // 签到额度，账户 ID 为用户 ID
+)
+
+// 系统账户，作为用户和令牌额度变动的对手方，保证所有流水之和为 0
+const (
+	QuotaLedgerSystemRevenue         = 1 // 消费收入
+	QuotaLedgerSystemIssuance        = 2 // 充值、兑换、赠送、管理员调整等额度发放
+	QuotaLedgerSystemTokenAllocation = 3 // 令牌额度分配
+	QuotaLedgerSystemAdjustment      = 4 // 期初余额
+)
+
+const (
+	QuotaLedgerSourceConsume      = "consume"
+	QuotaLedgerSourcePreConsume   = "pre_consume"
+	QuotaLedgerSourceRefund       = "refund"
+	QuotaLedgerSourceTask         = "task"
+	QuotaLedgerSourceTopUp        = "topup"
+	QuotaLedgerSourceRedemption   = "redemption"
+	QuotaLedgerSourceAffTransfer  = "aff_transfer"
+	QuotaLedgerSourceInvite       = "invite"
+	QuotaLedgerSourceRegister     = "register"
+	QuotaLedgerSourceAdmin        = "admin"
+	QuotaLedgerSourceTokenAdjust  = "token_adjust"
+	QuotaLedgerSourceOpening      = "opening"
+	QuotaLedgerSourceSubscription = "subscription"
+	QuotaLedgerSourceInvoice      = "invoice"
+	QuotaLedgerSourceOrganization = "organization"
+	QuotaLedgerSourceCheckin      = "checkin"
+	QuotaLedgerSourceExpire       = "expire"
+	QuotaLedgerSourceRecovery     = "recovery"
+)
+
+// QuotaLedgerRef 额度变动的来源
+type QuotaLedgerRef struct {
+	Source    string
+	Reference string // 来源单据，例如请求 ID、订单号、兑换码 ID
+	Remark    string
+}
+
+// QuotaLedgerEntry 额度流水，只追加不修改。每次变动写入账户和对手方两条记录，TransactionId 相同
+type QuotaLedgerEntry struct {
+	Id            int64  `json:"id"`
+	TransactionId string `json:"transaction_id" gorm:"type:varchar(32);index"`
+	AccountType   string `json:"account_type" gorm:"type:varchar(16);index:idx_qle_account,priority:1"`
+	AccountId     int    `json:"account_id" gorm:"index:idx_qle_account,priority:2"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	Delta         int64  `json:"delta"`
+	Source        string `json:"source" gorm:"type:varchar(32);index"`
+	Reference     string `json:"reference" gorm:"type:varchar(128);index"`
+	Remark        string `json:"remark" gorm:"type:varchar(255)"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint;index:idx_qle_account,priority:3"`
+}
+
+func quotaLedgerCounterparty(accountType string, source string) int {
+	if accountType == QuotaLedgerAccountToken {
+		return QuotaLedgerSystemTokenAllocation
+	}
+	switch source {
+	case QuotaLedgerSourceConsume, QuotaLedgerSourcePreConsume, QuotaLedgerSourceRefund, QuotaLedgerSourceTask, QuotaLedgerSourceRecovery:
+		return QuotaLedgerSystemRevenue
+	case QuotaLedgerSourceOpening:
+		return QuotaLedgerSystemAdjustment
+	default:
+		return QuotaLedgerSystemIssuance
+	}
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+func newQuotaLedgerEntries(accountType string, accountId int, userId int, delta int64, ref QuotaLedgerRef) []*QuotaLedgerEntry {
+	transactionId := common.GetUUID()
+	now := common.GetTimestamp()
+	entry := func(accountType string, accountId int, delta int64) *QuotaLedgerEntry {
+		return &QuotaLedgerEntry{
+			TransactionId: transactionId,
+			AccountType:   accountType,
+			AccountId:     accountId,
+			UserId:        userId,
+			Delta:         delta,
+			Source:        ref.Source,
+			Reference:     truncateRunes(ref.Reference, 128),
+			Remark:        truncateRunes(ref.Remark, 255),
+			CreatedAt:     now,
+		}
+	}
+	return []*QuotaLedgerEntry{
+		entry(accountType, accountId, delta),
+		entry(QuotaLedgerAccountSystem, quotaLedgerCounterparty(accountType, ref.Source), -delta),
+	}
+}
+
+// recordQuotaLedger 记录一次额度变动，应与余额变动在同一事务内调用，保证只有余额变动成功时才记账
+func recordQuotaLedger(tx *gorm.DB, accountType string, accountId int, userId int, delta int64, ref QuotaLedgerRef) error {
+	if delta == 0 || !operation_setting.GetQuotaLedgerSetting().Enabled {
+		return nil
+	}
+	return createQuotaLedgerEntries(tx, newQuotaLedgerEntries(accountType, accountId, userId, delta, ref))
+}
+
+// createQuotaLedgerEntries 写入流水，令牌流水缺少所属用户时先在同一事务内查询补全
+func createQuotaLedgerEntries(tx *gorm.DB, entries []*QuotaLedgerEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	tokenUsers := make(map[int]int)
+	for _, entry := range entries {
+		if entry.AccountType != QuotaLedgerAccountToken || entry.UserId != 0 {
+			continue
+		}
+		userId, ok := tokenUsers[entry.AccountId]
+		if !ok {
+			if err := tx.Unscoped().Model(&Token{}).Where("id = ?", entry.AccountId).Select("user_id").Scan(&userId).Error; err != nil {
+				return err
+			}
+			tokenUsers[entry.AccountId] = userId
+		}
+		// 对手方记录与令牌记录共用同一个 TransactionId，一并补全
+		for _, e := range entries {
+			if e.TransactionId == entry.TransactionId {
+				e.UserId = userId
+			}
+		}
+	}
+	return tx.Create(&entries).Error
+}
+
+func RecordUserQuotaLedger(tx *gorm.DB, userId int, delta int, ref QuotaLedgerRef) error {
+	return recordQuotaLedger(tx, QuotaLedgerAccountUser, userId, userId, int64(delta), ref)
+}
+
+func RecordTokenQuotaLedger(tx *gorm.DB, tokenId int, userId int, delta int, ref QuotaLedgerRef) error {
+	return recordQuotaLedger(tx, QuotaLedgerAccountToken, tokenId, userId, int64(delta), ref)
+}
+
+func RecordOrganizationQuotaLedger(tx *gorm.DB, orgId int, userId int, delta int, ref QuotaLedgerRef) error {
+	return recordQuotaLedger(tx, QuotaLedgerAccountOrganization, orgId, userId, int64(delta), ref)
+}
+
+// newPendingQuotaLedgerEntries 开启批量更新时先生成流水暂存，随增量刷新时一并写入，userId 为 0 时刷新时补全
+func newPendingQuotaLedgerEntries(accountType string, accountId int, userId int, delta int, ref QuotaLedgerRef) []*QuotaLedgerEntry {
+	if delta == 0 || !operation_setting.GetQuotaLedgerSetting().Enabled {
+		return nil
+	}
+	return newQuotaLedgerEntries(accountType, accountId, userId, int64(delta), ref)
+}
+
+// RecordCheckinQuotaLedger 签到额度保存在 Redis 中，Redis 中的额度变动成功后再记账
+func RecordCheckinQuotaLedger(userId int, delta int, ref QuotaLedgerRef) {
+	if err := recordQuotaLedger(DB, QuotaLedgerAccountCheckin, userId, userId, int64(delta), ref); err != nil {
+		common.SysError("failed to record check-in quota ledger: " + err.Error())
+	}
+}
+
+// GrantCheckinQuotaLedger 签到额度在次日零点整体过期，记录新发放的额度前先冲销上一次未用完的余额
+func GrantCheckinQuotaLedger(userId int, quota int, ref QuotaLedgerRef) {
+	if !operation_setting.GetQuotaLedgerSetting().Enabled {
+		return
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var balance int64
+		err := tx.Model(&QuotaLedgerEntry{}).Select("COALESCE(SUM(delta), 0)").
+			Where("account_type = ? AND account_id = ?", QuotaLedgerAccountCheckin, userId).Scan(&balance).Error
+		if err != nil {
+			return err
+		}
+		err = recordQuotaLedger(tx, QuotaLedgerAccountCheckin, userId, userId, -balance, QuotaLedgerRef{Source: QuotaLedgerSourceExpire, Remark: "签到额度过期"})
+		if err != nil {
+			return err
+		}
+		return recordQuotaLedger(tx, QuotaLedgerAccountCheckin, userId, userId, int64(quota), ref)
+	})
+	if err != nil {
+		common.SysError("failed to record check-in quota ledger: " + err.Error())
+	}
+}
+
+type QuotaLedgerQuery struct {
+	AccountType    string
+	AccountId      int
+	UserId         int
+	Source         string
+	Reference      string
+	TransactionId  string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func GetQuotaLedgerEntries(query QuotaLedgerQuery, startIdx int, num int) (entries []*QuotaLedgerEntry, total int64, err error) {
+	tx := DB.Model(&QuotaLedgerEntry{})
+	if query.AccountType != "" {
+		tx = tx.Where("account_type = ?", query.AccountType)
+		if query.AccountId != 0 {
+			tx = tx.Where("account_id = ?", query.AccountId)
+		}
+	}
+	if query.UserId != 0 {
+		tx = tx.Where("user_id = ?", query.UserId)
+	}
+	if query.Source != "" {
+		tx = tx.Where("source = ?", query.Source)
+	}
+	if query.Reference != "" {
+		tx = tx.Where("reference = ?", query.Reference)
+	}
+	if query.TransactionId != "" {
+		tx = tx.Where("transaction_id = ?", query.TransactionId)
+	}
+	if query.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", query.StartTimestamp)
+	}
+	if query.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", query.EndTimestamp)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&entries).Error
+	return entries, total, err
+}
+
+// GetQuotaLedgerBalance 根据流水计算账户在 at 时刻的余额，at 为 0 表示当前
+func GetQuotaLedgerBalance(accountType string, accountId int, at int64) (int64, error) {
+	if accountType == "" || accountId == 0 {
+		return 0, errors.New("账户类型和账户 ID 不能为空")
+	}
+	var balance int64
+	tx := DB.Model(&QuotaLedgerEntry{}).Select("COALESCE(SUM(delta), 0)").
+		Where("account_type = ? AND account_id = ?", accountType, accountId)
+	if at != 0 {
+		tx = tx.Where("created_at <= ?", at)
+	}
+	err := tx.Scan(&balance).Error
+	return balance, err
+}
+
+type quotaLedgerBalanceRow struct {
+	AccountId int
+	Balance   int64
+	Entries   int64
+}
+
+// getQuotaLedgerBalances 批量计算账户当前余额，返回的 map 中不存在的账户表示没有任何流水
+func getQuotaLedgerBalances(accountType string, accountIds []int) (map[int]int64, error) {
+	balances := make(map[int]int64, len(accountIds))
+	if len(accountIds) == 0 {
+		return balances, nil
+	}
+	var rows []quotaLedgerBalanceRow
+	err := DB.Model(&QuotaLedgerEntry{}).
+		Select("account_id, COALESCE(SUM(delta), 0) AS balance, COUNT(*) AS entries").
+		Where("account_type = ? AND account_id IN ?", accountType, accountIds).
+		Group("account_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if row.Entries > 0 {
+			balances[row.AccountId] = row.Balance
+		}
+	}
+	return balances, nil
+}
+
+// GetQuotaLedgerImbalance 所有流水之和，复式记账下应恒为 0
+func GetQuotaLedgerImbalance() (int64, error) {
+	var sum int64
+	err := DB.Model(&QuotaLedgerEntry{}).Select("COALESCE(SUM(delta), 0)").Scan(&sum).Error
+	return sum, err
+}
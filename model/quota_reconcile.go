@@ -0,0 +1,203 @@
+package model
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	quotaReconcileBatchSize    = 500
+	quotaReconcileMaxDetails   = 200
+	quotaReconcileRecheckDelay = 3 * time.Second
+)
+
+// QuotaDrift 账户余额与流水余额不一致的记录
+type QuotaDrift struct {
+	AccountType   string `json:"account_type"`
+	AccountId     int    `json:"account_id"`
+	UserId        int    `json:"user_id"`
+	Balance       int64  `json:"balance"`        // 账户当前余额（含尚未落库的批量更新）
+	LedgerBalance int64  `json:"ledger_balance"` // 流水累计余额
+	Drift         int64  `json:"drift"`          // Balance - LedgerBalance
+}
+
+// QuotaReconcileReport 一次对账的结果
+type QuotaReconcileReport struct {
+	Id           int    `json:"id"`
+	StartedAt    int64  `json:"started_at" gorm:"bigint;index"`
+	FinishedAt   int64  `json:"finished_at" gorm:"bigint"`
+	UserCount    int    `json:"user_count"`
+	TokenCount   int    `json:"token_count"`
+	OpenedCount  int    `json:"opened_count"` // 本次补记期初余额的账户数
+	DriftCount   int    `json:"drift_count"`
+	TotalDrift   int64  `json:"total_drift"` // 偏差绝对值之和
+	Imbalance    int64  `json:"imbalance"`   // 全部流水之和，不为 0 说明存在单边记账
+	Details      string `json:"details" gorm:"type:text"`
+	ErrorMessage string `json:"error_message" gorm:"type:text"`
+}
+
+type quotaReconcileAccount struct {
+	Id      int
+	UserId  int
+	Balance int64
+}
+
+type quotaReconcileTarget struct {
+	accountType string
+	batchType   int
+	load        func(afterId int) ([]quotaReconcileAccount, error)
+	reload      func(id int) (quotaReconcileAccount, error)
+}
+
+var quotaReconcileTargets = []quotaReconcileTarget{
+	{
+		accountType: QuotaLedgerAccountUser,
+		batchType:   BatchUpdateTypeUserQuota,
+		load: func(afterId int) (accounts []quotaReconcileAccount, err error) {
+			err = DB.Model(&User{}).Select("id, id AS user_id, quota AS balance").
+				Where("id > ?", afterId).Order("id").Limit(quotaReconcileBatchSize).Scan(&accounts).Error
+			return accounts, err
+		},
+		reload: func(id int) (account quotaReconcileAccount, err error) {
+			err = DB.Model(&User{}).Select("id, id AS user_id, quota AS balance").Where("id = ?", id).Scan(&account).Error
+			return account, err
+		},
+	},
+	{
+		accountType: QuotaLedgerAccountToken,
+		batchType:   BatchUpdateTypeTokenQuota,
+		load: func(afterId int) (accounts []quotaReconcileAccount, err error) {
+			err = DB.Model(&Token{}).Select("id, user_id, remain_quota AS balance").
+				Where("id > ?", afterId).Order("id").Limit(quotaReconcileBatchSize).Scan(&accounts).Error
+			return accounts, err
+		},
+		reload: func(id int) (account quotaReconcileAccount, err error) {
+			err = DB.Model(&Token{}).Select("id, user_id, remain_quota AS balance").Where("id = ?", id).Scan(&account).Error
+			return account, err
+		},
+	},
+}
+
+// ReconcileQuotaLedger 比对流水余额与用户额度、令牌剩余额度。
+// 中转请求的流水是异步写入的，首轮发现的偏差会在短暂等待后复核，只保留复核后仍存在的偏差。
+// openMissing 为 true 时为没有任何流水的账户补记期初余额
+func ReconcileQuotaLedger(openMissing bool) (*QuotaReconcileReport, []QuotaDrift, error) {
+	report := &QuotaReconcileReport{StartedAt: common.GetTimestamp()}
+	suspects := make(map[string][]int)
+	for _, target := range quotaReconcileTargets {
+		afterId := 0
+		for {
+			accounts, err := target.load(afterId)
+			if err != nil {
+				return report, nil, err
+			}
+			if len(accounts) == 0 {
+				break
+			}
+			afterId = accounts[len(accounts)-1].Id
+			ids := make([]int, 0, len(accounts))
+			for _, account := range accounts {
+				ids = append(ids, account.Id)
+			}
+			ledgerBalances, err := getQuotaLedgerBalances(target.accountType, ids)
+			if err != nil {
+				return report, nil, err
+			}
+			for _, account := range accounts {
+				pending := int64(getPendingBatchUpdate(target.batchType, account.Id))
+				ledgerBalance, ok := ledgerBalances[account.Id]
+				if !ok {
+					if !openMissing || pending != 0 || account.Balance == 0 {
+						continue
+					}
+					err = recordQuotaLedger(DB, target.accountType, account.Id, account.UserId, account.Balance, QuotaLedgerRef{
+						Source: QuotaLedgerSourceOpening,
+						Remark: "对账补记期初余额",
+					})
+					if err != nil {
+						return report, nil, err
+					}
+					report.OpenedCount++
+					continue
+				}
+				if account.Balance+pending != ledgerBalance {
+					suspects[target.accountType] = append(suspects[target.accountType], account.Id)
+				}
+			}
+			if target.accountType == QuotaLedgerAccountUser {
+				report.UserCount += len(accounts)
+			} else {
+				report.TokenCount += len(accounts)
+			}
+		}
+	}
+
+	drifts := make([]QuotaDrift, 0)
+	if len(suspects) > 0 {
+		time.Sleep(quotaReconcileRecheckDelay)
+		for _, target := range quotaReconcileTargets {
+			for _, id := range suspects[target.accountType] {
+				account, err := target.reload(id)
+				if err != nil {
+					return report, nil, err
+				}
+				ledgerBalance, err := GetQuotaLedgerBalance(target.accountType, id, 0)
+				if err != nil {
+					return report, nil, err
+				}
+				balance := account.Balance + int64(getPendingBatchUpdate(target.batchType, id))
+				if balance == ledgerBalance {
+					continue
+				}
+				drift := QuotaDrift{
+					AccountType:   target.accountType,
+					AccountId:     id,
+					UserId:        account.UserId,
+					Balance:       balance,
+					LedgerBalance: ledgerBalance,
+					Drift:         balance - ledgerBalance,
+				}
+				drifts = append(drifts, drift)
+				if drift.Drift < 0 {
+					report.TotalDrift -= drift.Drift
+				} else {
+					report.TotalDrift += drift.Drift
+				}
+			}
+		}
+	}
+	report.DriftCount = len(drifts)
+
+	imbalance, err := GetQuotaLedgerImbalance()
+	if err != nil {
+		return report, drifts, err
+	}
+	report.Imbalance = imbalance
+	return report, drifts, nil
+}
+
+// SaveQuotaReconcileReport 保存对账报告，明细最多保留 quotaReconcileMaxDetails 条
+func SaveQuotaReconcileReport(report *QuotaReconcileReport, drifts []QuotaDrift) error {
+	if len(drifts) > quotaReconcileMaxDetails {
+		drifts = drifts[:quotaReconcileMaxDetails]
+	}
+	if len(drifts) > 0 {
+		details, err := common.Marshal(drifts)
+		if err != nil {
+			return err
+		}
+		report.Details = string(details)
+	}
+	report.FinishedAt = common.GetTimestamp()
+	return DB.Create(report).Error
+}
+
+func GetQuotaReconcileReports(startIdx int, num int) (reports []*QuotaReconcileReport, total int64, err error) {
+	tx := DB.Model(&QuotaReconcileReport{})
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&reports).Error
+	return reports, total, err
+}
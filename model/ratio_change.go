@@ -0,0 +1,184 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	RatioChangeSetStatusPending    = "pending"
+	RatioChangeSetStatusApproved   = "approved"
+	RatioChangeSetStatusRejected   = "rejected"
+	RatioChangeSetStatusSuperseded = "superseded" // 有新的同步结果时旧的待审核变更集自动失效
+
+	RatioChangeSourceAuto     = "auto"     // 满足自动应用规则
+	RatioChangeSourceApproved = "approved" // 管理员审核通过
+)
+
+// RatioChangeItem 变更集中的一项，Current 为 nil 表示本地未配置
+type RatioChangeItem struct {
+	Model         string   `json:"model"`
+	RatioType     string   `json:"ratio_type"`
+	Current       *float64 `json:"current"`
+	Proposed      float64  `json:"proposed"`
+	Upstream      string   `json:"upstream"`
+	ChangePercent float64  `json:"change_percent"` // 相对本地值的变动百分比，本地未配置时为 0
+	ExceedsLimit  bool     `json:"exceeds_limit"`  // 超过最大变动幅度，只能人工审核
+	Applied       bool     `json:"applied"`
+}
+
+// RatioChangeSet 一次定时同步产生的待审核倍率变更
+type RatioChangeSet struct {
+	Id          int    `json:"id"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint;index"`
+	Status      string `json:"status" gorm:"type:varchar(16);index"`
+	Source      string `json:"source" gorm:"type:text"` // 成功拉取的上游，逗号分隔
+	Changes     string `json:"changes" gorm:"type:text"`
+	ChangeCount int    `json:"change_count"`
+	ReviewedBy  int    `json:"reviewed_by"`
+	ReviewedAt  int64  `json:"reviewed_at" gorm:"bigint"`
+	Remark      string `json:"remark" gorm:"type:text"`
+}
+
+func (set *RatioChangeSet) GetChanges() []*RatioChangeItem {
+	items := make([]*RatioChangeItem, 0)
+	if set.Changes == "" {
+		return items
+	}
+	if err := common.UnmarshalJsonStr(set.Changes, &items); err != nil {
+		common.SysError("failed to unmarshal ratio changes: " + err.Error())
+	}
+	return items
+}
+
+func (set *RatioChangeSet) SetChanges(items []*RatioChangeItem) {
+	data, _ := common.Marshal(items)
+	set.Changes = string(data)
+	set.ChangeCount = len(items)
+}
+
+func (set *RatioChangeSet) Insert() error {
+	set.CreatedAt = common.GetTimestamp()
+	return DB.Create(set).Error
+}
+
+func (set *RatioChangeSet) UpdateChanges() error {
+	return DB.Model(set).Update("changes", set.Changes).Error
+}
+
+// Review 处理待审核的变更集，变更集已被处理时返回错误，避免重复应用
+func (set *RatioChangeSet) Review(status string, reviewedBy int, remark string) error {
+	now := common.GetTimestamp()
+	result := DB.Model(&RatioChangeSet{}).
+		Where("id = ? AND status = ?", set.Id, RatioChangeSetStatusPending).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"changes":     set.Changes,
+			"reviewed_by": reviewedBy,
+			"reviewed_at": now,
+			"remark":      remark,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("变更集已被处理")
+	}
+	set.Status = status
+	set.ReviewedBy = reviewedBy
+	set.ReviewedAt = now
+	set.Remark = remark
+	return nil
+}
+
+func GetRatioChangeSetById(id int) (*RatioChangeSet, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	var set RatioChangeSet
+	err := DB.First(&set, "id = ?", id).Error
+	return &set, err
+}
+
+func GetRatioChangeSets(status string, startIdx int, num int) (sets []*RatioChangeSet, total int64, err error) {
+	tx := DB.Model(&RatioChangeSet{})
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&sets).Error
+	return sets, total, err
+}
+
+// SupersedePendingRatioChangeSets 将所有待审核的变更集标记为已失效
+func SupersedePendingRatioChangeSets() error {
+	return DB.Model(&RatioChangeSet{}).
+		Where("status = ?", RatioChangeSetStatusPending).
+		Updates(map[string]interface{}{
+			"status":      RatioChangeSetStatusSuperseded,
+			"reviewed_at": common.GetTimestamp(),
+		}).Error
+}
+
+// DeleteOldRatioChangeSets 分批删除早于 targetTimestamp 且已处理的变更集
+func DeleteOldRatioChangeSets(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
+	var total int64 = 0
+	for {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		result := DB.Where("created_at < ? AND status <> ?", targetTimestamp, RatioChangeSetStatusPending).Limit(limit).Delete(&RatioChangeSet{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+	return total, nil
+}
+
+// RatioChangeLog 倍率变更审计日志，OldValue/NewValue 为 nil 表示未配置或已删除
+type RatioChangeLog struct {
+	Id          int      `json:"id"`
+	CreatedAt   int64    `json:"created_at" gorm:"bigint;index"`
+	ChangeSetId int      `json:"change_set_id" gorm:"index"`
+	ModelName   string   `json:"model_name" gorm:"type:varchar(255);index"`
+	RatioType   string   `json:"ratio_type" gorm:"type:varchar(32)"`
+	OldValue    *float64 `json:"old_value"`
+	NewValue    *float64 `json:"new_value"`
+	Source      string   `json:"source" gorm:"type:varchar(16)"`
+	Upstream    string   `json:"upstream" gorm:"type:varchar(255)"`
+	OperatorId  int      `json:"operator_id"`
+}
+
+func InsertRatioChangeLogs(logs []*RatioChangeLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	now := common.GetTimestamp()
+	for _, log := range logs {
+		log.CreatedAt = now
+	}
+	return DB.Create(&logs).Error
+}
+
+func GetRatioChangeLogs(modelName string, changeSetId int, startIdx int, num int) (logs []*RatioChangeLog, total int64, err error) {
+	tx := DB.Model(&RatioChangeLog{})
+	if modelName != "" {
+		tx = tx.Where("model_name = ?", modelName)
+	}
+	if changeSetId != 0 {
+		tx = tx.Where("change_set_id = ?", changeSetId)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&logs).Error
+	return logs, total, err
+}
@@ -141,6 +141,10 @@ func Redeem(key string, userId int) (quota int, err error) {
 		if err != nil {
 			return err
 		}
+		err = RecordUserQuotaLedger(tx, userId, redemption.Quota, QuotaLedgerRef{Source: QuotaLedgerSourceRedemption, Reference: fmt.Sprintf("redemption:%d", redemption.Id), Remark: "兑换码充值"})
+		if err != nil {
+			return err
+		}
 		redemption.RedeemedTime = common.GetTimestamp()
 		redemption.Status = common.RedemptionCodeStatusUsed
 		redemption.UsedUserId = userId
@@ -0,0 +1,187 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	StatusSampleKindModel = "model"
+	StatusSampleKindGroup = "group"
+
+	StatusSampleSourceTraffic = "traffic"
+	StatusSampleSourceTest    = "test"
+
+	StatusIncidentTypeIncident    = "incident"
+	StatusIncidentTypeMaintenance = "maintenance"
+
+	StatusIncidentResolved = "resolved"
+)
+
+// StatusSample 按小时聚合的可用性样本，来源为真实请求或渠道测试
+type StatusSample struct {
+	Id         int    `json:"id"`
+	BucketTime int64  `json:"bucket_time" gorm:"bigint;index"`
+	Kind       string `json:"kind" gorm:"type:varchar(16);index"`
+	Name       string `json:"name" gorm:"type:varchar(128)"`
+	Source     string `json:"source" gorm:"type:varchar(16)"`
+	Total      int64  `json:"total" gorm:"default:0"`
+	Success    int64  `json:"success" gorm:"default:0"`
+	LatencySum int64  `json:"latency_sum" gorm:"default:0"` // 成功请求耗时总和，单位毫秒
+}
+
+// StatusSampleStat 某个组件在某个时间桶内的统计
+type StatusSampleStat struct {
+	Bucket     int64  `json:"bucket"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Total      int64  `json:"total"`
+	Success    int64  `json:"success"`
+	LatencySum int64  `json:"latency_sum"`
+}
+
+// IncreaseStatusSample 累加到已有的样本，不存在时新建
+func IncreaseStatusSample(sample *StatusSample) error {
+	result := LOG_DB.Model(&StatusSample{}).
+		Where("bucket_time = ? and kind = ? and name = ? and source = ?", sample.BucketTime, sample.Kind, sample.Name, sample.Source).
+		Updates(map[string]interface{}{
+			"total":       gorm.Expr("total + ?", sample.Total),
+			"success":     gorm.Expr("success + ?", sample.Success),
+			"latency_sum": gorm.Expr("latency_sum + ?", sample.LatencySum),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	return LOG_DB.Create(sample).Error
+}
+
+// GetStatusSampleStats 以 bucketSeconds 为粒度统计 startTimestamp 之后各组件的样本，不区分来源
+func GetStatusSampleStats(startTimestamp int64, bucketSeconds int64) (stats []*StatusSampleStat, err error) {
+	err = LOG_DB.Model(&StatusSample{}).
+		Select("bucket_time - bucket_time % ? AS bucket, kind, name, SUM(total) AS total, SUM(success) AS success, SUM(latency_sum) AS latency_sum", bucketSeconds).
+		Where("bucket_time >= ?", startTimestamp).
+		Group("bucket, kind, name").
+		Scan(&stats).Error
+	return stats, err
+}
+
+// DeleteOldStatusSamples 分批删除早于 targetTimestamp 的样本
+func DeleteOldStatusSamples(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
+	var total int64 = 0
+	for {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		result := LOG_DB.Where("bucket_time < ?", targetTimestamp).Limit(limit).Delete(&StatusSample{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+	return total, nil
+}
+
+// StatusIncident 管理员发布的故障或维护公告，AffectedModels/AffectedGroups 为受影响的组件，逗号分隔
+type StatusIncident struct {
+	Id             int    `json:"id"`
+	Type           string `json:"type" gorm:"type:varchar(16);index"`
+	Title          string `json:"title" gorm:"type:varchar(255)"`
+	Content        string `json:"content" gorm:"type:text"`
+	Severity       string `json:"severity" gorm:"type:varchar(16)"` // minor / major / critical
+	Status         string `json:"status" gorm:"type:varchar(16)"`   // investigating / identified / monitoring / resolved
+	AffectedModels string `json:"affected_models" gorm:"type:text"`
+	AffectedGroups string `json:"affected_groups" gorm:"type:text"`
+	StartTime      int64  `json:"start_time" gorm:"bigint;index"`
+	EndTime        int64  `json:"end_time" gorm:"bigint;index"` // 维护的计划结束时间或故障的恢复时间，0 表示未结束
+	CreatedAt      int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt      int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func (incident *StatusIncident) GetModels() []string {
+	return splitStatusComponents(incident.AffectedModels)
+}
+
+func (incident *StatusIncident) GetGroups() []string {
+	return splitStatusComponents(incident.AffectedGroups)
+}
+
+func splitStatusComponents(s string) []string {
+	components := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			components = append(components, item)
+		}
+	}
+	return components
+}
+
+// IsActive 判断公告在 now 时刻是否生效
+func (incident *StatusIncident) IsActive(now int64) bool {
+	if incident.StartTime > now {
+		return false
+	}
+	if incident.Type == StatusIncidentTypeMaintenance {
+		return incident.EndTime == 0 || incident.EndTime > now
+	}
+	return incident.Status != StatusIncidentResolved
+}
+
+func (incident *StatusIncident) Insert() error {
+	now := common.GetTimestamp()
+	incident.CreatedAt = now
+	incident.UpdatedAt = now
+	return DB.Create(incident).Error
+}
+
+func (incident *StatusIncident) Update() error {
+	incident.UpdatedAt = common.GetTimestamp()
+	return DB.Model(incident).Select("type", "title", "content", "severity", "status", "affected_models", "affected_groups", "start_time", "end_time", "updated_at").Updates(incident).Error
+}
+
+func GetStatusIncidentById(id int) (*StatusIncident, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	var incident StatusIncident
+	err := DB.First(&incident, "id = ?", id).Error
+	return &incident, err
+}
+
+func DeleteStatusIncidentById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	return DB.Delete(&StatusIncident{}, "id = ?", id).Error
+}
+
+func GetStatusIncidents(incidentType string, startIdx int, num int) (incidents []*StatusIncident, total int64, err error) {
+	tx := DB.Model(&StatusIncident{})
+	if incidentType != "" {
+		tx = tx.Where("type = ?", incidentType)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("start_time desc, id desc").Limit(num).Offset(startIdx).Find(&incidents).Error
+	return incidents, total, err
+}
+
+// GetPublicStatusIncidents 获取状态页需要展示的公告：未结束的、尚未开始的维护，以及 since 之后结束的
+func GetPublicStatusIncidents(since int64) (incidents []*StatusIncident, err error) {
+	err = DB.Where("end_time = 0 OR end_time >= ? OR start_time >= ?", since, since).
+		Order("start_time desc, id desc").
+		Limit(50).
+		Find(&incidents).Error
+	return incidents, err
+}
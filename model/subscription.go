@@ -0,0 +1,609 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SubscriptionStatusPending  = "pending"  // 已创建支付，等待支付回调
+	SubscriptionStatusActive   = "active"   // 生效中
+	SubscriptionStatusExpired  = "expired"  // 已到期或已取消
+	SubscriptionStatusCanceled = "canceled" // 支付未完成，订单已关闭
+)
+
+const SubscriptionPaymentAdmin = "admin"
+
+var ErrSubscriptionNotFound = errors.New("订阅不存在")
+
+// ErrSubscriptionAlreadyActive 支付完成时用户已有生效中的订阅，该订单已关闭，需要在支付平台取消并退款
+var ErrSubscriptionAlreadyActive = errors.New("用户已有生效中的订阅")
+
+// SubscriptionCheckoutTTL 订阅支付页面的有效期（秒），期间不允许再次下单，避免重复支付
+const SubscriptionCheckoutTTL = 30 * 60
+
+// SubscriptionPlan 订阅套餐，价格按月计费，实际扣款金额以支付平台配置的价格为准
+type SubscriptionPlan struct {
+	Id                    int     `json:"id"`
+	Name                  string  `json:"name" gorm:"type:varchar(64)"`
+	Description           string  `json:"description" gorm:"type:varchar(255)"`
+	Price                 float64 `json:"price"`
+	Currency              string  `json:"currency" gorm:"type:varchar(8)"`
+	Quota                 int     `json:"quota"`                                    // 每个周期包含的额度，周期结束时未用完的部分作废
+	UserGroup             string  `json:"user_group" gorm:"type:varchar(64)"`       // 订阅期间用户所在分组，为空不调整
+	AllowedGroups         string  `json:"allowed_groups" gorm:"type:text"`          // 允许使用的分组，逗号分隔，为空不限制
+	AllowedModels         string  `json:"allowed_models" gorm:"type:text"`          // 允许使用的模型，逗号分隔，为空不限制
+	RateLimitCount        int     `json:"rate_limit_count"`                         // 限流周期内最多请求次数（包括失败），0 表示使用分组配置
+	RateLimitSuccessCount int     `json:"rate_limit_success_count"`                 // 限流周期内最多成功请求次数，0 表示使用分组配置
+	StripePriceId         string  `json:"stripe_price_id" gorm:"type:varchar(128)"` // Stripe 按月循环扣款的价格 ID
+	CreemProductId        string  `json:"creem_product_id" gorm:"type:varchar(128)"`
+	Enabled               bool    `json:"enabled"`
+	SortOrder             int     `json:"sort_order"`
+	CreatedTime           int64   `json:"created_time" gorm:"bigint"`
+	UpdatedTime           int64   `json:"updated_time" gorm:"bigint"`
+}
+
+// UserSubscription 用户订阅，每个用户同时最多一个生效中的订阅
+type UserSubscription struct {
+	Id                     int               `json:"id"`
+	UserId                 int               `json:"user_id" gorm:"index"`
+	PlanId                 int               `json:"plan_id" gorm:"index"`
+	Status                 string            `json:"status" gorm:"type:varchar(16);index"`
+	PaymentMethod          string            `json:"payment_method" gorm:"type:varchar(16)"`
+	TradeNo                string            `json:"trade_no" gorm:"type:varchar(64);index"`
+	ProviderSubscriptionId string            `json:"provider_subscription_id" gorm:"type:varchar(128);index"` // 支付平台的订阅 ID
+	LastPaymentId          string            `json:"last_payment_id" gorm:"type:varchar(128)"`                // 最近一次处理的扣款，用于续费回调去重
+	PeriodStart            int64             `json:"period_start" gorm:"bigint"`
+	PeriodEnd              int64             `json:"period_end" gorm:"bigint;index"`
+	CancelAtPeriodEnd      bool              `json:"cancel_at_period_end"`
+	PeriodQuota            int               `json:"period_quota"`    // 本周期发放的包含额度
+	UsedQuotaBase          int               `json:"used_quota_base"` // 本周期开始时用户的已用额度，用于计算包含额度的使用量
+	PreviousGroup          string            `json:"previous_group" gorm:"type:varchar(64)"`
+	CreatedTime            int64             `json:"created_time" gorm:"bigint"`
+	UpdatedTime            int64             `json:"updated_time" gorm:"bigint"`
+	Plan                   *SubscriptionPlan `json:"plan,omitempty" gorm:"-"`
+}
+
+func splitSubscriptionList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (plan *SubscriptionPlan) Insert() error {
+	plan.CreatedTime = common.GetTimestamp()
+	plan.UpdatedTime = plan.CreatedTime
+	return DB.Create(plan).Error
+}
+
+func (plan *SubscriptionPlan) Update() error {
+	plan.UpdatedTime = common.GetTimestamp()
+	err := DB.Model(plan).Select("name", "description", "price", "currency", "quota", "user_group", "allowed_groups",
+		"allowed_models", "rate_limit_count", "rate_limit_success_count", "stripe_price_id", "creem_product_id",
+		"enabled", "sort_order", "updated_time").Updates(plan).Error
+	if err == nil {
+		invalidateSubscriptionEntitlements()
+	}
+	return err
+}
+
+func GetSubscriptionPlans(enabledOnly bool) (plans []*SubscriptionPlan, err error) {
+	tx := DB.Model(&SubscriptionPlan{})
+	if enabledOnly {
+		tx = tx.Where("enabled = ?", true)
+	}
+	err = tx.Order("sort_order desc, id").Find(&plans).Error
+	return plans, err
+}
+
+func GetSubscriptionPlanById(id int) (*SubscriptionPlan, error) {
+	plan := &SubscriptionPlan{}
+	err := DB.Where("id = ?", id).First(plan).Error
+	return plan, err
+}
+
+// DeleteSubscriptionPlanById 删除套餐，仍有生效中或待支付订阅的套餐不能删除
+func DeleteSubscriptionPlanById(id int) error {
+	var count int64
+	err := DB.Model(&UserSubscription{}).Where("plan_id = ? AND status IN ?", id,
+		[]string{SubscriptionStatusActive, SubscriptionStatusPending}).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该套餐仍有生效中的订阅，请先停用套餐")
+	}
+	return DB.Delete(&SubscriptionPlan{}, id).Error
+}
+
+func fillSubscriptionPlans(subs []*UserSubscription) error {
+	if len(subs) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.PlanId)
+	}
+	var plans []*SubscriptionPlan
+	if err := DB.Where("id IN ?", ids).Find(&plans).Error; err != nil {
+		return err
+	}
+	planMap := make(map[int]*SubscriptionPlan, len(plans))
+	for _, plan := range plans {
+		planMap[plan.Id] = plan
+	}
+	for _, sub := range subs {
+		sub.Plan = planMap[sub.PlanId]
+	}
+	return nil
+}
+
+// GetUserActiveSubscription 获取用户生效中的订阅，没有时返回 nil
+func GetUserActiveSubscription(userId int) (*UserSubscription, error) {
+	var subs []*UserSubscription
+	err := DB.Where("user_id = ? AND status = ?", userId, SubscriptionStatusActive).Order("id desc").Limit(1).Find(&subs).Error
+	if err != nil || len(subs) == 0 {
+		return nil, err
+	}
+	if err = fillSubscriptionPlans(subs); err != nil {
+		return nil, err
+	}
+	return subs[0], nil
+}
+
+func GetUserSubscriptionById(id int) (*UserSubscription, error) {
+	sub := &UserSubscription{}
+	if err := DB.Where("id = ?", id).First(sub).Error; err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func GetUserSubscriptionByTradeNo(tradeNo string) (*UserSubscription, error) {
+	sub := &UserSubscription{}
+	if err := DB.Where("trade_no = ?", tradeNo).First(sub).Error; err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// GetUserSubscriptionByProviderId 按支付平台的订阅 ID 查询最近一条订阅
+func GetUserSubscriptionByProviderId(providerSubscriptionId string) (*UserSubscription, error) {
+	if providerSubscriptionId == "" {
+		return nil, ErrSubscriptionNotFound
+	}
+	var subs []*UserSubscription
+	err := DB.Where("provider_subscription_id = ?", providerSubscriptionId).Order("id desc").Limit(1).Find(&subs).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(subs) == 0 {
+		return nil, ErrSubscriptionNotFound
+	}
+	return subs[0], nil
+}
+
+func GetUserSubscriptions(userId int, status string, startIdx int, num int) (subs []*UserSubscription, total int64, err error) {
+	tx := DB.Model(&UserSubscription{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&subs).Error; err != nil {
+		return nil, 0, err
+	}
+	err = fillSubscriptionPlans(subs)
+	return subs, total, err
+}
+
+// CreatePendingSubscription 创建待支付的订阅，支付回调后生效
+func CreatePendingSubscription(userId int, planId int, paymentMethod string, tradeNo string) (*UserSubscription, error) {
+	var count int64
+	err := DB.Model(&UserSubscription{}).Where("user_id = ? AND status = ?", userId, SubscriptionStatusActive).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("已有生效中的订阅，请使用变更套餐")
+	}
+	now := common.GetTimestamp()
+	err = DB.Model(&UserSubscription{}).Where("user_id = ? AND status = ? AND created_time >= ?",
+		userId, SubscriptionStatusPending, now-SubscriptionCheckoutTTL).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("已有待支付的订阅订单，请完成支付或 %d 分钟后再试", SubscriptionCheckoutTTL/60)
+	}
+	sub := &UserSubscription{
+		UserId:        userId,
+		PlanId:        planId,
+		Status:        SubscriptionStatusPending,
+		PaymentMethod: paymentMethod,
+		TradeNo:       tradeNo,
+		CreatedTime:   now,
+		UpdatedTime:   now,
+	}
+	return sub, DB.Create(sub).Error
+}
+
+func lockUserSubscription(tx *gorm.DB, query string, args ...interface{}) (*UserSubscription, error) {
+	sub := &UserSubscription{}
+	err := tx.Set("gorm:query_option", "FOR UPDATE").Where(query, args...).Order("id desc").First(sub).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSubscriptionNotFound
+	}
+	return sub, err
+}
+
+func adjustSubscriptionQuota(tx *gorm.DB, sub *UserSubscription, delta int, remark string) error {
+	if delta == 0 {
+		return nil
+	}
+	if err := tx.Model(&User{}).Where("id = ?", sub.UserId).Update("quota", gorm.Expr("quota + ?", delta)).Error; err != nil {
+		return err
+	}
+	return RecordUserQuotaLedger(tx, sub.UserId, delta, QuotaLedgerRef{
+		Source:    QuotaLedgerSourceSubscription,
+		Reference: fmt.Sprintf("subscription:%d", sub.Id),
+		Remark:    remark,
+	})
+}
+
+// unusedSubscriptionQuota 本周期尚未使用的包含额度。用户额度只有一个余额，消费时优先视为使用包含额度，
+// 因此未使用部分 = 发放额度 - 本周期已用额度，且不超过用户当前余额
+func unusedSubscriptionQuota(tx *gorm.DB, sub *UserSubscription) (int, error) {
+	var user User
+	if err := tx.Select("id", "quota", "used_quota").Where("id = ?", sub.UserId).First(&user).Error; err != nil {
+		return 0, err
+	}
+	used := user.UsedQuota + getPendingBatchUpdate(BatchUpdateTypeUsedQuota, user.Id) - sub.UsedQuotaBase
+	balance := user.Quota + getPendingBatchUpdate(BatchUpdateTypeUserQuota, user.Id)
+	unused := sub.PeriodQuota - used
+	if unused > balance {
+		unused = balance
+	}
+	if unused < 0 {
+		unused = 0
+	}
+	return unused, nil
+}
+
+func nextSubscriptionPeriodEnd(start int64) int64 {
+	return time.Unix(start, 0).AddDate(0, 1, 0).Unix()
+}
+
+// startSubscriptionPeriod 开始新周期：发放包含额度并记录已用额度基线
+func startSubscriptionPeriod(tx *gorm.DB, sub *UserSubscription, plan *SubscriptionPlan, start int64, end int64) error {
+	var user User
+	if err := tx.Select("id", "used_quota").Where("id = ?", sub.UserId).First(&user).Error; err != nil {
+		return err
+	}
+	if end <= start {
+		end = nextSubscriptionPeriodEnd(start)
+	}
+	sub.PeriodStart = start
+	sub.PeriodEnd = end
+	sub.PeriodQuota = plan.Quota
+	sub.UsedQuotaBase = user.UsedQuota + getPendingBatchUpdate(BatchUpdateTypeUsedQuota, user.Id)
+	sub.UpdatedTime = common.GetTimestamp()
+	if err := adjustSubscriptionQuota(tx, sub, plan.Quota, fmt.Sprintf("订阅 %s 本周期包含额度", plan.Name)); err != nil {
+		return err
+	}
+	return tx.Save(sub).Error
+}
+
+// ActivateSubscription 支付成功后激活待支付的订阅，重复回调直接返回。
+// 用户已有生效中的订阅时关闭该订单并返回 ErrSubscriptionAlreadyActive，由调用方在支付平台取消订阅并退款
+func ActivateSubscription(tradeNo string, providerSubscriptionId string, paymentId string, periodEnd int64) error {
+	if tradeNo == "" {
+		return errors.New("未提供订阅订单号")
+	}
+	var sub *UserSubscription
+	var plan *SubscriptionPlan
+	activated := false
+	conflict := false
+	closed := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		sub, err = lockUserSubscription(tx, "trade_no = ?", tradeNo)
+		if err != nil {
+			return err
+		}
+		if sub.Status == SubscriptionStatusCanceled && providerSubscriptionId != "" && sub.ProviderSubscriptionId == providerSubscriptionId {
+			// 此前因已有生效中的订阅而关闭，重复回调时仍需调用方完成取消和退款
+			conflict = true
+			return nil
+		}
+		if sub.Status != SubscriptionStatusPending {
+			return nil
+		}
+		var count int64
+		err = tx.Model(&UserSubscription{}).Where("user_id = ? AND status = ?", sub.UserId, SubscriptionStatusActive).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			conflict = true
+			closed = true
+			return tx.Model(sub).Updates(map[string]interface{}{
+				"status":                   SubscriptionStatusCanceled,
+				"provider_subscription_id": providerSubscriptionId,
+				"last_payment_id":          paymentId,
+				"updated_time":             common.GetTimestamp(),
+			}).Error
+		}
+		plan = &SubscriptionPlan{}
+		if err = tx.Where("id = ?", sub.PlanId).First(plan).Error; err != nil {
+			return err
+		}
+		var user User
+		if err = tx.Select("id", "group").Where("id = ?", sub.UserId).First(&user).Error; err != nil {
+			return err
+		}
+		sub.PreviousGroup = user.Group
+		if plan.UserGroup != "" && plan.UserGroup != user.Group {
+			if err = tx.Model(&User{}).Where("id = ?", sub.UserId).Update("group", plan.UserGroup).Error; err != nil {
+				return err
+			}
+		}
+		sub.Status = SubscriptionStatusActive
+		sub.ProviderSubscriptionId = providerSubscriptionId
+		sub.LastPaymentId = paymentId
+		activated = true
+		return startSubscriptionPeriod(tx, sub, plan, common.GetTimestamp(), periodEnd)
+	})
+	if err != nil {
+		return err
+	}
+	if closed {
+		RecordLog(sub.UserId, LogTypeSystem, fmt.Sprintf("订阅订单 %s 支付时已有生效中的订阅，订单已关闭，重复扣款将退回", tradeNo))
+	}
+	if conflict {
+		return ErrSubscriptionAlreadyActive
+	}
+	if !activated {
+		return nil
+	}
+	afterSubscriptionChanged(sub.UserId)
+	RecordLog(sub.UserId, LogTypeTopup, fmt.Sprintf("订阅套餐 %s 已生效，包含额度 %s，到期时间 %s", plan.Name,
+		logger.LogQuota(plan.Quota), time.Unix(sub.PeriodEnd, 0).Format("2006-01-02 15:04:05")))
+	return nil
+}
+
+// GrantSubscription 管理员直接为用户开通订阅，不经过支付平台，到期后不会自动续费
+func GrantSubscription(userId int, planId int) error {
+	if _, err := GetUserById(userId, false); err != nil {
+		return err
+	}
+	tradeNo := fmt.Sprintf("admin-%d-%s", userId, common.GetRandomString(8))
+	if _, err := CreatePendingSubscription(userId, planId, SubscriptionPaymentAdmin, tradeNo); err != nil {
+		return err
+	}
+	return ActivateSubscription(tradeNo, "", "", 0)
+}
+
+// RenewSubscription 续费成功后开始新周期：作废上周期未使用的包含额度，重新发放。
+// paymentId 相同的重复回调直接返回，periodEnd 为 0 时顺延一个月
+func RenewSubscription(providerSubscriptionId string, paymentId string, periodEnd int64) error {
+	if providerSubscriptionId == "" {
+		return ErrSubscriptionNotFound
+	}
+	var sub *UserSubscription
+	var plan *SubscriptionPlan
+	renewed := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		sub, err = lockUserSubscription(tx, "provider_subscription_id = ? AND status = ?", providerSubscriptionId, SubscriptionStatusActive)
+		if err != nil {
+			return err
+		}
+		if paymentId != "" && sub.LastPaymentId == paymentId {
+			return nil
+		}
+		plan = &SubscriptionPlan{}
+		if err = tx.Where("id = ?", sub.PlanId).First(plan).Error; err != nil {
+			return err
+		}
+		unused, err := unusedSubscriptionQuota(tx, sub)
+		if err != nil {
+			return err
+		}
+		if err = adjustSubscriptionQuota(tx, sub, -unused, "订阅周期结束，未使用的包含额度作废"); err != nil {
+			return err
+		}
+		now := common.GetTimestamp()
+		start := sub.PeriodEnd
+		if start > now || start < now-int64(operation_setting.GetSubscriptionSetting().GracePeriodHours)*3600 {
+			start = now
+		}
+		sub.LastPaymentId = paymentId
+		renewed = true
+		return startSubscriptionPeriod(tx, sub, plan, start, periodEnd)
+	})
+	if err != nil || !renewed {
+		return err
+	}
+	afterSubscriptionChanged(sub.UserId)
+	RecordLog(sub.UserId, LogTypeTopup, fmt.Sprintf("订阅套餐 %s 已续费，包含额度重置为 %s，到期时间 %s", plan.Name,
+		logger.LogQuota(plan.Quota), time.Unix(sub.PeriodEnd, 0).Format("2006-01-02 15:04:05")))
+	return nil
+}
+
+// ExpireSubscription 订阅到期或取消：作废未使用的包含额度，用户回到默认分组
+func ExpireSubscription(id int, reason string) error {
+	var sub *UserSubscription
+	expired := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		sub, err = lockUserSubscription(tx, "id = ?", id)
+		if err != nil {
+			return err
+		}
+		if sub.Status != SubscriptionStatusActive {
+			return nil
+		}
+		unused, err := unusedSubscriptionQuota(tx, sub)
+		if err != nil {
+			return err
+		}
+		if err = adjustSubscriptionQuota(tx, sub, -unused, "订阅结束，未使用的包含额度作废"); err != nil {
+			return err
+		}
+		plan := &SubscriptionPlan{}
+		if err = tx.Where("id = ?", sub.PlanId).First(plan).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err = restoreSubscriptionGroup(tx, sub, plan.UserGroup); err != nil {
+			return err
+		}
+		now := common.GetTimestamp()
+		if sub.PeriodEnd > now {
+			sub.PeriodEnd = now
+		}
+		sub.Status = SubscriptionStatusExpired
+		sub.UpdatedTime = now
+		expired = true
+		return tx.Save(sub).Error
+	})
+	if err != nil || !expired {
+		return err
+	}
+	afterSubscriptionChanged(sub.UserId)
+	RecordLog(sub.UserId, LogTypeManage, fmt.Sprintf("订阅已结束（%s）", reason))
+	return nil
+}
+
+// restoreSubscriptionGroup 用户仍在套餐分组时才调整，避免覆盖管理员期间手动修改的分组
+func restoreSubscriptionGroup(tx *gorm.DB, sub *UserSubscription, planGroup string) error {
+	if planGroup == "" {
+		return nil
+	}
+	var user User
+	if err := tx.Select("id", "group").Where("id = ?", sub.UserId).First(&user).Error; err != nil {
+		return err
+	}
+	if user.Group != planGroup {
+		return nil
+	}
+	group := operation_setting.GetSubscriptionSetting().ExpiredGroup
+	if group == "" {
+		group = sub.PreviousGroup
+	}
+	if group == "" || group == user.Group {
+		return nil
+	}
+	return tx.Model(&User{}).Where("id = ?", sub.UserId).Update("group", group).Error
+}
+
+// ChangeSubscriptionPlan 变更套餐并立即生效。prorate 为 true 时按本周期剩余时间折算包含额度差额：
+// 升级补发差额，降级收回差额（不超过未使用的包含额度）；否则新套餐额度从下个周期开始生效
+func ChangeSubscriptionPlan(id int, newPlanId int, prorate bool) (int, error) {
+	var sub *UserSubscription
+	var oldPlan, newPlan SubscriptionPlan
+	delta := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		sub, err = lockUserSubscription(tx, "id = ?", id)
+		if err != nil {
+			return err
+		}
+		if sub.Status != SubscriptionStatusActive {
+			return errors.New("订阅未生效")
+		}
+		if sub.PlanId == newPlanId {
+			return errors.New("已是该套餐")
+		}
+		if err = tx.Where("id = ?", sub.PlanId).First(&oldPlan).Error; err != nil {
+			return err
+		}
+		if err = tx.Where("id = ?", newPlanId).First(&newPlan).Error; err != nil {
+			return err
+		}
+		if prorate && sub.PeriodEnd > sub.PeriodStart {
+			fraction := float64(sub.PeriodEnd-common.GetTimestamp()) / float64(sub.PeriodEnd-sub.PeriodStart)
+			fraction = math.Max(0, math.Min(1, fraction))
+			delta = int(math.Round(float64(newPlan.Quota-oldPlan.Quota) * fraction))
+			if delta < 0 {
+				unused, err := unusedSubscriptionQuota(tx, sub)
+				if err != nil {
+					return err
+				}
+				if -delta > unused {
+					delta = -unused
+				}
+			}
+		}
+		remark := fmt.Sprintf("订阅由 %s 变更为 %s，按剩余周期折算包含额度", oldPlan.Name, newPlan.Name)
+		if err = adjustSubscriptionQuota(tx, sub, delta, remark); err != nil {
+			return err
+		}
+		if newPlan.UserGroup != oldPlan.UserGroup {
+			if newPlan.UserGroup == "" {
+				err = restoreSubscriptionGroup(tx, sub, oldPlan.UserGroup)
+			} else {
+				err = tx.Model(&User{}).Where("id = ?", sub.UserId).Update("group", newPlan.UserGroup).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+		sub.PlanId = newPlan.Id
+		sub.PeriodQuota += delta
+		sub.UpdatedTime = common.GetTimestamp()
+		return tx.Save(sub).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	afterSubscriptionChanged(sub.UserId)
+	RecordLog(sub.UserId, LogTypeManage, fmt.Sprintf("订阅由 %s 变更为 %s，包含额度调整 %s",
+		oldPlan.Name, newPlan.Name, logger.LogQuota(delta)))
+	return delta, nil
+}
+
+// SetSubscriptionCancelAtPeriodEnd 设置是否在本周期结束时取消订阅
+func SetSubscriptionCancelAtPeriodEnd(id int, cancel bool) error {
+	return DB.Model(&UserSubscription{}).Where("id = ? AND status = ?", id, SubscriptionStatusActive).
+		Updates(map[string]interface{}{"cancel_at_period_end": cancel, "updated_time": common.GetTimestamp()}).Error
+}
+
+// GetDueSubscriptions 获取应当到期的订阅：已取消续费或管理员开通的订阅在周期结束时到期，
+// 由支付平台续费的订阅额外等待宽限时间，超时仍未收到续费回调则到期
+func GetDueSubscriptions(now int64, graceSeconds int64, limit int) (subs []*UserSubscription, err error) {
+	err = DB.Where("status = ? AND period_end <= ? AND (cancel_at_period_end = ? OR payment_method = ? OR period_end <= ?)",
+		SubscriptionStatusActive, now, true, SubscriptionPaymentAdmin, now-graceSeconds).
+		Order("id").Limit(limit).Find(&subs).Error
+	return subs, err
+}
+
+// CloseStalePendingSubscriptions 关闭超时未支付的订阅订单
+func CloseStalePendingSubscriptions(before int64) (int64, error) {
+	result := DB.Model(&UserSubscription{}).Where("status = ? AND created_time < ?", SubscriptionStatusPending, before).
+		Updates(map[string]interface{}{"status": SubscriptionStatusCanceled, "updated_time": common.GetTimestamp()})
+	return result.RowsAffected, result.Error
+}
+
+func afterSubscriptionChanged(userId int) {
+	_ = invalidateUserCache(userId)
+	invalidateSubscriptionEntitlement(userId)
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// 订阅权益在每个中转请求中都会用到，本地缓存一段时间，其他节点的变更在缓存过期后生效
+const subscriptionEntitlementCacheTTL = 30 * time.Second
+
+// SubscriptionEntitlement 生效中订阅的权益
+type SubscriptionEntitlement struct {
+	SubscriptionId        int
+	PlanId                int
+	PlanName              string
+	AllowedGroups         map[string]bool // 为空表示不限制
+	AllowedModels         map[string]bool // 为空表示不限制
+	RateLimitCount        int
+	RateLimitSuccessCount int
+}
+
+type subscriptionEntitlementCacheItem struct {
+	entitlement *SubscriptionEntitlement
+	expireAt    time.Time
+}
+
+var subscriptionEntitlementCache = make(map[int]subscriptionEntitlementCacheItem)
+var subscriptionEntitlementCacheLock sync.RWMutex
+
+func toSubscriptionSet(s string) map[string]bool {
+	items := splitSubscriptionList(s)
+	if len(items) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// GetSubscriptionEntitlement 获取用户生效中订阅的权益，没有订阅时返回 nil
+func GetSubscriptionEntitlement(userId int) *SubscriptionEntitlement {
+	subscriptionEntitlementCacheLock.RLock()
+	item, ok := subscriptionEntitlementCache[userId]
+	subscriptionEntitlementCacheLock.RUnlock()
+	if ok && time.Now().Before(item.expireAt) {
+		return item.entitlement
+	}
+
+	var entitlement *SubscriptionEntitlement
+	sub, err := GetUserActiveSubscription(userId)
+	if err != nil {
+		common.SysError("failed to get user subscription: " + err.Error())
+		return nil
+	}
+	if sub != nil && sub.Plan != nil {
+		entitlement = &SubscriptionEntitlement{
+			SubscriptionId:        sub.Id,
+			PlanId:                sub.Plan.Id,
+			PlanName:              sub.Plan.Name,
+			AllowedGroups:         toSubscriptionSet(sub.Plan.AllowedGroups),
+			AllowedModels:         toSubscriptionSet(sub.Plan.AllowedModels),
+			RateLimitCount:        sub.Plan.RateLimitCount,
+			RateLimitSuccessCount: sub.Plan.RateLimitSuccessCount,
+		}
+	}
+	subscriptionEntitlementCacheLock.Lock()
+	subscriptionEntitlementCache[userId] = subscriptionEntitlementCacheItem{
+		entitlement: entitlement,
+		expireAt:    time.Now().Add(subscriptionEntitlementCacheTTL),
+	}
+	subscriptionEntitlementCacheLock.Unlock()
+	return entitlement
+}
+
+func invalidateSubscriptionEntitlement(userId int) {
+	subscriptionEntitlementCacheLock.Lock()
+	delete(subscriptionEntitlementCache, userId)
+	subscriptionEntitlementCacheLock.Unlock()
+}
+
+// invalidateSubscriptionEntitlements 套餐变更时清空全部缓存
+func invalidateSubscriptionEntitlements() {
+	subscriptionEntitlementCacheLock.Lock()
+	subscriptionEntitlementCache = make(map[int]subscriptionEntitlementCacheItem)
+	subscriptionEntitlementCacheLock.Unlock()
+}
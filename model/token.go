@@ -27,6 +27,16 @@ type Token struct {
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
+	OrganizationId     int            `json:"organization_id" gorm:"index;default:0"`           // 团队令牌，费用从团队额度池扣除
+	BudgetPeriod       string         `json:"budget_period" gorm:"type:varchar(16);default:''"` // 周期预算的重置周期：daily、weekly、monthly
+	BudgetQuota        int            `json:"budget_quota" gorm:"default:0"`                    // 每个周期的预算额度
+	BudgetSoftLimit    bool           `json:"budget_soft_limit"`                                // 软限制超出预算后仍可调用，只记录告警
+	BudgetUsedQuota    int            `json:"budget_used_quota" gorm:"-"`                       // 当前周期已用预算
+	BudgetResetTime    int64          `json:"budget_reset_time" gorm:"-"`                       // 当前周期结束时间
+	Scopes             string         `json:"scopes" gorm:"type:varchar(255);default:''"`       // 逗号分隔的作用域，为空表示不限制
+	MaxTokensLimit     int            `json:"max_tokens_limit" gorm:"default:0"`                // 对话请求允许的最大 max_tokens，0 表示不限制
+	DisableStream      bool           `json:"disable_stream"`                                   // 禁止流式请求
+	DisableTools       bool           `json:"disable_tools"`                                    // 禁止工具调用
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -167,9 +177,12 @@ func GetTokenByKey(key string, fromDB bool) (token *Token, err error) {
 }
 
 func (token *Token) Insert() error {
-	var err error
-	err = DB.Create(token).Error
-	return err
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(token).Error; err != nil {
+			return err
+		}
+		return RecordTokenQuotaLedger(tx, token.Id, token.UserId, token.RemainQuota, QuotaLedgerRef{Source: QuotaLedgerSourceTokenAdjust, Remark: "创建令牌"})
+	})
 }
 
 // Update Make sure your token's fields is completed, because this will update non-zero values
@@ -184,8 +197,20 @@ func (token *Token) Update() (err error) {
 			})
 		}
 	}()
-	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group").Updates(token).Error
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var originRemainQuota int
+		if err := tx.Model(&Token{}).Where("id = ?", token.Id).Select("remain_quota").Scan(&originRemainQuota).Error; err != nil {
+			return err
+		}
+		err := tx.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
+			"model_limits_enabled", "model_limits", "allow_ips", "group",
+			"budget_period", "budget_quota", "budget_soft_limit",
+			"scopes", "max_tokens_limit", "disable_stream", "disable_tools").Updates(token).Error
+		if err != nil {
+			return err
+		}
+		return RecordTokenQuotaLedger(tx, token.Id, token.UserId, token.RemainQuota-originRemainQuota, QuotaLedgerRef{Source: QuotaLedgerSourceTokenAdjust, Remark: "修改令牌额度"})
+	})
 	return err
 }
 
@@ -204,6 +229,22 @@ func (token *Token) SelectUpdate() (err error) {
 	return DB.Model(token).Select("accessed_time", "status").Updates(token).Error
 }
 
+// RotateKey 为令牌更换密钥，旧密钥和由旧密钥签发的派生令牌立即失效
+func (token *Token) RotateKey(key string) (err error) {
+	oldKey := token.Key
+	if err = DB.Model(token).Update("key", key).Error; err != nil {
+		return err
+	}
+	token.Key = key
+	if common.RedisEnabled {
+		// 同步删除旧密钥的缓存，避免轮换后旧密钥仍可通过缓存鉴权
+		if err := cacheDeleteToken(oldKey); err != nil {
+			common.SysLog("failed to delete token cache: " + err.Error())
+		}
+	}
+	return nil
+}
+
 func (token *Token) Delete() (err error) {
 	defer func() {
 		if shouldUpdateRedis(true, err) {
@@ -262,7 +303,7 @@ func DeleteTokenById(id int, userId int) (err error) {
 	return token.Delete()
 }
 
-func IncreaseTokenQuota(id int, key string, quota int) (err error) {
+func IncreaseTokenQuota(id int, key string, quota int, ref QuotaLedgerRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
@@ -275,24 +316,28 @@ func IncreaseTokenQuota(id int, key string, quota int) (err error) {
 		})
 	}
 	if common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeTokenQuota, id, quota)
+		addNewRecordWithLedger(BatchUpdateTypeTokenQuota, id, quota, newPendingQuotaLedgerEntries(QuotaLedgerAccountToken, id, 0, quota, ref))
 		return nil
 	}
-	return increaseTokenQuota(id, quota)
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := increaseTokenQuotaTx(tx, id, quota); err != nil {
+			return err
+		}
+		return RecordTokenQuotaLedger(tx, id, 0, quota, ref)
+	})
 }
 
-func increaseTokenQuota(id int, quota int) (err error) {
-	err = DB.Model(&Token{}).Where("id = ?", id).Updates(
+func increaseTokenQuotaTx(tx *gorm.DB, id int, quota int) error {
+	return tx.Model(&Token{}).Where("id = ?", id).Updates(
 		map[string]interface{}{
 			"remain_quota":  gorm.Expr("remain_quota + ?", quota),
 			"used_quota":    gorm.Expr("used_quota - ?", quota),
 			"accessed_time": common.GetTimestamp(),
 		},
 	).Error
-	return err
 }
 
-func DecreaseTokenQuota(id int, key string, quota int) (err error) {
+func DecreaseTokenQuota(id int, key string, quota int, ref QuotaLedgerRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
@@ -305,21 +350,15 @@ func DecreaseTokenQuota(id int, key string, quota int) (err error) {
 		})
 	}
 	if common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeTokenQuota, id, -quota)
+		addNewRecordWithLedger(BatchUpdateTypeTokenQuota, id, -quota, newPendingQuotaLedgerEntries(QuotaLedgerAccountToken, id, 0, -quota, ref))
 		return nil
 	}
-	return decreaseTokenQuota(id, quota)
-}
-
-func decreaseTokenQuota(id int, quota int) (err error) {
-	err = DB.Model(&Token{}).Where("id = ?", id).Updates(
-		map[string]interface{}{
-			"remain_quota":  gorm.Expr("remain_quota - ?", quota),
-			"used_quota":    gorm.Expr("used_quota + ?", quota),
-			"accessed_time": common.GetTimestamp(),
-		},
-	).Error
-	return err
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := increaseTokenQuotaTx(tx, id, -quota); err != nil {
+			return err
+		}
+		return RecordTokenQuotaLedger(tx, id, 0, -quota, ref)
+	})
 }
 
 // CountUserTokens returns total number of tokens for the given user, used for pagination
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 令牌周期预算的重置周期，为空表示不设置周期预算
+const (
+	TokenBudgetPeriodNone    = ""
+	TokenBudgetPeriodDaily   = "daily"
+	TokenBudgetPeriodWeekly  = "weekly"
+	TokenBudgetPeriodMonthly = "monthly"
+)
+
+// TokenBudgetUsage 令牌在每个预算周期内的累计消费
+type TokenBudgetUsage struct {
+	Id          int   `json:"id"`
+	TokenId     int   `json:"token_id" gorm:"uniqueIndex:idx_tbu_token_period,priority:1"`
+	PeriodStart int64 `json:"period_start" gorm:"bigint;uniqueIndex:idx_tbu_token_period,priority:2"`
+	UsedQuota   int   `json:"used_quota" gorm:"default:0"`
+	UpdatedAt   int64 `json:"updated_at" gorm:"bigint"`
+}
+
+func ValidateTokenBudgetPeriod(period string) error {
+	switch period {
+	case TokenBudgetPeriodNone, TokenBudgetPeriodDaily, TokenBudgetPeriodWeekly, TokenBudgetPeriodMonthly:
+		return nil
+	}
+	return errors.New("预算周期只能是 daily、weekly 或 monthly")
+}
+
+// TokenBudgetPeriodRange 返回 now 所在预算周期的起止时间，周期按服务器本地时区划分，每周从周一开始
+func TokenBudgetPeriodRange(period string, now time.Time) (int64, int64) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case TokenBudgetPeriodDaily:
+		return today.Unix(), today.AddDate(0, 0, 1).Unix()
+	case TokenBudgetPeriodWeekly:
+		start := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return start.Unix(), start.AddDate(0, 0, 7).Unix()
+	case TokenBudgetPeriodMonthly:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return start.Unix(), start.AddDate(0, 1, 0).Unix()
+	}
+	return 0, 0
+}
+
+func (token *Token) HasBudget() bool {
+	return token.BudgetPeriod != TokenBudgetPeriodNone && token.BudgetQuota > 0
+}
+
+// GetTokenBudgetUsed 获取令牌当前预算周期内的累计消费
+func GetTokenBudgetUsed(tokenId int, period string) (int, error) {
+	start, _ := TokenBudgetPeriodRange(period, time.Now())
+	var usage TokenBudgetUsage
+	err := DB.Where("token_id = ? AND period_start = ?", tokenId, start).Limit(1).Find(&usage).Error
+	return usage.UsedQuota, err
+}
+
+// IncreaseTokenBudgetUsed 累加令牌当前预算周期内的消费，delta 为负数表示退款
+func IncreaseTokenBudgetUsed(tokenId int, period string, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	start, _ := TokenBudgetPeriodRange(period, time.Now())
+	now := common.GetTimestamp()
+	for i := 0; i < 2; i++ {
+		result := DB.Model(&TokenBudgetUsage{}).
+			Where("token_id = ? AND period_start = ?", tokenId, start).
+			Updates(map[string]interface{}{
+				"used_quota": gorm.Expr("used_quota + ?", delta),
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 || delta < 0 {
+			// 跨周期的退款不计入新周期
+			return nil
+		}
+		usage := &TokenBudgetUsage{
+			TokenId:     tokenId,
+			PeriodStart: start,
+			UsedQuota:   delta,
+			UpdatedAt:   now,
+		}
+		// 并发插入时唯一索引冲突，重新走一次累加
+		result = DB.Clauses(clause.OnConflict{DoNothing: true}).Create(usage)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
+// ResetTokenBudgetUsed 清零令牌当前预算周期内的消费
+func ResetTokenBudgetUsed(tokenId int, period string) error {
+	start, _ := TokenBudgetPeriodRange(period, time.Now())
+	return DB.Where("token_id = ? AND period_start = ?", tokenId, start).Delete(&TokenBudgetUsage{}).Error
+}
+
+// FillTokenBudgetUsed 为设置了周期预算的令牌填充当前周期的消费，用于列表展示
+func FillTokenBudgetUsed(tokens []*Token) {
+	now := time.Now()
+	for _, token := range tokens {
+		if !token.HasBudget() {
+			continue
+		}
+		used, err := GetTokenBudgetUsed(token.Id, token.BudgetPeriod)
+		if err != nil {
+			common.SysError("failed to get token budget usage: " + err.Error())
+			continue
+		}
+		token.BudgetUsedQuota = used
+		_, token.BudgetResetTime = TokenBudgetPeriodRange(token.BudgetPeriod, now)
+	}
+}
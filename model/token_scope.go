@@ -0,0 +1,79 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 令牌作用域，限制令牌可以调用的接口类型，未设置作用域的令牌可以调用全部接口
+const (
+	TokenScopeChat       = "chat"
+	TokenScopeEmbeddings = "embeddings"
+	TokenScopeImages     = "images"
+	TokenScopeAudio      = "audio"
+	TokenScopeRealtime   = "realtime"
+	TokenScopeTasks      = "tasks"
+	TokenScopeFiles      = "files"
+	TokenScopeReadUsage  = "read-usage"
+)
+
+var TokenScopes = []string{
+	TokenScopeChat,
+	TokenScopeEmbeddings,
+	TokenScopeImages,
+	TokenScopeAudio,
+	TokenScopeRealtime,
+	TokenScopeTasks,
+	TokenScopeFiles,
+	TokenScopeReadUsage,
+}
+
+// NormalizeTokenScopes 校验并去重逗号分隔的作用域列表
+func NormalizeTokenScopes(scopes string) (string, error) {
+	seen := make(map[string]bool)
+	normalized := make([]string, 0)
+	for _, scope := range strings.Split(scopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || seen[scope] {
+			continue
+		}
+		valid := false
+		for _, s := range TokenScopes {
+			if s == scope {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return "", fmt.Errorf("无效的令牌作用域: %s", scope)
+		}
+		seen[scope] = true
+		normalized = append(normalized, scope)
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+func (token *Token) GetScopes() []string {
+	if token.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(token.Scopes, ",")
+}
+
+// HasScope 未设置作用域的令牌拥有全部作用域
+func (token *Token) HasScope(scope string) bool {
+	if token.Scopes == "" {
+		return true
+	}
+	for _, s := range token.GetScopes() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRequestRestrictions 令牌是否限制了请求参数，需要在鉴权时解析请求体
+func (token *Token) HasRequestRestrictions() bool {
+	return token.MaxTokensLimit > 0 || token.DisableStream || token.DisableTools
+}
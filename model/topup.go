@@ -90,6 +90,10 @@ func Recharge(referenceId string, customerId string) (err error) {
 		if err != nil {
 			return err
 		}
+		err = RecordUserQuotaLedger(tx, topUp.UserId, int(quota), QuotaLedgerRef{Source: QuotaLedgerSourceTopUp, Reference: topUp.TradeNo, Remark: "在线充值"})
+		if err != nil {
+			return err
+		}
 
 		return nil
 	})
@@ -291,6 +295,9 @@ func ManualCompleteTopUp(tradeNo string) error {
 		if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota + ?", quotaToAdd)).Error; err != nil {
 			return err
 		}
+		if err := RecordUserQuotaLedger(tx, topUp.UserId, quotaToAdd, QuotaLedgerRef{Source: QuotaLedgerSourceTopUp, Reference: topUp.TradeNo, Remark: "管理员补单"}); err != nil {
+			return err
+		}
 
 		userId = topUp.UserId
 		payMoney = topUp.Money
@@ -362,6 +369,10 @@ func RechargeCreem(referenceId string, customerEmail string, customerName string
 		if err != nil {
 			return err
 		}
+		err = RecordUserQuotaLedger(tx, topUp.UserId, int(quota), QuotaLedgerRef{Source: QuotaLedgerSourceTopUp, Reference: topUp.TradeNo, Remark: "Creem 充值"})
+		if err != nil {
+			return err
+		}
 
 		return nil
 	})
@@ -0,0 +1,203 @@
+package model
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/go-redis/redis/v8"
+)
+
+// 开启用量预聚合后 rpm/tpm 不再扫描日志表，改为按 10 秒分桶统计最近 60 秒的请求数和 token 数。
+// 启用 Redis 时各节点共享计数，否则只统计本节点，与内存限流的行为一致
+const (
+	usageRecentBucketSeconds = int64(10)
+	usageRecentWindowBuckets = 6
+	usageRecentRedisPrefix   = "usage_recent:"
+	usageRecentSeparator     = "\x1f"
+)
+
+type usageRecentKey struct {
+	Username  string
+	TokenName string
+	ModelName string
+	ChannelId int
+	Group     string
+}
+
+type usageRecentCount struct {
+	Requests int64
+	Tokens   int64
+}
+
+var (
+	usageRecentLock    sync.Mutex
+	usageRecentBuckets = make(map[int64]map[usageRecentKey]*usageRecentCount)
+)
+
+func (k usageRecentKey) field() string {
+	return strings.Join([]string{k.Username, k.TokenName, k.ModelName, strconv.Itoa(k.ChannelId), k.Group}, usageRecentSeparator)
+}
+
+func (k usageRecentKey) match(filter UsageRollupFilter) bool {
+	if filter.Username != "" && filter.Username != k.Username {
+		return false
+	}
+	if filter.TokenName != "" && filter.TokenName != k.TokenName {
+		return false
+	}
+	if filter.ModelName != "" && !matchSQLLike(filter.ModelName, k.ModelName) {
+		return false
+	}
+	if filter.Channel != 0 && filter.Channel != k.ChannelId {
+		return false
+	}
+	if filter.Group != "" && filter.Group != k.Group {
+		return false
+	}
+	return true
+}
+
+// matchSQLLike 按 SQL LIKE 语义匹配，与日志查询中 model_name like ? 的行为保持一致
+func matchSQLLike(pattern string, s string) bool {
+	if !strings.ContainsAny(pattern, "%_") {
+		return pattern == s
+	}
+	var builder strings.Builder
+	builder.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			builder.WriteString(".*")
+		case '_':
+			builder.WriteString(".")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	builder.WriteString("$")
+	matched, err := regexp.MatchString(builder.String(), s)
+	return err == nil && matched
+}
+
+func usageRecentRedisKey(bucket int64) string {
+	return usageRecentRedisPrefix + strconv.FormatInt(bucket, 10)
+}
+
+// recordUsageRecent 累加一条消费日志到最近用量计数
+func recordUsageRecent(log *Log) {
+	bucket := log.CreatedAt - log.CreatedAt%usageRecentBucketSeconds
+	key := usageRecentKey{
+		Username:  log.Username,
+		TokenName: log.TokenName,
+		ModelName: log.ModelName,
+		ChannelId: log.ChannelId,
+		Group:     log.Group,
+	}
+	tokens := int64(log.PromptTokens + log.CompletionTokens)
+	if common.RedisEnabled {
+		gopool.Go(func() {
+			ctx := context.Background()
+			redisKey := usageRecentRedisKey(bucket)
+			field := key.field()
+			pipe := common.RDB.TxPipeline()
+			pipe.HIncrBy(ctx, redisKey, field+usageRecentSeparator+"r", 1)
+			pipe.HIncrBy(ctx, redisKey, field+usageRecentSeparator+"t", tokens)
+			pipe.Expire(ctx, redisKey, time.Duration(2*usageRecentWindowBuckets*usageRecentBucketSeconds)*time.Second)
+			if _, err := pipe.Exec(ctx); err != nil {
+				common.SysError("failed to record recent usage: " + err.Error())
+			}
+		})
+		return
+	}
+	usageRecentLock.Lock()
+	defer usageRecentLock.Unlock()
+	now := time.Now().Unix()
+	oldest := now - now%usageRecentBucketSeconds - int64(usageRecentWindowBuckets)*usageRecentBucketSeconds
+	for b := range usageRecentBuckets {
+		if b < oldest {
+			delete(usageRecentBuckets, b)
+		}
+	}
+	if bucket < oldest {
+		return
+	}
+	counts, ok := usageRecentBuckets[bucket]
+	if !ok {
+		counts = make(map[usageRecentKey]*usageRecentCount)
+		usageRecentBuckets[bucket] = counts
+	}
+	count, ok := counts[key]
+	if !ok {
+		count = &usageRecentCount{}
+		counts[key] = count
+	}
+	count.Requests++
+	count.Tokens += tokens
+}
+
+// getRecentUsage 返回最近 60 秒内满足过滤条件的请求数和 token 数
+func getRecentUsage(filter UsageRollupFilter) (rpm int, tpm int) {
+	now := time.Now().Unix()
+	current := now - now%usageRecentBucketSeconds
+	buckets := make([]int64, 0, usageRecentWindowBuckets)
+	for i := 0; i < usageRecentWindowBuckets; i++ {
+		buckets = append(buckets, current-int64(i)*usageRecentBucketSeconds)
+	}
+	if common.RedisEnabled {
+		ctx := context.Background()
+		pipe := common.RDB.Pipeline()
+		for _, bucket := range buckets {
+			pipe.HGetAll(ctx, usageRecentRedisKey(bucket))
+		}
+		cmds, err := pipe.Exec(ctx)
+		if err != nil {
+			common.SysError("failed to get recent usage: " + err.Error())
+		}
+		for _, cmd := range cmds {
+			hashCmd, ok := cmd.(*redis.StringStringMapCmd)
+			if !ok {
+				continue
+			}
+			fields, err := hashCmd.Result()
+			if err != nil {
+				continue
+			}
+			for field, value := range fields {
+				parts := strings.Split(field, usageRecentSeparator)
+				if len(parts) != 6 {
+					continue
+				}
+				channelId, _ := strconv.Atoi(parts[3])
+				key := usageRecentKey{Username: parts[0], TokenName: parts[1], ModelName: parts[2], ChannelId: channelId, Group: parts[4]}
+				if !key.match(filter) {
+					continue
+				}
+				n, _ := strconv.Atoi(value)
+				if parts[5] == "r" {
+					rpm += n
+				} else {
+					tpm += n
+				}
+			}
+		}
+		return rpm, tpm
+	}
+	usageRecentLock.Lock()
+	defer usageRecentLock.Unlock()
+	for _, bucket := range buckets {
+		for key, count := range usageRecentBuckets[bucket] {
+			if key.match(filter) {
+				rpm += int(count.Requests)
+				tpm += int(count.Tokens)
+			}
+		}
+	}
+	return rpm, tpm
+}
@@ -0,0 +1,539 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	UsageRollupGranularityHour = "hour"
+	UsageRollupGranularityDay  = "day"
+
+	usageRollupHourSeconds = int64(3600)
+	usageRollupDaySeconds  = int64(86400)
+)
+
+// UsageRollup 预聚合的用量统计，按 用户/令牌/渠道/模型/分组 + 时间桶 聚合，聚合维度上有唯一索引
+type UsageRollup struct {
+	Id               int    `json:"id"`
+	BucketTime       int64  `json:"bucket_time" gorm:"bigint;index;uniqueIndex:,composite:rollup_key,priority:1"`
+	UserId           int    `json:"user_id" gorm:"index;uniqueIndex:,composite:rollup_key,priority:2"`
+	Username         string `json:"username" gorm:"size:64;default:''"`
+	TokenId          int    `json:"token_id" gorm:"index;default:0;uniqueIndex:,composite:rollup_key,priority:3"`
+	TokenName        string `json:"token_name" gorm:"size:64;default:''"`
+	ChannelId        int    `json:"channel_id" gorm:"index;default:0;uniqueIndex:,composite:rollup_key,priority:4"`
+	ModelName        string `json:"model_name" gorm:"index;size:128;default:'';uniqueIndex:,composite:rollup_key,priority:5"`
+	Group            string `json:"group" gorm:"size:64;default:'';uniqueIndex:,composite:rollup_key,priority:6"`
+	RequestCount     int64  `json:"request_count" gorm:"default:0"`
+	ErrorCount       int64  `json:"error_count" gorm:"default:0"`
+	PromptTokens     int64  `json:"prompt_tokens" gorm:"default:0"`
+	CompletionTokens int64  `json:"completion_tokens" gorm:"default:0"`
+	Quota            int64  `json:"quota" gorm:"default:0"`
+	UseTimeTotal     int64  `json:"use_time_total" gorm:"default:0"` // 成功请求耗时总和，单位秒
+}
+
+type UsageRollupHourly struct {
+	UsageRollup `gorm:"embedded"`
+}
+
+func (UsageRollupHourly) TableName() string {
+	return "usage_rollups_hourly"
+}
+
+type UsageRollupDaily struct {
+	UsageRollup `gorm:"embedded"`
+}
+
+func (UsageRollupDaily) TableName() string {
+	return "usage_rollups_daily"
+}
+
+func usageRollupTable(granularity string) (string, int64) {
+	if granularity == UsageRollupGranularityDay {
+		return UsageRollupDaily{}.TableName(), usageRollupDaySeconds
+	}
+	return UsageRollupHourly{}.TableName(), usageRollupHourSeconds
+}
+
+type usageRollupKey struct {
+	BucketTime int64
+	UserId     int
+	TokenId    int
+	ChannelId  int
+	ModelName  string
+	Group      string
+}
+
+var usageRollupCache = make(map[string]map[usageRollupKey]*UsageRollup)
+var usageRollupCacheLock = sync.Mutex{}
+
+// recordUsageRollup 将一条消费/错误日志累加到内存中的小时与天聚合，由 UpdateUsageRollups 定期落库
+func recordUsageRollup(log *Log) {
+	if !common.UsageRollupEnabled {
+		return
+	}
+	if log.Type != LogTypeConsume && log.Type != LogTypeError && log.Type != LogTypeRefund {
+		return
+	}
+	if log.Type == LogTypeConsume {
+		recordUsageRecent(log)
+	}
+	usageRollupCacheLock.Lock()
+	defer usageRollupCacheLock.Unlock()
+	for _, granularity := range []string{UsageRollupGranularityHour, UsageRollupGranularityDay} {
+		table, size := usageRollupTable(granularity)
+		key := usageRollupKey{
+			BucketTime: log.CreatedAt - log.CreatedAt%size,
+			UserId:     log.UserId,
+			TokenId:    log.TokenId,
+			ChannelId:  log.ChannelId,
+			ModelName:  log.ModelName,
+			Group:      log.Group,
+		}
+		bucket, ok := usageRollupCache[table]
+		if !ok {
+			bucket = make(map[usageRollupKey]*UsageRollup)
+			usageRollupCache[table] = bucket
+		}
+		rollup, ok := bucket[key]
+		if !ok {
+			rollup = &UsageRollup{
+				BucketTime: key.BucketTime,
+				UserId:     key.UserId,
+				Username:   log.Username,
+				TokenId:    key.TokenId,
+				TokenName:  log.TokenName,
+				ChannelId:  key.ChannelId,
+				ModelName:  key.ModelName,
+				Group:      key.Group,
+			}
+			bucket[key] = rollup
+		}
+		if log.Type == LogTypeError {
+			rollup.ErrorCount++
+			continue
+		}
+		if log.Type == LogTypeRefund {
+			// 退款只冲减额度，不计入请求数
+			rollup.Quota -= int64(log.Quota)
+			continue
+		}
+		rollup.RequestCount++
+		rollup.PromptTokens += int64(log.PromptTokens)
+		rollup.CompletionTokens += int64(log.CompletionTokens)
+		rollup.Quota += int64(log.Quota)
+		rollup.UseTimeTotal += int64(log.UseTime)
+	}
+}
+
+func UpdateUsageRollups() {
+	for {
+		time.Sleep(time.Duration(common.UsageRollupFlushInterval) * time.Second)
+		if common.UsageRollupEnabled {
+			SaveUsageRollupCache()
+		}
+	}
+}
+
+func SaveUsageRollupCache() {
+	usageRollupCacheLock.Lock()
+	cache := usageRollupCache
+	usageRollupCache = make(map[string]map[usageRollupKey]*UsageRollup)
+	usageRollupCacheLock.Unlock()
+
+	size := 0
+	for table, bucket := range cache {
+		for _, rollup := range bucket {
+			if err := increaseUsageRollup(table, rollup); err != nil {
+				common.SysError(fmt.Sprintf("failed to save usage rollup to %s: %s", table, err.Error()))
+				continue
+			}
+			size++
+		}
+	}
+	if size > 0 && common.DebugEnabled {
+		common.SysLog(fmt.Sprintf("saved %d usage rollup rows", size))
+	}
+}
+
+const usageRollupKeyWhere = "bucket_time = ? and user_id = ? and token_id = ? and channel_id = ? and model_name = ? and "
+
+func increaseUsageRollup(table string, rollup *UsageRollup) error {
+	for i := 0; i < 2; i++ {
+		result := LOG_DB.Table(table).
+			Where(usageRollupKeyWhere+logGroupCol+" = ?",
+				rollup.BucketTime, rollup.UserId, rollup.TokenId, rollup.ChannelId, rollup.ModelName, rollup.Group).
+			Updates(map[string]interface{}{
+				"request_count":     gorm.Expr("request_count + ?", rollup.RequestCount),
+				"error_count":       gorm.Expr("error_count + ?", rollup.ErrorCount),
+				"prompt_tokens":     gorm.Expr("prompt_tokens + ?", rollup.PromptTokens),
+				"completion_tokens": gorm.Expr("completion_tokens + ?", rollup.CompletionTokens),
+				"quota":             gorm.Expr("quota + ?", rollup.Quota),
+				"use_time_total":    gorm.Expr("use_time_total + ?", rollup.UseTimeTotal),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+		// 多个节点同时插入同一聚合行时唯一索引冲突，重新走一次累加
+		result = LOG_DB.Table(table).Clauses(clause.OnConflict{DoNothing: true}).Create(rollup)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("usage rollup row conflict in %s", table)
+}
+
+// FlushUsageRollups 立即写入内存中的聚合，用于优雅退出
+func FlushUsageRollups() {
+	if common.UsageRollupEnabled {
+		SaveUsageRollupCache()
+	}
+}
+
+// mergeDuplicateUsageRollups 合并唯一索引建立之前并发写入产生的重复聚合行，需在迁移聚合表之前调用
+func mergeDuplicateUsageRollups(db *gorm.DB) error {
+	for _, rollupModel := range []interface{ TableName() string }{UsageRollupHourly{}, UsageRollupDaily{}} {
+		table := rollupModel.TableName()
+		if !db.Migrator().HasTable(table) || db.Migrator().HasIndex(rollupModel, "idx_"+table+"_rollup_key") {
+			continue
+		}
+		var duplicates []*UsageRollup
+		err := db.Table(table).
+			Select(fmt.Sprintf("bucket_time, user_id, max(username) as username, token_id, max(token_name) as token_name, channel_id, model_name, %s as %s, "+
+				"sum(request_count) as request_count, sum(error_count) as error_count, sum(prompt_tokens) as prompt_tokens, "+
+				"sum(completion_tokens) as completion_tokens, sum(quota) as quota, sum(use_time_total) as use_time_total",
+				logGroupCol, logGroupCol)).
+			Group("bucket_time, user_id, token_id, channel_id, model_name, " + logGroupCol).
+			Having("count(*) > 1").
+			Scan(&duplicates).Error
+		if err != nil {
+			return err
+		}
+		for _, merged := range duplicates {
+			err = db.Transaction(func(tx *gorm.DB) error {
+				err := tx.Table(table).
+					Where(usageRollupKeyWhere+logGroupCol+" = ?",
+						merged.BucketTime, merged.UserId, merged.TokenId, merged.ChannelId, merged.ModelName, merged.Group).
+					Delete(&UsageRollup{}).Error
+				if err != nil {
+					return err
+				}
+				return tx.Table(table).Create(merged).Error
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(duplicates) > 0 {
+			common.SysLog(fmt.Sprintf("merged %d duplicate usage rollup rows in %s", len(duplicates), table))
+		}
+	}
+	return nil
+}
+
+// usageRollupSettledBefore 返回聚合表可信的时间上界（按小时对齐），
+// 在此之后的数据可能仍在各节点内存中尚未落库，需要直接查询日志表
+func usageRollupSettledBefore() int64 {
+	settled := time.Now().Unix() - int64(2*common.UsageRollupFlushInterval)
+	return settled - settled%usageRollupHourSeconds
+}
+
+// BackfillUsageRollups 从日志表重建 [startTimestamp, endTimestamp) 范围内的小时聚合，并据此重算涉及到的天聚合。
+// 该操作是幂等的，可以重复执行；尚未稳定的最近时段会被自动跳过。
+func BackfillUsageRollups(ctx context.Context, startTimestamp int64, endTimestamp int64) (int64, error) {
+	start := startTimestamp - startTimestamp%usageRollupHourSeconds
+	end := endTimestamp
+	if end%usageRollupHourSeconds != 0 {
+		end = end - end%usageRollupHourSeconds + usageRollupHourSeconds
+	}
+	if settled := usageRollupSettledBefore(); end > settled {
+		end = settled
+	}
+	if start >= end {
+		return 0, errors.New("no settled hours in the requested range")
+	}
+
+	var total int64
+	// 按天分批，避免单次聚合扫描过多日志
+	for chunkStart := start; chunkStart < end; {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		chunkEnd := chunkStart - chunkStart%usageRollupDaySeconds + usageRollupDaySeconds
+		if chunkEnd > end {
+			chunkEnd = end
+		}
+		count, err := backfillHourlyUsageRollups(chunkStart, chunkEnd)
+		if err != nil {
+			return total, err
+		}
+		total += count
+		if err = rebuildDailyUsageRollup(chunkStart - chunkStart%usageRollupDaySeconds); err != nil {
+			return total, err
+		}
+		chunkStart = chunkEnd
+	}
+	return total, nil
+}
+
+func backfillHourlyUsageRollups(start int64, end int64) (int64, error) {
+	var rollups []*UsageRollupHourly
+	bucketExpr := fmt.Sprintf("created_at - created_at %% %d", usageRollupHourSeconds)
+	err := LOG_DB.Table("logs").
+		Select(fmt.Sprintf("%s as bucket_time, user_id, max(username) as username, token_id, max(token_name) as token_name, channel_id, model_name, %s as %s, "+
+			"sum(case when type = %d then 1 else 0 end) as request_count, "+
+			"sum(case when type = %d then 1 else 0 end) as error_count, "+
+			"sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens, "+
+			"sum(case when type = %d then -quota else quota end) as quota, "+
+			"sum(case when type = %d then use_time else 0 end) as use_time_total",
+			bucketExpr, logGroupCol, logGroupCol, LogTypeConsume, LogTypeError, LogTypeRefund, LogTypeConsume)).
+		Where("created_at >= ? and created_at < ? and type in ?", start, end, []int{LogTypeConsume, LogTypeError, LogTypeRefund}).
+		Group(fmt.Sprintf("%s, user_id, token_id, channel_id, model_name, %s", bucketExpr, logGroupCol)).
+		Scan(&rollups).Error
+	if err != nil {
+		return 0, err
+	}
+	err = LOG_DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bucket_time >= ? and bucket_time < ?", start, end).Delete(&UsageRollupHourly{}).Error; err != nil {
+			return err
+		}
+		if len(rollups) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rollups, 100).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(rollups)), nil
+}
+
+func rebuildDailyUsageRollup(dayStart int64) error {
+	var rollups []*UsageRollupDaily
+	err := LOG_DB.Table(UsageRollupHourly{}.TableName()).
+		Select(fmt.Sprintf("%d as bucket_time, user_id, max(username) as username, token_id, max(token_name) as token_name, channel_id, model_name, %s as %s, "+
+			"sum(request_count) as request_count, sum(error_count) as error_count, sum(prompt_tokens) as prompt_tokens, "+
+			"sum(completion_tokens) as completion_tokens, sum(quota) as quota, sum(use_time_total) as use_time_total",
+			dayStart, logGroupCol, logGroupCol)).
+		Where("bucket_time >= ? and bucket_time < ?", dayStart, dayStart+usageRollupDaySeconds).
+		Group("user_id, token_id, channel_id, model_name, " + logGroupCol).
+		Scan(&rollups).Error
+	if err != nil {
+		return err
+	}
+	return LOG_DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bucket_time = ?", dayStart).Delete(&UsageRollupDaily{}).Error; err != nil {
+			return err
+		}
+		if len(rollups) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rollups, 100).Error
+	})
+}
+
+type UsageRollupFilter struct {
+	StartTimestamp int64
+	EndTimestamp   int64
+	UserId         int
+	Username       string
+	TokenName      string
+	ModelName      string
+	Channel        int
+	Group          string
+}
+
+func (f UsageRollupFilter) apply(tx *gorm.DB) *gorm.DB {
+	if f.UserId != 0 {
+		tx = tx.Where("user_id = ?", f.UserId)
+	}
+	if f.Username != "" {
+		tx = tx.Where("username = ?", f.Username)
+	}
+	if f.TokenName != "" {
+		tx = tx.Where("token_name = ?", f.TokenName)
+	}
+	if f.ModelName != "" {
+		tx = tx.Where("model_name like ?", f.ModelName)
+	}
+	if f.Channel != 0 {
+		tx = tx.Where("channel_id = ?", f.Channel)
+	}
+	if f.Group != "" {
+		tx = tx.Where(logGroupCol+" = ?", f.Group)
+	}
+	return tx
+}
+
+// usageRollupSum 一段时间内消费日志的汇总
+type usageRollupSum struct {
+	Quota  int64
+	Tokens int64
+	Count  int64
+}
+
+func (s *usageRollupSum) add(other usageRollupSum) {
+	s.Quota += other.Quota
+	s.Tokens += other.Tokens
+	s.Count += other.Count
+}
+
+func sumRollupUsage(table string, filter UsageRollupFilter, from int64, to int64) (sum usageRollupSum) {
+	if from >= to {
+		return sum
+	}
+	tx := LOG_DB.Table(table).
+		Select("coalesce(sum(quota), 0) as quota, coalesce(sum(prompt_tokens), 0) + coalesce(sum(completion_tokens), 0) as tokens, coalesce(sum(request_count), 0) as count").
+		Where("bucket_time >= ? and bucket_time < ?", from, to)
+	filter.apply(tx).Scan(&sum)
+	return sum
+}
+
+func sumLogUsage(filter UsageRollupFilter, from int64, to int64) (sum usageRollupSum) {
+	tx := LOG_DB.Table("logs").
+		Select("coalesce(sum(case when type = ? then -quota else quota end), 0) as quota, "+
+			"coalesce(sum(prompt_tokens), 0) + coalesce(sum(completion_tokens), 0) as tokens, "+
+			"coalesce(sum(case when type = ? then 1 else 0 end), 0) as count", LogTypeRefund, LogTypeConsume).
+		Where("type in ?", []int{LogTypeConsume, LogTypeRefund})
+	if from != 0 {
+		tx = tx.Where("created_at >= ?", from)
+	}
+	if to != 0 {
+		tx = tx.Where("created_at <= ?", to)
+	}
+	filter.apply(tx).Scan(&sum)
+	return sum
+}
+
+// sumUsageWithRollup 对完整覆盖的天/小时使用聚合表，首尾不足一小时及尚未稳定的部分回落到日志表
+func sumUsageWithRollup(filter UsageRollupFilter) (sum usageRollupSum) {
+	start, end := filter.StartTimestamp, filter.EndTimestamp
+	hourStart := start
+	if hourStart%usageRollupHourSeconds != 0 {
+		hourStart = hourStart - hourStart%usageRollupHourSeconds + usageRollupHourSeconds
+	}
+	hourEnd := usageRollupSettledBefore()
+	if end != 0 && (end+1)-(end+1)%usageRollupHourSeconds < hourEnd {
+		hourEnd = (end + 1) - (end+1)%usageRollupHourSeconds
+	}
+	if hourStart >= hourEnd {
+		return sumLogUsage(filter, start, end)
+	}
+
+	if start != hourStart {
+		sum.add(sumLogUsage(filter, start, hourStart-1))
+	}
+	sum.add(sumLogUsage(filter, hourEnd, end))
+
+	dayStart := hourStart
+	if dayStart%usageRollupDaySeconds != 0 {
+		dayStart = dayStart - dayStart%usageRollupDaySeconds + usageRollupDaySeconds
+	}
+	dayEnd := hourEnd - hourEnd%usageRollupDaySeconds
+	hourlyTable := UsageRollupHourly{}.TableName()
+	if dayStart < dayEnd {
+		sum.add(sumRollupUsage(UsageRollupDaily{}.TableName(), filter, dayStart, dayEnd))
+		sum.add(sumRollupUsage(hourlyTable, filter, hourStart, dayStart))
+		sum.add(sumRollupUsage(hourlyTable, filter, dayEnd, hourEnd))
+	} else {
+		sum.add(sumRollupUsage(hourlyTable, filter, hourStart, hourEnd))
+	}
+	return sum
+}
+
+func getQuotaDataFromRollup(filter UsageRollupFilter, withUser bool) (quotaDatas []*QuotaData, err error) {
+	columns := "model_name, bucket_time"
+	if withUser {
+		columns = "user_id, username, " + columns
+	}
+	tx := LOG_DB.Table(UsageRollupHourly{}.TableName()).
+		Select(columns+", sum(request_count) as count, sum(quota) as quota, sum(prompt_tokens) + sum(completion_tokens) as token_used, bucket_time as created_at").
+		Where("bucket_time >= ? and bucket_time <= ?", filter.StartTimestamp, filter.EndTimestamp)
+	err = filter.apply(tx).Group(columns).Find(&quotaDatas).Error
+	return quotaDatas, err
+}
+
+type UsageRollupStat struct {
+	BucketTime       int64   `json:"bucket_time"`
+	UserId           int     `json:"user_id,omitempty"`
+	Username         string  `json:"username,omitempty"`
+	TokenId          int     `json:"token_id,omitempty"`
+	TokenName        string  `json:"token_name,omitempty"`
+	ChannelId        int     `json:"channel_id,omitempty"`
+	ModelName        string  `json:"model_name,omitempty"`
+	Group            string  `json:"group,omitempty"`
+	RequestCount     int64   `json:"request_count"`
+	ErrorCount       int64   `json:"error_count"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Quota            int64   `json:"quota"`
+	UseTimeTotal     int64   `json:"-"`
+	AvgUseTime       float64 `json:"avg_use_time"`
+}
+
+// usageRollupDimensions 可用于 group_by 的维度，值为对应的列
+var usageRollupDimensions = map[string][]string{
+	"user":    {"user_id", "username"},
+	"token":   {"token_id", "token_name"},
+	"channel": {"channel_id"},
+	"model":   {"model_name"},
+	"group":   {"group"},
+}
+
+// GetUsageRollupStats 查询聚合表，按时间桶及 groupBy 中指定的维度汇总
+func GetUsageRollupStats(granularity string, filter UsageRollupFilter, groupBy []string) (stats []*UsageRollupStat, err error) {
+	table, _ := usageRollupTable(granularity)
+	columns := []string{"bucket_time"}
+	seen := make(map[string]bool)
+	for _, dimension := range groupBy {
+		dimensionColumns, ok := usageRollupDimensions[strings.TrimSpace(dimension)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported group_by dimension: %s", dimension)
+		}
+		for _, column := range dimensionColumns {
+			if seen[column] {
+				continue
+			}
+			seen[column] = true
+			if column == "group" {
+				column = logGroupCol
+			}
+			columns = append(columns, column)
+		}
+	}
+	groupColumns := strings.Join(columns, ", ")
+	tx := LOG_DB.Table(table).
+		Select(groupColumns + ", sum(request_count) as request_count, sum(error_count) as error_count, sum(prompt_tokens) as prompt_tokens, " +
+			"sum(completion_tokens) as completion_tokens, sum(quota) as quota, sum(use_time_total) as use_time_total")
+	if filter.StartTimestamp != 0 {
+		tx = tx.Where("bucket_time >= ?", filter.StartTimestamp)
+	}
+	if filter.EndTimestamp != 0 {
+		tx = tx.Where("bucket_time <= ?", filter.EndTimestamp)
+	}
+	err = filter.apply(tx).Group(groupColumns).Order("bucket_time asc").Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, stat := range stats {
+		if stat.RequestCount > 0 {
+			stat.AvgUseTime = float64(stat.UseTimeTotal) / float64(stat.RequestCount)
+		}
+	}
+	return stats, nil
+}
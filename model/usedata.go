@@ -102,6 +102,9 @@ func increaseQuotaData(userId int, username string, modelName string, count int,
 }
 
 func GetQuotaDataByUsername(username string, startTime int64, endTime int64) (quotaData []*QuotaData, err error) {
+	if common.UsageRollupEnabled {
+		return getQuotaDataFromRollup(UsageRollupFilter{StartTimestamp: startTime, EndTimestamp: endTime, Username: username}, true)
+	}
 	var quotaDatas []*QuotaData
 	// 从quota_data表中查询数据
 	err = DB.Table("quota_data").Where("username = ? and created_at >= ? and created_at <= ?", username, startTime, endTime).Find(&quotaDatas).Error
@@ -109,6 +112,9 @@ func GetQuotaDataByUsername(username string, startTime int64, endTime int64) (qu
 }
 
 func GetQuotaDataByUserId(userId int, startTime int64, endTime int64) (quotaData []*QuotaData, err error) {
+	if common.UsageRollupEnabled {
+		return getQuotaDataFromRollup(UsageRollupFilter{StartTimestamp: startTime, EndTimestamp: endTime, UserId: userId}, true)
+	}
 	var quotaDatas []*QuotaData
 	// 从quota_data表中查询数据
 	err = DB.Table("quota_data").Where("user_id = ? and created_at >= ? and created_at <= ?", userId, startTime, endTime).Find(&quotaDatas).Error
@@ -119,6 +125,9 @@ func GetAllQuotaDates(startTime int64, endTime int64, username string) (quotaDat
 	if username != "" {
 		return GetQuotaDataByUsername(username, startTime, endTime)
 	}
+	if common.UsageRollupEnabled {
+		return getQuotaDataFromRollup(UsageRollupFilter{StartTimestamp: startTime, EndTimestamp: endTime}, false)
+	}
 	var quotaDatas []*QuotaData
 	// 从quota_data表中查询数据
 	// only select model_name, sum(count) as count, sum(quota) as quota, model_name, created_at from quota_data group by model_name, created_at;
@@ -47,6 +47,8 @@ type User struct {
 	Setting          string         `json:"setting" gorm:"type:text;column:setting"`
 	Remark           string         `json:"remark,omitempty" gorm:"type:varchar(255)" validate:"max=255"`
 	StripeCustomer   string         `json:"stripe_customer" gorm:"type:varchar(64);column:stripe_customer;index"`
+	CreditLimit      int            `json:"credit_limit" gorm:"type:int;default:0"` // 后付费授信额度，余额最多可透支到 -CreditLimit
+	CreditSuspended  bool           `json:"credit_suspended" gorm:"default:false"`  // 存在逾期账单，暂停调用
 }
 
 func (user *User) ToBaseUser() *UserBase {
@@ -58,6 +60,9 @@ func (user *User) ToBaseUser() *UserBase {
 		Username: user.Username,
 		Setting:  user.Setting,
 		Email:    user.Email,
+
+		CreditLimit:     user.CreditLimit,
+		CreditSuspended: user.CreditSuspended,
 	}
 	return cache
 }
@@ -368,6 +373,9 @@ func (user *User) TransferAffQuotaToQuota(quota int) error {
 	if err := tx.Save(user).Error; err != nil {
 		return err
 	}
+	if err := RecordUserQuotaLedger(tx, user.Id, quota, QuotaLedgerRef{Source: QuotaLedgerSourceAffTransfer, Remark: "邀请额度划转"}); err != nil {
+		return err
+	}
 
 	// 提交事务
 	return tx.Commit().Error
@@ -392,9 +400,14 @@ func (user *User) Insert(inviterId int) error {
 		user.SetSetting(defaultSetting)
 	}
 
-	result := DB.Create(user)
-	if result.Error != nil {
-		return result.Error
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return RecordUserQuotaLedger(tx, user.Id, user.Quota, QuotaLedgerRef{Source: QuotaLedgerSourceRegister, Remark: "新用户注册赠送"})
+	})
+	if err != nil {
+		return err
 	}
 
 	// 用户创建成功后，根据角色初始化边栏配置
@@ -417,7 +430,11 @@ func (user *User) Insert(inviterId int) error {
 	}
 	if inviterId != 0 {
 		if common.QuotaForInvitee > 0 {
-			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true)
+			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true, QuotaLedgerRef{
+				Source:    QuotaLedgerSourceInvite,
+				Reference: fmt.Sprintf("inviter:%d", inviterId),
+				Remark:    "使用邀请码赠送",
+			})
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", logger.LogQuota(common.QuotaForInvitee)))
 		}
 		if common.QuotaForInviter > 0 {
@@ -463,13 +480,23 @@ func (user *User) Edit(updatePassword bool) error {
 		"group":        newUser.Group,
 		"quota":        newUser.Quota,
 		"remark":       newUser.Remark,
+		"credit_limit": newUser.CreditLimit,
 	}
 	if updatePassword {
 		updates["password"] = newUser.Password
 	}
 
-	DB.First(&user, user.Id)
-	if err = DB.Model(user).Updates(updates).Error; err != nil {
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, user.Id).Error; err != nil {
+			return err
+		}
+		originQuota := user.Quota
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		return RecordUserQuotaLedger(tx, user.Id, newUser.Quota-originQuota, QuotaLedgerRef{Source: QuotaLedgerSourceAdmin, Remark: "管理员修改用户额度"})
+	})
+	if err != nil {
 		return err
 	}
 
@@ -484,6 +511,9 @@ func (user *User) Delete() error {
 	if err := DB.Delete(user).Error; err != nil {
 		return err
 	}
+	if err := RemoveUserOrganizationMemberships(user.Id); err != nil {
+		common.SysError("failed to remove organization memberships: " + err.Error())
+	}
 
 	// 清除缓存
 	return invalidateUserCache(user.Id)
@@ -763,7 +793,7 @@ func GetUserSetting(id int, fromDB bool) (settingMap dto.UserSetting, err error)
 	return userBase.GetSetting(), nil
 }
 
-func IncreaseUserQuota(id int, quota int, db bool) (err error) {
+func IncreaseUserQuota(id int, quota int, db bool, ref QuotaLedgerRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
@@ -774,21 +804,22 @@ func IncreaseUserQuota(id int, quota int, db bool) (err error) {
 		}
 	})
 	if !db && common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeUserQuota, id, quota)
+		addNewRecordWithLedger(BatchUpdateTypeUserQuota, id, quota, newPendingQuotaLedgerEntries(QuotaLedgerAccountUser, id, id, quota, ref))
 		return nil
 	}
-	return increaseUserQuota(id, quota)
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := increaseUserQuotaTx(tx, id, quota); err != nil {
+			return err
+		}
+		return RecordUserQuotaLedger(tx, id, quota, ref)
+	})
 }
 
-func increaseUserQuota(id int, quota int) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", quota)).Error
-	if err != nil {
-		return err
-	}
-	return err
+func increaseUserQuotaTx(tx *gorm.DB, id int, quota int) error {
+	return tx.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", quota)).Error
 }
 
-func DecreaseUserQuota(id int, quota int) (err error) {
+func DecreaseUserQuota(id int, quota int, ref QuotaLedgerRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
@@ -799,28 +830,25 @@ func DecreaseUserQuota(id int, quota int) (err error) {
 		}
 	})
 	if common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeUserQuota, id, -quota)
+		addNewRecordWithLedger(BatchUpdateTypeUserQuota, id, -quota, newPendingQuotaLedgerEntries(QuotaLedgerAccountUser, id, id, -quota, ref))
 		return nil
 	}
-	return decreaseUserQuota(id, quota)
-}
-
-func decreaseUserQuota(id int, quota int) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota - ?", quota)).Error
-	if err != nil {
-		return err
-	}
-	return err
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := increaseUserQuotaTx(tx, id, -quota); err != nil {
+			return err
+		}
+		return RecordUserQuotaLedger(tx, id, -quota, ref)
+	})
 }
 
-func DeltaUpdateUserQuota(id int, delta int) (err error) {
+func DeltaUpdateUserQuota(id int, delta int, ref QuotaLedgerRef) (err error) {
 	if delta == 0 {
 		return nil
 	}
 	if delta > 0 {
-		return IncreaseUserQuota(id, delta, false)
+		return IncreaseUserQuota(id, delta, false, ref)
 	} else {
-		return DecreaseUserQuota(id, -delta)
+		return DecreaseUserQuota(id, -delta, ref)
 	}
 }
 
@@ -829,6 +857,15 @@ func DeltaUpdateUserQuota(id int, delta int) (err error) {
 //	return email
 //}
 
+func UpdateUserStripeCustomer(id int, customerId string) {
+	if customerId == "" {
+		return
+	}
+	if err := DB.Model(&User{}).Where("id = ?", id).Update("stripe_customer", customerId).Error; err != nil {
+		common.SysError("failed to update stripe customer: " + err.Error())
+	}
+}
+
 func GetRootUser() (user *User) {
 	DB.Where("role = ?", common.RoleRootUser).First(&user)
 	return user
@@ -22,6 +22,9 @@ type UserBase struct {
 	Status   int    `json:"status"`
 	Username string `json:"username"`
 	Setting  string `json:"setting"`
+
+	CreditLimit     int  `json:"credit_limit"`
+	CreditSuspended bool `json:"credit_suspended"`
 }
 
 func (user *UserBase) WriteContext(c *gin.Context) {
@@ -107,6 +110,9 @@ func GetUserCache(userId int) (userCache *UserBase, err error) {
 		Username: user.Username,
 		Setting:  user.Setting,
 		Email:    user.Email,
+
+		CreditLimit:     user.CreditLimit,
+		CreditSuspended: user.CreditSuspended,
 	}
 
 	return userCache, nil
@@ -0,0 +1,147 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserMonthlyUsage 用户每月在各模型上的累计 tokens，用于按用量阶梯计价，只记录配置了用量阶梯的模型
+type UserMonthlyUsage struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"uniqueIndex:idx_umu_user_model_month,priority:1"`
+	ModelName string `json:"model_name" gorm:"size:191;uniqueIndex:idx_umu_user_model_month,priority:2"`
+	Month     string `json:"month" gorm:"size:7;uniqueIndex:idx_umu_user_model_month,priority:3"` // 2006-01
+	Tokens    int64  `json:"tokens" gorm:"bigint;default:0"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func currentUsageMonth() string {
+	return time.Now().Format("2006-01")
+}
+
+// 未启用 Redis 时当月用量在内存中缓存的时间，用量阶梯只需要近似的累计值
+const userMonthlyUsageMemoryCacheTTL = time.Minute
+
+type userMonthlyUsageCacheEntry struct {
+	tokens    int64
+	expiresAt time.Time
+}
+
+var (
+	userMonthlyUsageCacheLock sync.Mutex
+	userMonthlyUsageCache     = make(map[string]*userMonthlyUsageCacheEntry)
+)
+
+func getUserMonthlyUsageCacheKey(userId int, modelName string, month string) string {
+	return fmt.Sprintf("user_monthly_usage:%d:%s:%s", userId, month, modelName)
+}
+
+// GetUserMonthlyModelTokens 获取用户当月在该模型上的累计 tokens，与用户额度一样优先读取缓存
+func GetUserMonthlyModelTokens(userId int, modelName string) int64 {
+	month := currentUsageMonth()
+	key := getUserMonthlyUsageCacheKey(userId, modelName, month)
+	if common.RedisEnabled {
+		if value, err := common.RedisGet(key); err == nil {
+			if tokens, err := strconv.ParseInt(value, 10, 64); err == nil {
+				return tokens
+			}
+		}
+	} else {
+		userMonthlyUsageCacheLock.Lock()
+		entry, ok := userMonthlyUsageCache[key]
+		userMonthlyUsageCacheLock.Unlock()
+		if ok && time.Now().Before(entry.expiresAt) {
+			return entry.tokens
+		}
+	}
+	var usage UserMonthlyUsage
+	err := DB.Where("user_id = ? AND model_name = ? AND month = ?", userId, modelName, month).
+		Limit(1).Find(&usage).Error
+	if err != nil {
+		common.SysError("failed to get user monthly usage: " + err.Error())
+		return 0
+	}
+	if common.RedisEnabled {
+		gopool.Go(func() {
+			err := common.RedisSet(key, strconv.FormatInt(usage.Tokens, 10), time.Duration(common.RedisKeyCacheSeconds())*time.Second)
+			if err != nil {
+				common.SysLog("failed to update user monthly usage cache: " + err.Error())
+			}
+		})
+	} else {
+		userMonthlyUsageCacheLock.Lock()
+		for k, e := range userMonthlyUsageCache {
+			if time.Now().After(e.expiresAt) {
+				delete(userMonthlyUsageCache, k)
+			}
+		}
+		userMonthlyUsageCache[key] = &userMonthlyUsageCacheEntry{tokens: usage.Tokens, expiresAt: time.Now().Add(userMonthlyUsageMemoryCacheTTL)}
+		userMonthlyUsageCacheLock.Unlock()
+	}
+	return usage.Tokens
+}
+
+// cacheIncrUserMonthlyModelTokens 数据库累加成功后同步累加缓存，缓存不存在时不处理
+func cacheIncrUserMonthlyModelTokens(userId int, modelName string, month string, tokens int64) {
+	key := getUserMonthlyUsageCacheKey(userId, modelName, month)
+	if common.RedisEnabled {
+		if err := common.RedisIncr(key, tokens); err != nil {
+			common.SysLog("failed to increase user monthly usage cache: " + err.Error())
+		}
+		return
+	}
+	userMonthlyUsageCacheLock.Lock()
+	defer userMonthlyUsageCacheLock.Unlock()
+	if entry, ok := userMonthlyUsageCache[key]; ok {
+		entry.tokens += tokens
+	}
+}
+
+// IncreaseUserMonthlyModelTokens 累加用户当月在该模型上的 tokens
+func IncreaseUserMonthlyModelTokens(userId int, modelName string, tokens int64) error {
+	if tokens <= 0 {
+		return nil
+	}
+	month := currentUsageMonth()
+	now := common.GetTimestamp()
+	for i := 0; i < 2; i++ {
+		result := DB.Model(&UserMonthlyUsage{}).
+			Where("user_id = ? AND model_name = ? AND month = ?", userId, modelName, month).
+			Updates(map[string]interface{}{
+				"tokens":     gorm.Expr("tokens + ?", tokens),
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			cacheIncrUserMonthlyModelTokens(userId, modelName, month, tokens)
+			return nil
+		}
+		usage := &UserMonthlyUsage{
+			UserId:    userId,
+			ModelName: modelName,
+			Month:     month,
+			Tokens:    tokens,
+			UpdatedAt: now,
+		}
+		// 并发插入时唯一索引冲突，重新走一次累加
+		result = DB.Clauses(clause.OnConflict{DoNothing: true}).Create(usage)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			cacheIncrUserMonthlyModelTokens(userId, modelName, month, tokens)
+			return nil
+		}
+	}
+	return nil
+}
@@ -2,7 +2,10 @@ package model
 
 import (
 	"errors"
+	"fmt"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
@@ -21,25 +24,123 @@ const (
 )
 
 var batchUpdateStores []map[int]int
+var batchUpdateLedgers []map[int][]*QuotaLedgerEntry // 与增量一同暂存的额度流水，刷新成功后才写入
 var batchUpdateLocks []sync.Mutex
 
+// batchUpdateWALLock 写入增量时持读锁，刷新时持写锁一次性交换全部暂存并封存预写日志段
+var batchUpdateWALLock sync.RWMutex
+var batchUpdateLog batchUpdateWAL
+
+// batchUpdateFlushLock 定时刷新与退出前的刷新可能同时发生，串行执行
+var batchUpdateFlushLock sync.Mutex
+
+// batchUpdateRetrySegment 落库失败的日志段。保留原段并在下次刷新时原样重试，
+// 不把增量写入新段，否则在删除旧段前崩溃会导致新旧两段都被重放
+type batchUpdateRetrySegment struct {
+	segment string
+	stores  []map[int]int
+	ledgers []map[int][]*QuotaLedgerEntry
+	records int
+	since   int64 // 毫秒
+}
+
+var batchUpdateRetryLock sync.Mutex
+var batchUpdateRetrySegments []*batchUpdateRetrySegment
+
+var batchUpdateOldestPending atomic.Int64 // 最早一条未刷新增量的时间（毫秒），0 表示没有
+var batchUpdateLastFlushAt atomic.Int64
+var batchUpdateLastFlushCost atomic.Int64 // 毫秒
+var batchUpdateFlushFailures atomic.Int64
+var batchUpdateWALFailures atomic.Int64
+var batchUpdateWALDegraded atomic.Bool // 最近一次写入或封存预写日志失败，增量可能只保存在内存中
+var batchUpdateWALLastError atomic.Value
+
 func init() {
 	for i := 0; i < BatchUpdateTypeCount; i++ {
 		batchUpdateStores = append(batchUpdateStores, make(map[int]int))
+		batchUpdateLedgers = append(batchUpdateLedgers, make(map[int][]*QuotaLedgerEntry))
 		batchUpdateLocks = append(batchUpdateLocks, sync.Mutex{})
 	}
 }
 
+// RecoverBatchUpdates 打开预写日志并重放上次未落库的增量，需在数据库初始化后、处理请求前调用。
+// 即使本次未开启批量更新也应调用，以免关闭批量更新后遗留的增量丢失
+func RecoverBatchUpdates() {
+	if common.BatchUpdateWAL == BatchUpdateWALNone {
+		return
+	}
+	if !common.BatchUpdateEnabled {
+		// 未开启批量更新时只重放遗留日志，不再写入新日志
+		if common.BatchUpdateWAL == BatchUpdateWALRedis && !common.RedisEnabled {
+			return
+		}
+		if common.BatchUpdateWAL != BatchUpdateWALRedis {
+			if _, err := os.Stat(common.BatchUpdateWALDir); err != nil {
+				return
+			}
+		}
+	}
+	wal, err := newBatchUpdateWAL()
+	if err != nil {
+		common.SysError("failed to open batch update wal, pending deltas will only be kept in memory: " + err.Error())
+		return
+	}
+	replayBatchUpdateWAL(wal)
+	cleanBatchUpdateCheckpoints()
+	if !common.BatchUpdateEnabled {
+		_ = wal.Close()
+		return
+	}
+	batchUpdateLog = wal
+	common.SysLog("batch update wal enabled: " + wal.Name())
+}
+
 func InitBatchUpdater() {
 	gopool.Go(func() {
+		lastReplay := time.Now()
 		for {
 			time.Sleep(time.Duration(common.BatchUpdateInterval) * time.Second)
 			batchUpdate()
+			// 接管其他已下线节点遗留的日志段
+			if batchUpdateLog != nil && batchUpdateLog.Name() == BatchUpdateWALRedis && time.Since(lastReplay) > time.Minute {
+				replayBatchUpdateWAL(batchUpdateLog)
+				lastReplay = time.Now()
+			}
 		}
 	})
 }
 
+// FlushBatchUpdates 立即刷新所有暂存增量并关闭预写日志，用于优雅退出
+func FlushBatchUpdates() {
+	if !common.BatchUpdateEnabled {
+		return
+	}
+	batchUpdate()
+	if batchUpdateLog != nil {
+		batchUpdateWALLock.Lock()
+		wal := batchUpdateLog
+		batchUpdateLog = nil
+		batchUpdateWALLock.Unlock()
+		if err := wal.Close(); err != nil {
+			common.SysError("failed to close batch update wal: " + err.Error())
+		}
+	}
+}
+
 func addNewRecord(type_ int, id int, value int) {
+	addNewRecordWithLedger(type_, id, value, nil)
+}
+
+// addNewRecordWithLedger 暂存增量及对应的额度流水，流水在增量刷新的同一事务内写入
+func addNewRecordWithLedger(type_ int, id int, value int, entries []*QuotaLedgerEntry) {
+	batchUpdateWALLock.RLock()
+	defer batchUpdateWALLock.RUnlock()
+	if batchUpdateLog != nil {
+		if err := batchUpdateLog.Append(batchUpdateWALRecord{Type: type_, Id: id, Value: value}); err != nil {
+			markBatchUpdateWALFailure("failed to append batch update wal: " + err.Error())
+		}
+	}
+	batchUpdateOldestPending.CompareAndSwap(0, time.Now().UnixMilli())
 	batchUpdateLocks[type_].Lock()
 	defer batchUpdateLocks[type_].Unlock()
 	if _, ok := batchUpdateStores[type_][id]; !ok {
@@ -47,41 +148,161 @@ func addNewRecord(type_ int, id int, value int) {
 	} else {
 		batchUpdateStores[type_][id] += value
 	}
+	if len(entries) > 0 {
+		batchUpdateLedgers[type_][id] = append(batchUpdateLedgers[type_][id], entries...)
+	}
+}
+
+// markBatchUpdateWALFailure 记录预写日志故障，由服务层的监控通知管理员
+func markBatchUpdateWALFailure(message string) {
+	batchUpdateWALFailures.Add(1)
+	batchUpdateWALLastError.Store(message)
+	batchUpdateWALDegraded.Store(true)
+	common.SysError(message)
+}
+
+// getPendingBatchUpdate 获取尚未写入数据库的批量更新增量，包括等待重试的日志段
+func getPendingBatchUpdate(type_ int, id int) int {
+	batchUpdateLocks[type_].Lock()
+	pending := batchUpdateStores[type_][id]
+	batchUpdateLocks[type_].Unlock()
+	batchUpdateRetryLock.Lock()
+	for _, retry := range batchUpdateRetrySegments {
+		pending += retry.stores[type_][id]
+	}
+	batchUpdateRetryLock.Unlock()
+	return pending
+}
+
+// retryBatchUpdateSegments 重试此前落库失败的日志段，已有检查点的段说明已被重放，直接删除
+func retryBatchUpdateSegments() {
+	batchUpdateRetryLock.Lock()
+	retries := append([]*batchUpdateRetrySegment(nil), batchUpdateRetrySegments...)
+	batchUpdateRetryLock.Unlock()
+	if len(retries) == 0 {
+		return
+	}
+	done := make(map[*batchUpdateRetrySegment]bool, len(retries))
+	for _, retry := range retries {
+		applied, err := isBatchUpdateSegmentApplied(retry.segment)
+		if err == nil && !applied {
+			err = applyBatchUpdateSegment(retry.stores, retry.ledgers, retry.segment, retry.records)
+		}
+		if err != nil {
+			batchUpdateFlushFailures.Add(1)
+			common.SysError(fmt.Sprintf("failed to retry batch update wal segment %s: %s", retry.segment, err.Error()))
+			continue
+		}
+		done[retry] = true
+		if err = batchUpdateLog.Remove(retry.segment); err != nil {
+			common.SysError("failed to remove batch update wal segment: " + err.Error())
+		}
+	}
+	batchUpdateRetryLock.Lock()
+	remaining := batchUpdateRetrySegments[:0]
+	for _, retry := range batchUpdateRetrySegments {
+		if !done[retry] {
+			remaining = append(remaining, retry)
+		}
+	}
+	batchUpdateRetrySegments = remaining
+	batchUpdateRetryLock.Unlock()
 }
 
 func batchUpdate() {
-	// check if there's any data to update
+	batchUpdateFlushLock.Lock()
+	defer batchUpdateFlushLock.Unlock()
+	if batchUpdateLog != nil {
+		retryBatchUpdateSegments()
+	}
+
+	// 交换暂存与封存日志段需要原子完成，否则封存后写入的增量会被算进旧段
+	batchUpdateWALLock.Lock()
 	hasData := false
 	for i := 0; i < BatchUpdateTypeCount; i++ {
-		batchUpdateLocks[i].Lock()
 		if len(batchUpdateStores[i]) > 0 {
 			hasData = true
-			batchUpdateLocks[i].Unlock()
 			break
 		}
-		batchUpdateLocks[i].Unlock()
 	}
-
 	if !hasData {
+		batchUpdateWALLock.Unlock()
 		return
 	}
-
-	common.SysLog("batch update started")
+	stores := make([]map[int]int, BatchUpdateTypeCount)
+	ledgers := make([]map[int][]*QuotaLedgerEntry, BatchUpdateTypeCount)
+	records := 0
 	for i := 0; i < BatchUpdateTypeCount; i++ {
 		batchUpdateLocks[i].Lock()
-		store := batchUpdateStores[i]
+		stores[i] = batchUpdateStores[i]
+		ledgers[i] = batchUpdateLedgers[i]
+		records += len(stores[i])
 		batchUpdateStores[i] = make(map[int]int)
+		batchUpdateLedgers[i] = make(map[int][]*QuotaLedgerEntry)
 		batchUpdateLocks[i].Unlock()
+	}
+	oldestPending := batchUpdateOldestPending.Swap(0)
+	segment := ""
+	if batchUpdateLog != nil {
+		var err error
+		segment, err = batchUpdateLog.Rotate()
+		if err != nil {
+			markBatchUpdateWALFailure("failed to rotate batch update wal: " + err.Error())
+		} else {
+			batchUpdateWALDegraded.Store(false)
+		}
+	}
+	batchUpdateWALLock.Unlock()
+
+	common.SysLog("batch update started")
+	startTime := time.Now()
+	if segment == "" {
+		applyBatchUpdateStores(stores, ledgers)
+	} else if err := applyBatchUpdateSegment(stores, ledgers, segment, records); err != nil {
+		// 事务整体回滚，保留该段，下次刷新时以同一检查点重试
+		batchUpdateFlushFailures.Add(1)
+		common.SysError("failed to apply batch update, will retry next time: " + err.Error())
+		batchUpdateRetryLock.Lock()
+		batchUpdateRetrySegments = append(batchUpdateRetrySegments, &batchUpdateRetrySegment{
+			segment: segment,
+			stores:  stores,
+			ledgers: ledgers,
+			records: records,
+			since:   oldestPending,
+		})
+		batchUpdateRetryLock.Unlock()
+	} else if err = batchUpdateLog.Remove(segment); err != nil {
+		common.SysError("failed to remove batch update wal segment: " + err.Error())
+	}
+	batchUpdateLastFlushAt.Store(time.Now().Unix())
+	batchUpdateLastFlushCost.Store(time.Since(startTime).Milliseconds())
+	common.SysLog("batch update finished")
+}
+
+// applyBatchUpdateStores 未启用预写日志时逐条落库，失败的增量只记录日志。
+// 额度增量与其流水在同一事务内写入
+func applyBatchUpdateStores(stores []map[int]int, ledgers []map[int][]*QuotaLedgerEntry) {
+	for i, store := range stores {
 		// TODO: maybe we can combine updates with same key?
 		for key, value := range store {
 			switch i {
 			case BatchUpdateTypeUserQuota:
-				err := increaseUserQuota(key, value)
+				err := DB.Transaction(func(tx *gorm.DB) error {
+					if err := increaseUserQuotaTx(tx, key, value); err != nil {
+						return err
+					}
+					return createQuotaLedgerEntries(tx, ledgers[i][key])
+				})
 				if err != nil {
 					common.SysLog("failed to batch update user quota: " + err.Error())
 				}
 			case BatchUpdateTypeTokenQuota:
-				err := increaseTokenQuota(key, value)
+				err := DB.Transaction(func(tx *gorm.DB) error {
+					if err := increaseTokenQuotaTx(tx, key, value); err != nil {
+						return err
+					}
+					return createQuotaLedgerEntries(tx, ledgers[i][key])
+				})
 				if err != nil {
 					common.SysLog("failed to batch update token quota: " + err.Error())
 				}
@@ -94,7 +315,128 @@ func batchUpdate() {
 			}
 		}
 	}
-	common.SysLog("batch update finished")
+}
+
+// applyBatchUpdateSegment 在一个事务中写入日志段的全部增量、对应流水和检查点，检查点已存在时整个事务失败
+func applyBatchUpdateSegment(stores []map[int]int, ledgers []map[int][]*QuotaLedgerEntry, segment string, records int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		checkpoint := &BatchUpdateCheckpoint{Segment: segment, Records: records, CreatedAt: common.GetTimestamp()}
+		if err := tx.Create(checkpoint).Error; err != nil {
+			return err
+		}
+		for i, store := range stores {
+			for key, value := range store {
+				var err error
+				switch i {
+				case BatchUpdateTypeUserQuota:
+					err = increaseUserQuotaTx(tx, key, value)
+				case BatchUpdateTypeTokenQuota:
+					err = increaseTokenQuotaTx(tx, key, value)
+				case BatchUpdateTypeUsedQuota:
+					err = tx.Model(&User{}).Where("id = ?", key).Update("used_quota", gorm.Expr("used_quota + ?", value)).Error
+				case BatchUpdateTypeRequestCount:
+					err = tx.Model(&User{}).Where("id = ?", key).Update("request_count", gorm.Expr("request_count + ?", value)).Error
+				case BatchUpdateTypeChannelUsedQuota:
+					err = tx.Model(&Channel{}).Where("id = ?", key).Update("used_quota", gorm.Expr("used_quota + ?", value)).Error
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		for _, ledger := range ledgers {
+			for _, entries := range ledger {
+				if err := createQuotaLedgerEntries(tx, entries); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+var batchUpdateTypeNames = []string{
+	BatchUpdateTypeUserQuota:        "user_quota",
+	BatchUpdateTypeTokenQuota:       "token_quota",
+	BatchUpdateTypeUsedQuota:        "used_quota",
+	BatchUpdateTypeChannelUsedQuota: "channel_used_quota",
+	BatchUpdateTypeRequestCount:     "request_count",
+}
+
+type BatchUpdatePending struct {
+	Type   string
+	Keys   int
+	Amount int64
+}
+
+type BatchUpdateStats struct {
+	WAL           string
+	Pending       []BatchUpdatePending
+	FlushLag      time.Duration // 最早一条未刷新增量距今的时间
+	LastFlushAt   int64
+	LastFlushCost time.Duration
+	FlushFailures int64
+	WALFailures   int64
+	WALSegments   int  // 待重放的日志段数量
+	WALDegraded   bool // 最近一次写入或封存预写日志失败
+	WALLastError  string
+	RetrySegments int // 落库失败等待重试的日志段数量
+}
+
+func GetBatchUpdateStats() BatchUpdateStats {
+	stats := BatchUpdateStats{
+		Pending:       make([]BatchUpdatePending, 0, BatchUpdateTypeCount),
+		LastFlushAt:   batchUpdateLastFlushAt.Load(),
+		LastFlushCost: time.Duration(batchUpdateLastFlushCost.Load()) * time.Millisecond,
+		FlushFailures: batchUpdateFlushFailures.Load(),
+		WALFailures:   batchUpdateWALFailures.Load(),
+		WALDegraded:   batchUpdateWALDegraded.Load(),
+	}
+	if lastError, ok := batchUpdateWALLastError.Load().(string); ok {
+		stats.WALLastError = lastError
+	}
+	batchUpdateRetryLock.Lock()
+	retries := append([]*batchUpdateRetrySegment(nil), batchUpdateRetrySegments...)
+	batchUpdateRetryLock.Unlock()
+	stats.RetrySegments = len(retries)
+	for i := 0; i < BatchUpdateTypeCount; i++ {
+		pending := BatchUpdatePending{Type: batchUpdateTypeNames[i]}
+		batchUpdateLocks[i].Lock()
+		pending.Keys = len(batchUpdateStores[i])
+		for _, value := range batchUpdateStores[i] {
+			pending.Amount += int64(value)
+		}
+		batchUpdateLocks[i].Unlock()
+		for _, retry := range retries {
+			pending.Keys += len(retry.stores[i])
+			for _, value := range retry.stores[i] {
+				pending.Amount += int64(value)
+			}
+		}
+		stats.Pending = append(stats.Pending, pending)
+	}
+	oldest := batchUpdateOldestPending.Load()
+	for _, retry := range retries {
+		if retry.since != 0 && (oldest == 0 || retry.since < oldest) {
+			oldest = retry.since
+		}
+	}
+	if oldest != 0 {
+		stats.FlushLag = time.Since(time.UnixMilli(oldest))
+	}
+	batchUpdateWALLock.RLock()
+	wal := batchUpdateLog
+	batchUpdateWALLock.RUnlock()
+	if wal != nil {
+		stats.WAL = wal.Name()
+		if wal.Name() == BatchUpdateWALFile {
+			// 文件模式的段只属于本进程，统计不会产生认领副作用
+			if segments, err := wal.RecoverableSegments(); err == nil {
+				stats.WALSegments = len(segments)
+			}
+		}
+	}
+	return stats
 }
 
 func RecordExist(err error) (bool, error) {
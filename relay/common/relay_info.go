@@ -85,6 +85,7 @@ type RelayInfo struct {
 	UsingGroup        string // 使用的分组
 	UserGroup         string // 用户所在分组
 	TokenUnlimited    bool
+	OrganizationId    int // 团队令牌所属团队，费用从团队额度池扣除
 	StartTime         time.Time
 	FirstResponseTime time.Time
 	isFirstResponse   bool
@@ -96,6 +97,7 @@ type RelayInfo struct {
 	RelayMode              int
 	OriginModelName        string
 	RequestURLPath         string
+	RequestId              string // 请求 ID，用于额度流水关联
 	ShouldIncludeUsage     bool
 	DisablePing            bool // 是否禁止向下游发送自定义 Ping
 	ClientWs               *websocket.Conn
@@ -111,9 +113,18 @@ type RelayInfo struct {
 	UserQuota              int
 	RelayFormat            types.RelayFormat
 	SendResponseCount      int
-	FinalPreConsumedQuota  int  // 最终预消耗的配额
-	CheckinQuotaConsumed   int  // 签到额度消费量
-	IsClaudeBetaQuery      bool // /v1/messages?beta=true
+	FinalPreConsumedQuota  int    // 最终预消耗的配额
+	CheckinQuotaConsumed   int    // 签到额度消费量
+	RealtimeSettledQuota   int    // 实时会话中按响应逐次结算的额度合计，开启小数额度时使用
+	RealtimeQuotaCarry     int64  // 实时会话最近一次结算后结转的余数（微额度）
+	IsClaudeBetaQuery      bool   // /v1/messages?beta=true
+	TokenBudgetPeriod      string // 令牌周期预算的重置周期，为空表示未设置
+	TokenBudgetQuota       int    // 令牌每个周期的预算额度
+	TokenBudgetSoftLimit   bool   // 软限制超出预算后仍可调用
+	DerivedTokenId         string // 派生令牌 ID，为空表示使用父令牌本身
+	DerivedTokenQuota      int    // 派生令牌可以消费的额度上限
+	DerivedTokenExpiresAt  int64  // 派生令牌过期时间
+	DerivedTokenReserved   int    // 已从派生令牌额度中预留、尚未计入消费的额度
 
 	PriceData types.PriceData
 
@@ -401,10 +412,20 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		TokenId:        common.GetContextKeyInt(c, constant.ContextKeyTokenId),
 		TokenKey:       common.GetContextKeyString(c, constant.ContextKeyTokenKey),
 		TokenUnlimited: common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
+		OrganizationId: common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId),
+
+		TokenBudgetPeriod:    common.GetContextKeyString(c, constant.ContextKeyTokenBudgetPeriod),
+		TokenBudgetQuota:     common.GetContextKeyInt(c, constant.ContextKeyTokenBudgetQuota),
+		TokenBudgetSoftLimit: common.GetContextKeyBool(c, constant.ContextKeyTokenBudgetSoftLimit),
+
+		DerivedTokenId:        common.GetContextKeyString(c, constant.ContextKeyDerivedTokenId),
+		DerivedTokenQuota:     common.GetContextKeyInt(c, constant.ContextKeyDerivedTokenQuota),
+		DerivedTokenExpiresAt: common.GetContextKeyInt64(c, constant.ContextKeyDerivedTokenExpiresAt),
 
 		isFirstResponse: true,
 		RelayMode:       relayconstant.Path2RelayMode(c.Request.URL.Path),
 		RequestURLPath:  c.Request.URL.String(),
+		RequestId:       c.GetString(common.RequestIdKey),
 		IsStream:        isStream,
 
 		StartTime:         startTime,
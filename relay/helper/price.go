@@ -5,6 +5,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
@@ -49,6 +50,8 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	modelPrice, usePrice := ratio_setting.GetModelPrice(info.OriginModelName, false)
 
 	groupRatioInfo := HandleGroupRatio(c, info)
+	timeDiscount := resolvePricingTimeDiscount(info)
+	groupRatioInfo.GroupRatio *= timeDiscount.GroupMultiplier
 
 	var preConsumedQuota int
 	var modelRatio float64
@@ -61,6 +64,7 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	var audioRatio float64
 	var audioCompletionRatio float64
 	var freeModel bool
+	tierInfo := resolvePricingTierInfo(info)
 	if !usePrice {
 		preConsumedTokens := common.Max(promptTokens, common.PreConsumedQuota)
 		if meta.MaxTokens != 0 {
@@ -87,12 +91,20 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		imageRatio, _ = ratio_setting.GetImageRatio(info.OriginModelName)
 		audioRatio = ratio_setting.GetAudioRatio(info.OriginModelName)
 		audioCompletionRatio = ratio_setting.GetAudioCompletionRatio(info.OriginModelName)
+		if tierInfo.Enabled() {
+			tierInfo.BaseModelRatio = modelRatio
+			tierInfo.BaseCompletionRatio = completionRatio
+			tierInfo.BaseCacheRatio = cacheRatio
+			modelRatio, completionRatio, cacheRatio = tierInfo.Resolve(promptTokens)
+		}
+		modelRatio *= timeDiscount.ModelMultiplier
 		ratio := modelRatio * groupRatioInfo.GroupRatio
 		preConsumedQuota = int(float64(preConsumedTokens) * ratio)
 	} else {
 		if meta.ImagePriceRatio != 0 {
 			modelPrice = modelPrice * meta.ImagePriceRatio
 		}
+		modelPrice = modelPrice * tierInfo.VolumeMultiplier * timeDiscount.ModelMultiplier
 		preConsumedQuota = int(modelPrice * common.QuotaPerUnit * groupRatioInfo.GroupRatio)
 	}
 
@@ -130,6 +142,9 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		CacheCreation5mRatio: cacheCreationRatio5m,
 		CacheCreation1hRatio: cacheCreationRatio1h,
 		QuotaToPreConsume:    preConsumedQuota,
+		PricingVersion:       ratio_setting.GetPricingVersion(),
+		TierInfo:             tierInfo,
+		TimeDiscount:         timeDiscount,
 	}
 
 	if common.DebugEnabled {
@@ -139,6 +154,27 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	return priceData, nil
 }
 
+// resolvePricingTierInfo 读取模型的阶梯计价配置，配置了用量阶梯时查询用户当月累计用量确定价格系数
+func resolvePricingTierInfo(info *relaycommon.RelayInfo) types.PricingTierInfo {
+	tierInfo := types.PricingTierInfo{VolumeMultiplier: 1}
+	tiers, ok := ratio_setting.GetModelPricingTiers(info.OriginModelName)
+	if !ok {
+		return tierInfo
+	}
+	tierInfo.ContextTiers = tiers.ContextTiers
+	if len(tiers.VolumeTiers) > 0 {
+		tierInfo.HasVolumeTiers = true
+		tierInfo.MonthlyTokens = model.GetUserMonthlyModelTokens(info.UserId, info.OriginModelName)
+		tierInfo.VolumeMultiplier = tiers.VolumeMultiplier(tierInfo.MonthlyTokens)
+	}
+	return tierInfo
+}
+
+// resolvePricingTimeDiscount 按请求开始时间确定时段定价系数，保证预扣费与结算使用同一时段的价格
+func resolvePricingTimeDiscount(info *relaycommon.RelayInfo) types.PricingTimeDiscount {
+	return ratio_setting.GetPricingTimeDiscount(info.OriginModelName, info.UsingGroup, info.StartTime)
+}
+
 // ModelPriceHelperPerCall 按次计费的 PriceHelper (MJ、Task)
 func ModelPriceHelperPerCall(c *gin.Context, info *relaycommon.RelayInfo) types.PerCallPriceData {
 	groupRatioInfo := HandleGroupRatio(c, info)
@@ -153,11 +189,16 @@ func ModelPriceHelperPerCall(c *gin.Context, info *relaycommon.RelayInfo) types.
 			modelPrice = defaultPrice
 		}
 	}
+	timeDiscount := resolvePricingTimeDiscount(info)
+	modelPrice *= timeDiscount.ModelMultiplier
+	groupRatioInfo.GroupRatio *= timeDiscount.GroupMultiplier
 	quota := int(modelPrice * common.QuotaPerUnit * groupRatioInfo.GroupRatio)
 	priceData := types.PerCallPriceData{
 		ModelPrice:     modelPrice,
 		Quota:          quota,
 		GroupRatioInfo: groupRatioInfo,
+		PricingVersion: ratio_setting.GetPricingVersion(),
+		TimeDiscount:   timeDiscount,
 	}
 	return priceData
 }
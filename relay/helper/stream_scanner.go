@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/metrics"
+	"github.com/QuantumNous/new-api/common/tracing"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
@@ -40,6 +42,10 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		return
 	}
 
+	metrics.RelayInFlightStreams.Inc()
+	defer metrics.RelayInFlightStreams.Dec()
+	defer tracing.Start(c, "stream").End()
+
 	// 确保响应体总是被关闭
 	defer func() {
 		if resp.Body != nil {
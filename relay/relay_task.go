@@ -54,6 +54,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 		modelName = service.CoverTaskActionToModelName(platform, info.Action)
 	}
 	modelPrice, success := ratio_setting.GetModelPrice(modelName, true)
+	pricingVersion := ratio_setting.GetPricingVersion()
 	if !success {
 		defaultPrice, ok := ratio_setting.GetDefaultModelPriceMap()[modelName]
 		if !ok {
@@ -63,10 +64,15 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 		}
 	}
 
+	// 时段定价按请求开始时间确定
+	timeDiscount := ratio_setting.GetPricingTimeDiscount(modelName, info.UsingGroup, info.StartTime)
+	modelPrice *= timeDiscount.ModelMultiplier
+
 	// 预扣
-	groupRatio := ratio_setting.GetGroupRatio(info.UsingGroup)
+	groupRatio := ratio_setting.GetGroupRatio(info.UsingGroup) * timeDiscount.GroupMultiplier
 	var ratio float64
 	userGroupRatio, hasUserGroupRatio := ratio_setting.GetGroupGroupRatio(info.UserGroup, info.UsingGroup)
+	userGroupRatio *= timeDiscount.GroupMultiplier
 	if hasUserGroupRatio {
 		ratio = modelPrice * userGroupRatio
 	} else {
@@ -83,7 +89,12 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 		}
 	}
 	println(fmt.Sprintf("model: %s, model_price: %.4f, group: %s, group_ratio: %.4f, final_ratio: %.4f", modelName, modelPrice, info.UsingGroup, groupRatio, ratio))
-	userQuota, err := model.GetUserQuota(info.UserId, false)
+	// 可用额度已包含后付费授信额度，团队令牌只使用团队额度
+	userQuota, err := service.GetBillingQuota(info)
+	if errors.Is(err, service.ErrUserCreditSuspended) {
+		taskErr = service.TaskErrorWrapperLocal(errors.New("user is suspended due to overdue invoices"), "user_credit_suspended", http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		taskErr = service.TaskErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 		return
@@ -93,6 +104,12 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 		taskErr = service.TaskErrorWrapperLocal(errors.New("user quota is not enough"), "quota_not_enough", http.StatusForbidden)
 		return
 	}
+	if err = service.ReserveTokenBudget(info, quota); err != nil {
+		taskErr = service.TaskErrorWrapperLocal(err, "token_budget_exceeded", http.StatusForbidden)
+		return
+	}
+	// 派生令牌预留的额度在提交成功扣费时抵扣，提交失败时释放
+	defer service.ReleaseTokenBudget(info)
 
 	if info.OriginTaskID != "" {
 		originTask, exist, err := model.GetByTaskId(info.UserId, info.OriginTaskID)
@@ -181,6 +198,10 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 				if hasUserGroupRatio {
 					other["user_group_ratio"] = userGroupRatio
 				}
+				if pricingVersion != 0 {
+					other["pricing_version"] = pricingVersion
+				}
+				service.AppendPricingTimeDiscountInfo(timeDiscount, other)
 				model.RecordConsumeLog(c, info.UserId, model.RecordConsumeLogParams{
 					ChannelId: info.ChannelId,
 					ModelName: modelName,
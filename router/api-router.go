@@ -19,6 +19,7 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/uptime/status", controller.GetUptimeKumaStatus)
 		apiRouter.GET("/models", middleware.UserAuth(), controller.DashboardListModels)
 		apiRouter.GET("/status/test", middleware.AdminAuth(), controller.TestStatus)
+		apiRouter.GET("/status/page", controller.GetStatusPage)
 		apiRouter.GET("/notice", controller.GetNotice)
 		apiRouter.GET("/user-agreement", controller.GetUserAgreement)
 		apiRouter.GET("/privacy-policy", controller.GetPrivacyPolicy)
@@ -48,6 +49,15 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.POST("/verify", middleware.UserAuth(), middleware.CriticalRateLimit(), controller.UniversalVerify)
 		apiRouter.GET("/verify/status", middleware.UserAuth(), controller.GetVerificationStatus)
 
+		statusIncidentRoute := apiRouter.Group("/status/incidents")
+		statusIncidentRoute.Use(middleware.AdminAuth())
+		{
+			statusIncidentRoute.GET("/", controller.GetStatusIncidents)
+			statusIncidentRoute.POST("/", controller.AddStatusIncident)
+			statusIncidentRoute.PUT("/", controller.UpdateStatusIncident)
+			statusIncidentRoute.DELETE("/:id", controller.DeleteStatusIncident)
+		}
+
 		userRoute := apiRouter.Group("/user")
 		{
 			userRoute.POST("/register", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Register)
@@ -84,6 +94,13 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.POST("/stripe/pay", middleware.CriticalRateLimit(), controller.RequestStripePay)
 				selfRoute.POST("/stripe/amount", controller.RequestStripeAmount)
 				selfRoute.POST("/creem/pay", middleware.CriticalRateLimit(), controller.RequestCreemPay)
+				selfRoute.GET("/subscription", controller.GetSubscriptionSelf)
+				selfRoute.POST("/subscription/pay", middleware.CriticalRateLimit(), controller.RequestSubscriptionPay)
+				selfRoute.POST("/subscription/change", middleware.CriticalRateLimit(), controller.ChangeSubscriptionPlan)
+				selfRoute.POST("/subscription/cancel", controller.CancelSubscriptionSelf)
+				selfRoute.GET("/invoice", controller.GetInvoicesSelf)
+				selfRoute.GET("/invoice/statement", controller.GetUserStatementSelf)
+				selfRoute.GET("/invoice/:id", controller.GetInvoiceSelf)
 				selfRoute.POST("/aff_transfer", controller.TransferAffQuota)
 				selfRoute.PUT("/setting", controller.UpdateUserSetting)
 
@@ -118,6 +135,53 @@ func SetApiRouter(router *gin.Engine) {
 				adminRoute.DELETE("/:id/2fa", controller.AdminDisable2FA)
 			}
 		}
+		subscriptionRoute := apiRouter.Group("/subscription")
+		subscriptionRoute.Use(middleware.AdminAuth())
+		{
+			subscriptionRoute.GET("/", controller.GetAllSubscriptions)
+			subscriptionRoute.GET("/plans", controller.GetSubscriptionPlans)
+			subscriptionRoute.POST("/plans", controller.AddSubscriptionPlan)
+			subscriptionRoute.PUT("/plans", controller.UpdateSubscriptionPlan)
+			subscriptionRoute.DELETE("/plans/:id", controller.DeleteSubscriptionPlan)
+			subscriptionRoute.POST("/grant", controller.AdminGrantSubscription)
+			subscriptionRoute.POST("/:id/expire", controller.AdminExpireSubscription)
+		}
+		organizationRoute := apiRouter.Group("/organization")
+		organizationRoute.Use(middleware.UserAuth())
+		{
+			organizationRoute.GET("/", controller.GetSelfOrganizations)
+			organizationRoute.POST("/", controller.CreateOrganization)
+			organizationRoute.POST("/invitation/accept", middleware.CriticalRateLimit(), controller.AcceptOrganizationInvitation)
+			organizationRoute.GET("/:id", controller.GetOrganization)
+			organizationRoute.PUT("/:id", controller.UpdateOrganization)
+			organizationRoute.DELETE("/:id", controller.DeleteOrganization)
+			organizationRoute.POST("/:id/fund", controller.FundOrganization)
+			organizationRoute.POST("/:id/leave", controller.LeaveOrganization)
+			organizationRoute.PUT("/:id/member", controller.UpdateOrganizationMember)
+			organizationRoute.DELETE("/:id/member/:user_id", controller.RemoveOrganizationMember)
+			organizationRoute.GET("/:id/invitation", controller.GetOrganizationInvitations)
+			organizationRoute.POST("/:id/invitation", controller.CreateOrganizationInvitation)
+			organizationRoute.DELETE("/:id/invitation/:invitation_id", controller.RevokeOrganizationInvitation)
+			organizationRoute.GET("/:id/token", controller.GetOrganizationTokens)
+			organizationRoute.GET("/:id/usage", controller.GetOrganizationUsage)
+		}
+		organizationAdminRoute := apiRouter.Group("/organization/admin")
+		organizationAdminRoute.Use(middleware.AdminAuth())
+		{
+			organizationAdminRoute.GET("/", controller.GetAllOrganizations)
+			organizationAdminRoute.POST("/:id/quota", controller.AdminAdjustOrganizationQuota)
+			organizationAdminRoute.POST("/:id/status", controller.AdminUpdateOrganizationStatus)
+		}
+		invoiceRoute := apiRouter.Group("/invoice")
+		invoiceRoute.Use(middleware.AdminAuth())
+		{
+			invoiceRoute.GET("/", controller.GetAllInvoices)
+			invoiceRoute.GET("/:id", controller.GetInvoice)
+			invoiceRoute.POST("/generate", controller.GenerateInvoice)
+			invoiceRoute.POST("/run", controller.RunInvoiceJob)
+			invoiceRoute.POST("/:id/pay", controller.MarkInvoicePaid)
+			invoiceRoute.POST("/suspend", controller.SetUserCreditSuspended)
+		}
 		optionRoute := apiRouter.Group("/option")
 		optionRoute.Use(middleware.RootAuth())
 		{
@@ -135,6 +199,29 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			ratioSyncRoute.GET("/channels", controller.GetSyncableChannels)
 			ratioSyncRoute.POST("/fetch", controller.FetchUpstreamRatios)
+			ratioSyncRoute.POST("/run", controller.RunRatioSync)
+			ratioSyncRoute.GET("/changes", controller.GetRatioChangeSets)
+			ratioSyncRoute.POST("/changes/:id/approve", controller.ApproveRatioChangeSet)
+			ratioSyncRoute.POST("/changes/:id/reject", controller.RejectRatioChangeSet)
+			ratioSyncRoute.GET("/logs", controller.GetRatioChangeLogs)
+		}
+		pricingVersionRoute := apiRouter.Group("/pricing_version")
+		pricingVersionRoute.Use(middleware.RootAuth())
+		{
+			pricingVersionRoute.GET("/", controller.GetPricingVersions)
+			pricingVersionRoute.GET("/at", controller.GetPricingAt)
+			pricingVersionRoute.GET("/:id", controller.GetPricingVersion)
+			pricingVersionRoute.POST("/", controller.CreatePricingVersion)
+			pricingVersionRoute.POST("/:id/restore", controller.RestorePricingVersion)
+			pricingVersionRoute.DELETE("/:id", controller.CancelPricingVersion)
+		}
+		quotaLedgerRoute := apiRouter.Group("/quota_ledger")
+		quotaLedgerRoute.Use(middleware.RootAuth())
+		{
+			quotaLedgerRoute.GET("/", controller.GetQuotaLedgerEntries)
+			quotaLedgerRoute.GET("/balance", controller.GetQuotaLedgerBalance)
+			quotaLedgerRoute.GET("/reports", controller.GetQuotaReconcileReports)
+			quotaLedgerRoute.POST("/reconcile", controller.RunQuotaReconcile)
 		}
 		channelRoute := apiRouter.Group("/channel")
 		channelRoute.Use(middleware.AdminAuth())
@@ -147,6 +234,18 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.POST("/:id/key", middleware.RootAuth(), middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired(), controller.GetChannelKey)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
+			channelRoute.GET("/verify", controller.GetModelVerifications)
+			channelRoute.POST("/verify", controller.VerifyAllChannelModels)
+			channelRoute.POST("/verify/:id", controller.VerifyChannelModel)
+			channelRoute.POST("/capability", controller.TestAllChannelCapabilities)
+			channelRoute.GET("/capability/:id", controller.GetChannelCapabilities)
+			channelRoute.POST("/capability/:id", controller.TestChannelCapabilities)
+			channelRoute.GET("/capability/:id/history", controller.GetChannelTestResults)
+			channelRoute.GET("/capability/:id/trend", controller.GetChannelCapabilityTrend)
+			channelRoute.GET("/model_sync", controller.GetChannelModelSyncs)
+			channelRoute.GET("/model_sync/report", controller.GetChannelModelSyncReport)
+			channelRoute.POST("/model_sync", controller.SyncAllChannelModels)
+			channelRoute.POST("/model_sync/:id", controller.SyncChannelModels)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
 			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
 			channelRoute.POST("/", controller.AddChannel)
@@ -175,6 +274,14 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.PUT("/", controller.UpdateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
 			tokenRoute.POST("/batch", controller.DeleteTokenBatch)
+			tokenRoute.POST("/:id/rotate", controller.RotateToken)
+		}
+
+		// 使用令牌本身鉴权，签发短期派生令牌
+		tokenDeriveRoute := apiRouter.Group("/token/derive")
+		tokenDeriveRoute.Use(middleware.CriticalRateLimit(), middleware.TokenAuth())
+		{
+			tokenDeriveRoute.POST("", controller.DeriveToken)
 		}
 
 		usageRoute := apiRouter.Group("/usage")
@@ -209,10 +316,28 @@ func SetApiRouter(router *gin.Engine) {
 		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
+		logRoute.GET("/sink/stats", middleware.RootAuth(), controller.GetLogSinkStats)
+		logRoute.GET("/archive", middleware.AdminAuth(), controller.GetLogArchives)
+		logRoute.POST("/archive/run", middleware.RootAuth(), controller.RunLogArchive)
+		logRoute.GET("/archive/:id/logs", middleware.AdminAuth(), controller.QueryLogArchive)
+		logRoute.POST("/archive/:id/restore", middleware.RootAuth(), controller.RestoreLogArchive)
+		logRoute.GET("/payload/:request_id", middleware.AdminAuth(), controller.GetPayloadCapture)
+		logRoute.POST("/payload/:request_id/replay", middleware.AdminAuth(), controller.ReplayPayloadCapture)
+
+		inflightRoute := apiRouter.Group("/inflight")
+		inflightRoute.Use(middleware.AdminAuth())
+		{
+			inflightRoute.GET("/", controller.GetInflightRequests)
+			inflightRoute.GET("/stream", controller.StreamInflightRequests)
+			inflightRoute.POST("/cancel", controller.CancelInflightRequests)
+		}
 
 		dataRoute := apiRouter.Group("/data")
 		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
 		dataRoute.GET("/self", middleware.UserAuth(), controller.GetUserQuotaDates)
+		dataRoute.GET("/rollup", middleware.AdminAuth(), controller.GetUsageRollups)
+		dataRoute.GET("/rollup/self", middleware.UserAuth(), controller.GetUserUsageRollups)
+		dataRoute.POST("/rollup/backfill", middleware.RootAuth(), controller.BackfillUsageRollups)
 
 		logRoute.Use(middleware.CORS())
 		{
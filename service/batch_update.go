@@ -0,0 +1,27 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+)
+
+// MonitorBatchUpdateWAL 预写日志写入或封存失败时增量只保存在内存中，进程崩溃会丢失，
+// 每次进入这种状态时通知超级管理员一次
+func MonitorBatchUpdateWAL() {
+	degraded := false
+	for {
+		time.Sleep(30 * time.Second)
+		stats := model.GetBatchUpdateStats()
+		if stats.WALDegraded && !degraded {
+			NotifyRootUser(dto.NotifyTypeBatchUpdateWAL, "批量更新预写日志异常",
+				fmt.Sprintf("批量更新预写日志写入失败，未落库的额度增量可能只保存在内存中，进程崩溃时会丢失。最近错误：%s", stats.WALLastError))
+		} else if !stats.WALDegraded && degraded {
+			common.SysLog("batch update wal recovered")
+		}
+		degraded = stats.WALDegraded
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	ChannelCapabilityStream      = "stream"
+	ChannelCapabilityToolCall    = "tool_call"
+	ChannelCapabilityVision      = "vision"
+	ChannelCapabilityJSONMode    = "json_mode"
+	ChannelCapabilityEmbedding   = "embedding"
+	ChannelCapabilityLongContext = "long_context"
+)
+
+var ChannelCapabilities = []string{
+	ChannelCapabilityStream,
+	ChannelCapabilityToolCall,
+	ChannelCapabilityVision,
+	ChannelCapabilityJSONMode,
+	ChannelCapabilityEmbedding,
+	ChannelCapabilityLongContext,
+}
+
+const (
+	// 32x32 纯红色 PNG
+	capabilityVisionImage       = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAACAAAAAgCAIAAAD8GO2jAAAAKElEQVR4nO3NsQ0AAAzCMP5/un0CNkuZ41wybXsHAAAAAAAAAAAAxR4yw/wuPL6QkAAAAABJRU5ErkJggg=="
+	capabilityLongContextFiller = "The quick brown fox jumps over the lazy dog. "
+	capabilityLongContextNeedle = "7391"
+	capabilityMessageMaxLength  = 200
+)
+
+type ChannelCapabilityProbe struct {
+	Capability string
+	Path       string
+	ModelName  string
+	Body       []byte
+}
+
+func IsEmbeddingModel(modelName string) bool {
+	name := strings.ToLower(modelName)
+	return strings.Contains(name, "embed") || strings.HasPrefix(name, "m3e") || strings.Contains(name, "bge-")
+}
+
+// BuildChannelCapabilityProbe 生成能力测试请求，渠道下没有适用的模型时返回 nil
+func BuildChannelCapabilityProbe(capability string, testModel string, models []string, longContextTokens int) *ChannelCapabilityProbe {
+	if capability == ChannelCapabilityEmbedding {
+		for _, m := range models {
+			if IsEmbeddingModel(m) {
+				body, _ := common.Marshal(map[string]any{
+					"model": m,
+					"input": "hello world",
+				})
+				return &ChannelCapabilityProbe{Capability: capability, Path: "/v1/embeddings", ModelName: m, Body: body}
+			}
+		}
+		return nil
+	}
+	if testModel == "" || IsEmbeddingModel(testModel) {
+		return nil
+	}
+
+	var body []byte
+	switch capability {
+	case ChannelCapabilityStream:
+		body = buildModelVerifyBody(testModel, "Say hello.", 16, map[string]any{"stream": true})
+	case ChannelCapabilityToolCall:
+		body = buildModelVerifyBody(testModel, modelVerifyToolPrompt, 128, map[string]any{"tools": getWeatherTools})
+	case ChannelCapabilityJSONMode:
+		body = buildModelVerifyBody(testModel, `Return a JSON object with a key "ok" set to true.`, 32, map[string]any{
+			"response_format": map[string]any{"type": "json_object"},
+		})
+	case ChannelCapabilityVision:
+		body, _ = common.Marshal(map[string]any{
+			"model": testModel,
+			"messages": []map[string]any{
+				{
+					"role": "user",
+					"content": []map[string]any{
+						{"type": "text", "text": "What is the color of this image? Answer with one word."},
+						{"type": "image_url", "image_url": map[string]any{"url": capabilityVisionImage}},
+					},
+				},
+			},
+			"temperature": 0,
+			"max_tokens":  16,
+		})
+	case ChannelCapabilityLongContext:
+		// 在长文本中间埋入一个数字，要求模型找出
+		repeat := longContextTokens / 10
+		filler := strings.Repeat(capabilityLongContextFiller, repeat/2)
+		content := filler + "The secret code is " + capabilityLongContextNeedle + ". " + filler +
+			"\nWhat is the secret code mentioned above? Answer with only the code."
+		body = buildModelVerifyBody(testModel, content, 16, nil)
+	default:
+		return nil
+	}
+	return &ChannelCapabilityProbe{Capability: capability, Path: "/v1/chat/completions", ModelName: testModel, Body: body}
+}
+
+// CheckChannelCapability 校验能力测试的响应是否符合预期
+func CheckChannelCapability(capability string, response string, isStream bool) (bool, string) {
+	switch capability {
+	case ChannelCapabilityStream:
+		if !isStream {
+			return false, "响应不是流式格式"
+		}
+		if output := ExtractResponseOutput(response, true); output == "" {
+			return false, "流式响应中没有内容"
+		}
+		return true, ""
+	case ChannelCapabilityToolCall:
+		name := gjson.Get(response, "choices.0.message.tool_calls.0.function.name").String()
+		if name != "get_weather" {
+			return false, "未调用工具：" + limitCapabilityMessage(ExtractResponseOutput(response, false))
+		}
+		return true, ""
+	case ChannelCapabilityJSONMode:
+		content := strings.TrimSpace(gjson.Get(response, "choices.0.message.content").String())
+		if !gjson.Valid(content) || !gjson.Parse(content).IsObject() {
+			return false, "输出不是 JSON 对象：" + limitCapabilityMessage(content)
+		}
+		return true, ""
+	case ChannelCapabilityVision:
+		output := ExtractResponseOutput(response, false)
+		if !strings.Contains(strings.ToLower(output), "red") {
+			return false, "未能识别图片内容：" + limitCapabilityMessage(output)
+		}
+		return true, ""
+	case ChannelCapabilityEmbedding:
+		if gjson.Get(response, "data.0.embedding.#").Int() == 0 {
+			return false, "响应中没有向量"
+		}
+		return true, ""
+	case ChannelCapabilityLongContext:
+		output := ExtractResponseOutput(response, false)
+		if !strings.Contains(output, capabilityLongContextNeedle) {
+			return false, "未能从长文本中找到目标内容：" + limitCapabilityMessage(output)
+		}
+		return true, ""
+	}
+	return false, fmt.Sprintf("未知的能力：%s", capability)
+}
+
+func limitCapabilityMessage(message string) string {
+	runes := []rune(message)
+	if len(runes) > capabilityMessageMaxLength {
+		return string(runes[:capabilityMessageMaxLength]) + "..."
+	}
+	return message
+}
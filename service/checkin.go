@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
 )
 
 const (
@@ -135,6 +136,11 @@ func PerformCheckin(userId int, quotaAmount int) (*CheckinStatus, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to set check-in quota: %v", err)
 	}
+	model.GrantCheckinQuotaLedger(userId, quotaAmount, model.QuotaLedgerRef{
+		Source:    model.QuotaLedgerSourceCheckin,
+		Reference: GetSingaporeDate(),
+		Remark:    "每日签到",
+	})
 
 	return &CheckinStatus{
 		HasCheckedIn:   true,
@@ -166,7 +172,7 @@ func GetCheckinQuota(userId int) (int, error) {
 
 // ConsumeCheckinQuota consumes check-in quota for a user
 // Returns the amount actually consumed (may be less than requested if not enough quota)
-func ConsumeCheckinQuota(userId int, amount int) (int, error) {
+func ConsumeCheckinQuota(userId int, amount int, ref model.QuotaLedgerRef) (int, error) {
 	if !common.RedisEnabled {
 		return 0, nil
 	}
@@ -209,12 +215,13 @@ func ConsumeCheckinQuota(userId int, amount int) (int, error) {
 	if err != nil {
 		return 0, fmt.Errorf("failed to consume check-in quota: %v", err)
 	}
+	model.RecordCheckinQuotaLedger(userId, -consumed, ref)
 
 	return consumed, nil
 }
 
 // ReturnCheckinQuota returns quota back to check-in pool (for failed requests)
-func ReturnCheckinQuota(userId int, amount int) error {
+func ReturnCheckinQuota(userId int, amount int, ref model.QuotaLedgerRef) error {
 	if !common.RedisEnabled {
 		return nil
 	}
@@ -243,6 +250,7 @@ func ReturnCheckinQuota(userId int, amount int) error {
 	if err != nil {
 		return fmt.Errorf("failed to return check-in quota: %v", err)
 	}
+	model.RecordCheckinQuotaLedger(userId, amount, ref)
 
 	return nil
 }
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	derivedTokenIssuer = "new-api"
+
+	DerivedTokenDefaultTTL = time.Hour
+	DerivedTokenMaxTTL     = 24 * time.Hour
+)
+
+// DerivedTokenClaims 派生令牌的声明。派生令牌由父令牌签发，使用父令牌鉴权和计费，
+// 签名密钥由父令牌的密钥派生，父令牌轮换密钥后已签发的派生令牌全部失效
+type DerivedTokenClaims struct {
+	ParentId int      `json:"pid"`
+	Models   []string `json:"models,omitempty"` // 为空表示沿用父令牌的模型限制
+	Quota    int      `json:"quota"`            // 派生令牌可以消费的额度上限
+	jwt.RegisteredClaims
+}
+
+func derivedTokenSigningKey(parentKey string) []byte {
+	return []byte(common.GenerateHMAC("derived-token:" + parentKey))
+}
+
+// IsDerivedToken 派生令牌是 JWT 格式，普通令牌不会包含点号
+func IsDerivedToken(key string) bool {
+	return strings.HasPrefix(key, "eyJ") && strings.Count(key, ".") == 2
+}
+
+// MintDerivedToken 由父令牌签发派生令牌，返回令牌和过期时间
+func MintDerivedToken(parent *model.Token, models []string, quota int, ttl time.Duration) (string, int64, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := DerivedTokenClaims{
+		ParentId: parent.Id,
+		Models:   models,
+		Quota:    quota,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        common.GetUUID(),
+			Issuer:    derivedTokenIssuer,
+			Subject:   strconv.Itoa(parent.UserId),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(derivedTokenSigningKey(parent.Key))
+	if err != nil {
+		return "", 0, err
+	}
+	return signed, expiresAt.Unix(), nil
+}
+
+// 父令牌 ID 到密钥的本地缓存，密钥只用于校验签名，之后仍由 ValidateUserToken 按密钥鉴权，
+// 父令牌轮换密钥或删除后缓存中的旧密钥无法通过鉴权。不存在的 ID 同样缓存，避免伪造的令牌反复查库
+const (
+	derivedTokenParentCacheTTL     = 60 // 秒
+	derivedTokenParentCacheMaxSize = 10000
+)
+
+type derivedTokenParent struct {
+	key       string // 为空表示父令牌不存在
+	expiresAt int64
+}
+
+var (
+	derivedTokenParentLock sync.Mutex
+	derivedTokenParents    = make(map[int]*derivedTokenParent)
+)
+
+func getDerivedTokenParentKey(parentId int) (string, error) {
+	if parentId <= 0 {
+		return "", errors.New("父令牌不存在")
+	}
+	now := time.Now().Unix()
+	derivedTokenParentLock.Lock()
+	parent, ok := derivedTokenParents[parentId]
+	derivedTokenParentLock.Unlock()
+	if !ok || parent.expiresAt < now {
+		token, err := model.GetTokenById(parentId)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err
+		}
+		parent = &derivedTokenParent{expiresAt: now + derivedTokenParentCacheTTL}
+		if err == nil {
+			parent.key = token.Key
+		}
+		derivedTokenParentLock.Lock()
+		if len(derivedTokenParents) >= derivedTokenParentCacheMaxSize {
+			for id, cached := range derivedTokenParents {
+				if cached.expiresAt < now {
+					delete(derivedTokenParents, id)
+				}
+			}
+		}
+		if len(derivedTokenParents) < derivedTokenParentCacheMaxSize {
+			derivedTokenParents[parentId] = parent
+		}
+		derivedTokenParentLock.Unlock()
+	}
+	if parent.key == "" {
+		return "", errors.New("父令牌不存在")
+	}
+	return parent.key, nil
+}
+
+// ParseDerivedToken 校验派生令牌的签名和有效期，返回声明和父令牌的密钥
+func ParseDerivedToken(tokenString string) (*DerivedTokenClaims, string, error) {
+	claims := &DerivedTokenClaims{}
+	var parentKey string
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		var err error
+		parentKey, err = getDerivedTokenParentKey(claims.ParentId)
+		if err != nil {
+			return nil, err
+		}
+		return derivedTokenSigningKey(parentKey), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(derivedTokenIssuer),
+		jwt.WithExpirationRequired())
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, "", errors.New("派生令牌已过期")
+		}
+		return nil, "", errors.New("无效的派生令牌")
+	}
+	if claims.ID == "" || claims.Quota <= 0 {
+		return nil, "", errors.New("无效的派生令牌")
+	}
+	return claims, parentKey, nil
+}
+
+// ApplyDerivedTokenClaims 返回应用了派生令牌模型限制的父令牌副本，父令牌修改模型限制后取交集
+func ApplyDerivedTokenClaims(parent *model.Token, claims *DerivedTokenClaims) *model.Token {
+	token := *parent
+	if len(claims.Models) == 0 {
+		return &token
+	}
+	parentLimits := parent.GetModelLimitsMap()
+	models := make([]string, 0, len(claims.Models))
+	for _, m := range claims.Models {
+		if !parent.ModelLimitsEnabled || parentLimits[m] {
+			models = append(models, m)
+		}
+	}
+	token.ModelLimitsEnabled = true
+	token.ModelLimits = strings.Join(models, ",")
+	return &token
+}
+
+type derivedTokenUsage struct {
+	used      int
+	expiresAt int64
+}
+
+// 未启用 Redis 时派生令牌的消费计数保存在各节点的内存中，多节点部署时每个节点分别计数，
+// 派生令牌的额度上限只在单个节点内生效，且节点重启后计数清零。多节点部署需要启用 Redis
+var (
+	derivedTokenUsageLock sync.Mutex
+	derivedTokenUsages    = make(map[string]*derivedTokenUsage)
+)
+
+func derivedTokenUsageKey(id string) string {
+	return "derived_token_usage:" + id
+}
+
+// GetDerivedTokenUsed 获取派生令牌已消费的额度
+func GetDerivedTokenUsed(id string) (int, error) {
+	if common.RedisEnabled {
+		used, err := common.RDB.Get(context.Background(), derivedTokenUsageKey(id)).Int()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return 0, err
+		}
+		return used, nil
+	}
+	derivedTokenUsageLock.Lock()
+	defer derivedTokenUsageLock.Unlock()
+	if usage, ok := derivedTokenUsages[id]; ok {
+		return usage.used, nil
+	}
+	return 0, nil
+}
+
+// IncreaseDerivedTokenUsed 累加派生令牌的消费，计数在派生令牌过期后自动清理
+func IncreaseDerivedTokenUsed(id string, delta int, expiresAt int64) error {
+	_, err := addDerivedTokenUsed(id, delta, expiresAt)
+	return err
+}
+
+// addDerivedTokenUsed 累加派生令牌的消费并返回累加后的值
+func addDerivedTokenUsed(id string, delta int, expiresAt int64) (int, error) {
+	if common.RedisEnabled {
+		ctx := context.Background()
+		key := derivedTokenUsageKey(id)
+		pipe := common.RDB.TxPipeline()
+		incr := pipe.IncrBy(ctx, key, int64(delta))
+		pipe.ExpireAt(ctx, key, time.Unix(expiresAt, 0))
+		if _, err := pipe.Exec(ctx); err != nil {
+			return 0, err
+		}
+		return int(incr.Val()), nil
+	}
+	now := time.Now().Unix()
+	derivedTokenUsageLock.Lock()
+	defer derivedTokenUsageLock.Unlock()
+	for key, usage := range derivedTokenUsages {
+		if usage.expiresAt < now {
+			delete(derivedTokenUsages, key)
+		}
+	}
+	usage, ok := derivedTokenUsages[id]
+	if !ok {
+		usage = &derivedTokenUsage{expiresAt: expiresAt}
+		derivedTokenUsages[id] = usage
+	}
+	usage.used += delta
+	return usage.used, nil
+}
+
+// reserveDerivedTokenQuota 从派生令牌额度中预留 quota，先累加再判断，超出上限时回滚并拒绝，
+// 并发请求不会同时通过校验后超出额度上限
+func reserveDerivedTokenQuota(id string, limit int, quota int, expiresAt int64) error {
+	if quota <= 0 {
+		return checkDerivedTokenQuota(id, limit, quota)
+	}
+	used, err := addDerivedTokenUsed(id, quota, expiresAt)
+	if err != nil {
+		return err
+	}
+	if used <= limit {
+		return nil
+	}
+	if _, err = addDerivedTokenUsed(id, -quota, expiresAt); err != nil {
+		common.SysError("failed to roll back derived token reservation: " + err.Error())
+	}
+	return fmt.Errorf("derived token quota exceeded, used: %s, quota: %s, need: %s",
+		logger.FormatQuota(used-quota), logger.FormatQuota(limit), logger.FormatQuota(quota))
+}
+
+func checkDerivedTokenQuota(id string, limit int, quota int) error {
+	used, err := GetDerivedTokenUsed(id)
+	if err != nil {
+		return err
+	}
+	if used >= limit || used+quota > limit {
+		return fmt.Errorf("derived token quota exceeded, used: %s, quota: %s, need: %s",
+			logger.FormatQuota(used), logger.FormatQuota(limit), logger.FormatQuota(quota))
+	}
+	return nil
+}
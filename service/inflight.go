@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	inflightNodeKeyPrefix = "inflight:node:"
+	inflightCancelChannel = "inflight:cancel"
+	inflightSyncInterval  = time.Second
+	inflightNodeKeyTTL    = 5 * time.Second
+)
+
+var ErrInflightCancelled = errors.New("request cancelled by administrator")
+
+// InflightRequest 正在处理的中继请求快照
+type InflightRequest struct {
+	RequestId     string `json:"request_id"`
+	Node          string `json:"node"`
+	UserId        int    `json:"user_id"`
+	Username      string `json:"username"`
+	TokenId       int    `json:"token_id"`
+	TokenName     string `json:"token_name"`
+	ModelName     string `json:"model_name"`
+	Group         string `json:"group"`
+	ChannelId     int    `json:"channel_id"`
+	ChannelName   string `json:"channel_name"`
+	Path          string `json:"path"`
+	IsStream      bool   `json:"is_stream"`
+	StartTime     int64  `json:"start_time"` // 毫秒时间戳
+	BytesStreamed int64  `json:"bytes_streamed"`
+	Tokens        int64  `json:"tokens"` // 流式输出的估算 token 数
+}
+
+// InflightCancelFilter 取消条件，请求 id、令牌、用户任选其一
+type InflightCancelFilter struct {
+	RequestId string `json:"request_id"`
+	UserId    int    `json:"user_id"`
+	TokenId   int    `json:"token_id"`
+}
+
+func (f InflightCancelFilter) IsEmpty() bool {
+	return f.RequestId == "" && f.UserId == 0 && f.TokenId == 0
+}
+
+func (f InflightCancelFilter) match(request *InflightRequest) bool {
+	if f.RequestId != "" && f.RequestId != request.RequestId {
+		return false
+	}
+	if f.UserId != 0 && f.UserId != request.UserId {
+		return false
+	}
+	if f.TokenId != 0 && f.TokenId != request.TokenId {
+		return false
+	}
+	return true
+}
+
+// InflightEntry 单个请求的登记信息，cancelCtx 只在管理员取消时结束
+type InflightEntry struct {
+	mutex     sync.RWMutex
+	request   InflightRequest
+	isStream  atomic.Bool
+	bytes     atomic.Int64
+	tokens    atomic.Int64
+	cancelCtx context.Context
+	cancel    context.CancelCauseFunc
+	stop      func() bool
+}
+
+var (
+	inflightNodeId   = newInflightNodeId()
+	inflightRequests sync.Map // request id -> *InflightEntry
+)
+
+func newInflightNodeId() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "node"
+	}
+	return hostname + "-" + common.GetRandomString(6)
+}
+
+// inflightWriter 统计写给客户端的字节数，流式响应额外估算已输出的 token
+type inflightWriter struct {
+	gin.ResponseWriter
+	entry *InflightEntry
+}
+
+func (w *inflightWriter) Write(data []byte) (int, error) {
+	w.entry.observe(string(data))
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *inflightWriter) WriteString(s string) (int, error) {
+	w.entry.observe(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (e *InflightEntry) observe(data string) {
+	e.bytes.Add(int64(len(data)))
+	if !e.isStream.Load() {
+		return
+	}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		text := streamEventText(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		if text != "" {
+			e.tokens.Add(int64(EstimateTokenByModel(e.request.ModelName, text)))
+		}
+	}
+}
+
+// StartInflightRequest 登记请求并接管请求上下文，管理员取消时会中断上游请求与流式读取
+func StartInflightRequest(c *gin.Context) *InflightEntry {
+	requestId := c.GetString(common.RequestIdKey)
+	if requestId == "" || c.IsWebsocket() {
+		return nil
+	}
+	entry := &InflightEntry{
+		request: InflightRequest{
+			RequestId: requestId,
+			Node:      inflightNodeId,
+			UserId:    c.GetInt("id"),
+			Username:  c.GetString("username"),
+			TokenId:   c.GetInt("token_id"),
+			TokenName: c.GetString("token_name"),
+			ModelName: common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
+			Group:     common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
+			Path:      c.Request.URL.Path,
+			StartTime: time.Now().UnixMilli(),
+		},
+	}
+	entry.cancelCtx, entry.cancel = context.WithCancelCause(context.Background())
+	requestCtx, cancelRequest := context.WithCancelCause(c.Request.Context())
+	entry.stop = context.AfterFunc(entry.cancelCtx, func() {
+		cancelRequest(context.Cause(entry.cancelCtx))
+	})
+	c.Request = c.Request.WithContext(requestCtx)
+	c.Writer = &inflightWriter{ResponseWriter: c.Writer, entry: entry}
+	common.SetContextKey(c, constant.ContextKeyInflightRequest, entry)
+	inflightRequests.Store(requestId, entry)
+	return entry
+}
+
+func getInflightEntry(c *gin.Context) *InflightEntry {
+	value, ok := common.GetContextKey(c, constant.ContextKeyInflightRequest)
+	if !ok {
+		return nil
+	}
+	entry, _ := value.(*InflightEntry)
+	return entry
+}
+
+// InflightUpstreamContext 返回仅受管理员取消控制的上下文，用于上游请求，客户端断开不影响原有行为
+func InflightUpstreamContext(c *gin.Context) context.Context {
+	if entry := getInflightEntry(c); entry != nil {
+		return entry.cancelCtx
+	}
+	return nil
+}
+
+func (e *InflightEntry) SetStream(isStream bool) {
+	if e == nil {
+		return
+	}
+	e.isStream.Store(isStream)
+}
+
+func (e *InflightEntry) SetChannel(channelId int, channelName string) {
+	if e == nil {
+		return
+	}
+	e.mutex.Lock()
+	e.request.ChannelId = channelId
+	e.request.ChannelName = channelName
+	e.mutex.Unlock()
+}
+
+// CancelCause 请求被管理员取消时返回原因
+func (e *InflightEntry) CancelCause() error {
+	if e == nil || e.cancelCtx.Err() == nil {
+		return nil
+	}
+	return context.Cause(e.cancelCtx)
+}
+
+func (e *InflightEntry) Finish() {
+	if e == nil {
+		return
+	}
+	inflightRequests.Delete(e.request.RequestId)
+	e.stop()
+	e.cancel(nil)
+}
+
+func (e *InflightEntry) snapshot() InflightRequest {
+	e.mutex.RLock()
+	request := e.request
+	e.mutex.RUnlock()
+	request.IsStream = e.isStream.Load()
+	request.BytesStreamed = e.bytes.Load()
+	request.Tokens = e.tokens.Load()
+	return request
+}
+
+func localInflightRequests() []InflightRequest {
+	requests := make([]InflightRequest, 0)
+	inflightRequests.Range(func(_, value any) bool {
+		requests = append(requests, value.(*InflightEntry).snapshot())
+		return true
+	})
+	return requests
+}
+
+// ListInflightRequests 返回所有节点正在处理的请求，启用 Redis 时合并其他节点上报的快照
+func ListInflightRequests() []InflightRequest {
+	requests := localInflightRequests()
+	if common.RedisEnabled {
+		requests = append(requests, remoteInflightRequests()...)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].StartTime < requests[j].StartTime
+	})
+	return requests
+}
+
+func remoteInflightRequests() []InflightRequest {
+	ctx := context.Background()
+	var keys []string
+	iter := common.RDB.Scan(ctx, 0, inflightNodeKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if iter.Val() != inflightNodeKeyPrefix+inflightNodeId {
+			keys = append(keys, iter.Val())
+		}
+	}
+	if err := iter.Err(); err != nil {
+		common.SysError("failed to scan inflight requests: " + err.Error())
+		return nil
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	values, err := common.RDB.MGet(ctx, keys...).Result()
+	if err != nil {
+		common.SysError("failed to get inflight requests: " + err.Error())
+		return nil
+	}
+	var requests []InflightRequest
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var nodeRequests []InflightRequest
+		if err = common.UnmarshalJsonStr(data, &nodeRequests); err == nil {
+			requests = append(requests, nodeRequests...)
+		}
+	}
+	return requests
+}
+
+func cancelLocalInflightRequests(filter InflightCancelFilter) int {
+	count := 0
+	inflightRequests.Range(func(_, value any) bool {
+		entry := value.(*InflightEntry)
+		entry.mutex.RLock()
+		matched := filter.match(&entry.request)
+		entry.mutex.RUnlock()
+		if matched && entry.cancelCtx.Err() == nil {
+			entry.cancel(ErrInflightCancelled)
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// CancelInflightRequests 取消匹配的请求，返回本节点取消的数量；启用 Redis 时广播给其他节点
+func CancelInflightRequests(filter InflightCancelFilter) (int, error) {
+	if filter.IsEmpty() {
+		return 0, errors.New("cancel filter is empty")
+	}
+	count := cancelLocalInflightRequests(filter)
+	if common.RedisEnabled {
+		data, err := common.Marshal(filter)
+		if err != nil {
+			return count, err
+		}
+		if err = common.RDB.Publish(context.Background(), inflightCancelChannel, data).Err(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// InitInflightMonitor 多节点部署时定期上报本节点的请求快照，并订阅其他节点发起的取消
+func InitInflightMonitor() {
+	if !common.RedisEnabled {
+		return
+	}
+	go func() {
+		key := inflightNodeKeyPrefix + inflightNodeId
+		for {
+			data, err := common.Marshal(localInflightRequests())
+			if err == nil {
+				err = common.RedisSet(key, string(data), inflightNodeKeyTTL)
+			}
+			if err != nil {
+				common.SysError("failed to report inflight requests: " + err.Error())
+			}
+			time.Sleep(inflightSyncInterval)
+		}
+	}()
+	go func() {
+		pubsub := common.RDB.Subscribe(context.Background(), inflightCancelChannel)
+		defer pubsub.Close()
+		for message := range pubsub.Channel() {
+			var filter InflightCancelFilter
+			if err := common.UnmarshalJsonStr(message.Payload, &filter); err != nil || filter.IsEmpty() {
+				continue
+			}
+			if count := cancelLocalInflightRequests(filter); count > 0 {
+				common.SysLog(fmt.Sprintf("cancelled %d inflight requests by broadcast", count))
+			}
+		}
+	}()
+}
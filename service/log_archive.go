@@ -0,0 +1,344 @@
+package service
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+const (
+	LogArchiveFormatJsonlGzip = "jsonl.gz"
+
+	logArchiveBatchSize     = 1000
+	logArchiveDeleteLimit   = 1000
+	logArchivePartitionSize = int64(86400)
+)
+
+var logArchiveRunning atomic.Bool
+var autoArchiveLogsOnce sync.Once
+
+var ErrLogArchiveRunning = errors.New("log archive job is already running")
+
+func logArchiveObjectKey(startTimestamp int64) string {
+	day := time.Unix(startTimestamp, 0).UTC()
+	return fmt.Sprintf("%s/logs-%s.%s", day.Format("2006/01/02"), day.Format("20060102"), LogArchiveFormatJsonlGzip)
+}
+
+// ArchiveOldLogs 将超过保留天数的日志按天（UTC）导出到冷存储，返回本次生成的归档数量
+func ArchiveOldLogs(ctx context.Context) (int, error) {
+	if !logArchiveRunning.CompareAndSwap(false, true) {
+		return 0, ErrLogArchiveRunning
+	}
+	defer logArchiveRunning.Store(false)
+
+	setting := operation_setting.GetLogArchiveSetting()
+	if setting.RetentionDays <= 0 {
+		return 0, errors.New("log archive retention_days must be positive")
+	}
+	storage, err := GetLogArchiveStorage(setting.StorageType)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Unix() - int64(setting.RetentionDays)*logArchivePartitionSize
+	cutoff = cutoff - cutoff%logArchivePartitionSize
+	oldest, err := model.GetOldestLogTimestamp(cutoff)
+	if err != nil || oldest == 0 {
+		return 0, err
+	}
+
+	archived := 0
+	for dayStart := oldest - oldest%logArchivePartitionSize; dayStart < cutoff; dayStart += logArchivePartitionSize {
+		if err = ctx.Err(); err != nil {
+			return archived, err
+		}
+		dayEnd := dayStart + logArchivePartitionSize
+		existing, err := model.GetLogArchiveByRange(dayStart, dayEnd)
+		if err != nil {
+			return archived, err
+		}
+		if existing != nil {
+			// 已恢复的分区不再自动归档，否则恢复的日志会在下一次运行时被重新删除；
+			// 已导出但删除失败的分区重试删除
+			if existing.Status == model.LogArchiveStatusExported && setting.DeleteAfterArchive {
+				if err = deleteArchivedLogs(ctx, existing); err != nil {
+					return archived, fmt.Errorf("failed to delete archived logs of %s: %w", time.Unix(dayStart, 0).UTC().Format("2006-01-02"), err)
+				}
+				common.SysLog(fmt.Sprintf("deleted archived logs of %s:%s", existing.Storage, existing.ObjectKey))
+			}
+			continue
+		}
+		archive, err := archiveLogPartition(ctx, storage, dayStart, dayEnd, setting.DeleteAfterArchive)
+		if err != nil {
+			return archived, fmt.Errorf("failed to archive logs of %s: %w", time.Unix(dayStart, 0).UTC().Format("2006-01-02"), err)
+		}
+		if archive != nil {
+			archived++
+			common.SysLog(fmt.Sprintf("archived %d logs to %s:%s", archive.LogCount, archive.Storage, archive.ObjectKey))
+		}
+	}
+	return archived, nil
+}
+
+func archiveLogPartition(ctx context.Context, storage LogArchiveStorage, startTimestamp int64, endTimestamp int64, deleteAfterArchive bool) (*model.LogArchive, error) {
+	// 先压缩到临时文件，避免大分区占用过多内存
+	file, err := os.CreateTemp("", "log-archive-*.jsonl.gz")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+
+	hash := sha256.New()
+	gzipWriter := gzip.NewWriter(io.MultiWriter(file, hash))
+	archive := &model.LogArchive{
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+		Storage:        storage.Name(),
+		ObjectKey:      logArchiveObjectKey(startTimestamp),
+		Format:         LogArchiveFormatJsonlGzip,
+	}
+	afterId := 0
+	for {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		logs, err := model.GetLogsInRangeAfterId(startTimestamp, endTimestamp, afterId, logArchiveBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			line, err := common.Marshal(log)
+			if err != nil {
+				return nil, err
+			}
+			if _, err = gzipWriter.Write(append(line, '\n')); err != nil {
+				return nil, err
+			}
+			if archive.MinLogId == 0 || log.Id < archive.MinLogId {
+				archive.MinLogId = log.Id
+			}
+			archive.MaxLogId = log.Id
+			archive.LogCount++
+		}
+		if len(logs) < logArchiveBatchSize {
+			break
+		}
+		afterId = logs[len(logs)-1].Id
+	}
+	if archive.LogCount == 0 {
+		return nil, nil
+	}
+	if err = gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	archive.Checksum = hex.EncodeToString(hash.Sum(nil))
+	if archive.Size, err = file.Seek(0, io.SeekCurrent); err != nil {
+		return nil, err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err = storage.Put(ctx, archive.ObjectKey, file, archive.Size); err != nil {
+		return nil, err
+	}
+
+	archive.Status = model.LogArchiveStatusExported
+	if err = archive.Insert(); err != nil {
+		return nil, err
+	}
+	if !deleteAfterArchive {
+		return archive, nil
+	}
+	return archive, deleteArchivedLogs(ctx, archive)
+}
+
+// deleteArchivedLogs 删除已导出分区在日志库中的原始数据，失败时归档保持 exported 状态，下次运行时重试
+func deleteArchivedLogs(ctx context.Context, archive *model.LogArchive) error {
+	if _, err := model.DeleteLogsInRange(ctx, archive.StartTimestamp, archive.EndTimestamp, archive.MaxLogId, logArchiveDeleteLimit); err != nil {
+		return err
+	}
+	archive.Status = model.LogArchiveStatusArchived
+	return archive.Update()
+}
+
+// readLogArchive 逐行读取归档文件，handle 返回 false 时停止读取
+func readLogArchive(ctx context.Context, archive *model.LogArchive, handle func(log *model.Log) (bool, error)) error {
+	storage, err := GetLogArchiveStorage(archive.Storage)
+	if err != nil {
+		return err
+	}
+	body, err := storage.Get(ctx, archive.ObjectKey)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	gzipReader, err := gzip.NewReader(body)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+
+	reader := bufio.NewReader(gzipReader)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var log model.Log
+			if err = common.Unmarshal(line, &log); err != nil {
+				return err
+			}
+			next, err := handle(&log)
+			if err != nil {
+				return err
+			}
+			if !next {
+				return nil
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+type LogArchiveQuery struct {
+	LogType        int
+	StartTimestamp int64
+	EndTimestamp   int64
+	Username       string
+	TokenName      string
+	ModelName      string
+	Channel        int
+	Group          string
+}
+
+func (q LogArchiveQuery) match(log *model.Log) bool {
+	if q.LogType != model.LogTypeUnknown && log.Type != q.LogType {
+		return false
+	}
+	if q.StartTimestamp != 0 && log.CreatedAt < q.StartTimestamp {
+		return false
+	}
+	if q.EndTimestamp != 0 && log.CreatedAt > q.EndTimestamp {
+		return false
+	}
+	if q.Username != "" && log.Username != q.Username {
+		return false
+	}
+	if q.TokenName != "" && log.TokenName != q.TokenName {
+		return false
+	}
+	if q.ModelName != "" && log.ModelName != q.ModelName {
+		return false
+	}
+	if q.Channel != 0 && log.ChannelId != q.Channel {
+		return false
+	}
+	if q.Group != "" && log.Group != q.Group {
+		return false
+	}
+	return true
+}
+
+// QueryLogArchive 在归档文件中按条件分页查询日志，结果按 id 倒序返回以与日志列表保持一致
+func QueryLogArchive(ctx context.Context, archive *model.LogArchive, query LogArchiveQuery, startIdx int, num int) ([]*model.Log, int, error) {
+	var matched []*model.Log
+	total := 0
+	err := readLogArchive(ctx, archive, func(log *model.Log) (bool, error) {
+		if !query.match(log) {
+			return true, nil
+		}
+		total++
+		// 归档内按 id 升序写入，只保留最后 startIdx+num 条即可得到倒序分页
+		matched = append(matched, log)
+		if len(matched) > startIdx+num {
+			matched = matched[1:]
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	logs := make([]*model.Log, 0, num)
+	for i := len(matched) - 1 - startIdx; i >= 0 && len(logs) < num; i-- {
+		logs = append(logs, matched[i])
+	}
+	return logs, total, nil
+}
+
+// RestoreLogArchive 将归档重新导入日志库
+func RestoreLogArchive(ctx context.Context, archive *model.LogArchive) (int64, error) {
+	var total int64
+	batch := make([]*model.Log, 0, logArchiveBatchSize)
+	flush := func() error {
+		count, err := model.ImportLogs(batch)
+		if err != nil {
+			return err
+		}
+		total += count
+		batch = batch[:0]
+		return nil
+	}
+	err := readLogArchive(ctx, archive, func(log *model.Log) (bool, error) {
+		batch = append(batch, log)
+		if len(batch) >= logArchiveBatchSize {
+			if err := flush(); err != nil {
+				return false, err
+			}
+		}
+		return ctx.Err() == nil, ctx.Err()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return total, err
+	}
+	return total, model.MarkLogArchiveRestored(archive)
+}
+
+func AutomaticallyArchiveLogs() {
+	// 只在Master节点定时归档日志
+	if !common.IsMasterNode {
+		return
+	}
+	autoArchiveLogsOnce.Do(func() {
+		for {
+			setting := operation_setting.GetLogArchiveSetting()
+			if !setting.Enabled {
+				time.Sleep(1 * time.Minute)
+				continue
+			}
+			common.SysLog("automatically archiving old logs")
+			count, err := ArchiveOldLogs(context.Background())
+			if err != nil {
+				common.SysError("failed to archive logs: " + err.Error())
+			} else {
+				common.SysLog(fmt.Sprintf("automatically log archive finished, %d partitions archived", count))
+			}
+			interval := setting.IntervalHours
+			if interval <= 0 {
+				interval = 24
+			}
+			time.Sleep(time.Duration(interval) * time.Hour)
+		}
+	})
+}
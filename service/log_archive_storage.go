@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// LogArchiveStorage 日志归档文件的存储后端
+type LogArchiveStorage interface {
+	Name() string
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+func GetLogArchiveStorage(storageType string) (LogArchiveStorage, error) {
+	setting := operation_setting.GetLogArchiveSetting()
+	switch storageType {
+	case operation_setting.LogArchiveStorageLocal:
+		if setting.LocalDir == "" {
+			return nil, errors.New("log archive local_dir is not configured")
+		}
+		return &localLogArchiveStorage{dir: setting.LocalDir}, nil
+	case operation_setting.LogArchiveStorageS3:
+		if setting.S3Endpoint == "" || setting.S3Bucket == "" {
+			return nil, errors.New("log archive s3_endpoint and s3_bucket are required")
+		}
+		return &s3LogArchiveStorage{
+			endpoint:  strings.TrimSuffix(setting.S3Endpoint, "/"),
+			region:    setting.S3Region,
+			bucket:    setting.S3Bucket,
+			prefix:    strings.Trim(setting.S3Prefix, "/"),
+			pathStyle: setting.S3PathStyle,
+			credentials: aws.Credentials{
+				AccessKeyID:     setting.S3AccessKeyId,
+				SecretAccessKey: setting.S3SecretAccessKey,
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported log archive storage type: %s", storageType)
+	}
+}
+
+type localLogArchiveStorage struct {
+	dir string
+}
+
+func (s *localLogArchiveStorage) Name() string {
+	return operation_setting.LogArchiveStorageLocal
+}
+
+func (s *localLogArchiveStorage) path(key string) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	rel, err := filepath.Rel(s.dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid archive key: %s", key)
+	}
+	return path, nil
+}
+
+func (s *localLogArchiveStorage) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免进程中断留下不完整的归档
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (s *localLogArchiveStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// s3LogArchiveStorage 通过 SigV4 签名直接调用 S3 REST API，兼容 MinIO、R2 等 S3 协议服务
+type s3LogArchiveStorage struct {
+	endpoint    string
+	region      string
+	bucket      string
+	prefix      string
+	pathStyle   bool
+	credentials aws.Credentials
+}
+
+func (s *s3LogArchiveStorage) Name() string {
+	return operation_setting.LogArchiveStorageS3
+}
+
+func (s *s3LogArchiveStorage) objectURL(key string) (string, error) {
+	endpoint, err := url.Parse(s.endpoint)
+	if err != nil {
+		return "", err
+	}
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	if s.pathStyle {
+		endpoint.Path = "/" + s.bucket + "/" + key
+	} else {
+		endpoint.Host = s.bucket + "." + endpoint.Host
+		endpoint.Path = "/" + key
+	}
+	return endpoint.String(), nil
+}
+
+func (s *s3LogArchiveStorage) do(ctx context.Context, method string, key string, body io.Reader, size int64) (*http.Response, error) {
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, objectURL, body)
+	if err != nil {
+		return nil, err
+	}
+	// 归档文件可能很大，不对请求体做签名，依赖 HTTPS 保证传输完整性
+	payloadHash := "UNSIGNED-PAYLOAD"
+	if body == nil {
+		emptyHash := sha256.Sum256(nil)
+		payloadHash = hex.EncodeToString(emptyHash[:])
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if method == http.MethodPut {
+		req.Header.Set("Content-Type", "application/gzip")
+		req.ContentLength = size
+	}
+	if err = v4.NewSigner().SignHTTP(ctx, s.credentials, req, payloadHash, "s3", s.region, time.Now()); err != nil {
+		return nil, err
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer CloseResponseBodyGracefully(resp)
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("s3 %s %s failed: status %d, body: %s", method, key, resp.StatusCode, string(respBody))
+	}
+	return resp, nil
+}
+
+func (s *s3LogArchiveStorage) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	resp, err := s.do(ctx, http.MethodPut, key, body, size)
+	if err != nil {
+		return err
+	}
+	CloseResponseBodyGracefully(resp)
+	return nil
+}
+
+func (s *s3LogArchiveStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
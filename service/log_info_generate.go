@@ -31,6 +31,39 @@ func appendRequestPath(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, other
 	}
 }
 
+// appendPricingTierInfo 记录阶梯计价的档位与基础倍率，用于在日志详情中解释计费过程
+func appendPricingTierInfo(priceData types.PriceData, other map[string]interface{}) {
+	tierInfo := priceData.TierInfo
+	if !tierInfo.Enabled() {
+		return
+	}
+	if len(tierInfo.ContextTiers) > 0 && !priceData.UsePrice {
+		other["tier_context"] = tierInfo.ContextTier
+		other["tier_context_tokens"] = tierInfo.ContextTokens
+		if tierInfo.ContextTier > 0 {
+			other["tier_min_prompt_tokens"] = tierInfo.ContextTiers[tierInfo.ContextTier-1].MinPromptTokens
+		}
+		other["tier_base_model_ratio"] = tierInfo.BaseModelRatio
+		other["tier_base_completion_ratio"] = tierInfo.BaseCompletionRatio
+	}
+	if tierInfo.HasVolumeTiers {
+		other["tier_monthly_tokens"] = tierInfo.MonthlyTokens
+		other["tier_volume_multiplier"] = tierInfo.VolumeMultiplier
+	}
+}
+
+// AppendPricingTimeDiscountInfo 记录命中的时段定价规则，日志中的模型和分组倍率已包含该系数
+func AppendPricingTimeDiscountInfo(discount types.PricingTimeDiscount, other map[string]interface{}) {
+	if discount.ModelRule != "" {
+		other["time_rule_model"] = discount.ModelRule
+		other["time_rule_model_multiplier"] = discount.ModelMultiplier
+	}
+	if discount.GroupRule != "" {
+		other["time_rule_group"] = discount.GroupRule
+		other["time_rule_group_multiplier"] = discount.GroupMultiplier
+	}
+}
+
 func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelRatio, groupRatio, completionRatio float64,
 	cacheTokens int, cacheRatio float64, modelPrice float64, userGroupRatio float64) map[string]interface{} {
 	other := make(map[string]interface{})
@@ -42,6 +75,11 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	other["model_price"] = modelPrice
 	other["user_group_ratio"] = userGroupRatio
 	other["frt"] = float64(relayInfo.FirstResponseTime.UnixMilli() - relayInfo.StartTime.UnixMilli())
+	if relayInfo.PriceData.PricingVersion != 0 {
+		other["pricing_version"] = relayInfo.PriceData.PricingVersion
+	}
+	appendPricingTierInfo(relayInfo.PriceData, other)
+	AppendPricingTimeDiscountInfo(relayInfo.PriceData.TimeDiscount, other)
 	if relayInfo.ReasoningEffort != "" {
 		other["reasoning_effort"] = relayInfo.ReasoningEffort
 	}
@@ -68,6 +106,7 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		adminInfo["local_count_tokens"] = isLocalCountTokens
 	}
 
+	AppendPayloadCaptureInfo(ctx, adminInfo)
 	other["admin_info"] = adminInfo
 	appendRequestPath(ctx, relayInfo, other)
 	return other
@@ -125,6 +164,10 @@ func GenerateMjOtherInfo(relayInfo *relaycommon.RelayInfo, priceData types.PerCa
 	if priceData.GroupRatioInfo.HasSpecialRatio {
 		other["user_group_ratio"] = priceData.GroupRatioInfo.GroupSpecialRatio
 	}
+	if priceData.PricingVersion != 0 {
+		other["pricing_version"] = priceData.PricingVersion
+	}
+	AppendPricingTimeDiscountInfo(priceData.TimeDiscount, other)
 	appendRequestPath(nil, relayInfo, other)
 	return other
 }
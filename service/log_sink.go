@@ -0,0 +1,228 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+func init() {
+	model.RegisterLogSinkFactory(operation_setting.LogSinkTypeHTTP, newHttpLogSink)
+	model.RegisterLogSinkFactory(operation_setting.LogSinkTypeKafkaRest, newKafkaRestLogSink)
+	model.RegisterLogSinkFactory(operation_setting.LogSinkTypeClickHouse, newClickHouseLogSink)
+	model.RegisterLogSinkFactory(operation_setting.LogSinkTypeLoki, newLokiLogSink)
+}
+
+func logTypeName(logType int) string {
+	switch logType {
+	case model.LogTypeConsume:
+		return "consume"
+	case model.LogTypeError:
+		return "error"
+	default:
+		return strconv.Itoa(logType)
+	}
+}
+
+// postLogSinkBody 发送请求并在非 2xx 时返回错误
+func postLogSinkBody(ctx context.Context, config operation_setting.LogSinkConfig, endpoint string, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range config.Headers {
+		req.Header.Set(key, value)
+	}
+	if config.Username != "" {
+		req.SetBasicAuth(config.Username, config.Password)
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer CloseResponseBodyGracefully(resp)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+func validateLogSinkEndpoint(config operation_setting.LogSinkConfig) error {
+	if config.Endpoint == "" {
+		return errors.New("endpoint is required")
+	}
+	if _, err := url.ParseRequestURI(config.Endpoint); err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+	return nil
+}
+
+// httpLogSink 以 JSON 数组批量 POST 到任意 HTTP 接口
+type httpLogSink struct {
+	config operation_setting.LogSinkConfig
+}
+
+func newHttpLogSink(config operation_setting.LogSinkConfig) (model.LogSink, error) {
+	if err := validateLogSinkEndpoint(config); err != nil {
+		return nil, err
+	}
+	return &httpLogSink{config: config}, nil
+}
+
+func (s *httpLogSink) Name() string {
+	return s.config.Name
+}
+
+func (s *httpLogSink) Send(ctx context.Context, logs []*model.Log) error {
+	body, err := common.Marshal(logs)
+	if err != nil {
+		return err
+	}
+	return postLogSinkBody(ctx, s.config, s.config.Endpoint, "application/json", body)
+}
+
+// kafkaRestLogSink 通过 Kafka REST Proxy (v2 API) 写入 topic，以用户 id 作为消息 key
+type kafkaRestLogSink struct {
+	config operation_setting.LogSinkConfig
+}
+
+func newKafkaRestLogSink(config operation_setting.LogSinkConfig) (model.LogSink, error) {
+	if err := validateLogSinkEndpoint(config); err != nil {
+		return nil, err
+	}
+	if config.Topic == "" {
+		return nil, errors.New("topic is required")
+	}
+	return &kafkaRestLogSink{config: config}, nil
+}
+
+func (s *kafkaRestLogSink) Name() string {
+	return s.config.Name
+}
+
+func (s *kafkaRestLogSink) Send(ctx context.Context, logs []*model.Log) error {
+	type record struct {
+		Key   string     `json:"key"`
+		Value *model.Log `json:"value"`
+	}
+	records := make([]record, 0, len(logs))
+	for _, log := range logs {
+		records = append(records, record{Key: strconv.Itoa(log.UserId), Value: log})
+	}
+	body, err := common.Marshal(map[string]any{"records": records})
+	if err != nil {
+		return err
+	}
+	endpoint := strings.TrimSuffix(s.config.Endpoint, "/") + "/topics/" + url.PathEscape(s.config.Topic)
+	return postLogSinkBody(ctx, s.config, endpoint, "application/vnd.kafka.json.v2+json", body)
+}
+
+// clickHouseLogSink 通过 ClickHouse HTTP 接口以 JSONEachRow 格式插入，表的列名需与日志 JSON 字段一致
+type clickHouseLogSink struct {
+	config operation_setting.LogSinkConfig
+}
+
+func newClickHouseLogSink(config operation_setting.LogSinkConfig) (model.LogSink, error) {
+	if err := validateLogSinkEndpoint(config); err != nil {
+		return nil, err
+	}
+	if config.Table == "" {
+		return nil, errors.New("table is required")
+	}
+	return &clickHouseLogSink{config: config}, nil
+}
+
+func (s *clickHouseLogSink) Name() string {
+	return s.config.Name
+}
+
+func (s *clickHouseLogSink) Send(ctx context.Context, logs []*model.Log) error {
+	var buf bytes.Buffer
+	for _, log := range logs {
+		line, err := common.Marshal(log)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	table := "`" + strings.ReplaceAll(s.config.Table, "`", "") + "`"
+	if s.config.Database != "" {
+		table = "`" + strings.ReplaceAll(s.config.Database, "`", "") + "`." + table
+	}
+	query := url.Values{}
+	query.Set("query", "INSERT INTO "+table+" FORMAT JSONEachRow")
+	query.Set("input_format_skip_unknown_fields", "1")
+	endpoint := strings.TrimSuffix(s.config.Endpoint, "/") + "/?" + query.Encode()
+	return postLogSinkBody(ctx, s.config, endpoint, "application/x-ndjson", buf.Bytes())
+}
+
+// lokiLogSink 通过 Loki push API 写入，按日志类型、模型分组划分 stream
+type lokiLogSink struct {
+	config operation_setting.LogSinkConfig
+}
+
+func newLokiLogSink(config operation_setting.LogSinkConfig) (model.LogSink, error) {
+	if err := validateLogSinkEndpoint(config); err != nil {
+		return nil, err
+	}
+	return &lokiLogSink{config: config}, nil
+}
+
+func (s *lokiLogSink) Name() string {
+	return s.config.Name
+}
+
+func (s *lokiLogSink) Send(ctx context.Context, logs []*model.Log) error {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	streams := make(map[string]*stream)
+	for _, log := range logs {
+		line, err := common.Marshal(log)
+		if err != nil {
+			return err
+		}
+		labels := map[string]string{
+			"job":   "new-api",
+			"type":  logTypeName(log.Type),
+			"group": log.Group,
+		}
+		for key, value := range s.config.Labels {
+			labels[key] = value
+		}
+		key := labels["type"] + "|" + labels["group"]
+		st, ok := streams[key]
+		if !ok {
+			st = &stream{Stream: labels}
+			streams[key] = st
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(log.CreatedAt*1e9, 10), string(line)})
+	}
+	payload := make([]*stream, 0, len(streams))
+	for _, st := range streams {
+		payload = append(payload, st)
+	}
+	body, err := common.Marshal(map[string]any{"streams": payload})
+	if err != nil {
+		return err
+	}
+	endpoint := s.config.Endpoint
+	if !strings.Contains(endpoint, "/loki/api/") {
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/loki/api/v1/push"
+	}
+	return postLogSinkBody(ctx, s.config, endpoint, "application/json", body)
+}
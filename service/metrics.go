@@ -0,0 +1,214 @@
+package service
+
+import (
+	"database/sql"
+	"strconv"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/metrics"
+	"github.com/QuantumNous/new-api/model"
+
+	"gorm.io/gorm"
+)
+
+var initMetricsOnce sync.Once
+
+// InitMetrics 注册抓取时实时计算的指标，请求相关的指标在 common/metrics 中定义
+func InitMetrics() {
+	initMetricsOnce.Do(func() {
+		metrics.NewGaugeFunc("new_api_channel_status",
+			"Channel status (1 enabled, 2 manually disabled, 3 auto disabled).",
+			[]string{"channel", "name", "type"}, collectChannelStatus)
+		metrics.NewGaugeFunc("new_api_pending_tasks",
+			"Async tasks that have not reached a final status.",
+			[]string{"platform", "status"}, collectPendingTasks)
+		metrics.NewGaugeFunc("new_api_db_connections",
+			"Database connection pool connections by state.",
+			[]string{"db", "state"}, collectDBConnections)
+		metrics.NewCounterFunc("new_api_db_wait_total",
+			"Total number of connections waited for.",
+			[]string{"db"}, func() []metrics.Sample {
+				return collectDBStats(func(stats sql.DBStats) float64 { return float64(stats.WaitCount) })
+			})
+		metrics.NewCounterFunc("new_api_db_wait_seconds_total",
+			"Total time blocked waiting for a new connection.",
+			[]string{"db"}, func() []metrics.Sample {
+				return collectDBStats(func(stats sql.DBStats) float64 { return stats.WaitDuration.Seconds() })
+			})
+		metrics.NewGaugeFunc("new_api_redis_pool_connections",
+			"Redis connection pool connections by state.",
+			[]string{"state"}, collectRedisPool)
+		metrics.NewCounterFunc("new_api_redis_pool_events_total",
+			"Redis connection pool hits, misses and timeouts.",
+			[]string{"event"}, collectRedisPoolEvents)
+		metrics.NewGaugeFunc("new_api_log_sink_queued",
+			"Logs waiting in the external log sink queue.",
+			nil, func() []metrics.Sample {
+				return []metrics.Sample{{Value: float64(model.GetLogSinkStats().Queued)}}
+			})
+		metrics.NewGaugeFunc("new_api_batch_update_pending_amount",
+			"Sum of batched deltas not yet flushed to the database.",
+			[]string{"type"}, func() []metrics.Sample {
+				return collectBatchUpdatePending(func(pending model.BatchUpdatePending) float64 { return float64(pending.Amount) })
+			})
+		metrics.NewGaugeFunc("new_api_batch_update_pending_keys",
+			"Rows with batched deltas not yet flushed to the database.",
+			[]string{"type"}, func() []metrics.Sample {
+				return collectBatchUpdatePending(func(pending model.BatchUpdatePending) float64 { return float64(pending.Keys) })
+			})
+		metrics.NewGaugeFunc("new_api_batch_update_flush_lag_seconds",
+			"Age of the oldest batched delta not yet flushed.",
+			nil, func() []metrics.Sample {
+				return []metrics.Sample{{Value: model.GetBatchUpdateStats().FlushLag.Seconds()}}
+			})
+		metrics.NewGaugeFunc("new_api_batch_update_last_flush_duration_seconds",
+			"Duration of the last batch update flush.",
+			nil, func() []metrics.Sample {
+				return []metrics.Sample{{Value: model.GetBatchUpdateStats().LastFlushCost.Seconds()}}
+			})
+		metrics.NewGaugeFunc("new_api_batch_update_last_flush_timestamp_seconds",
+			"Unix time of the last batch update flush.",
+			nil, func() []metrics.Sample {
+				return []metrics.Sample{{Value: float64(model.GetBatchUpdateStats().LastFlushAt)}}
+			})
+		metrics.NewGaugeFunc("new_api_batch_update_wal_segments",
+			"Sealed write-ahead log segments waiting to be applied.",
+			nil, func() []metrics.Sample {
+				return []metrics.Sample{{Value: float64(model.GetBatchUpdateStats().WALSegments)}}
+			})
+		metrics.NewGaugeFunc("new_api_batch_update_wal_degraded",
+			"Whether the last write-ahead log append or rotation failed (1) or not (0).",
+			nil, func() []metrics.Sample {
+				value := 0.0
+				if model.GetBatchUpdateStats().WALDegraded {
+					value = 1
+				}
+				return []metrics.Sample{{Value: value}}
+			})
+		metrics.NewGaugeFunc("new_api_batch_update_retry_segments",
+			"Write-ahead log segments whose flush failed and will be retried.",
+			nil, func() []metrics.Sample {
+				return []metrics.Sample{{Value: float64(model.GetBatchUpdateStats().RetrySegments)}}
+			})
+		metrics.NewCounterFunc("new_api_batch_update_failures_total",
+			"Batch update flush and write-ahead log failures.",
+			[]string{"stage"}, func() []metrics.Sample {
+				stats := model.GetBatchUpdateStats()
+				return []metrics.Sample{
+					{LabelValues: []string{"flush"}, Value: float64(stats.FlushFailures)},
+					{LabelValues: []string{"wal"}, Value: float64(stats.WALFailures)},
+				}
+			})
+	})
+}
+
+func collectBatchUpdatePending(value func(pending model.BatchUpdatePending) float64) []metrics.Sample {
+	pendings := model.GetBatchUpdateStats().Pending
+	samples := make([]metrics.Sample, 0, len(pendings))
+	for _, pending := range pendings {
+		samples = append(samples, metrics.Sample{
+			LabelValues: []string{pending.Type},
+			Value:       value(pending),
+		})
+	}
+	return samples
+}
+
+func collectChannelStatus() []metrics.Sample {
+	channels, err := model.GetChannelStatusList()
+	if err != nil {
+		common.SysError("failed to collect channel status metrics: " + err.Error())
+		return nil
+	}
+	samples := make([]metrics.Sample, 0, len(channels))
+	for _, channel := range channels {
+		samples = append(samples, metrics.Sample{
+			LabelValues: []string{strconv.Itoa(channel.Id), channel.Name, strconv.Itoa(channel.Type)},
+			Value:       float64(channel.Status),
+		})
+	}
+	return samples
+}
+
+func collectPendingTasks() []metrics.Sample {
+	counts, err := model.CountPendingTasks()
+	if err != nil {
+		common.SysError("failed to collect pending task metrics: " + err.Error())
+		return nil
+	}
+	samples := make([]metrics.Sample, 0, len(counts))
+	for _, count := range counts {
+		samples = append(samples, metrics.Sample{
+			LabelValues: []string{count.Platform, count.Status},
+			Value:       float64(count.Count),
+		})
+	}
+	return samples
+}
+
+// metricsDatabases 日志库与主库相同时只输出一份
+func metricsDatabases() map[string]*gorm.DB {
+	dbs := map[string]*gorm.DB{}
+	if model.DB != nil {
+		dbs["main"] = model.DB
+	}
+	if model.LOG_DB != nil && model.LOG_DB != model.DB {
+		dbs["log"] = model.LOG_DB
+	}
+	return dbs
+}
+
+func collectDBStats(value func(stats sql.DBStats) float64) []metrics.Sample {
+	var samples []metrics.Sample
+	for name, db := range metricsDatabases() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			continue
+		}
+		samples = append(samples, metrics.Sample{LabelValues: []string{name}, Value: value(sqlDB.Stats())})
+	}
+	return samples
+}
+
+func collectDBConnections() []metrics.Sample {
+	var samples []metrics.Sample
+	for name, db := range metricsDatabases() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			continue
+		}
+		stats := sqlDB.Stats()
+		samples = append(samples,
+			metrics.Sample{LabelValues: []string{name, "open"}, Value: float64(stats.OpenConnections)},
+			metrics.Sample{LabelValues: []string{name, "in_use"}, Value: float64(stats.InUse)},
+			metrics.Sample{LabelValues: []string{name, "idle"}, Value: float64(stats.Idle)},
+			metrics.Sample{LabelValues: []string{name, "max_open"}, Value: float64(stats.MaxOpenConnections)},
+		)
+	}
+	return samples
+}
+
+func collectRedisPool() []metrics.Sample {
+	if !common.RedisEnabled || common.RDB == nil {
+		return nil
+	}
+	stats := common.RDB.PoolStats()
+	return []metrics.Sample{
+		{LabelValues: []string{"total"}, Value: float64(stats.TotalConns)},
+		{LabelValues: []string{"idle"}, Value: float64(stats.IdleConns)},
+		{LabelValues: []string{"stale"}, Value: float64(stats.StaleConns)},
+	}
+}
+
+func collectRedisPoolEvents() []metrics.Sample {
+	if !common.RedisEnabled || common.RDB == nil {
+		return nil
+	}
+	stats := common.RDB.PoolStats()
+	return []metrics.Sample{
+		{LabelValues: []string{"hit"}, Value: float64(stats.Hits)},
+		{LabelValues: []string{"miss"}, Value: float64(stats.Misses)},
+		{LabelValues: []string{"timeout"}, Value: float64(stats.Timeouts)},
+	}
+}
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	ModelVerifyFamilyOpenAI = "openai"
+	ModelVerifyFamilyClaude = "claude"
+	ModelVerifyFamilyGemini = "gemini"
+
+	ModelVerifyProbeCutoff    = "cutoff"
+	ModelVerifyProbeTokenizer = "tokenizer"
+	ModelVerifyProbeToolCall  = "tool_call"
+)
+
+// ModelFingerprint 模型的已知特征，用于判断渠道返回的是否为所声称的模型
+type ModelFingerprint struct {
+	Family            string
+	KnowledgeCutoff   string // YYYY-MM，模型自述的知识截止时间
+	TokenizerModel    string // 本地分词对照所用的模型名，为空则跳过分词探针
+	SystemFingerprint bool   // 响应中是否应带有 system_fingerprint
+	Logprobs          bool   // 是否支持 logprobs
+}
+
+var modelFingerprints = map[string]ModelFingerprint{
+	"gpt-3.5-turbo":     {Family: ModelVerifyFamilyOpenAI, KnowledgeCutoff: "2021-09", TokenizerModel: "gpt-3.5-turbo", SystemFingerprint: true, Logprobs: true},
+	"gpt-4":             {Family: ModelVerifyFamilyOpenAI, KnowledgeCutoff: "2021-09", TokenizerModel: "gpt-4", Logprobs: true},
+	"gpt-4-turbo":       {Family: ModelVerifyFamilyOpenAI, KnowledgeCutoff: "2023-12", TokenizerModel: "gpt-4", SystemFingerprint: true, Logprobs: true},
+	"gpt-4o":            {Family: ModelVerifyFamilyOpenAI, KnowledgeCutoff: "2023-10", TokenizerModel: "gpt-4o", SystemFingerprint: true, Logprobs: true},
+	"gpt-4o-mini":       {Family: ModelVerifyFamilyOpenAI, KnowledgeCutoff: "2023-10", TokenizerModel: "gpt-4o", SystemFingerprint: true, Logprobs: true},
+	"gpt-4.1":           {Family: ModelVerifyFamilyOpenAI, KnowledgeCutoff: "2024-06", TokenizerModel: "gpt-4o", SystemFingerprint: true, Logprobs: true},
+	"gpt-4.1-mini":      {Family: ModelVerifyFamilyOpenAI, KnowledgeCutoff: "2024-06", TokenizerModel: "gpt-4o", SystemFingerprint: true, Logprobs: true},
+	"gpt-4.1-nano":      {Family: ModelVerifyFamilyOpenAI, KnowledgeCutoff: "2024-06", TokenizerModel: "gpt-4o", SystemFingerprint: true, Logprobs: true},
+	"claude-3-haiku":    {Family: ModelVerifyFamilyClaude, KnowledgeCutoff: "2023-08"},
+	"claude-3-opus":     {Family: ModelVerifyFamilyClaude, KnowledgeCutoff: "2023-08"},
+	"claude-3-5-haiku":  {Family: ModelVerifyFamilyClaude, KnowledgeCutoff: "2024-07"},
+	"claude-3-5-sonnet": {Family: ModelVerifyFamilyClaude, KnowledgeCutoff: "2024-04"},
+	"claude-3-7-sonnet": {Family: ModelVerifyFamilyClaude, KnowledgeCutoff: "2024-10"},
+	"claude-sonnet-4":   {Family: ModelVerifyFamilyClaude, KnowledgeCutoff: "2025-03"},
+	"claude-sonnet-4-5": {Family: ModelVerifyFamilyClaude, KnowledgeCutoff: "2025-01"},
+	"claude-opus-4":     {Family: ModelVerifyFamilyClaude, KnowledgeCutoff: "2025-03"},
+	"claude-haiku-4-5":  {Family: ModelVerifyFamilyClaude, KnowledgeCutoff: "2025-02"},
+	"gemini-1.5-flash":  {Family: ModelVerifyFamilyGemini, KnowledgeCutoff: "2023-11"},
+	"gemini-1.5-pro":    {Family: ModelVerifyFamilyGemini, KnowledgeCutoff: "2023-11"},
+	"gemini-2.0-flash":  {Family: ModelVerifyFamilyGemini, KnowledgeCutoff: "2024-08"},
+	"gemini-2.5-flash":  {Family: ModelVerifyFamilyGemini, KnowledgeCutoff: "2025-01"},
+	"gemini-2.5-pro":    {Family: ModelVerifyFamilyGemini, KnowledgeCutoff: "2025-01"},
+}
+
+// 各项检查的权重，总分为适用检查的加权平均
+var modelVerifyWeights = map[string]float64{
+	"response_model":     1,
+	"system_fingerprint": 0.5,
+	"knowledge_cutoff":   1,
+	"tokenizer":          1.5,
+	"logprobs":           0.5,
+	"tool_call":          1,
+}
+
+const (
+	modelVerifyCutoffPrompt = "What is your knowledge cutoff date? Answer with only the year and month in YYYY-MM format."
+	// 混合多语言、emoji 与代码的文本，不同分词器的切分结果差异明显
+	modelVerifyTokenizerSample = "日本語のテキスト🙂 naïve café façade; Привет, мир! 1234567890 func(x *int) { return x**2 } ñandú Ünïcödé"
+	modelVerifyToolPrompt      = "What's the weather like in Paris right now? Use the get_weather tool."
+)
+
+// getWeatherTools 工具调用探针所用的函数定义
+var getWeatherTools = []map[string]any{
+	{
+		"type": "function",
+		"function": map[string]any{
+			"name":        "get_weather",
+			"description": "Get the current weather of a city",
+			"parameters": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"city": map[string]any{"type": "string", "description": "City name"},
+				},
+				"required": []string{"city"},
+			},
+		},
+	},
+}
+
+type ModelVerifyProbe struct {
+	Name string
+	Body []byte
+}
+
+// ModelVerifyResponse 探针请求的结果，Body 为 OpenAI 格式的响应
+type ModelVerifyResponse struct {
+	Body         string
+	PromptTokens int
+	Err          error
+}
+
+type ModelVerifyCheck struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+	Score  float64 `json:"score"`
+	Detail string  `json:"detail"`
+}
+
+type ModelVerifyReport struct {
+	Score             float64            `json:"score"`
+	Checks            []ModelVerifyCheck `json:"checks"`
+	ResponseModel     string             `json:"response_model"`
+	SystemFingerprint string             `json:"system_fingerprint"`
+	AllFailed         bool               `json:"all_failed"`
+}
+
+// GetModelFingerprint 按最长前缀匹配模型的指纹特征，未知模型返回 nil
+func GetModelFingerprint(modelName string) (string, *ModelFingerprint) {
+	name := strings.ToLower(strings.TrimSpace(modelName))
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	matched := ""
+	for key := range modelFingerprints {
+		if strings.HasPrefix(name, key) && len(key) > len(matched) {
+			matched = key
+		}
+	}
+	if matched == "" {
+		return "", nil
+	}
+	fingerprint := modelFingerprints[matched]
+	return matched, &fingerprint
+}
+
+func buildModelVerifyBody(modelName string, content string, maxTokens int, extra map[string]any) []byte {
+	body := map[string]any{
+		"model": modelName,
+		"messages": []map[string]any{
+			{"role": "user", "content": content},
+		},
+		"temperature": 0,
+		"max_tokens":  maxTokens,
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+	data, _ := common.Marshal(body)
+	return data
+}
+
+// BuildModelVerifyProbes 根据模型特征生成需要发送的探针请求
+func BuildModelVerifyProbes(modelName string, fingerprint *ModelFingerprint) []ModelVerifyProbe {
+	cutoffExtra := map[string]any{}
+	if fingerprint.Logprobs {
+		cutoffExtra["logprobs"] = true
+		cutoffExtra["top_logprobs"] = 1
+	}
+	probes := []ModelVerifyProbe{
+		{Name: ModelVerifyProbeCutoff, Body: buildModelVerifyBody(modelName, modelVerifyCutoffPrompt, 16, cutoffExtra)},
+	}
+	if fingerprint.TokenizerModel != "" {
+		probes = append(probes, ModelVerifyProbe{
+			Name: ModelVerifyProbeTokenizer,
+			Body: buildModelVerifyBody(modelName, modelVerifyCutoffPrompt+"\n"+modelVerifyTokenizerSample, 16, nil),
+		})
+	}
+	probes = append(probes, ModelVerifyProbe{
+		Name: ModelVerifyProbeToolCall,
+		Body: buildModelVerifyBody(modelName, modelVerifyToolPrompt, 128, map[string]any{
+			"tools": getWeatherTools,
+		}),
+	})
+	return probes
+}
+
+// EvaluateModelVerification 根据探针结果逐项打分并计算加权总分
+func EvaluateModelVerification(modelKey string, fingerprint *ModelFingerprint, responses map[string]*ModelVerifyResponse) ModelVerifyReport {
+	report := ModelVerifyReport{AllFailed: true}
+	for _, resp := range responses {
+		if resp != nil && resp.Err == nil {
+			report.AllFailed = false
+		}
+	}
+	addCheck := func(name string, score float64, detail string) {
+		report.Checks = append(report.Checks, ModelVerifyCheck{
+			Name:   name,
+			Weight: modelVerifyWeights[name],
+			Score:  score,
+			Detail: detail,
+		})
+	}
+
+	cutoff := responses[ModelVerifyProbeCutoff]
+	if cutoff == nil || cutoff.Err != nil {
+		detail := probeErrorDetail(cutoff)
+		addCheck("response_model", 0, detail)
+		if fingerprint.SystemFingerprint {
+			addCheck("system_fingerprint", 0, detail)
+		}
+		addCheck("knowledge_cutoff", 0, detail)
+		if fingerprint.Logprobs {
+			addCheck("logprobs", 0, detail)
+		}
+	} else {
+		body := gjson.Parse(cutoff.Body)
+		report.ResponseModel = body.Get("model").String()
+		report.SystemFingerprint = body.Get("system_fingerprint").String()
+
+		responseKey, _ := GetModelFingerprint(report.ResponseModel)
+		if responseKey == modelKey {
+			addCheck("response_model", 1, report.ResponseModel)
+		} else {
+			addCheck("response_model", 0, fmt.Sprintf("响应模型为 %q", report.ResponseModel))
+		}
+		if fingerprint.SystemFingerprint {
+			if strings.HasPrefix(report.SystemFingerprint, "fp_") {
+				addCheck("system_fingerprint", 1, report.SystemFingerprint)
+			} else {
+				addCheck("system_fingerprint", 0, "缺少 system_fingerprint")
+			}
+		}
+		answer := body.Get("choices.0.message.content").String()
+		score, detail := scoreKnowledgeCutoff(answer, fingerprint.KnowledgeCutoff)
+		addCheck("knowledge_cutoff", score, detail)
+		if fingerprint.Logprobs {
+			if body.Get("choices.0.logprobs.content.#").Int() > 0 {
+				addCheck("logprobs", 1, "")
+			} else {
+				addCheck("logprobs", 0, "响应中缺少 logprobs")
+			}
+		}
+	}
+
+	if fingerprint.TokenizerModel != "" {
+		tokenizer := responses[ModelVerifyProbeTokenizer]
+		switch {
+		case cutoff == nil || cutoff.Err != nil:
+			addCheck("tokenizer", 0, probeErrorDetail(cutoff))
+		case tokenizer == nil || tokenizer.Err != nil:
+			addCheck("tokenizer", 0, probeErrorDetail(tokenizer))
+		case cutoff.PromptTokens <= 0 || tokenizer.PromptTokens <= 0:
+			addCheck("tokenizer", 0, "响应中缺少 usage")
+		default:
+			expected := CountTextToken(modelVerifyCutoffPrompt+"\n"+modelVerifyTokenizerSample, fingerprint.TokenizerModel) -
+				CountTextToken(modelVerifyCutoffPrompt, fingerprint.TokenizerModel)
+			actual := tokenizer.PromptTokens - cutoff.PromptTokens
+			detail := fmt.Sprintf("期望增量 %d，实际增量 %d", expected, actual)
+			switch delta := int(math.Abs(float64(expected - actual))); {
+			case delta == 0:
+				addCheck("tokenizer", 1, detail)
+			case delta <= 2:
+				addCheck("tokenizer", 0.5, detail)
+			default:
+				addCheck("tokenizer", 0, detail)
+			}
+		}
+	}
+
+	toolCall := responses[ModelVerifyProbeToolCall]
+	if toolCall == nil || toolCall.Err != nil {
+		addCheck("tool_call", 0, probeErrorDetail(toolCall))
+	} else {
+		call := gjson.Get(toolCall.Body, "choices.0.message.tool_calls.0.function")
+		arguments := call.Get("arguments").String()
+		switch {
+		case call.Get("name").String() != "get_weather":
+			addCheck("tool_call", 0, "未调用工具")
+		case gjson.Valid(arguments) && strings.Contains(strings.ToLower(gjson.Get(arguments, "city").String()), "paris"):
+			addCheck("tool_call", 1, arguments)
+		default:
+			addCheck("tool_call", 0.5, fmt.Sprintf("工具参数异常：%s", arguments))
+		}
+	}
+
+	var totalWeight, totalScore float64
+	for _, check := range report.Checks {
+		totalWeight += check.Weight
+		totalScore += check.Weight * check.Score
+	}
+	if totalWeight > 0 {
+		report.Score = math.Round(totalScore/totalWeight*1000) / 1000
+	}
+	return report
+}
+
+func probeErrorDetail(resp *ModelVerifyResponse) string {
+	if resp == nil {
+		return "探针未执行"
+	}
+	if resp.Err != nil {
+		return fmt.Sprintf("请求失败：%s", resp.Err.Error())
+	}
+	return ""
+}
+
+var (
+	cutoffNumericRegex = regexp.MustCompile(`(\d{4})\s*[-/.年]\s*(\d{1,2})`)
+	cutoffMonthRegex   = regexp.MustCompile(`(?i)(january|february|march|april|may|june|july|august|september|october|november|december)\s*,?\s*(\d{4})`)
+	cutoffMonthNames   = []string{"january", "february", "march", "april", "may", "june", "july", "august", "september", "october", "november", "december"}
+)
+
+func parseCutoffMonths(text string) (int, bool) {
+	if m := cutoffNumericRegex.FindStringSubmatch(text); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		if month >= 1 && month <= 12 {
+			return year*12 + month - 1, true
+		}
+	}
+	if m := cutoffMonthRegex.FindStringSubmatch(text); m != nil {
+		year, _ := strconv.Atoi(m[2])
+		for i, name := range cutoffMonthNames {
+			if strings.EqualFold(name, m[1]) {
+				return year*12 + i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// scoreKnowledgeCutoff 自述截止时间与已知值越接近得分越高
+func scoreKnowledgeCutoff(answer string, expected string) (float64, string) {
+	expectedMonths, _ := parseCutoffMonths(expected)
+	actualMonths, ok := parseCutoffMonths(answer)
+	if !ok {
+		return 0, fmt.Sprintf("无法识别回答：%q", answer)
+	}
+	detail := fmt.Sprintf("期望 %s，回答 %q", expected, answer)
+	switch diff := int(math.Abs(float64(expectedMonths - actualMonths))); {
+	case diff == 0:
+		return 1, detail
+	case diff <= 3:
+		return 0.7, detail
+	case diff <= 6:
+		return 0.4, detail
+	default:
+		return 0, detail
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+)
+
+// GetOrganizationAvailableQuota 团队成员当前可用的团队额度，取团队额度池和成员剩余消费上限中较小的一个
+func GetOrganizationAvailableQuota(orgId int, userId int) (int, error) {
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		return 0, err
+	}
+	if org.Status != model.OrganizationStatusEnabled {
+		return 0, errors.New("团队已被禁用")
+	}
+	member, err := model.GetOrganizationMember(orgId, userId)
+	if err != nil {
+		return 0, err
+	}
+	if !member.CanUseTokens() {
+		return 0, errors.New("当前团队角色不能使用团队令牌")
+	}
+	available := org.Quota
+	if member.SpendingCap > 0 && member.SpendingCap-member.UsedQuota < available {
+		available = member.SpendingCap - member.UsedQuota
+	}
+	return available, nil
+}
+
+// GetBillingQuota 本次请求付费方的可用额度，团队令牌使用团队额度，否则使用用户额度加上后付费授信额度。
+// 用户因账单逾期被暂停时返回 ErrUserCreditSuspended
+func GetBillingQuota(relayInfo *relaycommon.RelayInfo) (int, error) {
+	if relayInfo.OrganizationId != 0 {
+		return GetOrganizationAvailableQuota(relayInfo.OrganizationId, relayInfo.UserId)
+	}
+	creditLimit, suspended, err := GetUserCredit(relayInfo.UserId)
+	if err != nil {
+		return 0, err
+	}
+	if suspended {
+		return 0, ErrUserCreditSuspended
+	}
+	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	if err != nil {
+		return 0, err
+	}
+	return userQuota + creditLimit, nil
+}
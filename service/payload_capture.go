@@ -0,0 +1,399 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+const payloadCaptureKeyPurpose = "payload_capture"
+
+// cappedBuffer 超过上限的内容直接丢弃并标记截断，不影响原始数据流
+type cappedBuffer struct {
+	mutex     sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	remaining := b.limit - b.buf.Len()
+	if len(p) > remaining {
+		b.truncated = true
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	b.buf.Write(p)
+	return len(p), nil
+}
+
+func (b *cappedBuffer) snapshot() (string, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String(), b.truncated
+}
+
+// PayloadCapture 单个请求的内容抓取状态
+type PayloadCapture struct {
+	limit           int
+	clientRequest   *cappedBuffer
+	upstreamRequest *cappedBuffer
+	response        *cappedBuffer
+	mutex           sync.Mutex
+}
+
+type PayloadCaptureData struct {
+	ClientRequest     string `json:"client_request"`
+	UpstreamRequest   string `json:"upstream_request"`
+	Response          string `json:"response"`
+	AssembledResponse string `json:"assembled_response,omitempty"`
+}
+
+type PayloadCaptureDetail struct {
+	*model.PayloadCapture
+	Data PayloadCaptureData `json:"data"`
+}
+
+// payloadCaptureWriter 在写给客户端的同时保留一份响应内容
+type payloadCaptureWriter struct {
+	gin.ResponseWriter
+	capture *PayloadCapture
+}
+
+func (w *payloadCaptureWriter) Write(data []byte) (int, error) {
+	_, _ = w.capture.response.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *payloadCaptureWriter) WriteString(s string) (int, error) {
+	_, _ = w.capture.response.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func shouldCapturePayload(c *gin.Context) bool {
+	setting := operation_setting.GetPayloadCaptureSetting()
+	if !setting.Enabled || c.IsWebsocket() {
+		return false
+	}
+	if slices.Contains(setting.UserIds, c.GetInt("id")) ||
+		slices.Contains(setting.TokenIds, c.GetInt("token_id")) ||
+		slices.Contains(setting.ChannelIds, c.GetInt("channel_id")) {
+		return true
+	}
+	return setting.SampleRate > 0 && rand.Float64() < setting.SampleRate
+}
+
+// StartPayloadCapture 判断当前请求是否需要抓取，需要时记录客户端请求体并接管响应写入
+func StartPayloadCapture(c *gin.Context) *PayloadCapture {
+	if !shouldCapturePayload(c) {
+		return nil
+	}
+	limit := operation_setting.GetPayloadCaptureSetting().MaxBodyBytes
+	if limit <= 0 {
+		limit = 256 * 1024
+	}
+	capture := &PayloadCapture{
+		limit:           limit,
+		clientRequest:   &cappedBuffer{limit: limit},
+		upstreamRequest: &cappedBuffer{limit: limit},
+		response:        &cappedBuffer{limit: limit},
+	}
+	if body, err := common.GetRequestBody(c); err == nil {
+		_, _ = capture.clientRequest.Write(body)
+	}
+	c.Writer = &payloadCaptureWriter{ResponseWriter: c.Writer, capture: capture}
+	common.SetContextKey(c, constant.ContextKeyPayloadCapture, capture)
+	return capture
+}
+
+func getPayloadCapture(c *gin.Context) *PayloadCapture {
+	value, ok := common.GetContextKey(c, constant.ContextKeyPayloadCapture)
+	if !ok {
+		return nil
+	}
+	capture, _ := value.(*PayloadCapture)
+	return capture
+}
+
+// CapturePayloadUpstreamRequest 记录发往上游的请求体，重试时以最后一次为准。
+// 内存中的请求体直接读取后重新包装，保证 Content-Length 不变
+func CapturePayloadUpstreamRequest(c *gin.Context, body io.Reader) io.Reader {
+	capture := getPayloadCapture(c)
+	if capture == nil || body == nil {
+		return body
+	}
+	buffer := &cappedBuffer{limit: capture.limit}
+	capture.mutex.Lock()
+	capture.upstreamRequest = buffer
+	capture.mutex.Unlock()
+	switch body.(type) {
+	case *bytes.Buffer, *bytes.Reader, *strings.Reader:
+		data, _ := io.ReadAll(body)
+		_, _ = buffer.Write(data)
+		return bytes.NewReader(data)
+	default:
+		return io.TeeReader(body, buffer)
+	}
+}
+
+// AppendPayloadCaptureInfo 在日志的管理员信息中关联抓取记录，便于从日志详情查看
+func AppendPayloadCaptureInfo(c *gin.Context, adminInfo map[string]interface{}) {
+	if getPayloadCapture(c) != nil {
+		adminInfo["payload_capture_request_id"] = c.GetString(common.RequestIdKey)
+	}
+}
+
+// FinishPayloadCapture 请求结束后异步加密保存抓取内容
+func FinishPayloadCapture(c *gin.Context, capture *PayloadCapture, relayInfo *relaycommon.RelayInfo, statusCode int) {
+	if capture == nil {
+		return
+	}
+	capture.mutex.Lock()
+	upstreamRequest := capture.upstreamRequest
+	capture.mutex.Unlock()
+
+	var data PayloadCaptureData
+	var truncated [3]bool
+	data.ClientRequest, truncated[0] = capture.clientRequest.snapshot()
+	data.UpstreamRequest, truncated[1] = upstreamRequest.snapshot()
+	data.Response, truncated[2] = capture.response.snapshot()
+
+	record := &model.PayloadCapture{
+		RequestId:   c.GetString(common.RequestIdKey),
+		UserId:      c.GetInt("id"),
+		TokenId:     c.GetInt("token_id"),
+		ChannelId:   c.GetInt("channel_id"),
+		ModelName:   c.GetString("original_model"),
+		RequestPath: c.Request.URL.Path,
+		StatusCode:  statusCode,
+		Truncated:   truncated[0] || truncated[1] || truncated[2],
+	}
+	if relayInfo != nil {
+		record.IsStream = relayInfo.IsStream
+		record.UseTimeMs = time.Since(relayInfo.StartTime).Milliseconds()
+		if relayInfo.IsStream && relayInfo.HasSendResponse() {
+			record.FirstTokenMs = relayInfo.FirstResponseTime.Sub(relayInfo.StartTime).Milliseconds()
+		}
+	}
+	gopool.Go(func() {
+		if record.IsStream {
+			data.AssembledResponse = assembleStreamResponse(data.Response)
+		}
+		if err := savePayloadCapture(record, &data); err != nil {
+			common.SysError(fmt.Sprintf("failed to save payload capture %s: %s", record.RequestId, err.Error()))
+		}
+	})
+}
+
+func savePayloadCapture(record *model.PayloadCapture, data *PayloadCaptureData) error {
+	raw, err := common.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err = gz.Write(raw); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	encrypted, err := common.EncryptAESGCM(common.DeriveKey(payloadCaptureKeyPurpose), compressed.Bytes())
+	if err != nil {
+		return err
+	}
+	record.Size = len(raw)
+	record.Payload = base64.StdEncoding.EncodeToString(encrypted)
+	return record.Insert()
+}
+
+func GetPayloadCaptureDetail(requestId string) (*PayloadCaptureDetail, error) {
+	record, err := model.GetPayloadCaptureByRequestId(requestId)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := base64.StdEncoding.DecodeString(record.Payload)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := common.DecryptAESGCM(common.DeriveKey(payloadCaptureKeyPurpose), encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt payload failed: %w", err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, err
+	}
+	detail := &PayloadCaptureDetail{PayloadCapture: record}
+	if err = common.Unmarshal(raw, &detail.Data); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// assembleStreamResponse 从 SSE 响应中拼接出完整的输出文本
+func assembleStreamResponse(response string) string {
+	var builder strings.Builder
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		builder.WriteString(streamEventText(strings.TrimSpace(strings.TrimPrefix(line, "data:"))))
+	}
+	return builder.String()
+}
+
+// streamEventText 取出单个流式事件中的输出文本，兼容 OpenAI、Responses、Claude、Gemini 格式
+func streamEventText(payload string) string {
+	if payload == "" || payload == "[DONE]" || !gjson.Valid(payload) {
+		return ""
+	}
+	var builder strings.Builder
+	event := gjson.Parse(payload)
+	switch {
+	case event.Get("choices").Exists():
+		builder.WriteString(event.Get("choices.0.delta.reasoning_content").String())
+		builder.WriteString(event.Get("choices.0.delta.content").String())
+		builder.WriteString(event.Get("choices.0.text").String())
+	case event.Get("type").String() == "response.output_text.delta":
+		builder.WriteString(event.Get("delta").String())
+	case event.Get("type").String() == "content_block_delta":
+		builder.WriteString(event.Get("delta.thinking").String())
+		builder.WriteString(event.Get("delta.text").String())
+		builder.WriteString(event.Get("delta.partial_json").String())
+	case event.Get("candidates").Exists():
+		for _, part := range event.Get("candidates.0.content.parts").Array() {
+			builder.WriteString(part.Get("text").String())
+		}
+	}
+	return builder.String()
+}
+
+// ExtractResponseOutput 从流式或非流式响应中取出输出文本
+func ExtractResponseOutput(response string, isStream bool) string {
+	if isStream {
+		return assembleStreamResponse(response)
+	}
+	if !gjson.Valid(response) {
+		return response
+	}
+	result := gjson.Parse(response)
+	var builder strings.Builder
+	switch {
+	case result.Get("choices").Exists():
+		builder.WriteString(result.Get("choices.0.message.reasoning_content").String())
+		builder.WriteString(result.Get("choices.0.message.content").String())
+		builder.WriteString(result.Get("choices.0.text").String())
+	case result.Get("output").IsArray():
+		for _, item := range result.Get("output").Array() {
+			for _, content := range item.Get("content").Array() {
+				builder.WriteString(content.Get("text").String())
+			}
+		}
+	case result.Get("content").IsArray():
+		for _, content := range result.Get("content").Array() {
+			builder.WriteString(content.Get("thinking").String())
+			builder.WriteString(content.Get("text").String())
+		}
+	case result.Get("candidates").Exists():
+		for _, part := range result.Get("candidates.0.content.parts").Array() {
+			builder.WriteString(part.Get("text").String())
+		}
+	}
+	return builder.String()
+}
+
+// ExtractResponseUsage 从响应中读取上游返回的 token 用量，流式响应以最后一次出现的 usage 为准
+func ExtractResponseUsage(response string) (promptTokens int, completionTokens int) {
+	parse := func(result gjson.Result) {
+		usage := result.Get("usage")
+		if !usage.Exists() {
+			usage = result.Get("response.usage")
+		}
+		if !usage.Exists() {
+			usage = result.Get("message.usage")
+		}
+		if usage.Exists() {
+			if v := usage.Get("prompt_tokens"); v.Exists() {
+				promptTokens = int(v.Int())
+			} else if v := usage.Get("input_tokens"); v.Exists() {
+				promptTokens = int(v.Int())
+			}
+			if v := usage.Get("completion_tokens"); v.Exists() {
+				completionTokens = int(v.Int())
+			} else if v := usage.Get("output_tokens"); v.Exists() {
+				completionTokens = int(v.Int())
+			}
+			return
+		}
+		if metadata := result.Get("usageMetadata"); metadata.Exists() {
+			promptTokens = int(metadata.Get("promptTokenCount").Int())
+			completionTokens = int(metadata.Get("candidatesTokenCount").Int())
+		}
+	}
+	if gjson.Valid(response) {
+		parse(gjson.Parse(response))
+		return
+	}
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if gjson.Valid(payload) {
+			parse(gjson.Parse(payload))
+		}
+	}
+	return
+}
+
+// AutomaticallyCleanPayloadCaptures 按保留天数与最大条数定期清理抓取记录
+func AutomaticallyCleanPayloadCaptures() {
+	// 只在Master节点定时清理
+	if !common.IsMasterNode {
+		return
+	}
+	for {
+		setting := operation_setting.GetPayloadCaptureSetting()
+		ctx := context.Background()
+		if setting.RetentionDays > 0 {
+			target := time.Now().Add(-time.Duration(setting.RetentionDays) * 24 * time.Hour).Unix()
+			if _, err := model.DeleteOldPayloadCaptures(ctx, target, 1000); err != nil {
+				common.SysError("failed to delete old payload captures: " + err.Error())
+			}
+		}
+		if _, err := model.TrimPayloadCaptures(ctx, setting.MaxRecords, 1000); err != nil {
+			common.SysError("failed to trim payload captures: " + err.Error())
+		}
+		time.Sleep(time.Hour)
+	}
+}
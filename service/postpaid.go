@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// 月初留出一段时间等待异步写入的消费日志落库后再出账
+const invoiceGenerateDelay = time.Hour
+
+var autoProcessInvoicesOnce sync.Once
+
+var ErrUserCreditSuspended = errors.New("账户存在逾期未付款的账单，已暂停调用，请付款后重试")
+
+// GetUserCredit 获取用户的授信额度和是否因逾期被暂停，未开启后付费时均为零值
+func GetUserCredit(userId int) (creditLimit int, suspended bool, err error) {
+	if !operation_setting.GetPostpaidSetting().Enabled {
+		return 0, false, nil
+	}
+	userCache, err := model.GetUserCache(userId)
+	if err != nil {
+		return 0, false, err
+	}
+	return userCache.CreditLimit, userCache.CreditSuspended, nil
+}
+
+// billingPeriodOf 返回 now 所在自然月的上一个月 [start, end)
+func billingPeriodOf(now time.Time) (time.Time, time.Time) {
+	end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return end.AddDate(0, -1, 0), end
+}
+
+// GenerateMonthlyInvoices 为所有授信用户生成上个月的账单，已生成的账单不会重复生成，返回新生成的账单数
+func GenerateMonthlyInvoices(now time.Time) (int, error) {
+	start, end := billingPeriodOf(now)
+	if now.Sub(end) < invoiceGenerateDelay {
+		return 0, nil
+	}
+	userIds, err := model.GetPostpaidUserIds()
+	if err != nil {
+		return 0, err
+	}
+	dueTime := now.AddDate(0, 0, operation_setting.GetPostpaidSetting().DueDays).Unix()
+	generated := 0
+	for _, userId := range userIds {
+		_, created, err := model.GenerateInvoice(userId, start.Unix(), end.Unix(), dueTime)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to generate invoice for user %d: %s", userId, err.Error()))
+			continue
+		}
+		if created {
+			generated++
+		}
+	}
+	return generated, nil
+}
+
+// ProcessOverdueInvoices 标记逾期账单，并按配置暂停逾期用户的调用
+func ProcessOverdueInvoices(now time.Time) error {
+	userIds, err := model.MarkOverdueInvoices(now.Unix())
+	if err != nil {
+		return err
+	}
+	if !operation_setting.GetPostpaidSetting().AutoSuspend {
+		return nil
+	}
+	for _, userId := range userIds {
+		if err = model.SetUserCreditSuspended(userId, true); err != nil {
+			return err
+		}
+		model.RecordLog(userId, model.LogTypeSystem, "账单逾期未付款，已暂停调用")
+	}
+	return nil
+}
+
+func AutomaticallyProcessPostpaidInvoices() {
+	// 只在Master节点出账
+	if !common.IsMasterNode {
+		return
+	}
+	autoProcessInvoicesOnce.Do(func() {
+		for {
+			time.Sleep(1 * time.Hour)
+			if !operation_setting.GetPostpaidSetting().Enabled {
+				continue
+			}
+			now := time.Now()
+			generated, err := GenerateMonthlyInvoices(now)
+			if err != nil {
+				common.SysError("failed to generate invoices: " + err.Error())
+			} else if generated > 0 {
+				common.SysLog(fmt.Sprintf("%d invoices generated", generated))
+			}
+			if err = ProcessOverdueInvoices(now); err != nil {
+				common.SysError("failed to process overdue invoices: " + err.Error())
+			}
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -24,7 +25,7 @@ func ReturnPreConsumedQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo) {
 		if relayInfo.CheckinQuotaConsumed > 0 {
 			logger.LogInfo(c, fmt.Sprintf("用户 %d 请求失败 (free 分组), 返还签到额度 %s", relayInfo.UserId, logger.FormatQuota(relayInfo.CheckinQuotaConsumed)))
 			gopool.Go(func() {
-				err := ReturnCheckinQuota(relayInfo.UserId, relayInfo.CheckinQuotaConsumed)
+				err := ReturnCheckinQuota(relayInfo.UserId, relayInfo.CheckinQuotaConsumed, relayQuotaLedgerRef(relayInfo, model.QuotaLedgerSourceRefund))
 				if err != nil {
 					common.SysLog("error return check-in quota: " + err.Error())
 				}
@@ -33,10 +34,12 @@ func ReturnPreConsumedQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo) {
 		// Also return token quota for free group
 		if relayInfo.FinalPreConsumedQuota != 0 && !relayInfo.IsPlayground {
 			gopool.Go(func() {
-				err := model.IncreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, relayInfo.FinalPreConsumedQuota)
+				err := model.IncreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, relayInfo.FinalPreConsumedQuota, relayQuotaLedgerRef(relayInfo, model.QuotaLedgerSourceRefund))
 				if err != nil {
 					common.SysLog("error return token quota for free group: " + err.Error())
+					return
 				}
+				recordTokenSpend(relayInfo, -relayInfo.FinalPreConsumedQuota)
 			})
 		}
 		return
@@ -70,6 +73,17 @@ func PreConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 		return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
 	}
 
+	creditLimit, suspended, err := GetUserCredit(relayInfo.UserId)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	if suspended {
+		return types.NewErrorWithStatusCode(
+			ErrUserCreditSuspended,
+			types.ErrorCodeInsufficientUserQuota, http.StatusForbidden,
+			types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+	}
+
 	// Check if current request is using the "free" group
 	isFreeGroup := relayInfo.UsingGroup == CheckinQuotaGroup
 
@@ -101,7 +115,7 @@ func PreConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 				return types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 			}
 
-			checkinConsumed, _ := ConsumeCheckinQuota(relayInfo.UserId, preConsumedQuota)
+			checkinConsumed, _ := ConsumeCheckinQuota(relayInfo.UserId, preConsumedQuota, relayQuotaLedgerRef(relayInfo, model.QuotaLedgerSourcePreConsume))
 			relayInfo.CheckinQuotaConsumed = checkinConsumed
 
 			logger.LogInfo(c, fmt.Sprintf("用户 %d 使用签到额度 %s (free 分组), 预扣费后剩余签到额度: %s",
@@ -114,16 +128,28 @@ func PreConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 	}
 
 	// Non-free group: exclusively use user's paid quota
-	if userQuota <= 0 {
+	// 后付费用户的余额可以透支到 -creditLimit
+	availableQuota := userQuota + creditLimit
+	quotaOwner := "用户"
+	if relayInfo.OrganizationId != 0 {
+		// 团队令牌只使用团队额度池，不使用个人额度和授信额度
+		availableQuota, err = GetOrganizationAvailableQuota(relayInfo.OrganizationId, relayInfo.UserId)
+		if err != nil {
+			return types.NewErrorWithStatusCode(err, types.ErrorCodeInsufficientUserQuota, http.StatusForbidden,
+				types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
+		userQuota, creditLimit, quotaOwner = availableQuota, 0, "团队"
+	}
+	if availableQuota <= 0 {
 		return types.NewErrorWithStatusCode(
-			fmt.Errorf("用户额度不足，剩余额度: %s", logger.FormatQuota(userQuota)),
+			fmt.Errorf("%s额度不足，剩余额度: %s%s", quotaOwner, logger.FormatQuota(userQuota), creditLimitHint(creditLimit)),
 			types.ErrorCodeInsufficientUserQuota, http.StatusForbidden,
 			types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 	}
 
-	if userQuota < preConsumedQuota {
+	if availableQuota < preConsumedQuota {
 		return types.NewErrorWithStatusCode(
-			fmt.Errorf("预扣费额度失败，用户剩余额度: %s，需要预扣费额度: %s", logger.FormatQuota(userQuota), logger.FormatQuota(preConsumedQuota)),
+			fmt.Errorf("预扣费额度失败，%s剩余额度: %s%s，需要预扣费额度: %s", quotaOwner, logger.FormatQuota(userQuota), creditLimitHint(creditLimit), logger.FormatQuota(preConsumedQuota)),
 			types.ErrorCodeInsufficientUserQuota, http.StatusForbidden,
 			types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 	}
@@ -131,7 +157,8 @@ func PreConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 	trustQuota := common.GetTrustQuota()
 
 	relayInfo.UserQuota = userQuota
-	if userQuota > trustQuota {
+	// 设置了硬限制周期预算的令牌每次都预扣费，保证预算计数及时生效
+	if availableQuota > trustQuota && !hasHardTokenBudget(relayInfo) {
 		// 用户额度充足，判断令牌额度是否充足
 		if !relayInfo.TokenUnlimited {
 			// 非无限令牌，判断令牌额度是否充足
@@ -149,13 +176,32 @@ func PreConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 		}
 	}
 
+	if preConsumedQuota == 0 {
+		// 未预扣费时仍需校验令牌的周期预算
+		if err := CheckTokenBudget(relayInfo, 0); err != nil {
+			return types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
+	}
+
 	if preConsumedQuota > 0 {
 		err := PreConsumeTokenQuota(relayInfo, preConsumedQuota)
 		if err != nil {
 			return types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 		}
 
-		err = model.DecreaseUserQuota(relayInfo.UserId, preConsumedQuota)
+		err = model.PreConsumeBillingQuota(relayInfo.UserId, relayInfo.OrganizationId, preConsumedQuota, relayQuotaLedgerRef(relayInfo, model.QuotaLedgerSourcePreConsume))
+		if errors.Is(err, model.ErrOrganizationQuotaInsufficient) {
+			// 并发请求已用完团队额度池，退还刚预扣的令牌额度
+			if !relayInfo.IsPlayground {
+				if returnErr := model.IncreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, preConsumedQuota, relayQuotaLedgerRef(relayInfo, model.QuotaLedgerSourceRefund)); returnErr != nil {
+					common.SysLog("error return token quota: " + returnErr.Error())
+				} else {
+					recordTokenSpend(relayInfo, -preConsumedQuota)
+				}
+			}
+			return types.NewErrorWithStatusCode(err, types.ErrorCodeInsufficientUserQuota, http.StatusForbidden,
+				types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
 		}
@@ -167,3 +213,10 @@ func PreConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 	relayInfo.FinalPreConsumedQuota = preConsumedQuota
 	return nil
 }
+
+func creditLimitHint(creditLimit int) string {
+	if creditLimit <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("（授信额度: %s）", logger.FormatQuota(creditLimit))
+}
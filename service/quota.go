@@ -3,12 +3,12 @@ package service
 import (
 	"errors"
 	"fmt"
-	"log"
 	"math"
 	"strings"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/tracing"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
@@ -30,13 +30,14 @@ type TokenDetails struct {
 }
 
 type QuotaInfo struct {
-	InputDetails  TokenDetails
-	OutputDetails TokenDetails
-	ModelName     string
-	UsePrice      bool
-	ModelPrice    float64
-	ModelRatio    float64
-	GroupRatio    float64
+	InputDetails    TokenDetails
+	OutputDetails   TokenDetails
+	ModelName       string
+	UsePrice        bool
+	ModelPrice      float64
+	ModelRatio      float64
+	CompletionRatio float64
+	GroupRatio      float64
 }
 
 func hasCustomModelRatio(modelName string, currentRatio float64) bool {
@@ -48,16 +49,31 @@ func hasCustomModelRatio(modelName string, currentRatio float64) bool {
 }
 
 func calculateAudioQuota(info QuotaInfo) int {
+	quota := calculateAudioQuotaExact(info)
+	if info.UsePrice {
+		return int(quota.IntPart())
+	}
+
+	// If ratio is not zero and quota is less than or equal to zero, set quota to 1
+	ratio := decimal.NewFromFloat(info.GroupRatio).Mul(decimal.NewFromFloat(info.ModelRatio))
+	if !ratio.IsZero() && quota.LessThanOrEqual(decimal.Zero) {
+		quota = decimal.NewFromInt(1)
+	}
+
+	return int(quota.Round(0).IntPart())
+}
+
+// calculateAudioQuotaExact 不取整的精确额度，开启小数额度时交给 SettleFractionalQuota 结转余数
+func calculateAudioQuotaExact(info QuotaInfo) decimal.Decimal {
 	if info.UsePrice {
 		modelPrice := decimal.NewFromFloat(info.ModelPrice)
 		quotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
 		groupRatio := decimal.NewFromFloat(info.GroupRatio)
 
-		quota := modelPrice.Mul(quotaPerUnit).Mul(groupRatio)
-		return int(quota.IntPart())
+		return modelPrice.Mul(quotaPerUnit).Mul(groupRatio)
 	}
 
-	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(info.ModelName))
+	completionRatio := decimal.NewFromFloat(info.CompletionRatio)
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(info.ModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(info.ModelName))
 
@@ -76,21 +92,14 @@ func calculateAudioQuota(info QuotaInfo) int {
 	quota = quota.Add(inputAudioTokens.Mul(audioRatio))
 	quota = quota.Add(outputAudioTokens.Mul(audioRatio).Mul(audioCompletionRatio))
 
-	quota = quota.Mul(ratio)
-
-	// If ratio is not zero and quota is less than or equal to zero, set quota to 1
-	if !ratio.IsZero() && quota.LessThanOrEqual(decimal.Zero) {
-		quota = decimal.NewFromInt(1)
-	}
-
-	return int(quota.Round(0).IntPart())
+	return quota.Mul(ratio)
 }
 
 func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.RealtimeUsage) error {
 	if relayInfo.UsePrice {
 		return nil
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := GetBillingQuota(relayInfo)
 	if err != nil {
 		return err
 	}
@@ -105,21 +114,11 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	textOutTokens := usage.OutputTokenDetails.TextTokens
 	audioInputTokens := usage.InputTokenDetails.AudioTokens
 	audioOutTokens := usage.OutputTokenDetails.AudioTokens
-	groupRatio := ratio_setting.GetGroupRatio(relayInfo.UsingGroup)
-	modelRatio, _, _ := ratio_setting.GetModelRatio(modelName)
-
-	autoGroup, exists := ctx.Get("auto_group")
-	if exists {
-		groupRatio = ratio_setting.GetGroupRatio(autoGroup.(string))
-		log.Printf("final group ratio: %f", groupRatio)
-		relayInfo.UsingGroup = autoGroup.(string)
-	}
-
-	actualGroupRatio := groupRatio
-	userGroupRatio, ok := ratio_setting.GetGroupGroupRatio(relayInfo.UserGroup, relayInfo.UsingGroup)
-	if ok {
-		actualGroupRatio = userGroupRatio
-	}
+	// 实时会话按每次响应结算，倍率与文本计费路径一样按上下文阶梯解析
+	relayInfo.PriceData.ApplyContextTier(usage.InputTokens)
+	modelRatio := relayInfo.PriceData.ModelRatio
+	// 分组倍率沿用请求开始时解析的结果，已包含自动分组、用户分组特殊倍率和时段折扣，与 PostWssConsumeQuota 记录的一致
+	actualGroupRatio := relayInfo.PriceData.GroupRatioInfo.GroupRatio
 
 	quotaInfo := QuotaInfo{
 		InputDetails: TokenDetails{
@@ -130,10 +129,11 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 			TextTokens:  textOutTokens,
 			AudioTokens: audioOutTokens,
 		},
-		ModelName:  modelName,
-		UsePrice:   relayInfo.UsePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: actualGroupRatio,
+		ModelName:       modelName,
+		UsePrice:        relayInfo.UsePrice,
+		ModelRatio:      modelRatio,
+		CompletionRatio: relayInfo.PriceData.CompletionRatio,
+		GroupRatio:      actualGroupRatio,
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
@@ -146,6 +146,20 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 		return fmt.Errorf("token quota is not enough, token remain quota: %s, need quota: %s", logger.FormatQuota(token.RemainQuota), logger.FormatQuota(quota))
 	}
 
+	if err = ReserveTokenBudget(relayInfo, quota); err != nil {
+		return err
+	}
+	// 预留的额度在结算时抵扣，结算失败或结转后无需扣费时释放剩余部分
+	defer ReleaseTokenBudget(relayInfo)
+
+	if IsFractionalQuotaEnabled() {
+		quota, relayInfo.RealtimeQuotaCarry = SettleFractionalQuota(relayInfo, calculateAudioQuotaExact(quotaInfo))
+		relayInfo.RealtimeSettledQuota += quota
+		if quota == 0 {
+			return nil
+		}
+	}
+
 	err = PostConsumeQuota(relayInfo, quota, 0, false)
 	if err != nil {
 		return err
@@ -156,6 +170,7 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 
 func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelName string,
 	usage *dto.RealtimeUsage, extraContent string) {
+	defer tracing.Start(ctx, "post_consume").End()
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.InputTokenDetails.TextTokens
@@ -165,7 +180,9 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	audioOutTokens := usage.OutputTokenDetails.AudioTokens
 
 	tokenName := ctx.GetString("token_name")
-	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(modelName))
+	// 按上下文阶梯解析倍率，与文本计费路径保持一致
+	relayInfo.PriceData.ApplyContextTier(usage.InputTokens)
+	completionRatio := decimal.NewFromFloat(relayInfo.PriceData.CompletionRatio)
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(relayInfo.OriginModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(modelName))
 
@@ -183,13 +200,20 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 			TextTokens:  textOutTokens,
 			AudioTokens: audioOutTokens,
 		},
-		ModelName:  modelName,
-		UsePrice:   usePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: groupRatio,
+		ModelName:       modelName,
+		UsePrice:        usePrice,
+		ModelRatio:      modelRatio,
+		CompletionRatio: completionRatio.InexactFloat64(),
+		GroupRatio:      groupRatio,
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
+	// 按价格计费时不逐次结算，不涉及余数
+	fractionalQuota := IsFractionalQuotaEnabled() && !usePrice
+	if fractionalQuota {
+		// 开启小数额度时已在每次响应时结转余数扣费，日志记录实际扣除的合计
+		quota = relayInfo.RealtimeSettledQuota
+	}
 
 	totalTokens := usage.TotalTokens
 	var logContent string
@@ -211,6 +235,7 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	} else {
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		RecordPricingTierUsage(relayInfo, totalTokens)
 	}
 
 	logModel := modelName
@@ -219,6 +244,9 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	}
 	other := GenerateWssOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	if fractionalQuota && totalTokens != 0 {
+		AppendFractionalQuotaInfo(other, calculateAudioQuotaExact(quotaInfo), relayInfo.RealtimeQuotaCarry)
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.InputTokens,
@@ -236,6 +264,7 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 }
 
 func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage) {
+	defer tracing.Start(ctx, "post_consume").End()
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
@@ -243,17 +272,25 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	modelName := relayInfo.OriginModelName
 
 	tokenName := ctx.GetString("token_name")
+	cacheTokens := usage.PromptTokensDetails.CachedTokens
+	cacheCreationTokens := usage.PromptTokensDetails.CachedCreationTokens
+
+	// 按完整输入长度（含缓存读写）确定阶梯档位，OpenRouter 返回的 prompt tokens 已包含缓存
+	contextTokens := promptTokens
+	if relayInfo.ChannelType != constant.ChannelTypeOpenRouter {
+		contextTokens += cacheTokens + cacheCreationTokens
+	}
+	relayInfo.PriceData.ApplyContextTier(contextTokens)
+
 	completionRatio := relayInfo.PriceData.CompletionRatio
 	modelRatio := relayInfo.PriceData.ModelRatio
 	groupRatio := relayInfo.PriceData.GroupRatioInfo.GroupRatio
 	modelPrice := relayInfo.PriceData.ModelPrice
 	cacheRatio := relayInfo.PriceData.CacheRatio
-	cacheTokens := usage.PromptTokensDetails.CachedTokens
 
 	cacheCreationRatio := relayInfo.PriceData.CacheCreationRatio
 	cacheCreationRatio5m := relayInfo.PriceData.CacheCreation5mRatio
 	cacheCreationRatio1h := relayInfo.PriceData.CacheCreation1hRatio
-	cacheCreationTokens := usage.PromptTokensDetails.CachedCreationTokens
 	cacheCreationTokens5m := usage.ClaudeCacheCreation5mTokens
 	cacheCreationTokens1h := usage.ClaudeCacheCreation1hTokens
 
@@ -285,7 +322,8 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		calculateQuota = modelPrice * common.QuotaPerUnit * groupRatio
 	}
 
-	if modelRatio != 0 && calculateQuota <= 0 {
+	fractionalQuota := IsFractionalQuotaEnabled()
+	if !fractionalQuota && modelRatio != 0 && calculateQuota <= 0 {
 		calculateQuota = 1
 	}
 
@@ -294,6 +332,7 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	totalTokens := promptTokens + completionTokens
 
 	var logContent string
+	var quotaCarry int64
 	// record all the consume log even if quota is 0
 	if totalTokens == 0 {
 		// in this case, must be some error happened
@@ -303,8 +342,12 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		logger.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, modelName, relayInfo.FinalPreConsumedQuota))
 	} else {
+		if fractionalQuota {
+			quota, quotaCarry = SettleFractionalQuota(relayInfo, decimal.NewFromFloat(calculateQuota))
+		}
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		RecordPricingTierUsage(relayInfo, contextTokens+completionTokens)
 	}
 
 	quotaDelta := quota - relayInfo.FinalPreConsumedQuota
@@ -336,6 +379,9 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		cacheCreationTokens5m, cacheCreationRatio5m,
 		cacheCreationTokens1h, cacheCreationRatio1h,
 		modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	if fractionalQuota && totalTokens != 0 {
+		AppendFractionalQuotaInfo(other, decimal.NewFromFloat(calculateQuota), quotaCarry)
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     promptTokens,
@@ -375,6 +421,7 @@ func CalcOpenRouterCacheCreateTokens(usage dto.Usage, priceData types.PriceData)
 }
 
 func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent string) {
+	defer tracing.Start(ctx, "post_consume").End()
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.PromptTokensDetails.TextTokens
@@ -384,7 +431,9 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	audioOutTokens := usage.CompletionTokenDetails.AudioTokens
 
 	tokenName := ctx.GetString("token_name")
-	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(relayInfo.OriginModelName))
+	// 按上下文阶梯解析倍率，与文本计费路径保持一致
+	relayInfo.PriceData.ApplyContextTier(usage.PromptTokens)
+	completionRatio := decimal.NewFromFloat(relayInfo.PriceData.CompletionRatio)
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(relayInfo.OriginModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(relayInfo.OriginModelName))
 
@@ -402,16 +451,19 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 			TextTokens:  textOutTokens,
 			AudioTokens: audioOutTokens,
 		},
-		ModelName:  relayInfo.OriginModelName,
-		UsePrice:   usePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: groupRatio,
+		ModelName:       relayInfo.OriginModelName,
+		UsePrice:        usePrice,
+		ModelRatio:      modelRatio,
+		CompletionRatio: completionRatio.InexactFloat64(),
+		GroupRatio:      groupRatio,
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
+	fractionalQuota := IsFractionalQuotaEnabled()
 
 	totalTokens := usage.TotalTokens
 	var logContent string
+	var quotaCarry int64
 	if !usePrice {
 		logContent = fmt.Sprintf("模型倍率 %.2f，补全倍率 %.2f，音频倍率 %.2f，音频补全倍率 %.2f，分组倍率 %.2f",
 			modelRatio, completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), groupRatio)
@@ -428,8 +480,12 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 		logger.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, relayInfo.OriginModelName, relayInfo.FinalPreConsumedQuota))
 	} else {
+		if fractionalQuota {
+			quota, quotaCarry = SettleFractionalQuota(relayInfo, calculateAudioQuotaExact(quotaInfo))
+		}
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		RecordPricingTierUsage(relayInfo, totalTokens)
 	}
 
 	quotaDelta := quota - relayInfo.FinalPreConsumedQuota
@@ -461,6 +517,9 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	}
 	other := GenerateAudioOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	if fractionalQuota && totalTokens != 0 {
+		AppendFractionalQuotaInfo(other, calculateAudioQuotaExact(quotaInfo), quotaCarry)
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.PromptTokens,
@@ -494,14 +553,32 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	if !relayInfo.TokenUnlimited && token.RemainQuota < quota {
 		return fmt.Errorf("token quota is not enough, token remain quota: %s, need quota: %s", logger.FormatQuota(token.RemainQuota), logger.FormatQuota(quota))
 	}
-	err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota)
+	if err = ReserveTokenBudget(relayInfo, quota); err != nil {
+		return err
+	}
+	err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota, relayQuotaLedgerRef(relayInfo, model.QuotaLedgerSourcePreConsume))
 	if err != nil {
+		ReleaseTokenBudget(relayInfo)
 		return err
 	}
+	recordTokenSpend(relayInfo, quota)
 	return nil
 }
 
+// relayQuotaLedgerRef 中转请求产生的额度流水以请求 ID 作为来源单据
+func relayQuotaLedgerRef(relayInfo *relaycommon.RelayInfo, source string) model.QuotaLedgerRef {
+	return model.QuotaLedgerRef{
+		Source:    source,
+		Reference: relayInfo.RequestId,
+		Remark:    relayInfo.OriginModelName,
+	}
+}
+
 func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool) (err error) {
+	ledgerRef := relayQuotaLedgerRef(relayInfo, model.QuotaLedgerSourceConsume)
+	if quota < 0 {
+		ledgerRef.Source = model.QuotaLedgerSourceRefund
+	}
 	// For "free" group, handle check-in quota exclusively
 	isFreeGroup := relayInfo.UsingGroup == CheckinQuotaGroup
 
@@ -509,20 +586,20 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		// Free group: adjust check-in quota only, never touch user's paid quota
 		if quota > 0 {
 			// Need to consume more from check-in quota
-			_, err = ConsumeCheckinQuota(relayInfo.UserId, quota)
+			_, err = ConsumeCheckinQuota(relayInfo.UserId, quota, ledgerRef)
 		} else if quota < 0 {
 			// Return excess check-in quota
-			err = ReturnCheckinQuota(relayInfo.UserId, -quota)
+			err = ReturnCheckinQuota(relayInfo.UserId, -quota, ledgerRef)
 		}
 		if err != nil {
 			return err
 		}
 	} else {
-		// Non-free group: handle user's paid quota
+		// Non-free group: handle user's paid quota, or the organization pool for organization tokens
 		if quota > 0 {
-			err = model.DecreaseUserQuota(relayInfo.UserId, quota)
+			err = model.DecreaseBillingQuota(relayInfo.UserId, relayInfo.OrganizationId, quota, ledgerRef)
 		} else {
-			err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false)
+			err = model.IncreaseBillingQuota(relayInfo.UserId, relayInfo.OrganizationId, -quota, ledgerRef)
 		}
 		if err != nil {
 			return err
@@ -531,17 +608,18 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 
 	if !relayInfo.IsPlayground {
 		if quota > 0 {
-			err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota)
+			err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota, ledgerRef)
 		} else {
-			err = model.IncreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, -quota)
+			err = model.IncreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, -quota, ledgerRef)
 		}
 		if err != nil {
 			return err
 		}
+		recordTokenSpend(relayInfo, quota)
 	}
 
-	if sendEmail && !isFreeGroup {
-		// Only send quota notification for paid quota
+	if sendEmail && !isFreeGroup && relayInfo.OrganizationId == 0 {
+		// Only send quota notification for the user's own paid quota
 		if (quota + preConsumedQuota) != 0 {
 			checkAndSendQuotaNotify(relayInfo, quota, preConsumedQuota)
 		}
@@ -550,6 +628,20 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 	return nil
 }
 
+// RecordPricingTierUsage 累计用户当月在该模型上的 tokens，只记录配置了用量阶梯的模型
+func RecordPricingTierUsage(relayInfo *relaycommon.RelayInfo, tokens int) {
+	if !relayInfo.PriceData.TierInfo.HasVolumeTiers || tokens <= 0 {
+		return
+	}
+	userId := relayInfo.UserId
+	modelName := relayInfo.OriginModelName
+	gopool.Go(func() {
+		if err := model.IncreaseUserMonthlyModelTokens(userId, modelName, int64(tokens)); err != nil {
+			common.SysError("failed to increase user monthly usage: " + err.Error())
+		}
+	})
+}
+
 func checkAndSendQuotaNotify(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int) {
 	gopool.Go(func() {
 		userSetting := relayInfo.UserSetting
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+const quotaDriftNotifyLimit = 10
+
+var quotaReconcileRunning atomic.Bool
+var autoReconcileQuotaOnce sync.Once
+
+var ErrQuotaReconcileRunning = errors.New("quota reconciliation is already running")
+
+// ReconcileQuotaLedger 执行一次额度对账并保存报告，发现偏差时按配置通知超级管理员
+func ReconcileQuotaLedger() (*model.QuotaReconcileReport, error) {
+	if !quotaReconcileRunning.CompareAndSwap(false, true) {
+		return nil, ErrQuotaReconcileRunning
+	}
+	defer quotaReconcileRunning.Store(false)
+
+	setting := operation_setting.GetQuotaLedgerSetting()
+	report, drifts, err := model.ReconcileQuotaLedger(setting.OpenMissingAccounts)
+	if err != nil {
+		report.ErrorMessage = err.Error()
+	}
+	if saveErr := model.SaveQuotaReconcileReport(report, drifts); saveErr != nil {
+		common.SysError("failed to save quota reconcile report: " + saveErr.Error())
+	}
+	if err != nil {
+		return report, err
+	}
+	if setting.NotifyOnDrift && (report.DriftCount > 0 || report.Imbalance != 0) {
+		notifyQuotaDrift(report, drifts)
+	}
+	return report, nil
+}
+
+func notifyQuotaDrift(report *model.QuotaReconcileReport, drifts []model.QuotaDrift) {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("额度对账发现 %d 个账户余额与流水不一致，偏差合计 %s，流水不平衡额 %d。",
+		report.DriftCount, logger.FormatQuota(int(report.TotalDrift)), report.Imbalance))
+	for i, drift := range drifts {
+		if i >= quotaDriftNotifyLimit {
+			builder.WriteString(fmt.Sprintf("<br/>…… 其余 %d 个账户请在对账报告中查看", len(drifts)-quotaDriftNotifyLimit))
+			break
+		}
+		builder.WriteString(fmt.Sprintf("<br/>%s #%d（用户 %d）：余额 %d，流水余额 %d，偏差 %d",
+			drift.AccountType, drift.AccountId, drift.UserId, drift.Balance, drift.LedgerBalance, drift.Drift))
+	}
+	NotifyRootUser(dto.NotifyTypeQuotaDrift, "额度对账发现偏差", builder.String())
+}
+
+func AutomaticallyReconcileQuotaLedger() {
+	// 只在Master节点定时对账
+	if !common.IsMasterNode {
+		return
+	}
+	autoReconcileQuotaOnce.Do(func() {
+		for {
+			setting := operation_setting.GetQuotaLedgerSetting()
+			if !setting.Enabled || !setting.ReconcileEnabled {
+				time.Sleep(1 * time.Minute)
+				continue
+			}
+			interval := setting.ReconcileIntervalMinutes
+			if interval <= 0 {
+				interval = 60
+			}
+			time.Sleep(time.Duration(interval) * time.Minute)
+			report, err := ReconcileQuotaLedger()
+			if err != nil {
+				common.SysError("failed to reconcile quota ledger: " + err.Error())
+				continue
+			}
+			common.SysLog(fmt.Sprintf("quota reconciliation finished, %d users, %d tokens, %d drifts, %d opened",
+				report.UserCount, report.TokenCount, report.DriftCount, report.OpenedCount))
+		}
+	})
+}
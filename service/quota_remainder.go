@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// QuotaMicroUnit 1 额度 = 1,000,000 微额度，不足 1 额度的部分以微额度累计
+	QuotaMicroUnit          = int64(1_000_000)
+	quotaRemainderKeyTTL    = 30 * 24 * time.Hour
+	quotaRemainderKeyPrefix = "quota_remainder:"
+	// 未启用 Redis 时清理过期余数的间隔
+	quotaRemainderCleanupInterval = 10 * time.Minute
+)
+
+type quotaRemainder struct {
+	micro     int64
+	expiresAt int64
+}
+
+// 未启用 Redis 时余数保存在内存中，与 Redis 一样在最后一次结算 quotaRemainderKeyTTL 后过期，余数为 0 时直接删除
+var (
+	quotaRemainders           = make(map[string]*quotaRemainder)
+	quotaRemaindersLock       sync.Mutex
+	quotaRemaindersLastPruned int64
+)
+
+func quotaRemainderKey(relayInfo *relaycommon.RelayInfo) string {
+	return fmt.Sprintf("%s%d:%d", quotaRemainderKeyPrefix, relayInfo.UserId, relayInfo.TokenId)
+}
+
+// addQuotaRemainder 累加微额度余数并返回累加后的值
+func addQuotaRemainder(key string, delta int64) (int64, error) {
+	if common.RedisEnabled {
+		ctx := context.Background()
+		pipe := common.RDB.TxPipeline()
+		incr := pipe.IncrBy(ctx, key, delta)
+		pipe.Expire(ctx, key, quotaRemainderKeyTTL)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return 0, err
+		}
+		return incr.Val(), nil
+	}
+	now := time.Now().Unix()
+	quotaRemaindersLock.Lock()
+	defer quotaRemaindersLock.Unlock()
+	if now-quotaRemaindersLastPruned >= int64(quotaRemainderCleanupInterval.Seconds()) {
+		for k, remainder := range quotaRemainders {
+			if remainder.expiresAt < now {
+				delete(quotaRemainders, k)
+			}
+		}
+		quotaRemaindersLastPruned = now
+	}
+	remainder, ok := quotaRemainders[key]
+	if !ok || remainder.expiresAt < now {
+		remainder = &quotaRemainder{}
+		quotaRemainders[key] = remainder
+	}
+	remainder.micro += delta
+	remainder.expiresAt = now + int64(quotaRemainderKeyTTL.Seconds())
+	total := remainder.micro
+	if total == 0 {
+		delete(quotaRemainders, key)
+	}
+	return total, nil
+}
+
+// IsFractionalQuotaEnabled 是否按微额度累计不足 1 额度的消耗
+func IsFractionalQuotaEnabled() bool {
+	return operation_setting.GetQuotaSetting().FractionalQuotaEnabled
+}
+
+// SettleFractionalQuota 将本次精确消耗计入该用户令牌的余数，返回本次应扣除的整数额度和结转后的余数（微额度）。
+// 余数跨请求结转，使长期扣费与倍率计算结果一致，不再对每次请求单独取整或按最低 1 额度扣费。
+// 并发请求可能使余数短暂为负，表示已提前扣除，后续请求会自动抵扣
+func SettleFractionalQuota(relayInfo *relaycommon.RelayInfo, exactQuota decimal.Decimal) (int, int64) {
+	micro := exactQuota.Mul(decimal.NewFromInt(QuotaMicroUnit)).Round(0).IntPart()
+	if micro <= 0 {
+		return 0, 0
+	}
+	key := quotaRemainderKey(relayInfo)
+	total, err := addQuotaRemainder(key, micro)
+	if err != nil {
+		common.SysError("failed to accumulate quota remainder: " + err.Error())
+		return int(exactQuota.Round(0).IntPart()), 0
+	}
+	whole := total / QuotaMicroUnit
+	if whole <= 0 {
+		return 0, total
+	}
+	carry, err := addQuotaRemainder(key, -whole*QuotaMicroUnit)
+	if err != nil {
+		// 扣减余数失败时不扣除整数部分，留待下次结算，避免重复计费
+		common.SysError("failed to settle quota remainder: " + err.Error())
+		return 0, total
+	}
+	return int(whole), carry
+}
+
+// AppendFractionalQuotaInfo 在消费日志中记录精确消耗和结转余数
+func AppendFractionalQuotaInfo(other map[string]interface{}, exactQuota decimal.Decimal, carry int64) {
+	other["quota_exact"] = exactQuota.Round(6).InexactFloat64()
+	other["quota_carry"] = decimal.NewFromInt(carry).Div(decimal.NewFromInt(QuotaMicroUnit)).InexactFloat64()
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+const (
+	StatusOperational = "operational"
+	StatusDegraded    = "degraded"
+	StatusDown        = "down"
+	StatusMaintenance = "maintenance"
+	StatusUnknown     = "unknown"
+
+	statusSampleBucketSeconds = 3600
+	statusPageHistoryDays     = 30
+	statusPageCacheSeconds    = 30
+)
+
+type statusSampleKey struct {
+	bucket int64
+	kind   string
+	name   string
+	source string
+}
+
+var statusSampleLock sync.Mutex
+var statusSampleBuffer = make(map[statusSampleKey]*model.StatusSample)
+
+// RecordStatusSample 记录一次可用性样本，同时计入模型与分组，样本先缓存在内存中定时写入数据库
+func RecordStatusSample(source string, modelName string, group string, success bool, latency time.Duration) {
+	now := time.Now().Unix()
+	bucket := now - now%statusSampleBucketSeconds
+	statusSampleLock.Lock()
+	defer statusSampleLock.Unlock()
+	for _, key := range []statusSampleKey{
+		{bucket: bucket, kind: model.StatusSampleKindModel, name: modelName, source: source},
+		{bucket: bucket, kind: model.StatusSampleKindGroup, name: group, source: source},
+	} {
+		if key.name == "" {
+			continue
+		}
+		sample, ok := statusSampleBuffer[key]
+		if !ok {
+			sample = &model.StatusSample{BucketTime: key.bucket, Kind: key.kind, Name: key.name, Source: key.source}
+			statusSampleBuffer[key] = sample
+		}
+		sample.Total++
+		if success {
+			sample.Success++
+			sample.LatencySum += latency.Milliseconds()
+		}
+	}
+}
+
+// RecordRelayStatus 根据真实请求的结果记录可用性，只有上游故障（5xx、429）才计为不可用，客户端错误与主动取消不计入
+func RecordRelayStatus(modelName string, group string, newAPIError *types.NewAPIError, latency time.Duration) {
+	if modelName == "" || !operation_setting.GetStatusPageSetting().Enabled {
+		return
+	}
+	success := true
+	if newAPIError != nil {
+		if newAPIError.GetErrorCode() == types.ErrorCodeRequestCancelled {
+			return
+		}
+		if newAPIError.StatusCode < http.StatusInternalServerError && newAPIError.StatusCode != http.StatusTooManyRequests {
+			return
+		}
+		success = false
+	}
+	RecordStatusSample(model.StatusSampleSourceTraffic, modelName, group, success, latency)
+}
+
+func flushStatusSamples() {
+	statusSampleLock.Lock()
+	samples := statusSampleBuffer
+	statusSampleBuffer = make(map[statusSampleKey]*model.StatusSample)
+	statusSampleLock.Unlock()
+	for _, sample := range samples {
+		if err := model.IncreaseStatusSample(sample); err != nil {
+			common.SysError("failed to save status sample: " + err.Error())
+		}
+	}
+}
+
+// SyncStatusSamples 每分钟将内存中的样本写入数据库，Master 节点每小时清理过期样本
+func SyncStatusSamples() {
+	lastCleanup := int64(0)
+	for {
+		time.Sleep(1 * time.Minute)
+		flushStatusSamples()
+		retentionDays := operation_setting.GetStatusPageSetting().RetentionDays
+		if !common.IsMasterNode || retentionDays <= 0 || time.Now().Unix()-lastCleanup < 3600 {
+			continue
+		}
+		lastCleanup = time.Now().Unix()
+		target := time.Now().AddDate(0, 0, -retentionDays).Unix()
+		if count, err := model.DeleteOldStatusSamples(context.Background(), target, 1000); err != nil {
+			common.SysError("failed to delete old status samples: " + err.Error())
+		} else if count > 0 {
+			common.SysLog(fmt.Sprintf("deleted %d old status samples", count))
+		}
+	}
+}
+
+type StatusComponent struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Uptime24h float64   `json:"uptime_24h"` // 百分比，-1 表示没有数据
+	Uptime7d  float64   `json:"uptime_7d"`
+	Uptime30d float64   `json:"uptime_30d"`
+	LatencyMs int64     `json:"latency_ms,omitempty"` // 最近 24 小时成功请求的平均耗时
+	History   []float64 `json:"history"`              // 最近 30 天每天的可用率，从旧到新
+}
+
+type StatusPage struct {
+	Status    string                  `json:"status"`
+	UpdatedAt int64                   `json:"updated_at"`
+	Models    []*StatusComponent      `json:"models"`
+	Groups    []*StatusComponent      `json:"groups"`
+	Incidents []*model.StatusIncident `json:"incidents"`
+}
+
+var statusPageLock sync.Mutex
+var statusPageCache *StatusPage
+
+// InvalidateStatusPage 公告变更后清除状态页缓存
+func InvalidateStatusPage() {
+	statusPageLock.Lock()
+	statusPageCache = nil
+	statusPageLock.Unlock()
+}
+
+// GetStatusPage 获取状态页数据，结果缓存 30 秒
+func GetStatusPage() (*StatusPage, error) {
+	statusPageLock.Lock()
+	defer statusPageLock.Unlock()
+	if statusPageCache != nil && common.GetTimestamp()-statusPageCache.UpdatedAt < statusPageCacheSeconds {
+		return statusPageCache, nil
+	}
+	page, err := buildStatusPage()
+	if err != nil {
+		return nil, err
+	}
+	statusPageCache = page
+	return page, nil
+}
+
+type statusAccumulator struct {
+	total      int64
+	success    int64
+	latencySum int64
+}
+
+func (a *statusAccumulator) add(stat *model.StatusSampleStat) {
+	a.total += stat.Total
+	a.success += stat.Success
+	a.latencySum += stat.LatencySum
+}
+
+func (a *statusAccumulator) uptime() float64 {
+	if a.total == 0 {
+		return -1
+	}
+	return math.Round(float64(a.success)/float64(a.total)*10000) / 100
+}
+
+func buildStatusPage() (*StatusPage, error) {
+	pageSetting := operation_setting.GetStatusPageSetting()
+	now := common.GetTimestamp()
+	today := now - now%86400
+	historyStart := today - (statusPageHistoryDays-1)*86400
+	stats, err := model.GetStatusSampleStats(historyStart, statusSampleBucketSeconds)
+	if err != nil {
+		return nil, err
+	}
+	incidents, err := model.GetPublicStatusIncidents(now - 7*86400)
+	if err != nil {
+		return nil, err
+	}
+
+	modelNames := pageSetting.Models
+	if len(modelNames) == 0 {
+		modelNames = model.GetEnabledModels()
+	}
+	groupNames := pageSetting.Groups
+	if len(groupNames) == 0 {
+		for group := range setting.GetUserUsableGroupsCopy() {
+			groupNames = append(groupNames, group)
+		}
+	}
+	modelNames = append([]string{}, modelNames...)
+	groupNames = append([]string{}, groupNames...)
+	sort.Strings(modelNames)
+	sort.Strings(groupNames)
+
+	buildComponents := func(kind string, names []string) []*StatusComponent {
+		components := make([]*StatusComponent, 0, len(names))
+		for _, name := range names {
+			var recent, day, week, month statusAccumulator
+			daily := make([]statusAccumulator, statusPageHistoryDays)
+			for _, stat := range stats {
+				if stat.Kind != kind || stat.Name != name {
+					continue
+				}
+				month.add(stat)
+				if stat.Bucket >= now-7*86400 {
+					week.add(stat)
+				}
+				if stat.Bucket >= now-86400 {
+					day.add(stat)
+				}
+				// 当前状态取最近两个小时，避免整点刚切换时样本过少
+				if stat.Bucket >= now-now%statusSampleBucketSeconds-statusSampleBucketSeconds {
+					recent.add(stat)
+				}
+				if index := (stat.Bucket - historyStart) / 86400; index >= 0 && index < statusPageHistoryDays {
+					daily[index].add(stat)
+				}
+			}
+			component := &StatusComponent{
+				Name:      name,
+				Status:    StatusUnknown,
+				Uptime24h: day.uptime(),
+				Uptime7d:  week.uptime(),
+				Uptime30d: month.uptime(),
+				History:   make([]float64, statusPageHistoryDays),
+			}
+			if recent.total > 0 {
+				ratio := float64(recent.success) / float64(recent.total)
+				switch {
+				case ratio < pageSetting.DownThreshold:
+					component.Status = StatusDown
+				case ratio < pageSetting.DegradedThreshold:
+					component.Status = StatusDegraded
+				default:
+					component.Status = StatusOperational
+				}
+			}
+			if pageSetting.ShowLatency && day.success > 0 {
+				component.LatencyMs = day.latencySum / day.success
+			}
+			for i := range daily {
+				component.History[i] = daily[i].uptime()
+			}
+			for _, incident := range incidents {
+				if !incident.IsActive(now) {
+					continue
+				}
+				affected := incident.GetModels()
+				if kind == model.StatusSampleKindGroup {
+					affected = incident.GetGroups()
+				}
+				if !common.StringsContains(affected, name) {
+					continue
+				}
+				component.Status = worseStatus(component.Status, incidentStatus(incident))
+			}
+			components = append(components, component)
+		}
+		return components
+	}
+
+	page := &StatusPage{
+		Status:    StatusOperational,
+		UpdatedAt: now,
+		Models:    buildComponents(model.StatusSampleKindModel, modelNames),
+		Groups:    buildComponents(model.StatusSampleKindGroup, groupNames),
+		Incidents: incidents,
+	}
+	for _, components := range [][]*StatusComponent{page.Models, page.Groups} {
+		for _, component := range components {
+			if component.Status != StatusUnknown {
+				page.Status = worseStatus(page.Status, component.Status)
+			}
+		}
+	}
+	// 没有指定受影响组件的全局公告同样影响整体状态
+	for _, incident := range incidents {
+		if incident.IsActive(now) && len(incident.GetModels()) == 0 && len(incident.GetGroups()) == 0 {
+			page.Status = worseStatus(page.Status, incidentStatus(incident))
+		}
+	}
+	return page, nil
+}
+
+func incidentStatus(incident *model.StatusIncident) string {
+	if incident.Type == model.StatusIncidentTypeMaintenance {
+		return StatusMaintenance
+	}
+	if incident.Severity == "minor" {
+		return StatusDegraded
+	}
+	return StatusDown
+}
+
+var statusPriority = map[string]int{
+	StatusUnknown:     0,
+	StatusOperational: 1,
+	StatusMaintenance: 2,
+	StatusDegraded:    3,
+	StatusDown:        4,
+}
+
+func worseStatus(a string, b string) string {
+	if statusPriority[b] > statusPriority[a] {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+const (
+	subscriptionSweepBatchSize     = 100
+	subscriptionPendingTimeoutHour = 24
+)
+
+var autoExpireSubscriptionsOnce sync.Once
+
+// GetSubscriptionEntitlement 获取用户订阅权益，未开放订阅或没有生效中的订阅时返回 nil
+func GetSubscriptionEntitlement(userId int) *model.SubscriptionEntitlement {
+	if !operation_setting.GetSubscriptionSetting().Enabled || userId == 0 {
+		return nil
+	}
+	return model.GetSubscriptionEntitlement(userId)
+}
+
+// ExpireDueSubscriptions 处理到期的订阅并关闭超时未支付的订单，返回到期的订阅数
+func ExpireDueSubscriptions() (int, error) {
+	now := common.GetTimestamp()
+	graceSeconds := int64(operation_setting.GetSubscriptionSetting().GracePeriodHours) * 3600
+	expired := 0
+	for {
+		subs, err := model.GetDueSubscriptions(now, graceSeconds, subscriptionSweepBatchSize)
+		if err != nil {
+			return expired, err
+		}
+		for _, sub := range subs {
+			reason := "订阅周期结束"
+			if !sub.CancelAtPeriodEnd && sub.PaymentMethod != model.SubscriptionPaymentAdmin {
+				reason = "超过宽限时间仍未续费"
+			}
+			if err = model.ExpireSubscription(sub.Id, reason); err != nil {
+				return expired, err
+			}
+			expired++
+		}
+		if len(subs) < subscriptionSweepBatchSize {
+			break
+		}
+	}
+	if _, err := model.CloseStalePendingSubscriptions(now - subscriptionPendingTimeoutHour*3600); err != nil {
+		return expired, err
+	}
+	return expired, nil
+}
+
+func AutomaticallyExpireSubscriptions() {
+	// 只在Master节点处理订阅到期
+	if !common.IsMasterNode {
+		return
+	}
+	autoExpireSubscriptionsOnce.Do(func() {
+		for {
+			time.Sleep(1 * time.Minute)
+			expired, err := ExpireDueSubscriptions()
+			if err != nil {
+				common.SysError("failed to expire subscriptions: " + err.Error())
+				continue
+			}
+			if expired > 0 {
+				common.SysLog(fmt.Sprintf("%d subscriptions expired", expired))
+			}
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+)
+
+func hasTokenBudget(relayInfo *relaycommon.RelayInfo) bool {
+	return relayInfo.TokenBudgetPeriod != model.TokenBudgetPeriodNone && relayInfo.TokenBudgetQuota > 0 && !relayInfo.IsPlayground
+}
+
+func isDerivedTokenRequest(relayInfo *relaycommon.RelayInfo) bool {
+	return relayInfo.DerivedTokenId != "" && !relayInfo.IsPlayground
+}
+
+// hasHardTokenBudget 硬限制的周期预算和派生令牌的额度需要每次预扣费，不能走信任额度跳过预扣
+func hasHardTokenBudget(relayInfo *relaycommon.RelayInfo) bool {
+	return isDerivedTokenRequest(relayInfo) || (hasTokenBudget(relayInfo) && !relayInfo.TokenBudgetSoftLimit)
+}
+
+// CheckTokenBudget 校验派生令牌的额度和令牌当前周期的预算，硬限制在超出预算时拒绝请求，软限制只在首次超出时记录告警
+func CheckTokenBudget(relayInfo *relaycommon.RelayInfo, quota int) error {
+	if isDerivedTokenRequest(relayInfo) {
+		if err := checkDerivedTokenQuota(relayInfo.DerivedTokenId, relayInfo.DerivedTokenQuota, quota); err != nil {
+			return err
+		}
+	}
+	return checkTokenPeriodBudget(relayInfo, quota)
+}
+
+// ReserveTokenBudget 与 CheckTokenBudget 相同，但派生令牌的额度以原子方式预留，预留的额度在 recordTokenSpend 时抵扣。
+// 请求失败未计入消费时需要调用 ReleaseTokenBudget 释放
+func ReserveTokenBudget(relayInfo *relaycommon.RelayInfo, quota int) error {
+	if isDerivedTokenRequest(relayInfo) {
+		err := reserveDerivedTokenQuota(relayInfo.DerivedTokenId, relayInfo.DerivedTokenQuota, quota, relayInfo.DerivedTokenExpiresAt)
+		if err != nil {
+			return err
+		}
+		if quota > 0 {
+			relayInfo.DerivedTokenReserved += quota
+		}
+	}
+	if err := checkTokenPeriodBudget(relayInfo, quota); err != nil {
+		ReleaseTokenBudget(relayInfo)
+		return err
+	}
+	return nil
+}
+
+// ReleaseTokenBudget 释放派生令牌中尚未计入消费的预留额度
+func ReleaseTokenBudget(relayInfo *relaycommon.RelayInfo) {
+	if relayInfo.DerivedTokenReserved <= 0 {
+		return
+	}
+	reserved := relayInfo.DerivedTokenReserved
+	relayInfo.DerivedTokenReserved = 0
+	if err := IncreaseDerivedTokenUsed(relayInfo.DerivedTokenId, -reserved, relayInfo.DerivedTokenExpiresAt); err != nil {
+		common.SysError("failed to release derived token reservation: " + err.Error())
+	}
+}
+
+func checkTokenPeriodBudget(relayInfo *relaycommon.RelayInfo, quota int) error {
+	if !hasTokenBudget(relayInfo) {
+		return nil
+	}
+	used, err := model.GetTokenBudgetUsed(relayInfo.TokenId, relayInfo.TokenBudgetPeriod)
+	if err != nil {
+		return err
+	}
+	budget := relayInfo.TokenBudgetQuota
+	if used < budget && used+quota <= budget {
+		return nil
+	}
+	_, resetTime := model.TokenBudgetPeriodRange(relayInfo.TokenBudgetPeriod, time.Now())
+	resetAt := time.Unix(resetTime, 0).Format("2006-01-02 15:04:05")
+	if relayInfo.TokenBudgetSoftLimit {
+		if used <= budget {
+			model.RecordLog(relayInfo.UserId, model.LogTypeSystem, fmt.Sprintf("令牌 #%d 本周期消费已超出预算 %s（软限制，继续放行），将于 %s 重置",
+				relayInfo.TokenId, logger.LogQuota(budget), resetAt))
+		}
+		return nil
+	}
+	return fmt.Errorf("token budget exceeded, period: %s, used: %s, budget: %s, need: %s, resets at %s",
+		relayInfo.TokenBudgetPeriod, logger.FormatQuota(used), logger.FormatQuota(budget), logger.FormatQuota(quota), resetAt)
+}
+
+// recordTokenSpend 累加派生令牌的消费和令牌当前周期的预算消费，quota 为负数表示退款
+func recordTokenSpend(relayInfo *relaycommon.RelayInfo, quota int) {
+	if quota == 0 {
+		return
+	}
+	if isDerivedTokenRequest(relayInfo) {
+		// 已预留的额度在预留时已经累加，只累加超出预留的部分
+		derivedDelta := quota
+		if quota > 0 && relayInfo.DerivedTokenReserved > 0 {
+			covered := min(quota, relayInfo.DerivedTokenReserved)
+			relayInfo.DerivedTokenReserved -= covered
+			derivedDelta -= covered
+		}
+		if derivedDelta != 0 {
+			if err := IncreaseDerivedTokenUsed(relayInfo.DerivedTokenId, derivedDelta, relayInfo.DerivedTokenExpiresAt); err != nil {
+				common.SysError("failed to update derived token usage: " + err.Error())
+			}
+		}
+	}
+	if !hasTokenBudget(relayInfo) {
+		return
+	}
+	// 同步累加，避免并发请求在计数落库前越过硬限制
+	if err := model.IncreaseTokenBudgetUsed(relayInfo.TokenId, relayInfo.TokenBudgetPeriod, quota); err != nil {
+		common.SysError("failed to update token budget usage: " + err.Error())
+	}
+}
@@ -0,0 +1,46 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	LogArchiveStorageLocal = "local"
+	LogArchiveStorageS3    = "s3"
+)
+
+type LogArchiveSetting struct {
+	Enabled            bool   `json:"enabled"`
+	RetentionDays      int    `json:"retention_days"`       // 超过该天数的日志会被归档
+	IntervalHours      int    `json:"interval_hours"`       // 定时归档间隔
+	DeleteAfterArchive bool   `json:"delete_after_archive"` // 归档成功后是否从日志库中删除
+	StorageType        string `json:"storage_type"`         // local / s3
+	LocalDir           string `json:"local_dir"`
+	S3Endpoint         string `json:"s3_endpoint"` // 兼容 S3 协议的服务地址，例如 https://s3.amazonaws.com 或 MinIO/R2 地址
+	S3Region           string `json:"s3_region"`
+	S3Bucket           string `json:"s3_bucket"`
+	S3Prefix           string `json:"s3_prefix"`
+	S3AccessKeyId      string `json:"s3_access_key_id"`
+	S3SecretAccessKey  string `json:"s3_secret_access_key"`
+	S3PathStyle        bool   `json:"s3_path_style"`
+}
+
+// 默认配置
+var logArchiveSetting = LogArchiveSetting{
+	Enabled:            false,
+	RetentionDays:      90,
+	IntervalHours:      24,
+	DeleteAfterArchive: true,
+	StorageType:        LogArchiveStorageLocal,
+	LocalDir:           "./data/log_archive",
+	S3Region:           "us-east-1",
+	S3Prefix:           "log_archive",
+	S3PathStyle:        true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("log_archive_setting", &logArchiveSetting)
+}
+
+func GetLogArchiveSetting() *LogArchiveSetting {
+	return &logArchiveSetting
+}
@@ -0,0 +1,63 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	LogSinkTypeHTTP       = "http"
+	LogSinkTypeKafkaRest  = "kafka_rest"
+	LogSinkTypeClickHouse = "clickhouse"
+	LogSinkTypeLoki       = "loki"
+)
+
+type LogSinkConfig struct {
+	Name           string            `json:"name"`
+	Type           string            `json:"type"` // http / kafka_rest / clickhouse / loki
+	Enabled        bool              `json:"enabled"`
+	Endpoint       string            `json:"endpoint"`
+	Headers        map[string]string `json:"headers"`
+	Topic          string            `json:"topic"`    // kafka_rest: 写入的 topic
+	Database       string            `json:"database"` // clickhouse: 数据库名
+	Table          string            `json:"table"`    // clickhouse: 表名，列名与日志 JSON 字段一致
+	Username       string            `json:"username"` // clickhouse / loki / http basic auth
+	Password       string            `json:"password"`
+	Labels         map[string]string `json:"labels"`    // loki: 附加的静态标签
+	LogTypes       []int             `json:"log_types"` // 为空时转发全部消费与错误日志
+	TimeoutSeconds int               `json:"timeout_seconds"`
+}
+
+type LogSinkSetting struct {
+	Enabled bool `json:"enabled"`
+	// DisableDBWrite 开启后消费与错误日志只写入外部 sink，不再写入 LOG_DB。
+	// 依赖日志表的功能会随之失效：未开启用量预聚合（USAGE_ROLLUP_ENABLED）时的日志统计与数据看板、
+	// 使用日志列表，以及基于消费日志出账的后付费账单
+	DisableDBWrite  bool            `json:"disable_db_write"`
+	QueueSize       int             `json:"queue_size"`
+	BatchSize       int             `json:"batch_size"`
+	FlushIntervalMs int             `json:"flush_interval_ms"`
+	BlockOnFull     bool            `json:"block_on_full"`    // 队列满时阻塞等待而不是直接丢弃
+	BlockTimeoutMs  int             `json:"block_timeout_ms"` // 阻塞等待的最长时间，超时后丢弃
+	MaxRetries      int             `json:"max_retries"`
+	Sinks           []LogSinkConfig `json:"sinks"`
+}
+
+// 默认配置
+var logSinkSetting = LogSinkSetting{
+	Enabled:         false,
+	DisableDBWrite:  false,
+	QueueSize:       10000,
+	BatchSize:       500,
+	FlushIntervalMs: 1000,
+	BlockOnFull:     false,
+	BlockTimeoutMs:  100,
+	MaxRetries:      2,
+	Sinks:           []LogSinkConfig{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("log_sink_setting", &logSinkSetting)
+}
+
+func GetLogSinkSetting() *LogSinkSetting {
+	return &logSinkSetting
+}
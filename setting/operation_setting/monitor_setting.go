@@ -10,12 +10,56 @@ import (
 type MonitorSetting struct {
 	AutoTestChannelEnabled bool    `json:"auto_test_channel_enabled"`
 	AutoTestChannelMinutes float64 `json:"auto_test_channel_minutes"`
+	// 模型验真：定时发送指纹探针，评估渠道所声称的模型是否真实
+	ModelVerifyEnabled       bool    `json:"model_verify_enabled"`
+	ModelVerifyMinutes       float64 `json:"model_verify_minutes"`
+	ModelVerifyThreshold     float64 `json:"model_verify_threshold"` // 得分低于该值视为未通过，取值 0~1
+	ModelVerifyAutoDisable   bool    `json:"model_verify_auto_disable"`
+	ModelVerifyNotify        bool    `json:"model_verify_notify"`
+	ModelVerifyRetentionDays int     `json:"model_verify_retention_days"`
+	// 能力测试：按渠道类型配置需要测试的能力，key 为渠道类型编号，default 为默认矩阵
+	CapabilityTestEnabled           bool                `json:"capability_test_enabled"`
+	CapabilityTestMinutes           float64             `json:"capability_test_minutes"`
+	CapabilityTestMatrix            map[string][]string `json:"capability_test_matrix"`
+	CapabilityTestLongContextTokens int                 `json:"capability_test_long_context_tokens"`
+	CapabilityTestRetentionDays     int                 `json:"capability_test_retention_days"`
+	// 上游模型同步：定时拉取渠道上游的模型列表并与渠道模型对比
+	ModelSyncEnabled       bool     `json:"model_sync_enabled"`
+	ModelSyncMinutes       float64  `json:"model_sync_minutes"`
+	ModelSyncAutoAdd       bool     `json:"model_sync_auto_add"`
+	ModelSyncAllowPatterns []string `json:"model_sync_allow_patterns"` // 自动添加的模型需匹配其中之一（正则），为空时不限制
+	ModelSyncAutoRemove    bool     `json:"model_sync_auto_remove"`
+	ModelSyncNotify        bool     `json:"model_sync_notify"`
+	ModelSyncRetentionDays int      `json:"model_sync_retention_days"`
 }
 
 // 默认配置
 var monitorSetting = MonitorSetting{
 	AutoTestChannelEnabled: false,
 	AutoTestChannelMinutes: 10,
+
+	ModelVerifyEnabled:       false,
+	ModelVerifyMinutes:       360,
+	ModelVerifyThreshold:     0.6,
+	ModelVerifyAutoDisable:   false,
+	ModelVerifyNotify:        true,
+	ModelVerifyRetentionDays: 30,
+
+	CapabilityTestEnabled: false,
+	CapabilityTestMinutes: 60,
+	CapabilityTestMatrix: map[string][]string{
+		"default": {"stream", "tool_call", "json_mode"},
+	},
+	CapabilityTestLongContextTokens: 32000,
+	CapabilityTestRetentionDays:     30,
+
+	ModelSyncEnabled:       false,
+	ModelSyncMinutes:       1440,
+	ModelSyncAutoAdd:       false,
+	ModelSyncAllowPatterns: []string{},
+	ModelSyncAutoRemove:    false,
+	ModelSyncNotify:        true,
+	ModelSyncRetentionDays: 90,
 }
 
 func init() {
@@ -23,6 +67,14 @@ func init() {
 	config.GlobalConfig.Register("monitor_setting", &monitorSetting)
 }
 
+// GetCapabilityTestMatrix 获取指定渠道类型需要测试的能力列表，未单独配置时使用默认矩阵
+func (s *MonitorSetting) GetCapabilityTestMatrix(channelType int) []string {
+	if capabilities, ok := s.CapabilityTestMatrix[strconv.Itoa(channelType)]; ok {
+		return capabilities
+	}
+	return s.CapabilityTestMatrix["default"]
+}
+
 func GetMonitorSetting() *MonitorSetting {
 	if os.Getenv("CHANNEL_TEST_FREQUENCY") != "" {
 		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_TEST_FREQUENCY"))
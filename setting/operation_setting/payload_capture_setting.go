@@ -0,0 +1,36 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// PayloadCaptureSetting 请求/响应内容抓取，命中用户、令牌、渠道名单或按采样率随机命中
+type PayloadCaptureSetting struct {
+	Enabled       bool    `json:"enabled"`
+	SampleRate    float64 `json:"sample_rate"` // 0~1，对未命中名单的请求随机采样
+	UserIds       []int   `json:"user_ids"`
+	TokenIds      []int   `json:"token_ids"`
+	ChannelIds    []int   `json:"channel_ids"`
+	MaxBodyBytes  int     `json:"max_body_bytes"` // 每部分内容的最大字节数，超出部分截断
+	RetentionDays int     `json:"retention_days"`
+	MaxRecords    int     `json:"max_records"` // 最多保留的记录数，0 表示不限制
+}
+
+// 默认配置
+var payloadCaptureSetting = PayloadCaptureSetting{
+	Enabled:       false,
+	SampleRate:    0,
+	UserIds:       []int{},
+	TokenIds:      []int{},
+	ChannelIds:    []int{},
+	MaxBodyBytes:  256 * 1024,
+	RetentionDays: 7,
+	MaxRecords:    100000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("payload_capture_setting", &payloadCaptureSetting)
+}
+
+func GetPayloadCaptureSetting() *PayloadCaptureSetting {
+	return &payloadCaptureSetting
+}
@@ -0,0 +1,25 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+type PostpaidSetting struct {
+	Enabled     bool `json:"enabled"`      // 是否开启后付费授信，开启后设置了授信额度的用户余额可以透支到负数
+	DueDays     int  `json:"due_days"`     // 账单出具后的付款期限（天）
+	AutoSuspend bool `json:"auto_suspend"` // 账单逾期后自动暂停用户调用
+}
+
+// 默认配置
+var postpaidSetting = PostpaidSetting{
+	Enabled:     false,
+	DueDays:     15,
+	AutoSuspend: true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("postpaid_setting", &postpaidSetting)
+}
+
+func GetPostpaidSetting() *PostpaidSetting {
+	return &postpaidSetting
+}
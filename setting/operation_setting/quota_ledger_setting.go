@@ -0,0 +1,29 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+type QuotaLedgerSetting struct {
+	Enabled                  bool `json:"enabled"`                    // 是否记录额度流水
+	ReconcileEnabled         bool `json:"reconcile_enabled"`          // 是否定时对账
+	ReconcileIntervalMinutes int  `json:"reconcile_interval_minutes"` // 定时对账间隔
+	OpenMissingAccounts      bool `json:"open_missing_accounts"`      // 对账时为没有流水的账户补记期初余额
+	NotifyOnDrift            bool `json:"notify_on_drift"`            // 发现偏差时通知超级管理员
+}
+
+// 默认配置
+var quotaLedgerSetting = QuotaLedgerSetting{
+	Enabled:                  true,
+	ReconcileEnabled:         true,
+	ReconcileIntervalMinutes: 60,
+	OpenMissingAccounts:      true,
+	NotifyOnDrift:            true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("quota_ledger_setting", &quotaLedgerSetting)
+}
+
+func GetQuotaLedgerSetting() *QuotaLedgerSetting {
+	return &quotaLedgerSetting
+}
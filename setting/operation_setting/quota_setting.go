@@ -4,6 +4,7 @@ import "github.com/QuantumNous/new-api/setting/config"
 
 type QuotaSetting struct {
 	EnableFreeModelPreConsume bool `json:"enable_free_model_pre_consume"` // 是否对免费模型启用预消耗
+	FractionalQuotaEnabled    bool `json:"fractional_quota_enabled"`      // 不足 1 额度的消耗按用户令牌累计结转，不再逐次取整
 }
 
 // 默认配置
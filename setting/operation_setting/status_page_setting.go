@@ -0,0 +1,34 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// StatusPageSetting 内置状态页配置，可用率由真实请求与渠道测试结果统计得出
+type StatusPageSetting struct {
+	Enabled           bool     `json:"enabled"`
+	Models            []string `json:"models"` // 展示的模型，为空时展示所有已启用的模型
+	Groups            []string `json:"groups"` // 展示的分组，为空时展示所有用户可选分组
+	ShowLatency       bool     `json:"show_latency"`
+	DegradedThreshold float64  `json:"degraded_threshold"` // 最近两小时可用率低于该值视为性能下降
+	DownThreshold     float64  `json:"down_threshold"`     // 最近两小时可用率低于该值视为不可用
+	RetentionDays     int      `json:"retention_days"`
+}
+
+// 默认配置
+var statusPageSetting = StatusPageSetting{
+	Enabled:           true,
+	Models:            []string{},
+	Groups:            []string{},
+	ShowLatency:       true,
+	DegradedThreshold: 0.99,
+	DownThreshold:     0.9,
+	RetentionDays:     35,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("status_page_setting", &statusPageSetting)
+}
+
+func GetStatusPageSetting() *StatusPageSetting {
+	return &statusPageSetting
+}
@@ -0,0 +1,27 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+type SubscriptionSetting struct {
+	Enabled          bool   `json:"enabled"`            // 是否开放订阅
+	ExpiredGroup     string `json:"expired_group"`      // 订阅到期后用户回到的分组，为空时恢复订阅前的分组
+	GracePeriodHours int    `json:"grace_period_hours"` // 周期结束后等待续费回调的宽限时间
+	ProrateOnChange  bool   `json:"prorate_on_change"`  // 升降级时按剩余周期折算包含额度
+}
+
+// 默认配置
+var subscriptionSetting = SubscriptionSetting{
+	Enabled:          false,
+	ExpiredGroup:     "default",
+	GracePeriodHours: 24,
+	ProrateOnChange:  true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("subscription_setting", &subscriptionSetting)
+}
+
+func GetSubscriptionSetting() *SubscriptionSetting {
+	return &subscriptionSetting
+}
@@ -0,0 +1,90 @@
+package ratio_setting
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// 阶梯计价配置，键为模型名称，例如：
+// {"gemini-2.5-pro": {"context_tiers": [{"min_prompt_tokens": 200000, "model_ratio": 1.25, "completion_ratio": 6}]}}
+var modelPricingTiersMap = make(map[string]*types.ModelPricingTiers)
+var modelPricingTiersMapMutex sync.RWMutex
+
+func ModelPricingTiers2JSONString() string {
+	modelPricingTiersMapMutex.RLock()
+	defer modelPricingTiersMapMutex.RUnlock()
+	jsonBytes, err := common.Marshal(modelPricingTiersMap)
+	if err != nil {
+		common.SysError("error marshalling model pricing tiers: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func parseModelPricingTiers(jsonStr string) (map[string]*types.ModelPricingTiers, error) {
+	tiersMap := make(map[string]*types.ModelPricingTiers)
+	if jsonStr == "" {
+		return tiersMap, nil
+	}
+	if err := common.UnmarshalJsonStr(jsonStr, &tiersMap); err != nil {
+		return nil, err
+	}
+	for name, tiers := range tiersMap {
+		if tiers == nil {
+			delete(tiersMap, name)
+			continue
+		}
+		for _, tier := range tiers.ContextTiers {
+			if tier.MinPromptTokens <= 0 {
+				return nil, fmt.Errorf("模型 %s 的输入长度阈值必须大于 0", name)
+			}
+			if tier.ModelRatio < 0 || tier.CompletionRatio < 0 || tier.CacheRatio < 0 {
+				return nil, fmt.Errorf("模型 %s 的阶梯倍率不能为负数", name)
+			}
+		}
+		for _, tier := range tiers.VolumeTiers {
+			if tier.MinMonthlyTokens < 0 || tier.Multiplier <= 0 {
+				return nil, fmt.Errorf("模型 %s 的用量阈值不能为负数，价格系数必须大于 0", name)
+			}
+		}
+		sort.SliceStable(tiers.ContextTiers, func(i, j int) bool {
+			return tiers.ContextTiers[i].MinPromptTokens < tiers.ContextTiers[j].MinPromptTokens
+		})
+		sort.SliceStable(tiers.VolumeTiers, func(i, j int) bool {
+			return tiers.VolumeTiers[i].MinMonthlyTokens < tiers.VolumeTiers[j].MinMonthlyTokens
+		})
+	}
+	return tiersMap, nil
+}
+
+// CheckModelPricingTiers 校验阶梯计价配置
+func CheckModelPricingTiers(jsonStr string) error {
+	_, err := parseModelPricingTiers(jsonStr)
+	return err
+}
+
+func UpdateModelPricingTiersByJSONString(jsonStr string) error {
+	tiersMap, err := parseModelPricingTiers(jsonStr)
+	if err != nil {
+		return err
+	}
+	modelPricingTiersMapMutex.Lock()
+	modelPricingTiersMap = tiersMap
+	modelPricingTiersMapMutex.Unlock()
+	InvalidateExposedDataCache()
+	return nil
+}
+
+// GetModelPricingTiers 获取模型的阶梯计价配置，返回值不应被修改
+func GetModelPricingTiers(name string) (*types.ModelPricingTiers, bool) {
+	modelPricingTiersMapMutex.RLock()
+	defer modelPricingTiersMapMutex.RUnlock()
+	tiers, ok := modelPricingTiersMap[FormatMatchingModelName(name)]
+	if !ok || (len(tiers.ContextTiers) == 0 && len(tiers.VolumeTiers) == 0) {
+		return nil, false
+	}
+	return tiers, true
+}
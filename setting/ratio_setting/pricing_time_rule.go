@@ -0,0 +1,225 @@
+package ratio_setting
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+const (
+	PricingTimeRuleTargetModel = "model" // 调整模型倍率，按次计费的模型调整模型价格
+	PricingTimeRuleTargetGroup = "group" // 调整分组倍率
+)
+
+// PricingTimeRule 时段定价规则，在有效期内每天的指定时段将模型或分组倍率乘以 Multiplier。
+// 同一目标命中多条规则时只取配置顺序中的第一条
+type PricingTimeRule struct {
+	Name       string   `json:"name"`
+	Target     string   `json:"target"`
+	Multiplier float64  `json:"multiplier"`
+	Models     []string `json:"models,omitempty"`     // 为空表示所有模型，支持以 * 结尾的前缀匹配
+	Groups     []string `json:"groups,omitempty"`     // 为空表示所有分组
+	Timezone   string   `json:"timezone,omitempty"`   // IANA 时区，例如 Asia/Shanghai，为空使用服务器时区
+	Weekdays   []int    `json:"weekdays,omitempty"`   // 0 为周日，为空表示每天；跨天时段按开始当天判断
+	StartTime  string   `json:"start_time,omitempty"` // HH:MM，开始晚于结束表示跨天，均为空表示全天
+	EndTime    string   `json:"end_time,omitempty"`
+	StartAt    int64    `json:"start_at,omitempty"` // 规则有效期，0 表示不限
+	EndAt      int64    `json:"end_at,omitempty"`
+
+	location    *time.Location
+	startMinute int
+	endMinute   int
+}
+
+var pricingTimeRules = make([]*PricingTimeRule, 0)
+var pricingTimeRulesMutex sync.RWMutex
+
+func parseClockMinute(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("时间格式应为 HH:MM：%s", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (rule *PricingTimeRule) init() error {
+	if rule.Name == "" {
+		return errors.New("规则名称不能为空")
+	}
+	if rule.Target != PricingTimeRuleTargetModel && rule.Target != PricingTimeRuleTargetGroup {
+		return fmt.Errorf("规则 %s 的 target 只能是 model 或 group", rule.Name)
+	}
+	if rule.Multiplier < 0 {
+		return fmt.Errorf("规则 %s 的倍率系数不能为负数", rule.Name)
+	}
+	for _, weekday := range rule.Weekdays {
+		if weekday < 0 || weekday > 6 {
+			return fmt.Errorf("规则 %s 的星期应为 0-6", rule.Name)
+		}
+	}
+	rule.location = time.Local
+	if rule.Timezone != "" {
+		location, err := time.LoadLocation(rule.Timezone)
+		if err != nil {
+			return fmt.Errorf("规则 %s 的时区无效：%s", rule.Name, rule.Timezone)
+		}
+		rule.location = location
+	}
+	if (rule.StartTime == "") != (rule.EndTime == "") {
+		return fmt.Errorf("规则 %s 的开始和结束时间需要同时设置", rule.Name)
+	}
+	if rule.StartTime != "" {
+		var err error
+		if rule.startMinute, err = parseClockMinute(rule.StartTime); err != nil {
+			return fmt.Errorf("规则 %s 的%s", rule.Name, err.Error())
+		}
+		if rule.endMinute, err = parseClockMinute(rule.EndTime); err != nil {
+			return fmt.Errorf("规则 %s 的%s", rule.Name, err.Error())
+		}
+	}
+	if rule.StartAt != 0 && rule.EndAt != 0 && rule.EndAt <= rule.StartAt {
+		return fmt.Errorf("规则 %s 的结束时间必须晚于开始时间", rule.Name)
+	}
+	return nil
+}
+
+func (rule *PricingTimeRule) matchWeekday(weekday time.Weekday) bool {
+	if len(rule.Weekdays) == 0 {
+		return true
+	}
+	for _, w := range rule.Weekdays {
+		if time.Weekday(w) == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveAt 规则在 at 时刻是否生效
+func (rule *PricingTimeRule) ActiveAt(at time.Time) bool {
+	if rule.StartAt != 0 && at.Unix() < rule.StartAt {
+		return false
+	}
+	if rule.EndAt != 0 && at.Unix() >= rule.EndAt {
+		return false
+	}
+	local := at.In(rule.location)
+	if rule.StartTime == "" || rule.startMinute == rule.endMinute {
+		return rule.matchWeekday(local.Weekday())
+	}
+	minute := local.Hour()*60 + local.Minute()
+	if rule.startMinute < rule.endMinute {
+		return minute >= rule.startMinute && minute < rule.endMinute && rule.matchWeekday(local.Weekday())
+	}
+	// 跨天时段，凌晨部分属于前一天开始的时段
+	if minute >= rule.startMinute {
+		return rule.matchWeekday(local.Weekday())
+	}
+	if minute < rule.endMinute {
+		return rule.matchWeekday(local.AddDate(0, 0, -1).Weekday())
+	}
+	return false
+}
+
+// MatchModel 规则是否适用于该模型
+func (rule *PricingTimeRule) MatchModel(modelName string) bool {
+	if len(rule.Models) == 0 {
+		return true
+	}
+	for _, pattern := range rule.Models {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(modelName, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if pattern == modelName {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *PricingTimeRule) matchGroup(group string) bool {
+	return len(rule.Groups) == 0 || common.StringsContains(rule.Groups, group)
+}
+
+func parsePricingTimeRules(jsonStr string) ([]*PricingTimeRule, error) {
+	rules := make([]*PricingTimeRule, 0)
+	if strings.TrimSpace(jsonStr) == "" {
+		return rules, nil
+	}
+	if err := common.UnmarshalJsonStr(jsonStr, &rules); err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if rule == nil {
+			return nil, errors.New("规则不能为空")
+		}
+		if err := rule.init(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+func PricingTimeRules2JSONString() string {
+	pricingTimeRulesMutex.RLock()
+	defer pricingTimeRulesMutex.RUnlock()
+	jsonBytes, err := common.Marshal(pricingTimeRules)
+	if err != nil {
+		common.SysError("error marshalling pricing time rules: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+// CheckPricingTimeRules 校验时段定价规则
+func CheckPricingTimeRules(jsonStr string) error {
+	_, err := parsePricingTimeRules(jsonStr)
+	return err
+}
+
+func UpdatePricingTimeRulesByJSONString(jsonStr string) error {
+	rules, err := parsePricingTimeRules(jsonStr)
+	if err != nil {
+		return err
+	}
+	pricingTimeRulesMutex.Lock()
+	pricingTimeRules = rules
+	pricingTimeRulesMutex.Unlock()
+	return nil
+}
+
+// GetPricingTimeRules 获取全部时段定价规则，返回值不应被修改
+func GetPricingTimeRules() []*PricingTimeRule {
+	pricingTimeRulesMutex.RLock()
+	defer pricingTimeRulesMutex.RUnlock()
+	return pricingTimeRules
+}
+
+// GetPricingTimeDiscount 获取 at 时刻对该模型和分组生效的时段定价系数，模型和分组各取第一条命中的规则，
+// at 为零值时使用当前时间
+func GetPricingTimeDiscount(modelName string, group string, at time.Time) types.PricingTimeDiscount {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	discount := types.PricingTimeDiscount{ModelMultiplier: 1, GroupMultiplier: 1}
+	pricingTimeRulesMutex.RLock()
+	defer pricingTimeRulesMutex.RUnlock()
+	for _, rule := range pricingTimeRules {
+		if !rule.MatchModel(modelName) || !rule.matchGroup(group) || !rule.ActiveAt(at) {
+			continue
+		}
+		if rule.Target == PricingTimeRuleTargetModel && discount.ModelRule == "" {
+			discount.ModelRule = rule.Name
+			discount.ModelMultiplier = rule.Multiplier
+		} else if rule.Target == PricingTimeRuleTargetGroup && discount.GroupRule == "" {
+			discount.GroupRule = rule.Name
+			discount.GroupMultiplier = rule.Multiplier
+		}
+	}
+	return discount
+}
@@ -0,0 +1,14 @@
+package ratio_setting
+
+import "sync/atomic"
+
+// currentPricingVersion 当前生效的定价版本，0 表示尚未记录版本
+var currentPricingVersion atomic.Int64
+
+func SetPricingVersion(id int) {
+	currentPricingVersion.Store(int64(id))
+}
+
+func GetPricingVersion() int {
+	return int(currentPricingVersion.Load())
+}
@@ -0,0 +1,48 @@
+package ratio_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// RatioSyncUpstream 定时同步的上游倍率来源
+type RatioSyncUpstream struct {
+	Name     string `json:"name"`
+	BaseURL  string `json:"base_url"`
+	Endpoint string `json:"endpoint"`
+}
+
+// RatioSyncSetting 定时同步上游倍率，差异生成待审核的变更集，满足规则的变更自动应用
+type RatioSyncSetting struct {
+	Enabled            bool                `json:"enabled"`
+	IntervalMinutes    float64             `json:"interval_minutes"`
+	Upstreams          []RatioSyncUpstream `json:"upstreams"`          // 按优先级排列，同一模型取第一个给出可信数值的上游
+	MaxChangePercent   float64             `json:"max_change_percent"` // 变动幅度超过该百分比的变更只能人工审核，0 表示不限制
+	AutoApplyVendors   []string            `json:"auto_apply_vendors"` // 这些供应商的模型变更自动应用
+	AutoApplyNewModels bool                `json:"auto_apply_new_models"`
+	Notify             bool                `json:"notify"`
+	RetentionDays      int                 `json:"retention_days"` // 已处理变更集的保留天数，审计日志不清理
+}
+
+// 默认配置
+var ratioSyncSetting = RatioSyncSetting{
+	Enabled:         false,
+	IntervalMinutes: 1440,
+	Upstreams: []RatioSyncUpstream{
+		{
+			Name:     "官方倍率预设",
+			BaseURL:  "https://basellm.github.io",
+			Endpoint: "/llm-metadata/api/newapi/ratio_config-v1-base.json",
+		},
+	},
+	MaxChangePercent:   50,
+	AutoApplyVendors:   []string{},
+	AutoApplyNewModels: false,
+	Notify:             true,
+	RetentionDays:      90,
+}
+
+func init() {
+	config.GlobalConfig.Register("ratio_sync_setting", &ratioSyncSetting)
+}
+
+func GetRatioSyncSetting() *RatioSyncSetting {
+	return &ratioSyncSetting
+}
@@ -24,12 +24,17 @@ type PriceData struct {
 	UsePrice             bool
 	QuotaToPreConsume    int // 预消耗额度
 	GroupRatioInfo       GroupRatioInfo
+	PricingVersion       int // 计价时生效的定价版本
+	TierInfo             PricingTierInfo
+	TimeDiscount         PricingTimeDiscount
 }
 
 type PerCallPriceData struct {
 	ModelPrice     float64
 	Quota          int
 	GroupRatioInfo GroupRatioInfo
+	PricingVersion int
+	TimeDiscount   PricingTimeDiscount
 }
 
 func (p PriceData) ToSetting() string {
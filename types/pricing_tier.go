@@ -0,0 +1,106 @@
+package types
+
+// ContextPricingTier 按输入长度分档的倍率，输入 tokens 超过 MinPromptTokens 时整次请求按该档计费，
+// 倍率为 0 表示沿用基础配置
+type ContextPricingTier struct {
+	MinPromptTokens int     `json:"min_prompt_tokens"`
+	ModelRatio      float64 `json:"model_ratio"`
+	CompletionRatio float64 `json:"completion_ratio"`
+	CacheRatio      float64 `json:"cache_ratio"`
+}
+
+// VolumePricingTier 按用户当月在该模型上的累计 tokens 分档，达到 MinMonthlyTokens 后价格乘以 Multiplier
+type VolumePricingTier struct {
+	MinMonthlyTokens int64   `json:"min_monthly_tokens"`
+	Multiplier       float64 `json:"multiplier"`
+}
+
+// ModelPricingTiers 单个模型的阶梯计价配置，各档位按阈值升序排列
+type ModelPricingTiers struct {
+	ContextTiers []ContextPricingTier `json:"context_tiers,omitempty"`
+	VolumeTiers  []VolumePricingTier  `json:"volume_tiers,omitempty"`
+}
+
+// VolumeMultiplier 返回当月累计用量对应的价格系数，未命中任何档位时为 1
+func (t *ModelPricingTiers) VolumeMultiplier(monthlyTokens int64) float64 {
+	multiplier := 1.0
+	for _, tier := range t.VolumeTiers {
+		if monthlyTokens < tier.MinMonthlyTokens {
+			break
+		}
+		multiplier = tier.Multiplier
+	}
+	return multiplier
+}
+
+// PricingTierInfo 本次请求的阶梯计价依据，预扣费时按估算的输入长度确定档位，结算时按实际输入长度重新确定
+type PricingTierInfo struct {
+	ContextTiers        []ContextPricingTier
+	HasVolumeTiers      bool
+	BaseModelRatio      float64
+	BaseCompletionRatio float64
+	BaseCacheRatio      float64
+	ContextTier         int // 命中的输入长度档位，从 1 开始，0 表示基础价格
+	ContextTokens       int
+	MonthlyTokens       int64
+	VolumeMultiplier    float64
+}
+
+// Resolve 按输入 tokens 确定档位，返回叠加用量系数后的模型、补全和缓存倍率
+func (info *PricingTierInfo) Resolve(promptTokens int) (modelRatio float64, completionRatio float64, cacheRatio float64) {
+	modelRatio, completionRatio, cacheRatio = info.BaseModelRatio, info.BaseCompletionRatio, info.BaseCacheRatio
+	info.ContextTokens = promptTokens
+	info.ContextTier = 0
+	for i, tier := range info.ContextTiers {
+		if promptTokens <= tier.MinPromptTokens {
+			break
+		}
+		info.ContextTier = i + 1
+	}
+	if info.ContextTier > 0 {
+		tier := info.ContextTiers[info.ContextTier-1]
+		if tier.ModelRatio > 0 {
+			modelRatio = tier.ModelRatio
+		}
+		if tier.CompletionRatio > 0 {
+			completionRatio = tier.CompletionRatio
+		}
+		if tier.CacheRatio > 0 {
+			cacheRatio = tier.CacheRatio
+		}
+	}
+	if info.VolumeMultiplier > 0 {
+		modelRatio *= info.VolumeMultiplier
+	}
+	return modelRatio, completionRatio, cacheRatio
+}
+
+// Enabled 是否配置了阶梯计价
+func (info *PricingTierInfo) Enabled() bool {
+	return len(info.ContextTiers) > 0 || info.HasVolumeTiers
+}
+
+// ApplyContextTier 按实际输入 tokens 重新确定输入长度档位并更新倍率，按次计费或未配置分档时不做处理。
+// 时段定价按请求开始时间确定，重新计算档位后仍沿用预扣费时的系数
+func (p *PriceData) ApplyContextTier(promptTokens int) {
+	if p.UsePrice || len(p.TierInfo.ContextTiers) == 0 {
+		return
+	}
+	p.ModelRatio, p.CompletionRatio, p.CacheRatio = p.TierInfo.Resolve(promptTokens)
+	if p.TimeDiscount.ModelMultiplier > 0 {
+		p.ModelRatio *= p.TimeDiscount.ModelMultiplier
+	}
+}
+
+// PricingTimeDiscount 按请求开始时间命中的时段定价规则，未命中时系数为 1
+type PricingTimeDiscount struct {
+	ModelRule       string
+	ModelMultiplier float64
+	GroupRule       string
+	GroupMultiplier float64
+}
+
+// Matched 是否命中了时段定价规则
+func (d PricingTimeDiscount) Matched() bool {
+	return d.ModelRule != "" || d.GroupRule != ""
+}
@@ -37,6 +37,8 @@ import Redemption from './pages/Redemption';
 import TopUp from './pages/TopUp';
 import Log from './pages/Log';
 import ErrorLog from './pages/ErrorLog';
+import Inflight from './pages/Inflight';
+import Organization from './pages/Organization';
 import Chat from './pages/Chat';
 import Chat2Link from './pages/Chat2Link';
 import Midjourney from './pages/Midjourney';
@@ -53,6 +55,7 @@ import CheckinSetting from './pages/Checkin';
 const Home = lazy(() => import('./pages/Home'));
 const Dashboard = lazy(() => import('./pages/Dashboard'));
 const About = lazy(() => import('./pages/About'));
+const Status = lazy(() => import('./pages/Status'));
 const UserAgreement = lazy(() => import('./pages/UserAgreement'));
 const PrivacyPolicy = lazy(() => import('./pages/PrivacyPolicy'));
 
@@ -126,6 +129,14 @@ function App() {
             </PrivateRoute>
           }
         />
+        <Route
+          path='/console/organization'
+          element={
+            <PrivateRoute>
+              <Organization />
+            </PrivateRoute>
+          }
+        />
         <Route
           path='/console/playground'
           element={
@@ -274,6 +285,14 @@ function App() {
             </AdminRoute>
           }
         />
+        <Route
+          path='/console/inflight'
+          element={
+            <AdminRoute>
+              <Inflight />
+            </AdminRoute>
+          }
+        />
         <Route
           path='/console'
           element={
@@ -331,6 +350,14 @@ function App() {
             </Suspense>
           }
         />
+        <Route
+          path='/status'
+          element={
+            <Suspense fallback={<Loading></Loading>} key={location.pathname}>
+              <Status />
+            </Suspense>
+          }
+        />
         <Route
           path='/user-agreement'
           element={
@@ -36,9 +36,11 @@ const routerMap = {
   token: '/console/token',
   redemption: '/console/redemption',
   topup: '/console/topup',
+  organization: '/console/organization',
   user: '/console/user',
   log: '/console/log',
   errorLog: '/console/error-log',
+  inflight: '/console/inflight',
   midjourney: '/console/midjourney',
   setting: '/console/setting',
   checkin: '/console/checkin',
@@ -129,6 +131,11 @@ const SiderBar = ({ onNavigate = () => {} }) => {
         itemKey: 'topup',
         to: '/topup',
       },
+      {
+        text: t('团队管理'),
+        itemKey: 'organization',
+        to: '/console/organization',
+      },
       {
         text: t('个人设置'),
         itemKey: 'personal',
@@ -177,6 +184,12 @@ const SiderBar = ({ onNavigate = () => {} }) => {
         to: '/console/error-log',
         className: isAdmin() ? '' : 'tableHiddle',
       },
+      {
+        text: t('进行中的请求'),
+        itemKey: 'inflight',
+        to: '/console/inflight',
+        className: isAdmin() ? '' : 'tableHiddle',
+      },
       {
         text: t('系统设置'),
         itemKey: 'setting',
@@ -25,6 +25,8 @@ import SettingsAnnouncements from '../../pages/Setting/Dashboard/SettingsAnnounc
 import SettingsFAQ from '../../pages/Setting/Dashboard/SettingsFAQ';
 import SettingsUptimeKuma from '../../pages/Setting/Dashboard/SettingsUptimeKuma';
 import SettingsDataDashboard from '../../pages/Setting/Dashboard/SettingsDataDashboard';
+import SettingsStatusPage from '../../pages/Setting/Dashboard/SettingsStatusPage';
+import SettingsStatusIncidents from '../../pages/Setting/Dashboard/SettingsStatusIncidents';
 
 const DashboardSetting = () => {
   let [inputs, setInputs] = useState({
@@ -37,6 +39,15 @@ const DashboardSetting = () => {
     'console_setting.faq_enabled': '',
     'console_setting.uptime_kuma_enabled': '',
 
+    /* 状态页 */
+    'status_page_setting.enabled': '',
+    'status_page_setting.models': '',
+    'status_page_setting.groups': '',
+    'status_page_setting.show_latency': '',
+    'status_page_setting.degraded_threshold': '',
+    'status_page_setting.down_threshold': '',
+    'status_page_setting.retention_days': '',
+
     // 用于迁移检测的旧键，下个版本会删除
     ApiInfo: '',
     Announcements: '',
@@ -165,6 +176,16 @@ const DashboardSetting = () => {
         <Card style={{ marginTop: '10px' }}>
           <SettingsUptimeKuma options={inputs} refresh={onRefresh} />
         </Card>
+
+        {/* 内置状态页设置 */}
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsStatusPage options={inputs} refresh={onRefresh} />
+        </Card>
+
+        {/* 故障与维护公告 */}
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsStatusIncidents />
+        </Card>
       </Spin>
     </>
   );
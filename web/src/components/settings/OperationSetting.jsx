@@ -26,6 +26,9 @@ import SettingsSensitiveWords from '../../pages/Setting/Operation/SettingsSensit
 import SettingsLog from '../../pages/Setting/Operation/SettingsLog';
 import SettingsMonitoring from '../../pages/Setting/Operation/SettingsMonitoring';
 import SettingsCreditLimit from '../../pages/Setting/Operation/SettingsCreditLimit';
+import SettingsQuotaLedger from '../../pages/Setting/Operation/SettingsQuotaLedger';
+import SettingsSubscription from '../../pages/Setting/Operation/SettingsSubscription';
+import SettingsPostpaid from '../../pages/Setting/Operation/SettingsPostpaid';
 import { API, showError, toBoolean } from '../../helpers';
 
 const OperationSetting = () => {
@@ -36,6 +39,21 @@ const OperationSetting = () => {
     QuotaForInviter: 0,
     QuotaForInvitee: 0,
     'quota_setting.enable_free_model_pre_consume': true,
+    'quota_setting.fractional_quota_enabled': false,
+
+    /* 额度流水与对账 */
+    'quota_ledger_setting.enabled': true,
+    'quota_ledger_setting.reconcile_enabled': true,
+    'quota_ledger_setting.reconcile_interval_minutes': 60,
+    'quota_ledger_setting.open_missing_accounts': true,
+    'quota_ledger_setting.notify_on_drift': true,
+    'subscription_setting.enabled': false,
+    'subscription_setting.expired_group': 'default',
+    'subscription_setting.grace_period_hours': 24,
+    'subscription_setting.prorate_on_change': true,
+    'postpaid_setting.enabled': false,
+    'postpaid_setting.due_days': 15,
+    'postpaid_setting.auto_suspend': true,
 
     /* 通用设置 */
     TopUpLink: '',
@@ -71,6 +89,24 @@ const OperationSetting = () => {
     AutomaticDisableKeywords: '',
     'monitor_setting.auto_test_channel_enabled': false,
     'monitor_setting.auto_test_channel_minutes': 10,
+    'monitor_setting.model_verify_enabled': false,
+    'monitor_setting.model_verify_minutes': 360,
+    'monitor_setting.model_verify_threshold': 0.6,
+    'monitor_setting.model_verify_auto_disable': false,
+    'monitor_setting.model_verify_notify': true,
+    'monitor_setting.model_verify_retention_days': 30,
+    'monitor_setting.capability_test_enabled': false,
+    'monitor_setting.capability_test_minutes': 60,
+    'monitor_setting.capability_test_matrix': '',
+    'monitor_setting.capability_test_long_context_tokens': 32000,
+    'monitor_setting.capability_test_retention_days': 30,
+    'monitor_setting.model_sync_enabled': false,
+    'monitor_setting.model_sync_minutes': 1440,
+    'monitor_setting.model_sync_auto_add': false,
+    'monitor_setting.model_sync_allow_patterns': '',
+    'monitor_setting.model_sync_auto_remove': false,
+    'monitor_setting.model_sync_notify': true,
+    'monitor_setting.model_sync_retention_days': 90,
   });
 
   let [loading, setLoading] = useState(false);
@@ -140,6 +176,18 @@ const OperationSetting = () => {
         <Card style={{ marginTop: '10px' }}>
           <SettingsCreditLimit options={inputs} refresh={onRefresh} />
         </Card>
+        {/* 额度流水与对账 */}
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsQuotaLedger options={inputs} refresh={onRefresh} />
+        </Card>
+        {/* 订阅套餐 */}
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsSubscription options={inputs} refresh={onRefresh} />
+        </Card>
+        {/* 后付费授信 */}
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsPostpaid options={inputs} refresh={onRefresh} />
+        </Card>
       </Spin>
     </>
   );
@@ -26,6 +26,9 @@ import ModelRatioSettings from '../../pages/Setting/Ratio/ModelRatioSettings';
 import ModelSettingsVisualEditor from '../../pages/Setting/Ratio/ModelSettingsVisualEditor';
 import ModelRatioNotSetEditor from '../../pages/Setting/Ratio/ModelRationNotSetEditor';
 import UpstreamRatioSync from '../../pages/Setting/Ratio/UpstreamRatioSync';
+import RatioSyncScheduleSettings from '../../pages/Setting/Ratio/RatioSyncScheduleSettings';
+import RatioChangeReview from '../../pages/Setting/Ratio/RatioChangeReview';
+import PricingVersions from '../../pages/Setting/Ratio/PricingVersions';
 
 import { API, showError, toBoolean } from '../../helpers';
 
@@ -42,6 +45,8 @@ const RatioSetting = () => {
     ImageRatio: '',
     AudioRatio: '',
     AudioCompletionRatio: '',
+    ModelPricingTiers: '',
+    PricingTimeRules: '',
     AutoGroups: '',
     DefaultUseAutoGroup: false,
     ExposeRatioEnabled: false,
@@ -114,6 +119,13 @@ const RatioSetting = () => {
           <Tabs.TabPane tab={t('上游倍率同步')} itemKey='upstream_sync'>
             <UpstreamRatioSync options={inputs} refresh={onRefresh} />
           </Tabs.TabPane>
+          <Tabs.TabPane tab={t('定时同步与审核')} itemKey='scheduled_sync'>
+            <RatioSyncScheduleSettings options={inputs} refresh={onRefresh} />
+            <RatioChangeReview />
+          </Tabs.TabPane>
+          <Tabs.TabPane tab={t('定价版本')} itemKey='pricing_versions'>
+            <PricingVersions options={inputs} refresh={onRefresh} />
+          </Tabs.TabPane>
         </Tabs>
       </Card>
     </Spin>
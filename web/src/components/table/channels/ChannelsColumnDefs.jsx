@@ -207,6 +207,8 @@ export const getChannelsColumns = ({
   channels,
   setShowMultiKeyManageModal,
   setCurrentMultiKeyChannel,
+  setModelVerifyChannel,
+  setCapabilityTestChannel,
 }) => {
   return [
     {
@@ -517,6 +519,18 @@ export const getChannelsColumns = ({
                 });
               },
             },
+            {
+              node: 'item',
+              name: t('模型验真'),
+              type: 'tertiary',
+              onClick: () => setModelVerifyChannel(record),
+            },
+            {
+              node: 'item',
+              name: t('能力测试'),
+              type: 'tertiary',
+              onClick: () => setCapabilityTestChannel(record),
+            },
           ];
 
           return (
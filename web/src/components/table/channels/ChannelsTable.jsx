@@ -60,6 +60,8 @@ const ChannelsTable = (channelsData) => {
     // Multi-key management
     setShowMultiKeyManageModal,
     setCurrentMultiKeyChannel,
+    setModelVerifyChannel,
+    setCapabilityTestChannel,
   } = channelsData;
 
   // Get all columns
@@ -84,6 +86,8 @@ const ChannelsTable = (channelsData) => {
       channels,
       setShowMultiKeyManageModal,
       setCurrentMultiKeyChannel,
+      setModelVerifyChannel,
+      setCapabilityTestChannel,
     });
   }, [
     t,
@@ -105,6 +109,8 @@ const ChannelsTable = (channelsData) => {
     channels,
     setShowMultiKeyManageModal,
     setCurrentMultiKeyChannel,
+    setModelVerifyChannel,
+    setCapabilityTestChannel,
   ]);
 
   // Filter columns based on visibility settings
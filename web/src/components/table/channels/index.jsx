@@ -31,6 +31,9 @@ import ColumnSelectorModal from './modals/ColumnSelectorModal';
 import EditChannelModal from './modals/EditChannelModal';
 import EditTagModal from './modals/EditTagModal';
 import MultiKeyManageModal from './modals/MultiKeyManageModal';
+import ModelVerifyModal from './modals/ModelVerifyModal';
+import CapabilityTestModal from './modals/CapabilityTestModal';
+import ModelSyncModal from './modals/ModelSyncModal';
 import { createCardProPagination } from '../../../helpers/utils';
 
 const ChannelsPage = () => {
@@ -61,6 +64,21 @@ const ChannelsPage = () => {
         channel={channelsData.currentMultiKeyChannel}
         onRefresh={channelsData.refresh}
       />
+      <ModelVerifyModal
+        visible={!!channelsData.modelVerifyChannel}
+        onCancel={() => channelsData.setModelVerifyChannel(null)}
+        channel={channelsData.modelVerifyChannel}
+      />
+      <CapabilityTestModal
+        visible={!!channelsData.capabilityTestChannel}
+        onCancel={() => channelsData.setCapabilityTestChannel(null)}
+        channel={channelsData.capabilityTestChannel}
+      />
+      <ModelSyncModal
+        visible={channelsData.showModelSync}
+        onCancel={() => channelsData.setShowModelSync(false)}
+        refresh={channelsData.refresh}
+      />
 
       {/* Main Content */}
       <CardPro
@@ -0,0 +1,331 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  Button,
+  Modal,
+  Select,
+  Space,
+  Table,
+  Tabs,
+  TabPane,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import {
+  API,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../../../helpers';
+
+const { Text } = Typography;
+
+const CAPABILITY_NAMES = {
+  stream: '流式输出',
+  tool_call: '工具调用',
+  vision: '图像输入',
+  json_mode: 'JSON 模式',
+  embedding: '向量嵌入',
+  long_context: '长上下文',
+};
+
+const CapabilityTestModal = ({ visible, onCancel, channel }) => {
+  const { t } = useTranslation();
+  const [days, setDays] = useState(7);
+  const [testing, setTesting] = useState(false);
+  const [summaries, setSummaries] = useState([]);
+  const [trend, setTrend] = useState({ bucket_seconds: 86400, items: [] });
+  const [history, setHistory] = useState([]);
+  const [historyTotal, setHistoryTotal] = useState(0);
+  const [historyPage, setHistoryPage] = useState(1);
+  const [capability, setCapability] = useState('');
+  const pageSize = 10;
+
+  const capabilityName = (key) => t(CAPABILITY_NAMES[key] || key);
+
+  const loadSummary = async () => {
+    const res = await API.get(
+      `/api/channel/capability/${channel.id}?days=${days}`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setSummaries(data || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const loadTrend = async () => {
+    const res = await API.get(
+      `/api/channel/capability/${channel.id}/trend?days=${days}`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setTrend(data);
+    } else {
+      showError(message);
+    }
+  };
+
+  const loadHistory = async (page = historyPage) => {
+    const res = await API.get(
+      `/api/channel/capability/${channel.id}/history?capability=${capability}&p=${page}&page_size=${pageSize}`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setHistory(data.items || []);
+      setHistoryTotal(data.total || 0);
+    } else {
+      showError(message);
+    }
+  };
+
+  const runTest = async () => {
+    setTesting(true);
+    try {
+      const res = await API.post(`/api/channel/capability/${channel.id}`);
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t('能力测试完成'));
+        setHistoryPage(1);
+        await Promise.all([loadSummary(), loadTrend(), loadHistory(1)]);
+      } else {
+        showError(message);
+      }
+    } finally {
+      setTesting(false);
+    }
+  };
+
+  useEffect(() => {
+    if (visible && channel) {
+      loadSummary();
+      loadTrend();
+    }
+  }, [visible, channel, days]);
+
+  useEffect(() => {
+    if (visible && channel) {
+      loadHistory(historyPage);
+    }
+  }, [visible, channel, capability, historyPage]);
+
+  // 将趋势数据整理为按时间分行、按能力分列的表格
+  const trendCapabilities = [
+    ...new Set(trend.items.map((item) => item.capability)),
+  ];
+  const trendRows = Object.values(
+    trend.items.reduce((rows, item) => {
+      const row = rows[item.bucket] || { bucket: item.bucket };
+      row[item.capability] = item;
+      rows[item.bucket] = row;
+      return rows;
+    }, {}),
+  ).sort((a, b) => b.bucket - a.bucket);
+
+  const renderUptime = (item) => {
+    if (!item || item.total === 0) return '-';
+    const uptime = (item.passed / item.total) * 100;
+    const color = uptime >= 99 ? 'green' : uptime >= 90 ? 'orange' : 'red';
+    return (
+      <Tag color={color}>
+        {`${uptime.toFixed(1)}% (${item.passed}/${item.total})`}
+      </Tag>
+    );
+  };
+
+  const summaryColumns = [
+    {
+      title: t('能力'),
+      dataIndex: 'capability',
+      render: (text, record) => (
+        <Space>
+          {capabilityName(text)}
+          {record.in_matrix && <Tag size='small'>{t('测试矩阵')}</Tag>}
+        </Space>
+      ),
+    },
+    {
+      title: t('最近结果'),
+      dataIndex: 'latest',
+      render: (latest) =>
+        latest ? (
+          <Space>
+            <Tag color={latest.success ? 'green' : 'red'}>
+              {latest.success ? t('支持') : t('失败')}
+            </Tag>
+            <Text type='tertiary' size='small'>
+              {timestamp2string(latest.created_at)}
+            </Text>
+          </Space>
+        ) : (
+          <Text type='tertiary'>{t('未测试')}</Text>
+        ),
+    },
+    {
+      title: t('通过率'),
+      dataIndex: 'uptime',
+      render: (text, record) => renderUptime(record),
+    },
+    {
+      title: t('原因'),
+      dataIndex: 'message',
+      render: (text, record) => (
+        <Text ellipsis={{ showTooltip: true }} style={{ maxWidth: 260 }}>
+          {record.latest?.message || '-'}
+        </Text>
+      ),
+    },
+  ];
+
+  const trendColumns = [
+    {
+      title: t('时间'),
+      dataIndex: 'bucket',
+      render: (text) =>
+        trend.bucket_seconds >= 86400
+          ? timestamp2string(text).slice(0, 10)
+          : timestamp2string(text).slice(0, 16),
+    },
+    ...trendCapabilities.map((key) => ({
+      title: capabilityName(key),
+      dataIndex: key,
+      render: (item) => renderUptime(item),
+    })),
+  ];
+
+  const historyColumns = [
+    {
+      title: t('时间'),
+      dataIndex: 'created_at',
+      render: (text) => timestamp2string(text),
+    },
+    {
+      title: t('能力'),
+      dataIndex: 'capability',
+      render: (text) => capabilityName(text),
+    },
+    {
+      title: t('模型'),
+      dataIndex: 'model_name',
+    },
+    {
+      title: t('结果'),
+      dataIndex: 'success',
+      render: (text, record) => (
+        <Tag color={text ? 'green' : 'red'}>
+          {text ? t('支持') : `${t('失败')} ${record.status_code || ''}`}
+        </Tag>
+      ),
+    },
+    {
+      title: t('用时'),
+      dataIndex: 'use_time_ms',
+      render: (text) => `${(text / 1000).toFixed(2)} s`,
+    },
+    {
+      title: t('原因'),
+      dataIndex: 'message',
+      render: (text) => (
+        <Text ellipsis={{ showTooltip: true }} style={{ maxWidth: 240 }}>
+          {text || '-'}
+        </Text>
+      ),
+    },
+  ];
+
+  return (
+    <Modal
+      title={`${t('能力测试')} - ${channel?.name || ''}`}
+      visible={visible}
+      onCancel={onCancel}
+      footer={null}
+      width={960}
+    >
+      <Space className='mb-3'>
+        <Select
+          value={days}
+          onChange={setDays}
+          style={{ width: 140 }}
+          optionList={[1, 7, 30, 90].map((d) => ({
+            label: t('最近 {{days}} 天', { days: d }),
+            value: d,
+          }))}
+        />
+        <Button type='primary' loading={testing} onClick={runTest}>
+          {t('立即测试')}
+        </Button>
+      </Space>
+      <Tabs type='line'>
+        <TabPane tab={t('概览')} itemKey='summary'>
+          <Table
+            columns={summaryColumns}
+            dataSource={summaries}
+            rowKey='capability'
+            pagination={false}
+            size='small'
+          />
+        </TabPane>
+        <TabPane tab={t('趋势')} itemKey='trend'>
+          <Table
+            columns={trendColumns}
+            dataSource={trendRows}
+            rowKey='bucket'
+            pagination={false}
+            size='small'
+          />
+        </TabPane>
+        <TabPane tab={t('历史')} itemKey='history'>
+          <Select
+            className='mb-2'
+            value={capability}
+            onChange={(value) => {
+              setCapability(value || '');
+              setHistoryPage(1);
+            }}
+            placeholder={t('全部能力')}
+            showClear
+            style={{ width: 200 }}
+            optionList={Object.keys(CAPABILITY_NAMES).map((key) => ({
+              label: capabilityName(key),
+              value: key,
+            }))}
+          />
+          <Table
+            columns={historyColumns}
+            dataSource={history}
+            rowKey='id'
+            size='small'
+            pagination={{
+              currentPage: historyPage,
+              pageSize,
+              total: historyTotal,
+              onPageChange: setHistoryPage,
+            }}
+          />
+        </TabPane>
+      </Tabs>
+    </Modal>
+  );
+};
+
+export default CapabilityTestModal;
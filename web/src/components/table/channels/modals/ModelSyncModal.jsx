@@ -0,0 +1,298 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  Button,
+  Modal,
+  Space,
+  Table,
+  Tabs,
+  TabPane,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import {
+  API,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../../../helpers';
+
+const { Text } = Typography;
+
+const STATUS_TAGS = {
+  synced: { color: 'green', text: '已同步' },
+  reported: { color: 'orange', text: '待处理' },
+  error: { color: 'red', text: '失败' },
+};
+
+const splitList = (value) => (value ? value.split(',') : []);
+
+const ModelSyncModal = ({ visible, onCancel, refresh }) => {
+  const { t } = useTranslation();
+  const [reports, setReports] = useState([]);
+  const [reportLoading, setReportLoading] = useState(false);
+  const [syncingId, setSyncingId] = useState(0);
+  const [history, setHistory] = useState([]);
+  const [historyTotal, setHistoryTotal] = useState(0);
+  const [historyPage, setHistoryPage] = useState(1);
+  const pageSize = 10;
+
+  const renderModels = (models, color) =>
+    models && models.length > 0 ? (
+      <div className='flex flex-wrap gap-1' style={{ maxWidth: 320 }}>
+        {models.map((m) => (
+          <Tag key={m} color={color} size='small'>
+            {m}
+          </Tag>
+        ))}
+      </div>
+    ) : (
+      '-'
+    );
+
+  const loadReport = async () => {
+    setReportLoading(true);
+    try {
+      const res = await API.get('/api/channel/model_sync/report');
+      const { success, message, data } = res.data;
+      if (success) {
+        setReports(data || []);
+      } else {
+        showError(message);
+      }
+    } finally {
+      setReportLoading(false);
+    }
+  };
+
+  const loadHistory = async (page = historyPage) => {
+    const res = await API.get(
+      `/api/channel/model_sync?p=${page}&page_size=${pageSize}`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setHistory(data.items || []);
+      setHistoryTotal(data.total || 0);
+    } else {
+      showError(message);
+    }
+  };
+
+  const syncChannel = async (channelId) => {
+    setSyncingId(channelId);
+    try {
+      const res = await API.post(`/api/channel/model_sync/${channelId}`);
+      const { success, message, data } = res.data;
+      if (success) {
+        showSuccess(t('同步完成'));
+        setReports(
+          reports.map((item) => (item.channel_id === channelId ? data : item)),
+        );
+        loadHistory(1);
+        setHistoryPage(1);
+        refresh();
+      } else {
+        showError(message);
+      }
+    } finally {
+      setSyncingId(0);
+    }
+  };
+
+  const syncAll = async () => {
+    const res = await API.post('/api/channel/model_sync');
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('已在后台开始同步，完成后可在同步记录中查看'));
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    if (visible) {
+      loadHistory(historyPage);
+    }
+  }, [visible, historyPage]);
+
+  const reportColumns = [
+    {
+      title: t('渠道'),
+      dataIndex: 'channel_name',
+      render: (text, record) => `#${record.channel_id} ${text}`,
+    },
+    {
+      title: t('上游新增'),
+      dataIndex: 'added',
+      render: (models, record) =>
+        record.error ? (
+          <Text type='danger' ellipsis={{ showTooltip: true }}>
+            {record.error}
+          </Text>
+        ) : (
+          renderModels(models, 'green')
+        ),
+    },
+    {
+      title: t('上游已移除'),
+      dataIndex: 'removed',
+      render: (models) => renderModels(models, 'red'),
+    },
+    {
+      title: t('将执行'),
+      dataIndex: 'to_add',
+      render: (toAdd, record) =>
+        toAdd.length === 0 && record.to_remove.length === 0 ? (
+          <Text type='tertiary'>{t('无')}</Text>
+        ) : (
+          <Text size='small'>
+            {toAdd.length > 0 && `+${toAdd.length} `}
+            {record.to_remove.length > 0 && `-${record.to_remove.length}`}
+          </Text>
+        ),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (text, record) => (
+        <Button
+          size='small'
+          disabled={
+            !!record.error ||
+            (record.to_add.length === 0 && record.to_remove.length === 0)
+          }
+          loading={syncingId === record.channel_id}
+          onClick={() => syncChannel(record.channel_id)}
+        >
+          {t('执行同步')}
+        </Button>
+      ),
+    },
+  ];
+
+  const historyColumns = [
+    {
+      title: t('时间'),
+      dataIndex: 'created_at',
+      render: (text) => timestamp2string(text),
+    },
+    {
+      title: t('渠道'),
+      dataIndex: 'channel_name',
+      render: (text, record) => `#${record.channel_id} ${text}`,
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'status',
+      render: (text, record) => {
+        const tag = STATUS_TAGS[text] || { color: 'grey', text };
+        return record.message ? (
+          <Space>
+            <Tag color={tag.color}>{t(tag.text)}</Tag>
+            <Text
+              type='tertiary'
+              size='small'
+              ellipsis={{ showTooltip: true }}
+              style={{ maxWidth: 160 }}
+            >
+              {record.message}
+            </Text>
+          </Space>
+        ) : (
+          <Tag color={tag.color}>{t(tag.text)}</Tag>
+        );
+      },
+    },
+    {
+      title: t('上游新增'),
+      dataIndex: 'added_models',
+      render: (text) => renderModels(splitList(text), 'green'),
+    },
+    {
+      title: t('上游已移除'),
+      dataIndex: 'removed_models',
+      render: (text) => renderModels(splitList(text), 'red'),
+    },
+    {
+      title: t('已自动处理'),
+      dataIndex: 'applied_added',
+      render: (text, record) =>
+        text || record.applied_removed ? (
+          <Text size='small'>
+            {text && `+${splitList(text).length} `}
+            {record.applied_removed &&
+              `-${splitList(record.applied_removed).length}`}
+          </Text>
+        ) : (
+          '-'
+        ),
+    },
+  ];
+
+  return (
+    <Modal
+      title={t('同步上游模型')}
+      visible={visible}
+      onCancel={onCancel}
+      footer={null}
+      width={1000}
+    >
+      <Tabs type='line'>
+        <TabPane tab={t('试运行报告')} itemKey='report'>
+          <Space className='mb-3'>
+            <Button type='primary' loading={reportLoading} onClick={loadReport}>
+              {t('生成报告')}
+            </Button>
+            <Button onClick={syncAll}>{t('同步所有通道')}</Button>
+            <Text type='tertiary' size='small'>
+              {t('试运行不会修改渠道，是否自动添加或移除模型取决于监控设置')}
+            </Text>
+          </Space>
+          <Table
+            columns={reportColumns}
+            dataSource={reports}
+            rowKey='channel_id'
+            loading={reportLoading}
+            pagination={{ pageSize }}
+            size='small'
+          />
+        </TabPane>
+        <TabPane tab={t('同步记录')} itemKey='history'>
+          <Table
+            columns={historyColumns}
+            dataSource={history}
+            rowKey='id'
+            size='small'
+            pagination={{
+              currentPage: historyPage,
+              pageSize,
+              total: historyTotal,
+              onPageChange: setHistoryPage,
+            }}
+          />
+        </TabPane>
+      </Tabs>
+    </Modal>
+  );
+};
+
+export default ModelSyncModal;
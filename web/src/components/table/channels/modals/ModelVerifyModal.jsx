@@ -0,0 +1,229 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  Button,
+  Modal,
+  Select,
+  Space,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import {
+  API,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../../../helpers';
+
+const { Text } = Typography;
+
+const STATUS_COLORS = {
+  passed: 'green',
+  failed: 'red',
+  error: 'grey',
+};
+
+const parseChecks = (details) => {
+  try {
+    return JSON.parse(details) || [];
+  } catch (e) {
+    return [];
+  }
+};
+
+const ModelVerifyModal = ({ visible, onCancel, channel }) => {
+  const { t } = useTranslation();
+  const [loading, setLoading] = useState(false);
+  const [verifying, setVerifying] = useState(false);
+  const [records, setRecords] = useState([]);
+  const [total, setTotal] = useState(0);
+  const [page, setPage] = useState(1);
+  const [modelName, setModelName] = useState('');
+  const pageSize = 10;
+
+  const models = channel?.models
+    ? channel.models.split(',').filter((m) => m !== '')
+    : [];
+
+  const loadRecords = async (p = page) => {
+    if (!channel) return;
+    setLoading(true);
+    try {
+      const res = await API.get(
+        `/api/channel/verify?channel_id=${channel.id}&model_name=${encodeURIComponent(modelName)}&p=${p}&page_size=${pageSize}`,
+      );
+      const { success, message, data } = res.data;
+      if (success) {
+        setRecords(data.items || []);
+        setTotal(data.total || 0);
+      } else {
+        showError(message);
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const runVerify = async () => {
+    setVerifying(true);
+    try {
+      const res = await API.post(
+        `/api/channel/verify/${channel.id}?model=${encodeURIComponent(modelName)}`,
+      );
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t('验真完成'));
+        setPage(1);
+        await loadRecords(1);
+      } else {
+        showError(message);
+      }
+    } finally {
+      setVerifying(false);
+    }
+  };
+
+  useEffect(() => {
+    if (visible) {
+      loadRecords(page);
+    }
+  }, [visible, channel, modelName, page]);
+
+  useEffect(() => {
+    if (!visible) {
+      setPage(1);
+      setModelName('');
+    }
+  }, [visible]);
+
+  const columns = [
+    {
+      title: t('时间'),
+      dataIndex: 'created_at',
+      render: (text) => timestamp2string(text),
+    },
+    {
+      title: t('模型'),
+      dataIndex: 'model_name',
+      render: (text, record) =>
+        text === record.upstream_model
+          ? text
+          : `${text} → ${record.upstream_model}`,
+    },
+    {
+      title: t('结果'),
+      dataIndex: 'status',
+      render: (text) => (
+        <Tag color={STATUS_COLORS[text] || 'grey'}>
+          {
+            {
+              passed: t('通过'),
+              failed: t('未通过'),
+              error: t('错误'),
+            }[text]
+          }
+        </Tag>
+      ),
+    },
+    {
+      title: t('得分'),
+      dataIndex: 'score',
+      render: (text) => text.toFixed(2),
+    },
+    {
+      title: t('响应模型'),
+      dataIndex: 'response_model',
+      render: (text, record) => (
+        <div>
+          <Text>{text || '-'}</Text>
+          {record.system_fingerprint && (
+            <div>
+              <Text type='tertiary' size='small'>
+                {record.system_fingerprint}
+              </Text>
+            </div>
+          )}
+        </div>
+      ),
+    },
+  ];
+
+  const expandRowRender = (record) => (
+    <Table
+      size='small'
+      pagination={false}
+      rowKey='name'
+      dataSource={parseChecks(record.details)}
+      columns={[
+        { title: t('检查项'), dataIndex: 'name' },
+        { title: t('权重'), dataIndex: 'weight' },
+        { title: t('得分'), dataIndex: 'score' },
+        { title: t('详情'), dataIndex: 'detail' },
+      ]}
+    />
+  );
+
+  return (
+    <Modal
+      title={`${t('模型验真')} - ${channel?.name || ''}`}
+      visible={visible}
+      onCancel={onCancel}
+      footer={null}
+      width={900}
+    >
+      <Space className='mb-3'>
+        <Select
+          value={modelName}
+          onChange={(value) => {
+            setModelName(value || '');
+            setPage(1);
+          }}
+          placeholder={t('全部模型')}
+          showClear
+          filter
+          style={{ width: 260 }}
+          optionList={models.map((m) => ({ label: m, value: m }))}
+        />
+        <Button type='primary' loading={verifying} onClick={runVerify}>
+          {t('立即验真')}
+        </Button>
+      </Space>
+      <Table
+        columns={columns}
+        dataSource={records}
+        rowKey='id'
+        loading={loading}
+        size='small'
+        expandedRowRender={expandRowRender}
+        pagination={{
+          currentPage: page,
+          pageSize,
+          total,
+          onPageChange: setPage,
+        }}
+      />
+    </Modal>
+  );
+};
+
+export default ModelVerifyModal;
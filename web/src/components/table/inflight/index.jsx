@@ -0,0 +1,173 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React from 'react';
+import {
+  Button,
+  Form,
+  Popconfirm,
+  Space,
+  Switch,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import CardPro from '../../common/ui/CardPro';
+import { useInflightData } from '../../../hooks/inflight/useInflightData';
+
+const { Title, Text } = Typography;
+
+const formatBytes = (bytes) => {
+  if (bytes < 1024) {
+    return `${bytes} B`;
+  }
+  if (bytes < 1024 * 1024) {
+    return `${(bytes / 1024).toFixed(1)} KB`;
+  }
+  return `${(bytes / 1024 / 1024).toFixed(1)} MB`;
+};
+
+const InflightPage = () => {
+  const { t, requests, connected, paused, setPaused, cancelRequests } =
+    useInflightData();
+
+  const columns = [
+    {
+      title: t('请求 ID'),
+      dataIndex: 'request_id',
+      render: (text, record) => (
+        <div>
+          <Text copyable={{ content: text }}>{text}</Text>
+          <div>
+            <Text type='tertiary' size='small'>
+              {record.node}
+            </Text>
+          </div>
+        </div>
+      ),
+    },
+    {
+      title: t('用户'),
+      dataIndex: 'username',
+      render: (text, record) => `${text} (${record.user_id})`,
+    },
+    {
+      title: t('令牌'),
+      dataIndex: 'token_name',
+      render: (text, record) => `${text} (${record.token_id})`,
+    },
+    {
+      title: t('模型'),
+      dataIndex: 'model_name',
+      render: (text, record) => (
+        <Space>
+          {text}
+          {record.is_stream && <Tag color='blue'>{t('流')}</Tag>}
+        </Space>
+      ),
+    },
+    {
+      title: t('渠道'),
+      dataIndex: 'channel_id',
+      render: (text, record) =>
+        text ? `${text} - ${record.channel_name}` : '-',
+    },
+    {
+      title: t('已用时间'),
+      dataIndex: 'start_time',
+      render: (text) => `${((Date.now() - text) / 1000).toFixed(1)} s`,
+    },
+    {
+      title: t('已输出'),
+      dataIndex: 'bytes_streamed',
+      render: (text, record) =>
+        record.is_stream
+          ? `${formatBytes(text)} / ${record.tokens} tokens`
+          : formatBytes(text),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (text, record) => (
+        <Popconfirm
+          title={t('确定要取消该请求吗？已输出的部分仍会计费')}
+          onConfirm={() => cancelRequests({ request_id: record.request_id })}
+        >
+          <Button type='danger' size='small'>
+            {t('取消')}
+          </Button>
+        </Popconfirm>
+      ),
+    },
+  ];
+
+  const statsArea = (
+    <div className='flex flex-col sm:flex-row justify-between items-start sm:items-center gap-4'>
+      <div className='flex items-center gap-3'>
+        <Title heading={4} style={{ margin: 0 }}>
+          {t('进行中的请求')}
+        </Title>
+        <Text type='tertiary'>
+          {t('共 {{count}} 条', { count: requests.length })}
+        </Text>
+        {connected ? (
+          <Tag color='green'>{t('实时')}</Tag>
+        ) : (
+          <Tag color='grey'>{t('未连接')}</Tag>
+        )}
+      </div>
+      <div className='flex items-center gap-2'>
+        <Text type='tertiary'>{t('暂停刷新')}</Text>
+        <Switch checked={paused} onChange={setPaused} size='small' />
+      </div>
+    </div>
+  );
+
+  const searchArea = (
+    <Form
+      layout='horizontal'
+      onSubmit={(values) =>
+        cancelRequests({
+          user_id: values.user_id || 0,
+          token_id: values.token_id || 0,
+        })
+      }
+    >
+      <Form.InputNumber field='user_id' label={t('用户ID')} min={0} />
+      <Form.InputNumber field='token_id' label={t('令牌 ID')} min={0} />
+      <Button htmlType='submit' type='danger'>
+        {t('批量取消')}
+      </Button>
+    </Form>
+  );
+
+  return (
+    <CardPro type='type2' statsArea={statsArea} searchArea={searchArea} t={t}>
+      <Table
+        columns={columns}
+        dataSource={requests}
+        rowKey='request_id'
+        pagination={false}
+        size='small'
+      />
+    </CardPro>
+  );
+};
+
+export default InflightPage;
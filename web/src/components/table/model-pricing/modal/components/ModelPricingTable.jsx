@@ -20,7 +20,10 @@ For commercial licensing, please contact support@quantumnous.com
 import React from 'react';
 import { Card, Avatar, Typography, Table, Tag } from '@douyinfe/semi-ui';
 import { IconCoinMoneyStroked } from '@douyinfe/semi-icons';
-import { calculateModelPrice } from '../../../../../helpers';
+import {
+  calculateModelPrice,
+  timestamp2string,
+} from '../../../../../helpers';
 
 const { Text } = Typography;
 
@@ -33,6 +36,7 @@ const ModelPricingTable = ({
   showRatio,
   usableGroup,
   autoGroups = [],
+  pricingTimeRules = [],
   t,
 }) => {
   const modelEnableGroups = Array.isArray(modelData?.enable_groups)
@@ -181,6 +185,152 @@ const ModelPricingTable = ({
     );
   };
 
+  const matchRuleModel = (rule) =>
+    !rule.models ||
+    rule.models.length === 0 ||
+    rule.models.some((pattern) =>
+      pattern.endsWith('*')
+        ? modelData?.model_name?.startsWith(pattern.slice(0, -1))
+        : pattern === modelData?.model_name,
+    );
+
+  const formatRuleWindow = (rule) => {
+    const parts = [];
+    if (rule.weekdays && rule.weekdays.length > 0) {
+      const names = ['周日', '周一', '周二', '周三', '周四', '周五', '周六'];
+      parts.push(rule.weekdays.map((d) => t(names[d])).join('/'));
+    }
+    parts.push(
+      rule.start_time ? `${rule.start_time}-${rule.end_time}` : t('全天'),
+    );
+    if (rule.timezone) parts.push(rule.timezone);
+    if (rule.start_at || rule.end_at) {
+      parts.push(
+        `${rule.start_at ? timestamp2string(rule.start_at) : ''} ~ ${
+          rule.end_at ? timestamp2string(rule.end_at) : ''
+        }`,
+      );
+    }
+    return parts.join(' ');
+  };
+
+  // 时段定价规则按配置顺序展示，同一目标以第一条生效的规则为准
+  const renderTimeRuleTable = () => {
+    const rules = pricingTimeRules.filter(matchRuleModel);
+    if (rules.length === 0) return null;
+    return (
+      <div className='mt-4'>
+        <Text className='text-sm font-medium'>{t('时段定价')}</Text>
+        <div className='text-xs text-gray-600 mb-2'>
+          {t('在指定时段内按倍数调整价格，按请求开始时间计费')}
+        </div>
+        <Table
+          dataSource={rules}
+          rowKey='name'
+          columns={[
+            {
+              title: t('规则'),
+              dataIndex: 'name',
+              render: (text, record) => (
+                <>
+                  {text}
+                  {record.active && (
+                    <Tag color='green' size='small' className='ml-1'>
+                      {t('生效中')}
+                    </Tag>
+                  )}
+                </>
+              ),
+            },
+            {
+              title: t('时段'),
+              dataIndex: 'start_time',
+              render: (text, record) => formatRuleWindow(record),
+            },
+            {
+              title: t('适用分组'),
+              dataIndex: 'groups',
+              render: (groups) =>
+                groups && groups.length > 0 ? groups.join(', ') : t('全部'),
+            },
+            {
+              title: t('调整'),
+              dataIndex: 'multiplier',
+              render: (value, record) =>
+                `${record.target === 'group' ? t('分组倍率') : t('模型价格')} ${value}x`,
+            },
+          ]}
+          pagination={false}
+          size='small'
+          bordered={false}
+          className='!rounded-lg'
+        />
+      </div>
+    );
+  };
+
+  // 阶梯价格以基础价格的倍数展示，与分组无关
+  const renderTierTable = () => {
+    const tiers = modelData?.pricing_tiers;
+    if (!tiers) return null;
+    const formatMultiple = (value) => `${parseFloat(value.toFixed(4))}x`;
+    const baseModelRatio = modelData.model_ratio || 0;
+    const baseCompletionRatio = modelData.completion_ratio || 0;
+    const tableData = [];
+    if (modelData.quota_type === 0 && baseModelRatio > 0) {
+      (tiers.context_tiers || []).forEach((tier) => {
+        const modelRatio = tier.model_ratio || baseModelRatio;
+        const completionRatio = tier.completion_ratio || baseCompletionRatio;
+        const outputMultiple =
+          baseCompletionRatio > 0
+            ? (modelRatio * completionRatio) /
+              (baseModelRatio * baseCompletionRatio)
+            : modelRatio / baseModelRatio;
+        tableData.push({
+          key: `context-${tier.min_prompt_tokens}`,
+          condition: t('输入超过 {{tokens}} tokens', {
+            tokens: tier.min_prompt_tokens.toLocaleString(),
+          }),
+          input: formatMultiple(modelRatio / baseModelRatio),
+          output: formatMultiple(outputMultiple),
+        });
+      });
+    }
+    (tiers.volume_tiers || []).forEach((tier) => {
+      tableData.push({
+        key: `volume-${tier.min_monthly_tokens}`,
+        condition: t('当月累计用量达到 {{tokens}} tokens', {
+          tokens: tier.min_monthly_tokens.toLocaleString(),
+        }),
+        input: formatMultiple(tier.multiplier),
+        output: formatMultiple(tier.multiplier),
+      });
+    });
+    if (tableData.length === 0) return null;
+    return (
+      <div className='mt-4'>
+        <Text className='text-sm font-medium'>{t('阶梯价格')}</Text>
+        <div className='text-xs text-gray-600 mb-2'>
+          {t(
+            '满足条件时按基础价格的倍数计费，输入长度阶梯对整次请求生效，用量阶梯可与其叠加',
+          )}
+        </div>
+        <Table
+          dataSource={tableData}
+          columns={[
+            { title: t('条件'), dataIndex: 'condition' },
+            { title: t('提示'), dataIndex: 'input' },
+            { title: t('补全'), dataIndex: 'output' },
+          ]}
+          pagination={false}
+          size='small'
+          bordered={false}
+          className='!rounded-lg'
+        />
+      </div>
+    );
+  };
+
   return (
     <Card className='!rounded-2xl shadow-sm border-0'>
       <div className='flex items-center mb-4'>
@@ -210,6 +360,8 @@ const ModelPricingTable = ({
         </div>
       )}
       {renderGroupPriceTable()}
+      {renderTierTable()}
+      {renderTimeRuleTable()}
     </Card>
   );
 };
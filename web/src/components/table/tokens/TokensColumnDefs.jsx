@@ -252,6 +252,33 @@ const renderAllowIps = (text, t) => {
   return <Space wrap>{ipTags}</Space>;
 };
 
+const BUDGET_PERIODS = {
+  daily: '每日',
+  weekly: '每周',
+  monthly: '每月',
+};
+
+// Render recurring budget usage inside the quota popover
+const renderBudgetUsage = (record, t) => {
+  if (!record.budget_period || !record.budget_quota) return null;
+  const { Paragraph } = Typography;
+  return (
+    <>
+      <Paragraph>
+        {t('周期预算')} ({t(BUDGET_PERIODS[record.budget_period])}
+        {record.budget_soft_limit ? `, ${t('软限制')}` : ''}):{' '}
+        {renderQuota(record.budget_used_quota || 0)} /{' '}
+        {renderQuota(record.budget_quota)}
+      </Paragraph>
+      {record.budget_reset_time > 0 && (
+        <Paragraph>
+          {t('预算重置时间')}: {timestamp2string(record.budget_reset_time)}
+        </Paragraph>
+      )}
+    </>
+  );
+};
+
 // Render separate quota usage column
 const renderQuotaUsage = (text, record, t) => {
   const { Paragraph } = Typography;
@@ -264,6 +291,7 @@ const renderQuotaUsage = (text, record, t) => {
         <Paragraph copyable={{ content: renderQuota(used) }}>
           {t('已用额度')}: {renderQuota(used)}
         </Paragraph>
+        {renderBudgetUsage(record, t)}
       </div>
     );
     return (
@@ -286,6 +314,7 @@ const renderQuotaUsage = (text, record, t) => {
       <Paragraph copyable={{ content: renderQuota(total) }}>
         {t('总额度')}: {renderQuota(total)}
       </Paragraph>
+      {renderBudgetUsage(record, t)}
     </div>
   );
   return (
@@ -402,6 +431,27 @@ const renderOperations = (
         {t('编辑')}
       </Button>
 
+      <Button
+        type='warning'
+        size='small'
+        onClick={() => {
+          Modal.confirm({
+            title: t('确定要轮换此令牌的密钥吗？'),
+            content: t(
+              '轮换后旧密钥立即失效，由该令牌签发的派生令牌也将全部失效',
+            ),
+            onOk: () => {
+              (async () => {
+                await manageToken(record.id, 'rotate', record);
+                await refresh();
+              })();
+            },
+          });
+        }}
+      >
+        {t('轮换密钥')}
+      </Button>
+
       <Button
         type='danger'
         size='small'
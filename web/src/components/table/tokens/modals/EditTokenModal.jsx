@@ -62,6 +62,7 @@ const EditTokenModal = (props) => {
   const formApiRef = useRef(null);
   const [models, setModels] = useState([]);
   const [groups, setGroups] = useState([]);
+  const [organizations, setOrganizations] = useState([]);
   const isEdit = props.editingToken.id !== undefined;
 
   const getInitValues = () => ({
@@ -73,6 +74,14 @@ const EditTokenModal = (props) => {
     model_limits: [],
     allow_ips: '',
     group: '',
+    organization_id: 0,
+    budget_period: '',
+    budget_quota: 0,
+    budget_soft_limit: false,
+    scopes: [],
+    max_tokens_limit: 0,
+    disable_stream: false,
+    disable_tools: false,
     tokenCount: 1,
   });
 
@@ -148,6 +157,19 @@ const EditTokenModal = (props) => {
     }
   };
 
+  const loadOrganizations = async () => {
+    let res = await API.get(`/api/organization/`);
+    const { success, data } = res.data;
+    if (success) {
+      // billing 角色只负责付费，不能创建团队令牌
+      setOrganizations(
+        (data || [])
+          .filter((org) => org.status === 1 && org.role !== 'billing')
+          .map((org) => ({ label: org.name, value: org.id })),
+      );
+    }
+  };
+
   const loadToken = async () => {
     setLoading(true);
     let res = await API.get(`/api/token/${props.editingToken.id}`);
@@ -161,6 +183,7 @@ const EditTokenModal = (props) => {
       } else {
         data.model_limits = [];
       }
+      data.scopes = data.scopes ? data.scopes.split(',') : [];
       if (formApiRef.current) {
         formApiRef.current.setValues({ ...getInitValues(), ...data });
       }
@@ -178,6 +201,7 @@ const EditTokenModal = (props) => {
     }
     loadModels();
     loadGroups();
+    loadOrganizations();
   }, [props.editingToken.id]);
 
   useEffect(() => {
@@ -209,6 +233,7 @@ const EditTokenModal = (props) => {
     if (isEdit) {
       let { tokenCount: _tc, ...localInputs } = values;
       localInputs.remain_quota = parseInt(localInputs.remain_quota);
+      localInputs.budget_quota = parseInt(localInputs.budget_quota) || 0;
       if (localInputs.expired_time !== -1) {
         let time = Date.parse(localInputs.expired_time);
         if (isNaN(time)) {
@@ -220,6 +245,9 @@ const EditTokenModal = (props) => {
       }
       localInputs.model_limits = localInputs.model_limits.join(',');
       localInputs.model_limits_enabled = localInputs.model_limits.length > 0;
+      localInputs.scopes = localInputs.scopes.join(',');
+      localInputs.max_tokens_limit =
+        parseInt(localInputs.max_tokens_limit, 10) || 0;
       let res = await API.put(`/api/token/`, {
         ...localInputs,
         id: parseInt(props.editingToken.id),
@@ -245,6 +273,7 @@ const EditTokenModal = (props) => {
           localInputs.name = baseName;
         }
         localInputs.remain_quota = parseInt(localInputs.remain_quota);
+        localInputs.budget_quota = parseInt(localInputs.budget_quota) || 0;
 
         if (localInputs.expired_time !== -1) {
           let time = Date.parse(localInputs.expired_time);
@@ -257,6 +286,9 @@ const EditTokenModal = (props) => {
         }
         localInputs.model_limits = localInputs.model_limits.join(',');
         localInputs.model_limits_enabled = localInputs.model_limits.length > 0;
+        localInputs.scopes = localInputs.scopes.join(',');
+        localInputs.max_tokens_limit =
+          parseInt(localInputs.max_tokens_limit, 10) || 0;
         let res = await API.post(`/api/token/`, localInputs);
         const { success, message } = res.data;
         if (success) {
@@ -377,6 +409,20 @@ const EditTokenModal = (props) => {
                       />
                     )}
                   </Col>
+                  {!isEdit && organizations.length > 0 && (
+                    <Col span={24}>
+                      <Form.Select
+                        field='organization_id'
+                        label={t('所属团队')}
+                        extraText={t('团队令牌的费用从团队额度池扣除')}
+                        optionList={[
+                          { label: t('个人（不使用团队额度）'), value: 0 },
+                          ...organizations,
+                        ]}
+                        style={{ width: '100%' }}
+                      />
+                    </Col>
+                  )}
                   <Col xs={24} sm={24} md={24} lg={10} xl={10}>
                     <Form.DatePicker
                       field='expired_time'
@@ -505,6 +551,52 @@ const EditTokenModal = (props) => {
                       )}
                     />
                   </Col>
+                  <Col xs={24} sm={24} md={24} lg={10} xl={10}>
+                    <Form.Select
+                      field='budget_period'
+                      label={t('周期预算')}
+                      optionList={[
+                        { label: t('不设置'), value: '' },
+                        { label: t('每日'), value: 'daily' },
+                        { label: t('每周'), value: 'weekly' },
+                        { label: t('每月'), value: 'monthly' },
+                      ]}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col xs={24} sm={24} md={24} lg={14} xl={14}>
+                    <Form.AutoComplete
+                      field='budget_quota'
+                      label={t('每周期预算额度')}
+                      placeholder={t('请输入额度')}
+                      type='number'
+                      disabled={!values.budget_period}
+                      extraText={renderQuotaWithPrompt(values.budget_quota)}
+                      rules={
+                        values.budget_period
+                          ? [{ required: true, message: t('请输入额度') }]
+                          : []
+                      }
+                      data={[
+                        { value: 500000, label: '1$' },
+                        { value: 5000000, label: '10$' },
+                        { value: 10000000, label: '20$' },
+                        { value: 50000000, label: '100$' },
+                      ]}
+                    />
+                  </Col>
+                  {values.budget_period && (
+                    <Col span={24}>
+                      <Form.Switch
+                        field='budget_soft_limit'
+                        label={t('软限制')}
+                        size='large'
+                        extraText={t(
+                          '开启后超出周期预算仍可继续调用，只记录告警日志；关闭时超出预算的请求将被拒绝，预算在每个周期开始时自动重置',
+                        )}
+                      />
+                    </Col>
+                  )}
                 </Row>
               </Card>
 
@@ -555,6 +647,49 @@ const EditTokenModal = (props) => {
                       style={{ width: '100%' }}
                     />
                   </Col>
+                  <Col span={24}>
+                    <Form.Select
+                      field='scopes'
+                      label={t('作用域')}
+                      placeholder={t('留空表示可以调用所有接口')}
+                      multiple
+                      optionList={[
+                        { label: t('对话'), value: 'chat' },
+                        { label: t('向量'), value: 'embeddings' },
+                        { label: t('图像'), value: 'images' },
+                        { label: t('音频'), value: 'audio' },
+                        { label: t('实时'), value: 'realtime' },
+                        { label: t('异步任务'), value: 'tasks' },
+                        { label: t('文件'), value: 'files' },
+                        { label: t('查询用量'), value: 'read-usage' },
+                      ]}
+                      showClear
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col xs={24} sm={24} md={24} lg={10} xl={10}>
+                    <Form.InputNumber
+                      field='max_tokens_limit'
+                      label={t('最大 max_tokens')}
+                      min={0}
+                      extraText={t(
+                        '0 表示不限制，设置后对话请求必须携带 max_tokens',
+                      )}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col xs={12} sm={12} md={12} lg={7} xl={7}>
+                    <Form.Switch
+                      field='disable_stream'
+                      label={t('禁止流式')}
+                    />
+                  </Col>
+                  <Col xs={12} sm={12} md={12} lg={7} xl={7}>
+                    <Form.Switch
+                      field='disable_tools'
+                      label={t('禁止工具调用')}
+                    />
+                  </Col>
                 </Row>
               </Card>
             </div>
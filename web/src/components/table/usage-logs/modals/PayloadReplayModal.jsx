@@ -0,0 +1,204 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState } from 'react';
+import { Modal, Form, Button, Table, Tag, Typography } from '@douyinfe/semi-ui';
+import { API, showError } from '../../../../helpers';
+
+const PayloadReplayModal = ({
+  payloadReplayRequestId,
+  setPayloadReplayRequestId,
+  t,
+}) => {
+  const [loading, setLoading] = useState(false);
+  const [result, setResult] = useState(null);
+
+  useEffect(() => {
+    setResult(null);
+  }, [payloadReplayRequestId]);
+
+  const replay = async (values) => {
+    setLoading(true);
+    try {
+      const res = await API.post(
+        `/api/log/payload/${payloadReplayRequestId}/replay`,
+        {
+          channel_id: values.channel_id || 0,
+          model: values.model || '',
+        },
+      );
+      const { success, message, data } = res.data;
+      if (success) {
+        setResult(data);
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      showError(error.message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const renderDiff = (value, unit = '') => {
+    if (!value) {
+      return <Tag color='grey'>0{unit}</Tag>;
+    }
+    return (
+      <Tag color={value > 0 ? 'red' : 'green'}>
+        {value > 0 ? '+' : ''}
+        {value}
+        {unit}
+      </Tag>
+    );
+  };
+
+  const rows = result
+    ? [
+        {
+          key: 'channel',
+          label: t('渠道'),
+          original: result.original.channel_id,
+          replay: result.replay.channel_id,
+        },
+        {
+          key: 'model',
+          label: t('模型'),
+          original: result.original.model_name,
+          replay: result.replay.model_name,
+        },
+        {
+          key: 'status',
+          label: t('状态码'),
+          original: result.original.status_code,
+          replay: result.replay.status_code,
+        },
+        {
+          key: 'use_time',
+          label: t('响应时间'),
+          original: `${result.original.use_time_ms} ms`,
+          replay: `${result.replay.use_time_ms} ms`,
+          diff: renderDiff(result.use_time_diff_ms, ' ms'),
+        },
+        {
+          key: 'first_token',
+          label: t('首字时间'),
+          original: `${result.original.first_token_ms} ms`,
+          replay: `${result.replay.first_token_ms} ms`,
+        },
+        {
+          key: 'prompt_tokens',
+          label: t('输入'),
+          original: result.original.prompt_tokens,
+          replay: result.replay.prompt_tokens,
+          diff: renderDiff(result.prompt_tokens_diff),
+        },
+        {
+          key: 'completion_tokens',
+          label: t('输出'),
+          original: result.original.completion_tokens,
+          replay: result.replay.completion_tokens,
+          diff: renderDiff(result.completion_tokens_diff),
+        },
+      ]
+    : [];
+
+  const columns = [
+    { title: '', dataIndex: 'label' },
+    { title: t('原始请求'), dataIndex: 'original' },
+    { title: t('重放结果'), dataIndex: 'replay' },
+    { title: t('差异'), dataIndex: 'diff' },
+  ];
+
+  const outputStyle = {
+    whiteSpace: 'pre-wrap',
+    wordBreak: 'break-all',
+    maxHeight: 240,
+    overflow: 'auto',
+  };
+
+  return (
+    <Modal
+      title={`${t('请求重放')} - ${payloadReplayRequestId}`}
+      visible={!!payloadReplayRequestId}
+      onCancel={() => setPayloadReplayRequestId('')}
+      footer={null}
+      width={900}
+      maskClosable
+    >
+      <Typography.Text type='tertiary'>
+        {t('使用原始请求内容在指定渠道或模型上重新请求，不会向原用户计费')}
+      </Typography.Text>
+      <Form layout='horizontal' onSubmit={replay} style={{ marginTop: 12 }}>
+        <Form.InputNumber
+          field='channel_id'
+          label={t('渠道 ID')}
+          placeholder={t('留空使用原渠道')}
+          min={0}
+        />
+        <Form.Input
+          field='model'
+          label={t('模型')}
+          placeholder={t('留空使用原模型')}
+        />
+        <Button htmlType='submit' type='primary' loading={loading}>
+          {t('重放')}
+        </Button>
+      </Form>
+      {result && (
+        <div style={{ marginTop: 16 }}>
+          <Table
+            columns={columns}
+            dataSource={rows}
+            rowKey='key'
+            pagination={false}
+            size='small'
+          />
+          {result.replay.error && (
+            <Typography.Text type='danger'>
+              {result.replay.error}
+            </Typography.Text>
+          )}
+          <div style={{ marginTop: 12 }}>
+            <Typography.Title heading={6}>
+              {t('输出对比')}{' '}
+              {result.output_identical ? (
+                <Tag color='green'>{t('一致')}</Tag>
+              ) : (
+                <Tag color='orange'>{t('不一致')}</Tag>
+              )}
+            </Typography.Title>
+            <div style={{ display: 'flex', gap: 12 }}>
+              <div style={{ flex: 1, minWidth: 0 }}>
+                <Typography.Text strong>{t('原始请求')}</Typography.Text>
+                <pre style={outputStyle}>{result.original.output}</pre>
+              </div>
+              <div style={{ flex: 1, minWidth: 0 }}>
+                <Typography.Text strong>{t('重放结果')}</Typography.Text>
+                <pre style={outputStyle}>{result.replay.output}</pre>
+              </div>
+            </div>
+          </div>
+        </div>
+      )}
+    </Modal>
+  );
+};
+
+export default PayloadReplayModal;
@@ -77,6 +77,7 @@ const EditUserModal = (props) => {
     telegram_id: '',
     email: '',
     quota: 0,
+    credit_limit: 0,
     group: 'default',
     remark: '',
   });
@@ -117,6 +118,8 @@ const EditUserModal = (props) => {
     let payload = { ...values };
     if (typeof payload.quota === 'string')
       payload.quota = parseInt(payload.quota) || 0;
+    if (typeof payload.credit_limit === 'string')
+      payload.credit_limit = parseInt(payload.credit_limit) || 0;
     if (userId) {
       payload.id = parseInt(userId);
     }
@@ -306,6 +309,19 @@ const EditUserModal = (props) => {
                           />
                         </Form.Slot>
                       </Col>
+
+                      <Col span={24}>
+                        <Form.InputNumber
+                          field='credit_limit'
+                          label={t('授信额度')}
+                          min={0}
+                          step={500000}
+                          extraText={t(
+                            '后付费用户的余额最多可透支到负的授信额度，需在运营设置中开启后付费授信',
+                          )}
+                          style={{ width: '100%' }}
+                        />
+                      </Col>
                     </Row>
                   </Card>
                 )}
@@ -0,0 +1,249 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState, useCallback } from 'react';
+import { Card, Button, Typography, Tag, Popconfirm } from '@douyinfe/semi-ui';
+import { Crown, Calendar, AlertCircle } from 'lucide-react';
+import {
+  API,
+  showError,
+  showSuccess,
+  renderQuota,
+  timestamp2string,
+} from '../../helpers';
+
+const { Text, Title } = Typography;
+
+const SubscriptionCard = ({ t, onSuccess }) => {
+  const [info, setInfo] = useState(null);
+  const [actionLoading, setActionLoading] = useState(false);
+
+  // 获取订阅信息
+  const fetchSubscription = useCallback(async () => {
+    try {
+      const res = await API.get('/api/user/subscription');
+      if (res.data.success) {
+        setInfo(res.data.data);
+      } else {
+        setInfo({ enabled: false });
+      }
+    } catch (error) {
+      setInfo({ enabled: false });
+    }
+  }, []);
+
+  useEffect(() => {
+    fetchSubscription();
+  }, [fetchSubscription]);
+
+  const subscribe = async (planId, paymentMethod) => {
+    setActionLoading(true);
+    try {
+      const res = await API.post('/api/user/subscription/pay', {
+        plan_id: planId,
+        payment_method: paymentMethod,
+      });
+      if (res.data.success) {
+        window.open(res.data.data.pay_link, '_blank');
+      } else {
+        showError(res.data.message);
+      }
+    } catch (error) {
+      showError(t('拉起支付失败'));
+    } finally {
+      setActionLoading(false);
+    }
+  };
+
+  const changePlan = async (planId) => {
+    setActionLoading(true);
+    try {
+      const res = await API.post('/api/user/subscription/change', {
+        plan_id: planId,
+      });
+      if (res.data.success) {
+        showSuccess(t('套餐已变更'));
+        await fetchSubscription();
+        if (onSuccess) {
+          onSuccess();
+        }
+      } else {
+        showError(res.data.message);
+      }
+    } finally {
+      setActionLoading(false);
+    }
+  };
+
+  const cancelSubscription = async () => {
+    setActionLoading(true);
+    try {
+      const res = await API.post('/api/user/subscription/cancel', {});
+      if (res.data.success) {
+        showSuccess(t('已取消续费，订阅将在本周期结束时到期'));
+        await fetchSubscription();
+      } else {
+        showError(res.data.message);
+      }
+    } finally {
+      setActionLoading(false);
+    }
+  };
+
+  if (!info || !info.enabled) {
+    return null;
+  }
+
+  const sub = info.subscription;
+  const plans = info.plans || [];
+  const canChange =
+    sub && (sub.payment_method === 'stripe' || sub.payment_method === 'creem');
+
+  const renderPlanActions = (plan) => {
+    if (sub) {
+      if (sub.plan_id === plan.id) {
+        return <Tag color='green'>{t('当前套餐')}</Tag>;
+      }
+      if (!canChange) {
+        return null;
+      }
+      return (
+        <Popconfirm
+          title={t('确认变更套餐？')}
+          content={
+            info.prorate_change
+              ? t('差价和包含额度将按本周期剩余时间折算')
+              : t('差价将按本周期剩余时间折算')
+          }
+          onConfirm={() => changePlan(plan.id)}
+        >
+          <Button size='small' loading={actionLoading}>
+            {t('变更为此套餐')}
+          </Button>
+        </Popconfirm>
+      );
+    }
+    return (
+      <div className='flex gap-2'>
+        {info.enable_stripe && plan.stripe_price_id && (
+          <Button
+            size='small'
+            theme='solid'
+            loading={actionLoading}
+            onClick={() => subscribe(plan.id, 'stripe')}
+          >
+            {t('Stripe 订阅')}
+          </Button>
+        )}
+        {info.enable_creem && plan.creem_product_id && (
+          <Button
+            size='small'
+            theme='solid'
+            loading={actionLoading}
+            onClick={() => subscribe(plan.id, 'creem')}
+          >
+            {t('Creem 订阅')}
+          </Button>
+        )}
+      </div>
+    );
+  };
+
+  return (
+    <Card className='mb-4 overflow-hidden'>
+      <div className='flex items-center gap-2 mb-3'>
+        <div className='p-2 rounded-lg bg-gradient-to-br from-violet-100 to-indigo-100 dark:from-violet-900/30 dark:to-indigo-900/30'>
+          <Crown className='w-5 h-5 text-violet-600 dark:text-violet-400' />
+        </div>
+        <Title heading={5} className='!mb-0'>
+          {t('订阅套餐')}
+        </Title>
+      </div>
+
+      {sub ? (
+        <div className='space-y-2 mb-4'>
+          <div className='flex items-center gap-2'>
+            <Text strong>{sub.plan?.name}</Text>
+            {sub.cancel_at_period_end ? (
+              <Tag color='orange'>{t('到期后不再续费')}</Tag>
+            ) : (
+              <Tag color='green'>{t('生效中')}</Tag>
+            )}
+          </div>
+          <div className='flex items-center gap-2'>
+            <Calendar className='w-4 h-4 text-gray-400' />
+            <Text type='tertiary'>
+              {t('当前周期')}: {timestamp2string(sub.period_start)} ~{' '}
+              {timestamp2string(sub.period_end)}
+            </Text>
+          </div>
+          <div className='flex items-center gap-2'>
+            <AlertCircle className='w-4 h-4 text-gray-400' />
+            <Text type='tertiary' size='small'>
+              {t('本周期包含额度')}: {renderQuota(sub.period_quota)}
+              {t('，未用完的额度在周期结束时清零')}
+            </Text>
+          </div>
+          {canChange && !sub.cancel_at_period_end && (
+            <Popconfirm
+              title={t('确认取消续费？')}
+              content={t('订阅将在本周期结束时到期')}
+              onConfirm={cancelSubscription}
+            >
+              <Button size='small' type='danger' loading={actionLoading}>
+                {t('取消续费')}
+              </Button>
+            </Popconfirm>
+          )}
+        </div>
+      ) : null}
+
+      {plans.length > 0 && (
+        <div className='grid grid-cols-1 md:grid-cols-2 xl:grid-cols-3 gap-3'>
+          {plans.map((plan) => (
+            <Card key={plan.id} bodyStyle={{ padding: 12 }}>
+              <div className='flex justify-between items-center mb-1'>
+                <Text strong>{plan.name}</Text>
+                <Text strong className='text-violet-600'>
+                  {plan.currency === 'EUR' ? '€' : '$'}
+                  {plan.price}/{t('月')}
+                </Text>
+              </div>
+              {plan.description && (
+                <div className='mb-1'>
+                  <Text type='tertiary' size='small'>
+                    {plan.description}
+                  </Text>
+                </div>
+              )}
+              <div className='mb-2'>
+                <Text size='small'>
+                  {t('每月包含额度')}: {renderQuota(plan.quota)}
+                </Text>
+              </div>
+              {renderPlanActions(plan)}
+            </Card>
+          ))}
+        </div>
+      )}
+    </Card>
+  );
+};
+
+export default SubscriptionCard;
@@ -36,6 +36,7 @@ import { StatusContext } from '../../context/Status';
 import RechargeCard from './RechargeCard';
 import InvitationCard from './InvitationCard';
 import CheckinCard from './CheckinCard';
+import SubscriptionCard from './SubscriptionCard';
 import TransferModal from './modals/TransferModal';
 import PaymentConfirmModal from './modals/PaymentConfirmModal';
 import TopupHistoryModal from './modals/TopupHistoryModal';
@@ -666,6 +667,9 @@ const TopUp = () => {
       {/* 每日签到卡片 */}
       <CheckinCard t={t} onCheckinSuccess={getUserQuota} />
 
+      {/* 订阅套餐卡片 */}
+      <SubscriptionCard t={t} onSuccess={getUserQuota} />
+
       {/* 用户信息头部 */}
       <div className='space-y-6'>
         <div className='grid grid-cols-1 lg:grid-cols-12 gap-6'>
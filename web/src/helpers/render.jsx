@@ -74,7 +74,9 @@ import {
   CircleUser,
   Package,
   AlertTriangle,
+  Activity,
   CalendarCheck,
+  Users,
 } from 'lucide-react';
 
 // 获取侧边栏Lucide图标组件
@@ -118,10 +120,14 @@ export function getLucideIcon(key, selected = false) {
       return <Package {...commonProps} color={iconColor} />;
     case 'errorLog':
       return <AlertTriangle {...commonProps} color={iconColor} />;
+    case 'inflight':
+      return <Activity {...commonProps} color={iconColor} />;
     case 'setting':
       return <Settings {...commonProps} color={iconColor} />;
     case 'checkin':
       return <CalendarCheck {...commonProps} color={iconColor} />;
+    case 'organization':
+      return <Users {...commonProps} color={iconColor} />;
     default:
       return <CircleUser {...commonProps} color={iconColor} />;
   }
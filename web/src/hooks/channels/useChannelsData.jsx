@@ -93,6 +93,15 @@ export const useChannelsData = () => {
   const [showMultiKeyManageModal, setShowMultiKeyManageModal] = useState(false);
   const [currentMultiKeyChannel, setCurrentMultiKeyChannel] = useState(null);
 
+  // Model verification states
+  const [modelVerifyChannel, setModelVerifyChannel] = useState(null);
+
+  // Capability test states
+  const [capabilityTestChannel, setCapabilityTestChannel] = useState(null);
+
+  // Upstream model sync states
+  const [showModelSync, setShowModelSync] = useState(false);
+
   // Refs
   const requestCounter = useRef(0);
   const allSelectingRef = useRef(false);
@@ -1080,6 +1089,18 @@ export const useChannelsData = () => {
     currentMultiKeyChannel,
     setCurrentMultiKeyChannel,
 
+    // Model verification states
+    modelVerifyChannel,
+    setModelVerifyChannel,
+
+    // Capability test states
+    capabilityTestChannel,
+    setCapabilityTestChannel,
+
+    // Upstream model sync states
+    showModelSync,
+    setShowModelSync,
+
     // Form
     formApi,
     setFormApi,
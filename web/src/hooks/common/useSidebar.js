@@ -55,6 +55,7 @@ export const useSidebar = () => {
     personal: {
       enabled: true,
       topup: true,
+      organization: true,
       personal: true,
     },
     admin: {
@@ -64,6 +65,7 @@ export const useSidebar = () => {
       redemption: true,
       user: true,
       errorLog: true,
+      inflight: true,
       setting: true,
       checkin: true,
     },
@@ -0,0 +1,87 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import { useEffect, useRef, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { SSE } from 'sse.js';
+import {
+  API,
+  getUserIdFromLocalStorage,
+  showError,
+  showSuccess,
+} from '../../helpers';
+
+export const useInflightData = () => {
+  const { t } = useTranslation();
+  const [requests, setRequests] = useState([]);
+  const [connected, setConnected] = useState(false);
+  const [paused, setPaused] = useState(false);
+  const sourceRef = useRef(null);
+
+  // 订阅进行中请求的推送
+  useEffect(() => {
+    if (paused) {
+      return;
+    }
+    const source = new SSE('/api/inflight/stream', {
+      headers: {
+        'New-Api-User': getUserIdFromLocalStorage(),
+      },
+      method: 'GET',
+      start: false,
+    });
+    source.addEventListener('inflight', (e) => {
+      try {
+        setRequests(JSON.parse(e.data) || []);
+        setConnected(true);
+      } catch (error) {
+        console.error('Failed to parse inflight data:', error);
+      }
+    });
+    source.addEventListener('error', () => {
+      setConnected(false);
+    });
+    source.stream();
+    sourceRef.current = source;
+    return () => {
+      source.close();
+      sourceRef.current = null;
+      setConnected(false);
+    };
+  }, [paused]);
+
+  const cancelRequests = async (filter) => {
+    const res = await API.post('/api/inflight/cancel', filter);
+    const { success, message, data } = res.data;
+    if (success) {
+      showSuccess(t('已取消 {{count}} 个请求', { count: data.cancelled }));
+    } else {
+      showError(message);
+    }
+  };
+
+  return {
+    t,
+    requests,
+    connected,
+    paused,
+    setPaused,
+    cancelRequests,
+  };
+};
@@ -51,6 +51,7 @@ export const useModelPricingData = () => {
   const [usableGroup, setUsableGroup] = useState({});
   const [endpointMap, setEndpointMap] = useState({});
   const [autoGroups, setAutoGroups] = useState([]);
+  const [pricingTimeRules, setPricingTimeRules] = useState([]);
 
   const [statusState] = useContext(StatusContext);
   const [userState] = useContext(UserContext);
@@ -231,6 +232,7 @@ export const useModelPricingData = () => {
       usable_group,
       supported_endpoint,
       auto_groups,
+      pricing_time_rules,
     } = res.data;
     if (success) {
       setGroupRatio(group_ratio);
@@ -246,6 +248,7 @@ export const useModelPricingData = () => {
       setVendorsMap(vendorMap);
       setEndpointMap(supported_endpoint || {});
       setAutoGroups(auto_groups || []);
+      setPricingTimeRules(pricing_time_rules || []);
       setModelsFormat(data, group_ratio, vendorMap);
     } else {
       showError(message);
@@ -366,6 +369,7 @@ export const useModelPricingData = () => {
     usableGroup,
     endpointMap,
     autoGroups,
+    pricingTimeRules,
 
     // 计算属性
     priceRate,
@@ -154,7 +154,7 @@ export const useTokensData = (openFluentNotification) => {
     window.open(url, '_blank');
   };
 
-  // Manage token function (delete, enable, disable)
+  // Manage token function (delete, enable, disable, rotate)
   const manageToken = async (id, action, record) => {
     setLoading(true);
     let data = { id };
@@ -171,6 +171,9 @@ export const useTokensData = (openFluentNotification) => {
         data.status = 2;
         res = await API.put('/api/token/?status_only=true', data);
         break;
+      case 'rotate':
+        res = await API.post(`/api/token/${id}/rotate`);
+        break;
     }
     const { success, message } = res.data;
     if (success) {
@@ -180,6 +183,9 @@ export const useTokensData = (openFluentNotification) => {
       if (action !== 'delete') {
         record.status = token.status;
       }
+      if (action === 'rotate') {
+        record.key = token.key;
+      }
       setTokens(newTokens);
     } else {
       showError(message);
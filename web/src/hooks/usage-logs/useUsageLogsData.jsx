@@ -19,7 +19,7 @@ For commercial licensing, please contact support@quantumnous.com
 
 import { useState, useEffect } from 'react';
 import { useTranslation } from 'react-i18next';
-import { Modal } from '@douyinfe/semi-ui';
+import { Button, Modal, Typography } from '@douyinfe/semi-ui';
 import {
   API,
   getTodayStartTimestamp,
@@ -112,6 +112,9 @@ export const useLogsData = () => {
   const [showUserInfo, setShowUserInfoModal] = useState(false);
   const [userInfoData, setUserInfoData] = useState(null);
 
+  // Payload replay modal state
+  const [payloadReplayRequestId, setPayloadReplayRequestId] = useState('');
+
   // Load saved column preferences from localStorage
   useEffect(() => {
     const savedColumns = localStorage.getItem(STORAGE_KEY);
@@ -304,6 +307,43 @@ export const useLogsData = () => {
     }
   };
 
+  // Payload capture function
+  const showPayloadCaptureFunc = async (requestId) => {
+    const res = await API.get(`/api/log/payload/${requestId}`);
+    const { success, message, data } = res.data;
+    if (!success) {
+      showError(message);
+      return;
+    }
+    const sections = [
+      [t('客户端请求'), data.data.client_request],
+      [t('上游请求'), data.data.upstream_request],
+      [t('响应内容'), data.data.response],
+      [t('拼接输出'), data.data.assembled_response],
+    ].filter(([, value]) => value);
+    Modal.info({
+      title: `${t('请求内容')} - ${requestId}`,
+      width: 900,
+      content: (
+        <div style={{ maxHeight: '65vh', overflow: 'auto' }}>
+          {data.truncated && (
+            <Typography.Text type='warning'>
+              {t('内容超出大小限制，已截断')}
+            </Typography.Text>
+          )}
+          {sections.map(([title, value]) => (
+            <div key={title} style={{ marginTop: 12 }}>
+              <Typography.Title heading={6}>{title}</Typography.Title>
+              <pre style={{ whiteSpace: 'pre-wrap', wordBreak: 'break-all' }}>
+                {value}
+              </pre>
+            </div>
+          ))}
+        </div>
+      ),
+    });
+  };
+
   // Format logs data
   const setLogsFormat = (logs) => {
     let expandDatesLocal = {};
@@ -482,6 +522,107 @@ export const useLogsData = () => {
           value: other.request_path,
         });
       }
+      if (
+        other?.tier_context !== undefined ||
+        other?.tier_volume_multiplier !== undefined
+      ) {
+        const tierLines = [];
+        if (other?.tier_context !== undefined) {
+          tierLines.push(
+            other.tier_context > 0
+              ? t(
+                  '输入 {{tokens}} tokens，超过 {{min}} tokens，命中第 {{tier}} 档（基础模型倍率 {{modelRatio}}，基础补全倍率 {{completionRatio}}）',
+                  {
+                    tokens: other.tier_context_tokens,
+                    min: other.tier_min_prompt_tokens,
+                    tier: other.tier_context,
+                    modelRatio: other.tier_base_model_ratio,
+                    completionRatio: other.tier_base_completion_ratio,
+                  },
+                )
+              : t('输入 {{tokens}} tokens，未超过阶梯阈值，按基础价格计费', {
+                  tokens: other.tier_context_tokens,
+                }),
+          );
+        }
+        if (other?.tier_volume_multiplier !== undefined) {
+          tierLines.push(
+            t('当月累计 {{tokens}} tokens，价格系数 {{multiplier}}', {
+              tokens: other.tier_monthly_tokens,
+              multiplier: other.tier_volume_multiplier,
+            }),
+          );
+        }
+        expandDataLocal.push({
+          key: t('阶梯计价'),
+          value: tierLines.join('；'),
+        });
+      }
+      if (other?.time_rule_model || other?.time_rule_group) {
+        const ruleLines = [];
+        if (other?.time_rule_model) {
+          ruleLines.push(
+            t('{{rule}}：模型价格 {{multiplier}}x', {
+              rule: other.time_rule_model,
+              multiplier: other.time_rule_model_multiplier,
+            }),
+          );
+        }
+        if (other?.time_rule_group) {
+          ruleLines.push(
+            t('{{rule}}：分组倍率 {{multiplier}}x', {
+              rule: other.time_rule_group,
+              multiplier: other.time_rule_group_multiplier,
+            }),
+          );
+        }
+        expandDataLocal.push({
+          key: t('时段定价'),
+          value: ruleLines.join('；'),
+        });
+      }
+      if (other?.quota_exact !== undefined) {
+        expandDataLocal.push({
+          key: t('精确消耗'),
+          value: t('{{exact}} 额度，累计结转余数 {{carry}} 额度', {
+            exact: other.quota_exact,
+            carry: other.quota_carry,
+          }),
+        });
+      }
+      if (other?.pricing_version) {
+        expandDataLocal.push({
+          key: t('定价版本'),
+          value: `#${other.pricing_version}`,
+        });
+      }
+      if (isAdminUser && other?.admin_info?.payload_capture_request_id) {
+        const requestId = other.admin_info.payload_capture_request_id;
+        expandDataLocal.push({
+          key: t('请求内容'),
+          value: (
+            <Button
+              size='small'
+              theme='borderless'
+              onClick={() => showPayloadCaptureFunc(requestId)}
+            >
+              {t('查看')}
+            </Button>
+          ),
+        });
+        expandDataLocal.push({
+          key: t('请求重放'),
+          value: (
+            <Button
+              size='small'
+              theme='borderless'
+              onClick={() => setPayloadReplayRequestId(requestId)}
+            >
+              {t('重放')}
+            </Button>
+          ),
+        });
+      }
       if (isAdminUser) {
         let localCountMode = '';
         if (other?.admin_info?.local_count_tokens) {
@@ -646,6 +787,10 @@ export const useLogsData = () => {
     userInfoData,
     showUserInfoFunc,
 
+    // Payload replay modal
+    payloadReplayRequestId,
+    setPayloadReplayRequestId,
+
     // Functions
     loadLogs,
     handlePageChange,
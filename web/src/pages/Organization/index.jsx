@@ -0,0 +1,596 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState } from 'react';
+import {
+  Button,
+  Card,
+  Descriptions,
+  Empty,
+  Input,
+  InputNumber,
+  Modal,
+  Popconfirm,
+  Select,
+  Space,
+  Spin,
+  Table,
+  Tabs,
+  TabPane,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import { useTranslation } from 'react-i18next';
+import {
+  API,
+  copy,
+  renderQuota,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../helpers';
+
+const { Text } = Typography;
+
+const ROLES = {
+  owner: { label: '所有者', color: 'red' },
+  admin: { label: '管理员', color: 'orange' },
+  member: { label: '成员', color: 'blue' },
+  billing: { label: '财务', color: 'green' },
+};
+
+const INVITATION_STATUSES = {
+  pending: { label: '待接受', color: 'blue' },
+  accepted: { label: '已接受', color: 'green' },
+  revoked: { label: '已撤销', color: 'grey' },
+};
+
+const Organization = () => {
+  const { t } = useTranslation();
+  const [loading, setLoading] = useState(false);
+  const [organizations, setOrganizations] = useState([]);
+  const [currentId, setCurrentId] = useState(null);
+  const [detail, setDetail] = useState(null);
+  const [invitations, setInvitations] = useState([]);
+  const [tokens, setTokens] = useState([]);
+  const [usage, setUsage] = useState([]);
+
+  const [newName, setNewName] = useState('');
+  const [inviteCode, setInviteCode] = useState('');
+  const [fundQuota, setFundQuota] = useState(0);
+  const [inviteForm, setInviteForm] = useState({
+    email: '',
+    role: 'member',
+    valid_days: 7,
+  });
+  const [editingMember, setEditingMember] = useState(null);
+
+  const org = detail?.organization;
+  const role = org?.role;
+  const canManageMembers = role === 'owner' || role === 'admin';
+  const canManageBilling = canManageMembers || role === 'billing';
+
+  const renderRole = (value) => {
+    const item = ROLES[value] || { label: value, color: 'grey' };
+    return <Tag color={item.color}>{t(item.label)}</Tag>;
+  };
+
+  const loadOrganizations = async (selectId) => {
+    setLoading(true);
+    try {
+      const res = await API.get('/api/organization/');
+      const { success, message, data } = res.data;
+      if (!success) {
+        showError(message);
+        return;
+      }
+      const list = data || [];
+      setOrganizations(list);
+      let id = selectId ?? currentId;
+      if (!list.some((item) => item.id === id)) {
+        id = list.length > 0 ? list[0].id : null;
+      }
+      setCurrentId(id);
+      if (id === null) {
+        setDetail(null);
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const loadDetail = async (id) => {
+    const res = await API.get(`/api/organization/${id}`);
+    const { success, message, data } = res.data;
+    if (!success) {
+      showError(message);
+      return;
+    }
+    setDetail(data);
+    const memberRole = data.organization.role;
+    const requests = [
+      API.get(`/api/organization/${id}/token`),
+      API.get(`/api/organization/${id}/usage`),
+    ];
+    if (memberRole === 'owner' || memberRole === 'admin') {
+      requests.push(API.get(`/api/organization/${id}/invitation`));
+    } else {
+      setInvitations([]);
+    }
+    const [tokenRes, usageRes, invitationRes] = await Promise.all(requests);
+    if (tokenRes.data.success) setTokens(tokenRes.data.data || []);
+    if (usageRes.data.success) setUsage(usageRes.data.data.items || []);
+    if (invitationRes?.data.success) {
+      setInvitations(invitationRes.data.data || []);
+    }
+  };
+
+  useEffect(() => {
+    loadOrganizations();
+  }, []);
+
+  useEffect(() => {
+    if (currentId !== null) {
+      loadDetail(currentId).then();
+    }
+  }, [currentId]);
+
+  const handleResult = async (res, successMessage, selectId) => {
+    const { success, message } = res.data;
+    if (!success) {
+      showError(message);
+      return false;
+    }
+    showSuccess(t(successMessage));
+    await loadOrganizations(selectId);
+    // 切换团队时由 currentId 的 effect 负责加载详情
+    if (selectId === undefined && currentId !== null) {
+      await loadDetail(currentId);
+    }
+    return true;
+  };
+
+  const createOrganization = async () => {
+    const res = await API.post('/api/organization/', { name: newName });
+    if (res.data.success) {
+      setNewName('');
+      await handleResult(res, '团队创建成功', res.data.data.id);
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  const acceptInvitation = async () => {
+    const res = await API.post('/api/organization/invitation/accept', {
+      code: inviteCode.trim(),
+    });
+    if (res.data.success) {
+      setInviteCode('');
+      await handleResult(res, '已加入团队', res.data.data.id);
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  const fundOrganization = async () => {
+    const res = await API.post(`/api/organization/${currentId}/fund`, {
+      quota: parseInt(fundQuota, 10) || 0,
+    });
+    if (await handleResult(res, '充值成功')) {
+      setFundQuota(0);
+    }
+  };
+
+  const leaveOrganization = async () => {
+    const res = await API.post(`/api/organization/${currentId}/leave`);
+    await handleResult(res, '已退出团队', null);
+  };
+
+  const deleteOrganization = async () => {
+    const res = await API.delete(`/api/organization/${currentId}`);
+    await handleResult(res, '团队已解散，剩余额度已退回', null);
+  };
+
+  const saveMember = async () => {
+    const res = await API.put(`/api/organization/${currentId}/member`, {
+      user_id: editingMember.user_id,
+      role: editingMember.role,
+      spending_cap: parseInt(editingMember.spending_cap, 10) || 0,
+      reset_used: editingMember.reset_used || false,
+    });
+    if (await handleResult(res, '保存成功')) {
+      setEditingMember(null);
+    }
+  };
+
+  const removeMember = async (userId) => {
+    const res = await API.delete(
+      `/api/organization/${currentId}/member/${userId}`,
+    );
+    await handleResult(res, '成员已移除');
+  };
+
+  const createInvitation = async () => {
+    const res = await API.post(
+      `/api/organization/${currentId}/invitation`,
+      inviteForm,
+    );
+    if (await handleResult(res, '邀请已创建')) {
+      setInviteForm({ email: '', role: 'member', valid_days: 7 });
+    }
+  };
+
+  const revokeInvitation = async (invitationId) => {
+    const res = await API.delete(
+      `/api/organization/${currentId}/invitation/${invitationId}`,
+    );
+    await handleResult(res, '邀请已撤销');
+  };
+
+  const roleOptions = Object.entries(ROLES)
+    .filter(([key]) => key !== 'owner' || role === 'owner')
+    .map(([key, item]) => ({ label: t(item.label), value: key }));
+
+  const memberColumns = [
+    {
+      title: t('用户'),
+      dataIndex: 'username',
+      render: (text, record) =>
+        `${record.display_name || text} (#${record.user_id})`,
+    },
+    { title: t('角色'), dataIndex: 'role', render: renderRole },
+    {
+      title: t('消费上限'),
+      dataIndex: 'spending_cap',
+      render: (value) => (value > 0 ? renderQuota(value) : t('不限制')),
+    },
+    {
+      title: t('已用额度'),
+      dataIndex: 'used_quota',
+      render: (value) => renderQuota(value),
+    },
+    {
+      title: t('加入时间'),
+      dataIndex: 'created_time',
+      render: (value) => timestamp2string(value),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, record) =>
+        canManageMembers && record.role !== 'owner' ? (
+          <Space>
+            <Button size='small' onClick={() => setEditingMember(record)}>
+              {t('编辑')}
+            </Button>
+            <Popconfirm
+              title={t('确定移除该成员？')}
+              content={t('该成员创建的团队令牌将被禁用')}
+              onConfirm={() => removeMember(record.user_id)}
+            >
+              <Button size='small' type='danger'>
+                {t('移除')}
+              </Button>
+            </Popconfirm>
+          </Space>
+        ) : role === 'owner' ? (
+          <Button size='small' onClick={() => setEditingMember(record)}>
+            {t('编辑')}
+          </Button>
+        ) : null,
+    },
+  ];
+
+  const invitationColumns = [
+    {
+      title: t('邀请码'),
+      dataIndex: 'code',
+      render: (text) => (
+        <Text copyable={{ onCopy: () => copy(text) }} code>
+          {text}
+        </Text>
+      ),
+    },
+    { title: t('邮箱'), dataIndex: 'email' },
+    { title: t('角色'), dataIndex: 'role', render: renderRole },
+    {
+      title: t('状态'),
+      dataIndex: 'status',
+      render: (value) => {
+        const item = INVITATION_STATUSES[value] || {
+          label: value,
+          color: 'grey',
+        };
+        return <Tag color={item.color}>{t(item.label)}</Tag>;
+      },
+    },
+    {
+      title: t('过期时间'),
+      dataIndex: 'expired_time',
+      render: (value) => timestamp2string(value),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, record) =>
+        record.status === 'pending' ? (
+          <Button
+            size='small'
+            type='danger'
+            onClick={() => revokeInvitation(record.id)}
+          >
+            {t('撤销')}
+          </Button>
+        ) : null,
+    },
+  ];
+
+  const tokenColumns = [
+    { title: t('名称'), dataIndex: 'name' },
+    { title: t('创建者'), dataIndex: 'user_id', render: (v) => `#${v}` },
+    {
+      title: t('状态'),
+      dataIndex: 'status',
+      render: (value) =>
+        value === 1 ? (
+          <Tag color='green'>{t('已启用')}</Tag>
+        ) : (
+          <Tag color='grey'>{t('已禁用')}</Tag>
+        ),
+    },
+    {
+      title: t('已用额度'),
+      dataIndex: 'used_quota',
+      render: (value) => renderQuota(value),
+    },
+  ];
+
+  const usageColumns = [
+    {
+      title: t('用户'),
+      dataIndex: 'username',
+      render: (text, record) => text || `#${record.user_id}`,
+    },
+    { title: t('模型'), dataIndex: 'model_name' },
+    { title: t('请求次数'), dataIndex: 'request_count' },
+    { title: t('输入 Tokens'), dataIndex: 'prompt_tokens' },
+    { title: t('输出 Tokens'), dataIndex: 'completion_tokens' },
+    {
+      title: t('消耗额度'),
+      dataIndex: 'quota',
+      render: (value) => renderQuota(value),
+    },
+  ];
+
+  return (
+    <div className='mt-[60px] px-2'>
+      <Spin spinning={loading}>
+        <Card className='!rounded-2xl mb-4'>
+          <Space wrap>
+            <Select
+              style={{ width: 240 }}
+              placeholder={t('选择团队')}
+              value={currentId}
+              onChange={setCurrentId}
+              optionList={organizations.map((item) => ({
+                label: item.name,
+                value: item.id,
+              }))}
+            />
+            <Input
+              style={{ width: 200 }}
+              placeholder={t('新团队名称')}
+              value={newName}
+              onChange={setNewName}
+            />
+            <Button onClick={createOrganization} disabled={!newName.trim()}>
+              {t('创建团队')}
+            </Button>
+            <Input
+              style={{ width: 200 }}
+              placeholder={t('邀请码')}
+              value={inviteCode}
+              onChange={setInviteCode}
+            />
+            <Button onClick={acceptInvitation} disabled={!inviteCode.trim()}>
+              {t('加入团队')}
+            </Button>
+          </Space>
+        </Card>
+
+        {!org ? (
+          <Card className='!rounded-2xl'>
+            <Empty description={t('还没有加入任何团队')} />
+          </Card>
+        ) : (
+          <Card className='!rounded-2xl'>
+            <Descriptions
+              row
+              data={[
+                { key: t('团队名称'), value: org.name },
+                { key: t('我的角色'), value: renderRole(org.role) },
+                { key: t('额度池余额'), value: renderQuota(org.quota) },
+                { key: t('已用额度'), value: renderQuota(org.used_quota) },
+                { key: t('成员数'), value: org.member_count },
+                {
+                  key: t('状态'),
+                  value:
+                    org.status === 1 ? (
+                      <Tag color='green'>{t('已启用')}</Tag>
+                    ) : (
+                      <Tag color='grey'>{t('已禁用')}</Tag>
+                    ),
+                },
+              ]}
+            />
+            <Space wrap className='mt-4'>
+              {canManageBilling && (
+                <>
+                  <InputNumber
+                    style={{ width: 200 }}
+                    min={0}
+                    value={fundQuota}
+                    onChange={setFundQuota}
+                    placeholder={t('从个人额度转入')}
+                  />
+                  <Button onClick={fundOrganization} disabled={!fundQuota}>
+                    {t('充值到额度池')}
+                  </Button>
+                </>
+              )}
+              {role === 'owner' ? (
+                <Popconfirm
+                  title={t('确定解散团队？')}
+                  content={t('剩余额度将退回给所有者，团队令牌将被禁用')}
+                  onConfirm={deleteOrganization}
+                >
+                  <Button type='danger'>{t('解散团队')}</Button>
+                </Popconfirm>
+              ) : (
+                <Popconfirm
+                  title={t('确定退出团队？')}
+                  onConfirm={leaveOrganization}
+                >
+                  <Button type='danger'>{t('退出团队')}</Button>
+                </Popconfirm>
+              )}
+            </Space>
+
+            <Tabs type='line' className='mt-4'>
+              <TabPane tab={t('成员')} itemKey='members'>
+                <Table
+                  columns={memberColumns}
+                  dataSource={detail.members || []}
+                  rowKey='id'
+                  pagination={false}
+                  size='small'
+                />
+              </TabPane>
+              {canManageMembers && (
+                <TabPane tab={t('邀请')} itemKey='invitations'>
+                  <Space wrap className='mb-3'>
+                    <Input
+                      style={{ width: 200 }}
+                      placeholder={t('邮箱（可选）')}
+                      value={inviteForm.email}
+                      onChange={(v) =>
+                        setInviteForm({ ...inviteForm, email: v })
+                      }
+                    />
+                    <Select
+                      style={{ width: 120 }}
+                      value={inviteForm.role}
+                      optionList={roleOptions.filter(
+                        (o) => o.value !== 'owner',
+                      )}
+                      onChange={(v) =>
+                        setInviteForm({ ...inviteForm, role: v })
+                      }
+                    />
+                    <InputNumber
+                      style={{ width: 140 }}
+                      min={1}
+                      max={30}
+                      suffix={t('天')}
+                      value={inviteForm.valid_days}
+                      onChange={(v) =>
+                        setInviteForm({ ...inviteForm, valid_days: v })
+                      }
+                    />
+                    <Button onClick={createInvitation}>{t('创建邀请')}</Button>
+                  </Space>
+                  <Table
+                    columns={invitationColumns}
+                    dataSource={invitations}
+                    rowKey='id'
+                    pagination={false}
+                    size='small'
+                  />
+                </TabPane>
+              )}
+              <TabPane tab={t('团队令牌')} itemKey='tokens'>
+                <Text type='tertiary' className='block mb-2'>
+                  {t('在令牌管理中创建令牌时选择团队，即可使用团队额度池')}
+                </Text>
+                <Table
+                  columns={tokenColumns}
+                  dataSource={tokens}
+                  rowKey='id'
+                  pagination={false}
+                  size='small'
+                />
+              </TabPane>
+              <TabPane tab={t('用量报表')} itemKey='usage'>
+                <Text type='tertiary' className='block mb-2'>
+                  {t('最近 30 天团队令牌的消费统计')}
+                </Text>
+                <Table
+                  columns={usageColumns}
+                  dataSource={usage}
+                  rowKey={(record) => `${record.user_id}-${record.model_name}`}
+                  pagination={false}
+                  size='small'
+                />
+              </TabPane>
+            </Tabs>
+          </Card>
+        )}
+      </Spin>
+
+      <Modal
+        title={t('编辑成员')}
+        visible={editingMember !== null}
+        onOk={saveMember}
+        onCancel={() => setEditingMember(null)}
+      >
+        {editingMember && (
+          <Space vertical align='start' style={{ width: '100%' }}>
+            <Text>{t('角色')}</Text>
+            <Select
+              style={{ width: '100%' }}
+              value={editingMember.role}
+              optionList={roleOptions}
+              onChange={(v) => setEditingMember({ ...editingMember, role: v })}
+            />
+            <Text>{t('消费上限（0 表示不限制）')}</Text>
+            <InputNumber
+              style={{ width: '100%' }}
+              min={0}
+              value={editingMember.spending_cap}
+              onChange={(v) =>
+                setEditingMember({ ...editingMember, spending_cap: v })
+              }
+            />
+            <Button
+              size='small'
+              onClick={() =>
+                setEditingMember({ ...editingMember, reset_used: true })
+              }
+              disabled={editingMember.reset_used}
+            >
+              {t('清零已用额度')}
+            </Button>
+          </Space>
+        )}
+      </Modal>
+    </div>
+  );
+};
+
+export default Organization;
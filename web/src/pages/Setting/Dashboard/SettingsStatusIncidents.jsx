@@ -0,0 +1,373 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useRef, useState } from 'react';
+import {
+  Button,
+  Form,
+  Modal,
+  Popconfirm,
+  Space,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import { Plus } from 'lucide-react';
+import {
+  API,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../../helpers';
+import { useTranslation } from 'react-i18next';
+
+const { Text } = Typography;
+
+const emptyIncident = {
+  id: 0,
+  type: 'incident',
+  title: '',
+  content: '',
+  severity: 'major',
+  status: 'investigating',
+  affected_models: '',
+  affected_groups: '',
+  start_time: 0,
+  end_time: 0,
+};
+
+const splitList = (value) =>
+  (value || '')
+    .split(',')
+    .map((item) => item.trim())
+    .filter(Boolean);
+
+const SettingsStatusIncidents = () => {
+  const { t } = useTranslation();
+  const [incidents, setIncidents] = useState([]);
+  const [total, setTotal] = useState(0);
+  const [page, setPage] = useState(1);
+  const [loading, setLoading] = useState(false);
+  const [editing, setEditing] = useState(null);
+  const [modalLoading, setModalLoading] = useState(false);
+  const formApiRef = useRef(null);
+  const pageSize = 10;
+
+  const severityOptions = [
+    { value: 'minor', label: t('轻微') },
+    { value: 'major', label: t('严重') },
+    { value: 'critical', label: t('紧急') },
+  ];
+  const statusOptions = [
+    { value: 'investigating', label: t('调查中') },
+    { value: 'identified', label: t('已定位') },
+    { value: 'monitoring', label: t('观察中') },
+    { value: 'resolved', label: t('已解决') },
+  ];
+
+  const loadIncidents = async (p = page) => {
+    setLoading(true);
+    try {
+      const res = await API.get(
+        `/api/status/incidents/?p=${p}&page_size=${pageSize}`,
+      );
+      const { success, message, data } = res.data;
+      if (success) {
+        setIncidents(data.items || []);
+        setTotal(data.total || 0);
+      } else {
+        showError(message);
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  useEffect(() => {
+    loadIncidents(page);
+  }, [page]);
+
+  const openEditor = (incident) => {
+    setEditing({
+      ...incident,
+      affected_models: splitList(incident.affected_models),
+      affected_groups: splitList(incident.affected_groups),
+      start_time: incident.start_time
+        ? new Date(incident.start_time * 1000)
+        : new Date(),
+      end_time: incident.end_time ? new Date(incident.end_time * 1000) : null,
+    });
+  };
+
+  const handleSave = async () => {
+    let values;
+    try {
+      values = await formApiRef.current.validate();
+    } catch (e) {
+      return;
+    }
+    const toTimestamp = (date) =>
+      date ? Math.floor(new Date(date).getTime() / 1000) : 0;
+    const payload = {
+      ...editing,
+      ...values,
+      affected_models: (values.affected_models || []).join(','),
+      affected_groups: (values.affected_groups || []).join(','),
+      start_time: toTimestamp(values.start_time),
+      end_time: toTimestamp(values.end_time),
+    };
+    setModalLoading(true);
+    try {
+      const res = payload.id
+        ? await API.put('/api/status/incidents/', payload)
+        : await API.post('/api/status/incidents/', payload);
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t('保存成功'));
+        setEditing(null);
+        await loadIncidents(page);
+      } else {
+        showError(message);
+      }
+    } finally {
+      setModalLoading(false);
+    }
+  };
+
+  const handleDelete = async (id) => {
+    const res = await API.delete(`/api/status/incidents/${id}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('删除成功'));
+      await loadIncidents(page);
+    } else {
+      showError(message);
+    }
+  };
+
+  const columns = [
+    {
+      title: t('类型'),
+      dataIndex: 'type',
+      render: (text) =>
+        text === 'maintenance' ? (
+          <Tag color='blue'>{t('维护')}</Tag>
+        ) : (
+          <Tag color='red'>{t('故障')}</Tag>
+        ),
+    },
+    {
+      title: t('标题'),
+      dataIndex: 'title',
+      render: (text) => (
+        <Text ellipsis={{ showTooltip: true }} style={{ maxWidth: 240 }}>
+          {text}
+        </Text>
+      ),
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'status',
+      render: (text, record) => {
+        if (record.type === 'maintenance') return '-';
+        const option = statusOptions.find((item) => item.value === text);
+        return (
+          <Tag color={text === 'resolved' ? 'green' : 'orange'}>
+            {option ? option.label : text}
+          </Tag>
+        );
+      },
+    },
+    {
+      title: t('影响范围'),
+      dataIndex: 'affected_models',
+      render: (text, record) => {
+        const components = [
+          ...splitList(record.affected_models),
+          ...splitList(record.affected_groups),
+        ];
+        return components.length > 0 ? (
+          <Text ellipsis={{ showTooltip: true }} style={{ maxWidth: 200 }}>
+            {components.join(', ')}
+          </Text>
+        ) : (
+          t('全部')
+        );
+      },
+    },
+    {
+      title: t('开始时间'),
+      dataIndex: 'start_time',
+      render: (text) => timestamp2string(text),
+    },
+    {
+      title: t('结束时间'),
+      dataIndex: 'end_time',
+      render: (text) => (text ? timestamp2string(text) : '-'),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      fixed: 'right',
+      render: (text, record) => (
+        <Space>
+          <Button
+            size='small'
+            type='tertiary'
+            onClick={() => openEditor(record)}
+          >
+            {t('编辑')}
+          </Button>
+          <Popconfirm
+            title={t('确定要删除此公告吗？')}
+            onConfirm={() => handleDelete(record.id)}
+          >
+            <Button size='small' type='danger'>
+              {t('删除')}
+            </Button>
+          </Popconfirm>
+        </Space>
+      ),
+    },
+  ];
+
+  return (
+    <>
+      <Form.Section
+        text={
+          <div className='flex items-center justify-between w-full'>
+            <span>{t('故障与维护公告')}</span>
+            <Button
+              icon={<Plus size={14} />}
+              theme='light'
+              type='primary'
+              onClick={() => openEditor(emptyIncident)}
+            >
+              {t('发布公告')}
+            </Button>
+          </div>
+        }
+      >
+        <Table
+          columns={columns}
+          dataSource={incidents}
+          rowKey='id'
+          loading={loading}
+          size='middle'
+          scroll={{ x: 'max-content' }}
+          pagination={{
+            currentPage: page,
+            pageSize,
+            total,
+            onPageChange: setPage,
+          }}
+        />
+      </Form.Section>
+
+      <Modal
+        title={editing?.id ? t('编辑公告') : t('发布公告')}
+        visible={editing !== null}
+        onOk={handleSave}
+        onCancel={() => setEditing(null)}
+        okText={t('保存')}
+        cancelText={t('取消')}
+        confirmLoading={modalLoading}
+      >
+        {editing && (
+          <Form
+            layout='vertical'
+            initValues={editing}
+            key={editing.id || 'new'}
+            getFormApi={(api) => (formApiRef.current = api)}
+          >
+            {({ values }) => (
+              <>
+                <Form.RadioGroup
+                  field='type'
+                  label={t('类型')}
+                  type='button'
+                  options={[
+                    { value: 'incident', label: t('故障') },
+                    { value: 'maintenance', label: t('维护') },
+                  ]}
+                />
+                <Form.Input
+                  field='title'
+                  label={t('标题')}
+                  rules={[{ required: true, message: t('请输入标题') }]}
+                />
+                <Form.TextArea field='content' label={t('详细说明')} rows={3} />
+                {values.type === 'incident' && (
+                  <>
+                    <Form.Select
+                      field='severity'
+                      label={t('严重程度')}
+                      optionList={severityOptions}
+                      extraText={t(
+                        '轻微显示为性能下降，严重与紧急显示为不可用',
+                      )}
+                    />
+                    <Form.Select
+                      field='status'
+                      label={t('处理状态')}
+                      optionList={statusOptions}
+                    />
+                  </>
+                )}
+                <Form.TagInput
+                  field='affected_models'
+                  label={t('受影响的模型')}
+                  placeholder={t('为空表示影响整体服务')}
+                />
+                <Form.TagInput
+                  field='affected_groups'
+                  label={t('受影响的分组')}
+                  placeholder={t('为空表示影响整体服务')}
+                />
+                <Form.DatePicker
+                  field='start_time'
+                  label={t('开始时间')}
+                  type='dateTime'
+                  rules={[{ required: true, message: t('请选择开始时间') }]}
+                />
+                <Form.DatePicker
+                  field='end_time'
+                  label={t('结束时间')}
+                  type='dateTime'
+                  extraText={
+                    values.type === 'maintenance'
+                      ? t('维护窗口的计划结束时间')
+                      : t('留空时在标记为已解决时自动记录')
+                  }
+                  rules={
+                    values.type === 'maintenance'
+                      ? [{ required: true, message: t('请选择结束时间') }]
+                      : []
+                  }
+                />
+              </>
+            )}
+          </Form>
+        )}
+      </Modal>
+    </>
+  );
+};
+
+export default SettingsStatusIncidents;
@@ -0,0 +1,254 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState, useRef } from 'react';
+import { Button, Col, Form, Row, Spin } from '@douyinfe/semi-ui';
+import {
+  compareObjects,
+  API,
+  showError,
+  showSuccess,
+  showWarning,
+  toBoolean,
+} from '../../../helpers';
+import { useTranslation } from 'react-i18next';
+
+const parseList = (value) => {
+  try {
+    const list = JSON.parse(value || '[]');
+    return Array.isArray(list) ? list : [];
+  } catch (e) {
+    return [];
+  }
+};
+
+export default function SettingsStatusPage(props) {
+  const { t } = useTranslation();
+
+  const [loading, setLoading] = useState(false);
+  const [inputs, setInputs] = useState({
+    'status_page_setting.enabled': true,
+    'status_page_setting.models': '[]',
+    'status_page_setting.groups': '[]',
+    'status_page_setting.show_latency': true,
+    'status_page_setting.degraded_threshold': 0.99,
+    'status_page_setting.down_threshold': 0.9,
+    'status_page_setting.retention_days': 35,
+  });
+  const refForm = useRef();
+  const [inputsRow, setInputsRow] = useState(inputs);
+
+  function onSubmit() {
+    const updateArray = compareObjects(inputs, inputsRow);
+    if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    if (
+      Number(inputs['status_page_setting.down_threshold']) >
+      Number(inputs['status_page_setting.degraded_threshold'])
+    ) {
+      return showError(t('不可用阈值不能高于性能下降阈值'));
+    }
+    const requestQueue = updateArray.map((item) => {
+      let value = '';
+      if (typeof inputs[item.key] === 'boolean') {
+        value = String(inputs[item.key]);
+      } else {
+        value = inputs[item.key];
+      }
+      return API.put('/api/option/', {
+        key: item.key,
+        value,
+      });
+    });
+    setLoading(true);
+    Promise.all(requestQueue)
+      .then((res) => {
+        if (requestQueue.length === 1) {
+          if (res.includes(undefined)) return;
+        } else if (requestQueue.length > 1) {
+          if (res.includes(undefined))
+            return showError(t('部分保存失败，请重试'));
+        }
+        showSuccess(t('保存成功'));
+        props.refresh();
+      })
+      .catch(() => {
+        showError(t('保存失败，请重试'));
+      })
+      .finally(() => {
+        setLoading(false);
+      });
+  }
+
+  useEffect(() => {
+    const currentInputs = { ...inputs };
+    for (let key in props.options) {
+      if (!Object.keys(inputs).includes(key)) continue;
+      if (typeof inputs[key] === 'boolean') {
+        currentInputs[key] = toBoolean(props.options[key]);
+      } else if (typeof inputs[key] === 'number') {
+        currentInputs[key] = Number(props.options[key]);
+      } else {
+        currentInputs[key] = props.options[key];
+      }
+    }
+    setInputs(currentInputs);
+    setInputsRow(structuredClone(currentInputs));
+    refForm.current.setValues({
+      ...currentInputs,
+      'status_page_setting.models': parseList(
+        currentInputs['status_page_setting.models'],
+      ),
+      'status_page_setting.groups': parseList(
+        currentInputs['status_page_setting.groups'],
+      ),
+    });
+  }, [props.options]);
+
+  return (
+    <>
+      <Spin spinning={loading}>
+        <Form
+          getFormApi={(formAPI) => (refForm.current = formAPI)}
+          style={{ marginBottom: 15 }}
+        >
+          <Form.Section text={t('状态页设置')}>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'status_page_setting.enabled'}
+                  label={t('启用内置状态页')}
+                  extraText={t(
+                    '根据真实请求与渠道测试统计可用率，公开访问 /status 查看；未配置 Uptime Kuma 时控制台可用性面板也使用该数据',
+                  )}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'status_page_setting.enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'status_page_setting.show_latency'}
+                  label={t('展示平均延迟')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'status_page_setting.show_latency': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={12} lg={12} xl={12}>
+                <Form.TagInput
+                  field={'status_page_setting.models'}
+                  label={t('展示的模型')}
+                  placeholder={t('为空时展示所有已启用的模型')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'status_page_setting.models': JSON.stringify(value),
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={12} lg={12} xl={12}>
+                <Form.TagInput
+                  field={'status_page_setting.groups'}
+                  label={t('展示的分组')}
+                  placeholder={t('为空时展示所有用户可选分组')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'status_page_setting.groups': JSON.stringify(value),
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('性能下降阈值')}
+                  step={0.01}
+                  min={0}
+                  max={1}
+                  extraText={t('最近两小时可用率低于该值时显示为性能下降')}
+                  field={'status_page_setting.degraded_threshold'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'status_page_setting.degraded_threshold': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('不可用阈值')}
+                  step={0.01}
+                  min={0}
+                  max={1}
+                  extraText={t('最近两小时可用率低于该值时显示为不可用')}
+                  field={'status_page_setting.down_threshold'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'status_page_setting.down_threshold': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('样本保留天数')}
+                  step={1}
+                  min={0}
+                  suffix={t('天')}
+                  extraText={t('0 表示不清理')}
+                  field={'status_page_setting.retention_days'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'status_page_setting.retention_days': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Button size='default' onClick={onSubmit}>
+                {t('保存状态页设置')}
+              </Button>
+            </Row>
+          </Form.Section>
+        </Form>
+      </Spin>
+    </>
+  );
+}
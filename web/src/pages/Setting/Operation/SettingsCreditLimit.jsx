@@ -37,6 +37,7 @@ export default function SettingsCreditLimit(props) {
     QuotaForInviter: '',
     QuotaForInvitee: '',
     'quota_setting.enable_free_model_pre_consume': true,
+    'quota_setting.fractional_quota_enabled': false,
   });
   const refForm = useRef();
   const [inputsRow, setInputsRow] = useState(inputs);
@@ -182,6 +183,23 @@ export default function SettingsCreditLimit(props) {
                 />
               </Col>
             </Row>
+            <Row>
+              <Col>
+                <Form.Switch
+                  label={t('累计不足 1 额度的消耗')}
+                  field={'quota_setting.fractional_quota_enabled'}
+                  extraText={t(
+                    '开启后，按倍率计算出的不足 1 额度的部分按用户令牌累计并结转到后续请求，不再逐次取整或按最低 1 额度扣费',
+                  )}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'quota_setting.fractional_quota_enabled': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
 
             <Row>
               <Button size='default' onClick={onSubmit}>
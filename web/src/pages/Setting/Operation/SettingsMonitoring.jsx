@@ -40,6 +40,24 @@ export default function SettingsMonitoring(props) {
     AutomaticDisableKeywords: '',
     'monitor_setting.auto_test_channel_enabled': false,
     'monitor_setting.auto_test_channel_minutes': 10,
+    'monitor_setting.model_verify_enabled': false,
+    'monitor_setting.model_verify_minutes': 360,
+    'monitor_setting.model_verify_threshold': 0.6,
+    'monitor_setting.model_verify_auto_disable': false,
+    'monitor_setting.model_verify_notify': true,
+    'monitor_setting.model_verify_retention_days': 30,
+    'monitor_setting.capability_test_enabled': false,
+    'monitor_setting.capability_test_minutes': 60,
+    'monitor_setting.capability_test_matrix': '',
+    'monitor_setting.capability_test_long_context_tokens': 32000,
+    'monitor_setting.capability_test_retention_days': 30,
+    'monitor_setting.model_sync_enabled': false,
+    'monitor_setting.model_sync_minutes': 1440,
+    'monitor_setting.model_sync_auto_add': false,
+    'monitor_setting.model_sync_allow_patterns': '',
+    'monitor_setting.model_sync_auto_remove': false,
+    'monitor_setting.model_sync_notify': true,
+    'monitor_setting.model_sync_retention_days': 90,
   });
   const refForm = useRef();
   const [inputsRow, setInputsRow] = useState(inputs);
@@ -47,6 +65,20 @@ export default function SettingsMonitoring(props) {
   function onSubmit() {
     const updateArray = compareObjects(inputs, inputsRow);
     if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    const matrixKey = 'monitor_setting.capability_test_matrix';
+    if (
+      updateArray.some((item) => item.key === matrixKey) &&
+      !verifyJSON(inputs[matrixKey])
+    ) {
+      return showError(t('能力测试矩阵不是合法的 JSON 字符串'));
+    }
+    const patternsKey = 'monitor_setting.model_sync_allow_patterns';
+    if (
+      updateArray.some((item) => item.key === patternsKey) &&
+      !verifyJSON(inputs[patternsKey])
+    ) {
+      return showError(t('自动添加模型规则不是合法的 JSON 字符串'));
+    }
     const requestQueue = updateArray.map((item) => {
       let value = '';
       if (typeof inputs[item.key] === 'boolean') {
@@ -221,6 +253,337 @@ export default function SettingsMonitoring(props) {
                 />
               </Col>
             </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'monitor_setting.model_verify_enabled'}
+                  label={t('定时模型验真')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  extraText={t(
+                    '定时向各渠道发送指纹探针，评估所声称的模型是否真实',
+                  )}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.model_verify_enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('模型验真间隔时间')}
+                  step={1}
+                  min={1}
+                  suffix={t('分钟')}
+                  placeholder={''}
+                  field={'monitor_setting.model_verify_minutes'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.model_verify_minutes': parseInt(value),
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('模型验真通过阈值')}
+                  step={0.05}
+                  min={0}
+                  max={1}
+                  extraText={t('得分低于此值视为未通过，取值 0~1')}
+                  placeholder={''}
+                  field={'monitor_setting.model_verify_threshold'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.model_verify_threshold': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'monitor_setting.model_verify_auto_disable'}
+                  label={t('验真未通过时自动禁用通道')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.model_verify_auto_disable': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'monitor_setting.model_verify_notify'}
+                  label={t('验真未通过时通知管理员')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.model_verify_notify': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('验真记录保留天数')}
+                  step={1}
+                  min={0}
+                  suffix={t('天')}
+                  extraText={t('为 0 时不清理')}
+                  placeholder={''}
+                  field={'monitor_setting.model_verify_retention_days'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.model_verify_retention_days':
+                        parseInt(value),
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'monitor_setting.capability_test_enabled'}
+                  label={t('定时能力测试')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  extraText={t(
+                    '按渠道类型的测试矩阵定时测试流式、工具调用、图像输入等能力',
+                  )}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.capability_test_enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('能力测试间隔时间')}
+                  step={1}
+                  min={1}
+                  suffix={t('分钟')}
+                  placeholder={''}
+                  field={'monitor_setting.capability_test_minutes'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.capability_test_minutes':
+                        parseInt(value),
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('长上下文测试长度')}
+                  step={1000}
+                  min={1000}
+                  suffix={'Token'}
+                  placeholder={''}
+                  field={'monitor_setting.capability_test_long_context_tokens'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.capability_test_long_context_tokens':
+                        parseInt(value),
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={16}>
+                <Form.TextArea
+                  label={t('能力测试矩阵')}
+                  extraText={t(
+                    '键为渠道类型编号，default 为默认矩阵；可选能力：stream、tool_call、vision、json_mode、embedding、long_context',
+                  )}
+                  placeholder={'{"default": ["stream", "tool_call"]}'}
+                  field={'monitor_setting.capability_test_matrix'}
+                  autosize={{ minRows: 4, maxRows: 12 }}
+                  trigger='blur'
+                  stopValidateWithError
+                  rules={[
+                    {
+                      validator: (rule, value) => verifyJSON(value),
+                      message: t('不是合法的 JSON 字符串'),
+                    },
+                  ]}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.capability_test_matrix': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={8}>
+                <Form.InputNumber
+                  label={t('能力测试记录保留天数')}
+                  step={1}
+                  min={0}
+                  suffix={t('天')}
+                  extraText={t('为 0 时不清理')}
+                  placeholder={''}
+                  field={'monitor_setting.capability_test_retention_days'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.capability_test_retention_days':
+                        parseInt(value),
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'monitor_setting.model_sync_enabled'}
+                  label={t('定时同步上游模型')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  extraText={t(
+                    '定时拉取渠道上游的模型列表，记录新增与移除的模型并通知管理员',
+                  )}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.model_sync_enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('上游模型同步间隔时间')}
+                  step={1}
+                  min={1}
+                  suffix={t('分钟')}
+                  placeholder={''}
+                  field={'monitor_setting.model_sync_minutes'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.model_sync_minutes': parseInt(value),
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'monitor_setting.model_sync_notify'}
+                  label={t('上游模型变化时通知管理员')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.model_sync_notify': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'monitor_setting.model_sync_auto_add'}
+                  label={t('自动添加上游新增的模型')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.model_sync_auto_add': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'monitor_setting.model_sync_auto_remove'}
+                  label={t('自动移除上游已下线的模型')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  extraText={t('不会移除渠道的全部模型')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.model_sync_auto_remove': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('上游模型同步记录保留天数')}
+                  step={1}
+                  min={0}
+                  suffix={t('天')}
+                  extraText={t('为 0 时不清理')}
+                  placeholder={''}
+                  field={'monitor_setting.model_sync_retention_days'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.model_sync_retention_days':
+                        parseInt(value),
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={16}>
+                <Form.TextArea
+                  label={t('自动添加模型规则')}
+                  extraText={t(
+                    '正则表达式数组，只有匹配其中之一的新模型才会被自动添加，为空时不限制',
+                  )}
+                  placeholder={'["^gpt-", "^claude-"]'}
+                  field={'monitor_setting.model_sync_allow_patterns'}
+                  autosize={{ minRows: 2, maxRows: 8 }}
+                  trigger='blur'
+                  stopValidateWithError
+                  rules={[
+                    {
+                      validator: (rule, value) => verifyJSON(value),
+                      message: t('不是合法的 JSON 字符串'),
+                    },
+                  ]}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'monitor_setting.model_sync_allow_patterns': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
             <Row>
               <Button size='default' onClick={onSubmit}>
                 {t('保存监控设置')}
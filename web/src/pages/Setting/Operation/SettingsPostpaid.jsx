@@ -0,0 +1,467 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState, useRef } from 'react';
+import {
+  Button,
+  Col,
+  DatePicker,
+  Form,
+  Input,
+  InputNumber,
+  Modal,
+  Row,
+  Select,
+  Space,
+  Spin,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import { useTranslation } from 'react-i18next';
+import {
+  compareObjects,
+  API,
+  renderQuota,
+  showError,
+  showSuccess,
+  showWarning,
+  timestamp2string,
+} from '../../../helpers';
+
+const { Text } = Typography;
+
+const STATUSES = {
+  issued: { label: '待付款', color: 'blue' },
+  paid: { label: '已付款', color: 'green' },
+  overdue: { label: '已逾期', color: 'red' },
+};
+
+export default function SettingsPostpaid(props) {
+  const { t } = useTranslation();
+  const [loading, setLoading] = useState(false);
+  const [inputs, setInputs] = useState({
+    'postpaid_setting.enabled': false,
+    'postpaid_setting.due_days': 15,
+    'postpaid_setting.auto_suspend': true,
+  });
+  const refForm = useRef();
+  const [inputsRow, setInputsRow] = useState(inputs);
+
+  const pageSize = 10;
+  const [invoices, setInvoices] = useState([]);
+  const [invoiceTotal, setInvoiceTotal] = useState(0);
+  const [invoicePage, setInvoicePage] = useState(1);
+  const [query, setQuery] = useState({ user_id: '', status: '' });
+  const [viewingInvoice, setViewingInvoice] = useState(null);
+  const [generateUserId, setGenerateUserId] = useState('');
+  const [generateMonth, setGenerateMonth] = useState(null);
+  const [payingInvoice, setPayingInvoice] = useState(null);
+  const [payRemark, setPayRemark] = useState('');
+
+  function onSubmit() {
+    const updateArray = compareObjects(inputs, inputsRow);
+    if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    const requestQueue = updateArray.map((item) => {
+      let value = '';
+      if (typeof inputs[item.key] === 'boolean') {
+        value = String(inputs[item.key]);
+      } else {
+        value = inputs[item.key];
+      }
+      return API.put('/api/option/', {
+        key: item.key,
+        value,
+      });
+    });
+    setLoading(true);
+    Promise.all(requestQueue)
+      .then((res) => {
+        if (requestQueue.length === 1) {
+          if (res.includes(undefined)) return;
+        } else if (requestQueue.length > 1) {
+          if (res.includes(undefined))
+            return showError(t('部分保存失败，请重试'));
+        }
+        showSuccess(t('保存成功'));
+        props.refresh();
+      })
+      .catch(() => {
+        showError(t('保存失败，请重试'));
+      })
+      .finally(() => {
+        setLoading(false);
+      });
+  }
+
+  const loadInvoices = async (page = invoicePage) => {
+    const params = new URLSearchParams({
+      p: page,
+      page_size: pageSize,
+      ...query,
+    });
+    const res = await API.get(`/api/invoice/?${params.toString()}`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setInvoices(data.items || []);
+      setInvoiceTotal(data.total || 0);
+    } else {
+      showError(message);
+    }
+  };
+
+  const viewInvoice = async (id) => {
+    const res = await API.get(`/api/invoice/${id}`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setViewingInvoice(data);
+    } else {
+      showError(message);
+    }
+  };
+
+  const generateInvoice = async () => {
+    if (!generateUserId || !generateMonth) {
+      showError(t('请输入用户 ID 并选择账单月份'));
+      return;
+    }
+    const month = new Date(generateMonth);
+    const res = await API.post('/api/invoice/generate', {
+      user_id: generateUserId,
+      month: `${month.getFullYear()}-${String(month.getMonth() + 1).padStart(
+        2,
+        '0',
+      )}`,
+    });
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('账单已出具'));
+      loadInvoices(invoicePage);
+    } else {
+      showError(message);
+    }
+  };
+
+  const runInvoiceJob = async () => {
+    const res = await API.post('/api/invoice/run');
+    const { success, message, data } = res.data;
+    if (success) {
+      showSuccess(t('已出具 {{count}} 张账单', { count: data.generated }));
+      loadInvoices(invoicePage);
+    } else {
+      showError(message);
+    }
+  };
+
+  const markPaid = async () => {
+    const res = await API.post(`/api/invoice/${payingInvoice.id}/pay`, {
+      remark: payRemark,
+    });
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('已确认收款'));
+      setPayingInvoice(null);
+      setPayRemark('');
+      loadInvoices(invoicePage);
+    } else {
+      showError(message);
+    }
+  };
+
+  const resumeUser = async (userId) => {
+    const res = await API.post('/api/invoice/suspend', {
+      user_id: userId,
+      suspended: false,
+    });
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('已恢复调用'));
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    const currentInputs = {};
+    for (let key in props.options) {
+      if (Object.keys(inputs).includes(key)) {
+        currentInputs[key] = props.options[key];
+      }
+    }
+    setInputs(currentInputs);
+    setInputsRow(structuredClone(currentInputs));
+    refForm.current.setValues(currentInputs);
+  }, [props.options]);
+
+  useEffect(() => {
+    loadInvoices(invoicePage);
+  }, [invoicePage]);
+
+  const invoiceColumns = [
+    {
+      title: t('账单编号'),
+      dataIndex: 'invoice_no',
+    },
+    {
+      title: t('用户'),
+      dataIndex: 'user_id',
+    },
+    {
+      title: t('账单周期'),
+      dataIndex: 'period_start',
+      render: (value, record) =>
+        `${timestamp2string(value)} ~ ${timestamp2string(record.period_end)}`,
+    },
+    {
+      title: t('请求数'),
+      dataIndex: 'request_count',
+    },
+    {
+      title: t('账单额度'),
+      dataIndex: 'quota',
+      render: (value) => renderQuota(value),
+    },
+    {
+      title: t('付款期限'),
+      dataIndex: 'due_time',
+      render: (value) => timestamp2string(value),
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'status',
+      render: (value) => (
+        <Tag color={STATUSES[value]?.color}>
+          {t(STATUSES[value]?.label || value)}
+        </Tag>
+      ),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, record) => (
+        <Space>
+          <Button size='small' onClick={() => viewInvoice(record.id)}>
+            {t('明细')}
+          </Button>
+          {record.status !== 'paid' && (
+            <Button
+              size='small'
+              type='primary'
+              onClick={() => setPayingInvoice(record)}
+            >
+              {t('确认收款')}
+            </Button>
+          )}
+          {record.status === 'overdue' && (
+            <Button size='small' onClick={() => resumeUser(record.user_id)}>
+              {t('恢复调用')}
+            </Button>
+          )}
+        </Space>
+      ),
+    },
+  ];
+
+  const itemColumns = [
+    {
+      title: t('令牌'),
+      dataIndex: 'token_name',
+    },
+    {
+      title: t('模型'),
+      dataIndex: 'model_name',
+    },
+    {
+      title: t('请求数'),
+      dataIndex: 'request_count',
+    },
+    {
+      title: t('输入'),
+      dataIndex: 'prompt_tokens',
+    },
+    {
+      title: t('输出'),
+      dataIndex: 'completion_tokens',
+    },
+    {
+      title: t('额度'),
+      dataIndex: 'quota',
+      render: (value) => renderQuota(value),
+    },
+  ];
+
+  return (
+    <>
+      <Spin spinning={loading}>
+        <Form
+          values={inputs}
+          getFormApi={(formAPI) => (refForm.current = formAPI)}
+          style={{ marginBottom: 15 }}
+        >
+          <Form.Section text={t('后付费授信与账单')}>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'postpaid_setting.enabled'}
+                  label={t('开启后付费授信')}
+                  extraText={t(
+                    '设置了授信额度的用户余额可以透支到负数，每月初按上月消费出具账单',
+                  )}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'postpaid_setting.enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  field={'postpaid_setting.due_days'}
+                  label={t('付款期限')}
+                  step={1}
+                  min={1}
+                  suffix={t('天')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'postpaid_setting.due_days': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'postpaid_setting.auto_suspend'}
+                  label={t('逾期自动暂停')}
+                  extraText={t('账单逾期未付款时暂停该用户的所有调用')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'postpaid_setting.auto_suspend': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Button size='default' onClick={onSubmit}>
+                {t('保存后付费设置')}
+              </Button>
+            </Row>
+          </Form.Section>
+        </Form>
+      </Spin>
+      <Space className='mb-3' wrap>
+        <InputNumber
+          placeholder={t('用户 ID')}
+          value={query.user_id}
+          min={1}
+          onChange={(value) => setQuery({ ...query, user_id: value || '' })}
+          style={{ width: 120 }}
+        />
+        <Select
+          placeholder={t('状态')}
+          value={query.status || undefined}
+          optionList={Object.entries(STATUSES).map(([value, status]) => ({
+            value,
+            label: t(status.label),
+          }))}
+          onChange={(value) => setQuery({ ...query, status: value || '' })}
+          showClear
+          style={{ width: 120 }}
+        />
+        <Button
+          onClick={() => {
+            setInvoicePage(1);
+            loadInvoices(1);
+          }}
+        >
+          {t('查询')}
+        </Button>
+        <Button onClick={runInvoiceJob}>{t('立即出账')}</Button>
+      </Space>
+      <Space className='mb-3' wrap>
+        <InputNumber
+          placeholder={t('用户 ID')}
+          value={generateUserId}
+          min={1}
+          onChange={(value) => setGenerateUserId(value || '')}
+          style={{ width: 120 }}
+        />
+        <DatePicker
+          type='month'
+          placeholder={t('账单月份')}
+          value={generateMonth}
+          onChange={setGenerateMonth}
+        />
+        <Button onClick={generateInvoice}>{t('出具账单')}</Button>
+      </Space>
+      <Table
+        columns={invoiceColumns}
+        dataSource={invoices}
+        rowKey='id'
+        size='small'
+        pagination={{
+          currentPage: invoicePage,
+          pageSize,
+          total: invoiceTotal,
+          onPageChange: setInvoicePage,
+        }}
+      />
+      <Modal
+        title={
+          t('账单明细') +
+          (viewingInvoice ? ` ${viewingInvoice.invoice_no}` : '')
+        }
+        visible={!!viewingInvoice}
+        onCancel={() => setViewingInvoice(null)}
+        footer={null}
+        width={800}
+      >
+        {viewingInvoice?.remark && <Text>{viewingInvoice.remark}</Text>}
+        <Table
+          columns={itemColumns}
+          dataSource={viewingInvoice?.items || []}
+          rowKey={(record) => `${record.token_name}-${record.model_name}`}
+          size='small'
+          pagination={{ pageSize }}
+        />
+      </Modal>
+      <Modal
+        title={t('确认收款')}
+        visible={!!payingInvoice}
+        onOk={markPaid}
+        onCancel={() => setPayingInvoice(null)}
+      >
+        <Space vertical align='start' style={{ width: '100%' }}>
+          <Text>
+            {t('确认后将为用户恢复账单额度')}:{' '}
+            {renderQuota(payingInvoice?.quota || 0)}
+          </Text>
+          <Input
+            placeholder={t('备注，例如付款流水号')}
+            value={payRemark}
+            onChange={setPayRemark}
+          />
+        </Space>
+      </Modal>
+    </>
+  );
+}
@@ -0,0 +1,539 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState, useRef } from 'react';
+import {
+  Button,
+  Col,
+  DatePicker,
+  Form,
+  Input,
+  InputNumber,
+  Modal,
+  Row,
+  Select,
+  Space,
+  Spin,
+  Table,
+  TabPane,
+  Tabs,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import { useTranslation } from 'react-i18next';
+import {
+  compareObjects,
+  API,
+  showError,
+  showSuccess,
+  showWarning,
+  timestamp2string,
+} from '../../../helpers';
+
+const { Text } = Typography;
+
+const ACCOUNT_TYPES = ['user', 'token', 'checkin', 'system'];
+
+const SOURCES = [
+  'consume',
+  'pre_consume',
+  'refund',
+  'task',
+  'topup',
+  'redemption',
+  'aff_transfer',
+  'invite',
+  'register',
+  'admin',
+  'token_adjust',
+  'opening',
+  'subscription',
+  'invoice',
+  'checkin',
+  'expire',
+  'recovery',
+];
+
+const parseDrifts = (value) => {
+  try {
+    return JSON.parse(value || '[]');
+  } catch (e) {
+    return [];
+  }
+};
+
+export default function SettingsQuotaLedger(props) {
+  const { t } = useTranslation();
+  const [loading, setLoading] = useState(false);
+  const [inputs, setInputs] = useState({
+    'quota_ledger_setting.enabled': true,
+    'quota_ledger_setting.reconcile_enabled': true,
+    'quota_ledger_setting.reconcile_interval_minutes': 60,
+    'quota_ledger_setting.open_missing_accounts': true,
+    'quota_ledger_setting.notify_on_drift': true,
+  });
+  const refForm = useRef();
+  const [inputsRow, setInputsRow] = useState(inputs);
+
+  const pageSize = 10;
+  const [reports, setReports] = useState([]);
+  const [reportTotal, setReportTotal] = useState(0);
+  const [reportPage, setReportPage] = useState(1);
+  const [viewingReport, setViewingReport] = useState(null);
+  const [entries, setEntries] = useState([]);
+  const [entryTotal, setEntryTotal] = useState(0);
+  const [entryPage, setEntryPage] = useState(1);
+  const [query, setQuery] = useState({
+    account_type: 'user',
+    account_id: '',
+    source: '',
+    reference: '',
+  });
+  const [balanceAt, setBalanceAt] = useState(null);
+  const [balance, setBalance] = useState(null);
+
+  function onSubmit() {
+    const updateArray = compareObjects(inputs, inputsRow);
+    if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    const requestQueue = updateArray.map((item) => {
+      let value = '';
+      if (typeof inputs[item.key] === 'boolean') {
+        value = String(inputs[item.key]);
+      } else {
+        value = inputs[item.key];
+      }
+      return API.put('/api/option/', {
+        key: item.key,
+        value,
+      });
+    });
+    setLoading(true);
+    Promise.all(requestQueue)
+      .then((res) => {
+        if (requestQueue.length === 1) {
+          if (res.includes(undefined)) return;
+        } else if (requestQueue.length > 1) {
+          if (res.includes(undefined))
+            return showError(t('部分保存失败，请重试'));
+        }
+        showSuccess(t('保存成功'));
+        props.refresh();
+      })
+      .catch(() => {
+        showError(t('保存失败，请重试'));
+      })
+      .finally(() => {
+        setLoading(false);
+      });
+  }
+
+  const loadReports = async (page = reportPage) => {
+    const res = await API.get(
+      `/api/quota_ledger/reports?p=${page}&page_size=${pageSize}`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setReports(data.items || []);
+      setReportTotal(data.total || 0);
+    } else {
+      showError(message);
+    }
+  };
+
+  const loadEntries = async (page = entryPage) => {
+    const params = new URLSearchParams({
+      p: page,
+      page_size: pageSize,
+      ...query,
+    });
+    const res = await API.get(`/api/quota_ledger/?${params.toString()}`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setEntries(data.items || []);
+      setEntryTotal(data.total || 0);
+    } else {
+      showError(message);
+    }
+  };
+
+  const runReconcile = async () => {
+    const res = await API.post('/api/quota_ledger/reconcile');
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('对账任务已开始，请稍后刷新查看报告'));
+    } else {
+      showError(message);
+    }
+  };
+
+  const queryBalance = async () => {
+    if (!query.account_id) {
+      showError(t('请输入账户 ID'));
+      return;
+    }
+    const timestamp = balanceAt
+      ? Math.floor(new Date(balanceAt).getTime() / 1000)
+      : 0;
+    const res = await API.get(
+      `/api/quota_ledger/balance?account_type=${query.account_type}&account_id=${query.account_id}&timestamp=${timestamp}`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setBalance(data.balance);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    const currentInputs = {};
+    for (let key in props.options) {
+      if (Object.keys(inputs).includes(key)) {
+        currentInputs[key] = props.options[key];
+      }
+    }
+    setInputs(currentInputs);
+    setInputsRow(structuredClone(currentInputs));
+    refForm.current.setValues(currentInputs);
+  }, [props.options]);
+
+  useEffect(() => {
+    loadReports(reportPage);
+  }, [reportPage]);
+
+  useEffect(() => {
+    loadEntries(entryPage);
+  }, [entryPage]);
+
+  const reportColumns = [
+    {
+      title: t('对账时间'),
+      dataIndex: 'started_at',
+      render: (value) => timestamp2string(value),
+    },
+    {
+      title: t('用户数'),
+      dataIndex: 'user_count',
+    },
+    {
+      title: t('令牌数'),
+      dataIndex: 'token_count',
+    },
+    {
+      title: t('补记期初'),
+      dataIndex: 'opened_count',
+    },
+    {
+      title: t('偏差账户'),
+      dataIndex: 'drift_count',
+      render: (value, record) =>
+        record.error_message ? (
+          <Tag color='red'>{t('失败')}</Tag>
+        ) : (
+          <Tag color={value > 0 ? 'red' : 'green'}>{value}</Tag>
+        ),
+    },
+    {
+      title: t('偏差合计'),
+      dataIndex: 'total_drift',
+    },
+    {
+      title: t('流水不平衡额'),
+      dataIndex: 'imbalance',
+      render: (value) => (
+        <Text type={value !== 0 ? 'danger' : undefined}>{value}</Text>
+      ),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, record) =>
+        record.details || record.error_message ? (
+          <Button size='small' onClick={() => setViewingReport(record)}>
+            {t('详情')}
+          </Button>
+        ) : null,
+    },
+  ];
+
+  const entryColumns = [
+    {
+      title: t('时间'),
+      dataIndex: 'created_at',
+      render: (value) => timestamp2string(value),
+    },
+    {
+      title: t('账户'),
+      dataIndex: 'account_type',
+      render: (value, record) => `${value} #${record.account_id}`,
+    },
+    {
+      title: t('用户'),
+      dataIndex: 'user_id',
+    },
+    {
+      title: t('变动'),
+      dataIndex: 'delta',
+      render: (value) => (
+        <Text type={value < 0 ? 'danger' : 'success'}>
+          {value > 0 ? `+${value}` : value}
+        </Text>
+      ),
+    },
+    {
+      title: t('来源'),
+      dataIndex: 'source',
+      render: (value) => <Tag>{value}</Tag>,
+    },
+    {
+      title: t('来源单据'),
+      dataIndex: 'reference',
+      render: (value) => value || '-',
+    },
+    {
+      title: t('备注'),
+      dataIndex: 'remark',
+    },
+  ];
+
+  const driftColumns = [
+    {
+      title: t('账户'),
+      dataIndex: 'account_type',
+      render: (value, record) => `${value} #${record.account_id}`,
+    },
+    {
+      title: t('用户'),
+      dataIndex: 'user_id',
+    },
+    {
+      title: t('账户余额'),
+      dataIndex: 'balance',
+    },
+    {
+      title: t('流水余额'),
+      dataIndex: 'ledger_balance',
+    },
+    {
+      title: t('偏差'),
+      dataIndex: 'drift',
+    },
+  ];
+
+  return (
+    <>
+      <Spin spinning={loading}>
+        <Form
+          values={inputs}
+          getFormApi={(formAPI) => (refForm.current = formAPI)}
+          style={{ marginBottom: 15 }}
+        >
+          <Form.Section text={t('额度流水与对账')}>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'quota_ledger_setting.enabled'}
+                  label={t('记录额度流水')}
+                  extraText={t('每次额度变动都会写入一条只追加的复式流水')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'quota_ledger_setting.enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'quota_ledger_setting.reconcile_enabled'}
+                  label={t('定时对账')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'quota_ledger_setting.reconcile_enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  field={'quota_ledger_setting.reconcile_interval_minutes'}
+                  label={t('对账间隔')}
+                  step={1}
+                  min={1}
+                  suffix={t('分钟')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'quota_ledger_setting.reconcile_interval_minutes': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'quota_ledger_setting.open_missing_accounts'}
+                  label={t('补记期初余额')}
+                  extraText={t(
+                    '对账时为没有任何流水的账户按当前余额补记一条期初流水',
+                  )}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'quota_ledger_setting.open_missing_accounts': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'quota_ledger_setting.notify_on_drift'}
+                  label={t('发现偏差时通知')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'quota_ledger_setting.notify_on_drift': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Button size='default' onClick={onSubmit}>
+                {t('保存额度流水设置')}
+              </Button>
+            </Row>
+          </Form.Section>
+        </Form>
+      </Spin>
+      <Tabs type='line'>
+        <TabPane tab={t('对账报告')} itemKey='reports'>
+          <Space className='mb-3'>
+            <Button onClick={runReconcile}>{t('立即对账')}</Button>
+            <Button onClick={() => loadReports(reportPage)}>
+              {t('刷新')}
+            </Button>
+          </Space>
+          <Table
+            columns={reportColumns}
+            dataSource={reports}
+            rowKey='id'
+            size='small'
+            pagination={{
+              currentPage: reportPage,
+              pageSize,
+              total: reportTotal,
+              onPageChange: setReportPage,
+            }}
+          />
+        </TabPane>
+        <TabPane tab={t('额度流水')} itemKey='entries'>
+          <Space className='mb-3' wrap>
+            <Select
+              value={query.account_type}
+              optionList={ACCOUNT_TYPES.map((value) => ({
+                value,
+                label: value,
+              }))}
+              onChange={(value) => setQuery({ ...query, account_type: value })}
+              style={{ width: 120 }}
+            />
+            <InputNumber
+              placeholder={t('账户 ID')}
+              value={query.account_id}
+              min={1}
+              onChange={(value) =>
+                setQuery({ ...query, account_id: value || '' })
+              }
+              style={{ width: 120 }}
+            />
+            <Select
+              placeholder={t('来源')}
+              value={query.source || undefined}
+              optionList={SOURCES.map((value) => ({ value, label: value }))}
+              onChange={(value) => setQuery({ ...query, source: value || '' })}
+              showClear
+              style={{ width: 150 }}
+            />
+            <Input
+              placeholder={t('来源单据')}
+              value={query.reference}
+              onChange={(value) => setQuery({ ...query, reference: value })}
+              showClear
+            />
+            <Button
+              onClick={() => {
+                setEntryPage(1);
+                loadEntries(1);
+              }}
+            >
+              {t('查询')}
+            </Button>
+          </Space>
+          <Space className='mb-3' wrap>
+            <DatePicker
+              type='dateTime'
+              placeholder={t('留空表示当前')}
+              value={balanceAt}
+              onChange={setBalanceAt}
+            />
+            <Button onClick={queryBalance}>{t('查询历史余额')}</Button>
+            {balance !== null && (
+              <Text>
+                {t('流水余额')}: {balance}
+              </Text>
+            )}
+          </Space>
+          <Table
+            columns={entryColumns}
+            dataSource={entries}
+            rowKey='id'
+            size='small'
+            pagination={{
+              currentPage: entryPage,
+              pageSize,
+              total: entryTotal,
+              onPageChange: setEntryPage,
+            }}
+          />
+        </TabPane>
+      </Tabs>
+      <Modal
+        title={t('对账报告') + (viewingReport ? ` #${viewingReport.id}` : '')}
+        visible={!!viewingReport}
+        onCancel={() => setViewingReport(null)}
+        footer={null}
+        width={800}
+      >
+        {viewingReport?.error_message && (
+          <Text type='danger'>{viewingReport.error_message}</Text>
+        )}
+        <Table
+          columns={driftColumns}
+          dataSource={parseDrifts(viewingReport?.details)}
+          rowKey={(record) => `${record.account_type}-${record.account_id}`}
+          size='small'
+          pagination={{ pageSize }}
+        />
+      </Modal>
+    </>
+  );
+}
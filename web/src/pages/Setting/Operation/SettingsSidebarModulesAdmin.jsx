@@ -56,6 +56,7 @@ export default function SettingsSidebarModulesAdmin(props) {
     personal: {
       enabled: true,
       topup: true,
+      organization: true,
       personal: true,
     },
     admin: {
@@ -65,6 +66,7 @@ export default function SettingsSidebarModulesAdmin(props) {
       redemption: true,
       user: true,
       errorLog: true,
+      inflight: true,
       setting: true,
     },
   });
@@ -116,6 +118,7 @@ export default function SettingsSidebarModulesAdmin(props) {
       personal: {
         enabled: true,
         topup: true,
+        organization: true,
         personal: true,
       },
       admin: {
@@ -125,6 +128,7 @@ export default function SettingsSidebarModulesAdmin(props) {
         redemption: true,
         user: true,
         errorLog: true,
+        inflight: true,
         setting: true,
       },
     };
@@ -185,7 +189,12 @@ export default function SettingsSidebarModulesAdmin(props) {
             midjourney: true,
             task: true,
           },
-          personal: { enabled: true, topup: true, personal: true },
+          personal: {
+            enabled: true,
+            topup: true,
+            organization: true,
+            personal: true,
+          },
           admin: {
             enabled: true,
             channel: true,
@@ -193,6 +202,7 @@ export default function SettingsSidebarModulesAdmin(props) {
             redemption: true,
             user: true,
             errorLog: true,
+            inflight: true,
             setting: true,
           },
         };
@@ -238,6 +248,11 @@ export default function SettingsSidebarModulesAdmin(props) {
       description: t('用户个人功能'),
       modules: [
         { key: 'topup', title: t('钱包管理'), description: t('余额充值管理') },
+        {
+          key: 'organization',
+          title: t('团队管理'),
+          description: t('团队额度池、成员和邀请'),
+        },
         {
           key: 'personal',
           title: t('个人设置'),
@@ -263,6 +278,11 @@ export default function SettingsSidebarModulesAdmin(props) {
           title: t('错误日志'),
           description: t('错误日志查看'),
         },
+        {
+          key: 'inflight',
+          title: t('进行中的请求'),
+          description: t('实时查看与取消进行中的请求'),
+        },
         {
           key: 'setting',
           title: t('系统设置'),
@@ -0,0 +1,611 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState, useRef } from 'react';
+import {
+  Button,
+  Col,
+  Form,
+  InputNumber,
+  Modal,
+  Popconfirm,
+  Row,
+  Select,
+  Space,
+  Spin,
+  Table,
+  TabPane,
+  Tabs,
+  Tag,
+} from '@douyinfe/semi-ui';
+import { useTranslation } from 'react-i18next';
+import {
+  compareObjects,
+  API,
+  renderQuota,
+  showError,
+  showSuccess,
+  showWarning,
+  timestamp2string,
+} from '../../../helpers';
+
+const STATUS_COLORS = {
+  pending: 'grey',
+  active: 'green',
+  expired: 'orange',
+  canceled: 'grey',
+};
+
+const emptyPlan = {
+  name: '',
+  description: '',
+  price: 0,
+  currency: 'USD',
+  quota: 0,
+  user_group: '',
+  allowed_groups: '',
+  allowed_models: '',
+  rate_limit_count: 0,
+  rate_limit_success_count: 0,
+  stripe_price_id: '',
+  creem_product_id: '',
+  enabled: true,
+  sort_order: 0,
+};
+
+export default function SettingsSubscription(props) {
+  const { t } = useTranslation();
+  const [loading, setLoading] = useState(false);
+  const [inputs, setInputs] = useState({
+    'subscription_setting.enabled': false,
+    'subscription_setting.expired_group': 'default',
+    'subscription_setting.grace_period_hours': 24,
+    'subscription_setting.prorate_on_change': true,
+  });
+  const refForm = useRef();
+  const [inputsRow, setInputsRow] = useState(inputs);
+
+  const pageSize = 10;
+  const [plans, setPlans] = useState([]);
+  const [editingPlan, setEditingPlan] = useState(null);
+  const planFormRef = useRef();
+  const [subscriptions, setSubscriptions] = useState([]);
+  const [subscriptionTotal, setSubscriptionTotal] = useState(0);
+  const [subscriptionPage, setSubscriptionPage] = useState(1);
+  const [query, setQuery] = useState({ user_id: '', status: '' });
+  const [grant, setGrant] = useState({ user_id: '', plan_id: undefined });
+
+  function onSubmit() {
+    const updateArray = compareObjects(inputs, inputsRow);
+    if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    const requestQueue = updateArray.map((item) => {
+      let value = '';
+      if (typeof inputs[item.key] === 'boolean') {
+        value = String(inputs[item.key]);
+      } else {
+        value = inputs[item.key];
+      }
+      return API.put('/api/option/', {
+        key: item.key,
+        value,
+      });
+    });
+    setLoading(true);
+    Promise.all(requestQueue)
+      .then((res) => {
+        if (requestQueue.length === 1) {
+          if (res.includes(undefined)) return;
+        } else if (requestQueue.length > 1) {
+          if (res.includes(undefined))
+            return showError(t('部分保存失败，请重试'));
+        }
+        showSuccess(t('保存成功'));
+        props.refresh();
+      })
+      .catch(() => {
+        showError(t('保存失败，请重试'));
+      })
+      .finally(() => {
+        setLoading(false);
+      });
+  }
+
+  const loadPlans = async () => {
+    const res = await API.get('/api/subscription/plans');
+    const { success, message, data } = res.data;
+    if (success) {
+      setPlans(data || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const loadSubscriptions = async (page = subscriptionPage) => {
+    const params = new URLSearchParams({
+      p: page,
+      page_size: pageSize,
+      ...query,
+    });
+    const res = await API.get(`/api/subscription/?${params.toString()}`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setSubscriptions(data.items || []);
+      setSubscriptionTotal(data.total || 0);
+    } else {
+      showError(message);
+    }
+  };
+
+  const savePlan = async () => {
+    const values = planFormRef.current.getValues();
+    const plan = { ...editingPlan, ...values };
+    const res = plan.id
+      ? await API.put('/api/subscription/plans', plan)
+      : await API.post('/api/subscription/plans', plan);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('保存成功'));
+      setEditingPlan(null);
+      loadPlans();
+    } else {
+      showError(message);
+    }
+  };
+
+  const deletePlan = async (id) => {
+    const res = await API.delete(`/api/subscription/plans/${id}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('删除成功'));
+      loadPlans();
+    } else {
+      showError(message);
+    }
+  };
+
+  const grantSubscription = async () => {
+    if (!grant.user_id || !grant.plan_id) {
+      showError(t('请输入用户 ID 并选择套餐'));
+      return;
+    }
+    const res = await API.post('/api/subscription/grant', grant);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('开通成功'));
+      loadSubscriptions(1);
+    } else {
+      showError(message);
+    }
+  };
+
+  const expireSubscription = async (id) => {
+    const res = await API.post(`/api/subscription/${id}/expire`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('订阅已结束'));
+      loadSubscriptions(subscriptionPage);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    const currentInputs = {};
+    for (let key in props.options) {
+      if (Object.keys(inputs).includes(key)) {
+        currentInputs[key] = props.options[key];
+      }
+    }
+    setInputs(currentInputs);
+    setInputsRow(structuredClone(currentInputs));
+    refForm.current.setValues(currentInputs);
+  }, [props.options]);
+
+  useEffect(() => {
+    loadPlans();
+  }, []);
+
+  useEffect(() => {
+    loadSubscriptions(subscriptionPage);
+  }, [subscriptionPage]);
+
+  const planColumns = [
+    {
+      title: t('名称'),
+      dataIndex: 'name',
+      render: (value, record) => (
+        <Space>
+          {value}
+          {!record.enabled && <Tag color='grey'>{t('已下架')}</Tag>}
+        </Space>
+      ),
+    },
+    {
+      title: t('月价格'),
+      dataIndex: 'price',
+      render: (value, record) => `${value} ${record.currency}`,
+    },
+    {
+      title: t('每周期额度'),
+      dataIndex: 'quota',
+      render: (value) => renderQuota(value),
+    },
+    {
+      title: t('订阅分组'),
+      dataIndex: 'user_group',
+      render: (value) => value || '-',
+    },
+    {
+      title: t('允许的模型'),
+      dataIndex: 'allowed_models',
+      render: (value) => value || t('不限'),
+    },
+    {
+      title: t('支付渠道'),
+      dataIndex: 'payment',
+      render: (_, record) => (
+        <Space>
+          {record.stripe_price_id && <Tag>Stripe</Tag>}
+          {record.creem_product_id && <Tag>Creem</Tag>}
+        </Space>
+      ),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, record) => (
+        <Space>
+          <Button size='small' onClick={() => setEditingPlan(record)}>
+            {t('编辑')}
+          </Button>
+          <Popconfirm
+            title={t('确定删除该套餐？')}
+            onConfirm={() => deletePlan(record.id)}
+          >
+            <Button size='small' type='danger'>
+              {t('删除')}
+            </Button>
+          </Popconfirm>
+        </Space>
+      ),
+    },
+  ];
+
+  const subscriptionColumns = [
+    {
+      title: 'ID',
+      dataIndex: 'id',
+    },
+    {
+      title: t('用户'),
+      dataIndex: 'user_id',
+    },
+    {
+      title: t('套餐'),
+      dataIndex: 'plan',
+      render: (value, record) => value?.name || `#${record.plan_id}`,
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'status',
+      render: (value, record) => (
+        <Space>
+          <Tag color={STATUS_COLORS[value]}>{t(value)}</Tag>
+          {value === 'active' && record.cancel_at_period_end && (
+            <Tag color='orange'>{t('到期后取消')}</Tag>
+          )}
+        </Space>
+      ),
+    },
+    {
+      title: t('支付渠道'),
+      dataIndex: 'payment_method',
+    },
+    {
+      title: t('本周期额度'),
+      dataIndex: 'period_quota',
+      render: (value) => renderQuota(value),
+    },
+    {
+      title: t('当前周期'),
+      dataIndex: 'period_start',
+      render: (value, record) =>
+        value
+          ? `${timestamp2string(value)} ~ ${timestamp2string(record.period_end)}`
+          : '-',
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, record) =>
+        record.status === 'active' ? (
+          <Popconfirm
+            title={t('确定立即结束该订阅？未使用的包含额度将作废')}
+            onConfirm={() => expireSubscription(record.id)}
+          >
+            <Button size='small' type='danger'>
+              {t('结束订阅')}
+            </Button>
+          </Popconfirm>
+        ) : null,
+    },
+  ];
+
+  return (
+    <>
+      <Spin spinning={loading}>
+        <Form
+          values={inputs}
+          getFormApi={(formAPI) => (refForm.current = formAPI)}
+          style={{ marginBottom: 15 }}
+        >
+          <Form.Section text={t('订阅套餐')}>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={6} lg={6} xl={6}>
+                <Form.Switch
+                  field={'subscription_setting.enabled'}
+                  label={t('开放订阅')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'subscription_setting.enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={6} lg={6} xl={6}>
+                <Form.Input
+                  field={'subscription_setting.expired_group'}
+                  label={t('到期后分组')}
+                  extraText={t('为空时恢复订阅前的分组')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'subscription_setting.expired_group': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={6} lg={6} xl={6}>
+                <Form.InputNumber
+                  field={'subscription_setting.grace_period_hours'}
+                  label={t('续费宽限时间')}
+                  extraText={t('周期结束后等待支付平台续费回调的时间')}
+                  step={1}
+                  min={0}
+                  suffix={t('小时')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'subscription_setting.grace_period_hours': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={6} lg={6} xl={6}>
+                <Form.Switch
+                  field={'subscription_setting.prorate_on_change'}
+                  label={t('升降级折算额度')}
+                  extraText={t(
+                    '变更套餐时按本周期剩余时间补发或收回包含额度差额',
+                  )}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'subscription_setting.prorate_on_change': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Button size='default' onClick={onSubmit}>
+                {t('保存订阅设置')}
+              </Button>
+            </Row>
+          </Form.Section>
+        </Form>
+      </Spin>
+      <Tabs type='line'>
+        <TabPane tab={t('套餐')} itemKey='plans'>
+          <Space className='mb-3'>
+            <Button onClick={() => setEditingPlan({ ...emptyPlan })}>
+              {t('新增套餐')}
+            </Button>
+          </Space>
+          <Table
+            columns={planColumns}
+            dataSource={plans}
+            rowKey='id'
+            size='small'
+            pagination={false}
+          />
+        </TabPane>
+        <TabPane tab={t('用户订阅')} itemKey='subscriptions'>
+          <Space className='mb-3' wrap>
+            <InputNumber
+              placeholder={t('用户 ID')}
+              value={query.user_id}
+              min={1}
+              onChange={(value) => setQuery({ ...query, user_id: value || '' })}
+            />
+            <Select
+              placeholder={t('状态')}
+              value={query.status || undefined}
+              showClear
+              optionList={Object.keys(STATUS_COLORS).map((value) => ({
+                value,
+                label: t(value),
+              }))}
+              onChange={(value) => setQuery({ ...query, status: value || '' })}
+              style={{ width: 120 }}
+            />
+            <Button
+              onClick={() => {
+                setSubscriptionPage(1);
+                loadSubscriptions(1);
+              }}
+            >
+              {t('查询')}
+            </Button>
+          </Space>
+          <Space className='mb-3' wrap>
+            <InputNumber
+              placeholder={t('用户 ID')}
+              value={grant.user_id}
+              min={1}
+              onChange={(value) => setGrant({ ...grant, user_id: value || '' })}
+            />
+            <Select
+              placeholder={t('套餐')}
+              value={grant.plan_id}
+              optionList={plans.map((plan) => ({
+                value: plan.id,
+                label: plan.name,
+              }))}
+              onChange={(value) => setGrant({ ...grant, plan_id: value })}
+              style={{ width: 160 }}
+            />
+            <Button onClick={grantSubscription}>{t('为用户开通')}</Button>
+          </Space>
+          <Table
+            columns={subscriptionColumns}
+            dataSource={subscriptions}
+            rowKey='id'
+            size='small'
+            pagination={{
+              currentPage: subscriptionPage,
+              pageSize,
+              total: subscriptionTotal,
+              onPageChange: setSubscriptionPage,
+            }}
+          />
+        </TabPane>
+      </Tabs>
+      <Modal
+        title={editingPlan?.id ? t('编辑套餐') : t('新增套餐')}
+        visible={!!editingPlan}
+        onOk={savePlan}
+        onCancel={() => setEditingPlan(null)}
+        width={720}
+      >
+        {editingPlan && (
+          <Form
+            initValues={editingPlan}
+            getFormApi={(formAPI) => (planFormRef.current = formAPI)}
+          >
+            <Row gutter={16}>
+              <Col span={12}>
+                <Form.Input field='name' label={t('名称')} />
+              </Col>
+              <Col span={12}>
+                <Form.Input field='description' label={t('描述')} />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col span={8}>
+                <Form.InputNumber
+                  field='price'
+                  label={t('月价格')}
+                  extraText={t('仅用于展示，实际扣款以支付平台配置为准')}
+                  min={0}
+                />
+              </Col>
+              <Col span={8}>
+                <Form.Input field='currency' label={t('币种')} />
+              </Col>
+              <Col span={8}>
+                <Form.InputNumber
+                  field='quota'
+                  label={t('每周期额度')}
+                  extraText={t('每个周期开始时发放，未用完的部分在周期结束时作废')}
+                  min={0}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col span={8}>
+                <Form.Input
+                  field='user_group'
+                  label={t('订阅分组')}
+                  extraText={t('订阅期间用户所在分组，为空不调整')}
+                />
+              </Col>
+              <Col span={16}>
+                <Form.Input
+                  field='allowed_groups'
+                  label={t('允许的分组')}
+                  extraText={t('逗号分隔，为空不限制')}
+                />
+              </Col>
+            </Row>
+            <Form.TextArea
+              field='allowed_models'
+              label={t('允许的模型')}
+              extraText={t('逗号分隔，为空不限制')}
+              autosize
+            />
+            <Row gutter={16}>
+              <Col span={12}>
+                <Form.InputNumber
+                  field='rate_limit_count'
+                  label={t('限流请求次数')}
+                  extraText={t('包括失败请求，0 表示使用分组限流配置')}
+                  min={0}
+                />
+              </Col>
+              <Col span={12}>
+                <Form.InputNumber
+                  field='rate_limit_success_count'
+                  label={t('限流成功请求次数')}
+                  extraText={t('0 表示使用分组限流配置')}
+                  min={0}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col span={12}>
+                <Form.Input
+                  field='stripe_price_id'
+                  label={t('Stripe 价格 ID')}
+                  extraText={t('按月循环扣款的价格，为空不支持 Stripe 订阅')}
+                />
+              </Col>
+              <Col span={12}>
+                <Form.Input
+                  field='creem_product_id'
+                  label={t('Creem 产品 ID')}
+                  extraText={t('订阅类型的产品，为空不支持 Creem 订阅')}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col span={12}>
+                <Form.InputNumber field='sort_order' label={t('排序')} />
+              </Col>
+              <Col span={12}>
+                <Form.Switch field='enabled' label={t('上架')} />
+              </Col>
+            </Row>
+          </Form>
+        )}
+      </Modal>
+    </>
+  );
+}
@@ -94,6 +94,7 @@ export default function SettingsSidebarModulesUser() {
       defaultConfig.personal = {
         enabled: true,
         topup: isSidebarModuleAllowed('personal', 'topup'),
+        organization: isSidebarModuleAllowed('personal', 'organization'),
         personal: isSidebarModuleAllowed('personal', 'personal'),
       };
     }
@@ -308,6 +309,11 @@ export default function SettingsSidebarModulesUser() {
       description: t('用户个人功能'),
       modules: [
         { key: 'topup', title: t('钱包管理'), description: t('余额充值管理') },
+        {
+          key: 'organization',
+          title: t('团队管理'),
+          description: t('团队额度池、成员和邀请'),
+        },
         {
           key: 'personal',
           title: t('个人设置'),
@@ -47,6 +47,8 @@ export default function ModelRatioSettings(props) {
     ImageRatio: '',
     AudioRatio: '',
     AudioCompletionRatio: '',
+    ModelPricingTiers: '',
+    PricingTimeRules: '',
     ExposeRatioEnabled: false,
   });
   const refForm = useRef();
@@ -294,6 +296,58 @@ export default function ModelRatioSettings(props) {
             />
           </Col>
         </Row>
+        <Row gutter={16}>
+          <Col xs={24} sm={16}>
+            <Form.TextArea
+              label={t('阶梯计价')}
+              extraText={t(
+                '键为模型名称；context_tiers 按输入长度分档，输入 tokens 超过阈值时整次请求使用该档倍率，倍率为 0 表示沿用基础配置；volume_tiers 按用户当月在该模型上的累计 tokens 分档，达到阈值后价格乘以系数，用量从配置后开始累计',
+              )}
+              placeholder={t(
+                '为一个 JSON 文本，例如：{"gemini-2.5-pro": {"context_tiers": [{"min_prompt_tokens": 200000, "model_ratio": 1.25, "completion_ratio": 6}], "volume_tiers": [{"min_monthly_tokens": 100000000, "multiplier": 0.9}]}}',
+              )}
+              field={'ModelPricingTiers'}
+              autosize={{ minRows: 6, maxRows: 12 }}
+              trigger='blur'
+              stopValidateWithError
+              rules={[
+                {
+                  validator: (rule, value) => verifyJSON(value),
+                  message: '不是合法的 JSON 字符串',
+                },
+              ]}
+              onChange={(value) =>
+                setInputs({ ...inputs, ModelPricingTiers: value })
+              }
+            />
+          </Col>
+        </Row>
+        <Row gutter={16}>
+          <Col xs={24} sm={16}>
+            <Form.TextArea
+              label={t('时段定价')}
+              extraText={t(
+                '为规则数组，在指定时段内将模型倍率（target 为 model，按次计费时为模型价格）或分组倍率（target 为 group）乘以 multiplier；models 支持 * 结尾的前缀匹配，models、groups、weekdays 为空表示全部；start_time 晚于 end_time 表示跨天；start_at、end_at 为规则有效期的时间戳；同一目标命中多条规则时取第一条，请求按开始时间计费',
+              )}
+              placeholder={t(
+                '为一个 JSON 文本，例如：[{"name": "夜间优惠", "target": "model", "multiplier": 0.5, "models": ["deepseek-*"], "timezone": "Asia/Shanghai", "start_time": "00:30", "end_time": "08:30"}]',
+              )}
+              field={'PricingTimeRules'}
+              autosize={{ minRows: 6, maxRows: 12 }}
+              trigger='blur'
+              stopValidateWithError
+              rules={[
+                {
+                  validator: (rule, value) => verifyJSON(value),
+                  message: '不是合法的 JSON 字符串',
+                },
+              ]}
+              onChange={(value) =>
+                setInputs({ ...inputs, PricingTimeRules: value })
+              }
+            />
+          </Col>
+        </Row>
         <Row gutter={16}>
           <Col span={16}>
             <Form.Switch
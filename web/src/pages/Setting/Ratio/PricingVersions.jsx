@@ -0,0 +1,443 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  Banner,
+  Button,
+  DatePicker,
+  Input,
+  Modal,
+  Popconfirm,
+  Select,
+  Space,
+  Table,
+  Tag,
+  TextArea,
+  Typography,
+} from '@douyinfe/semi-ui';
+import {
+  API,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../../helpers';
+
+const { Text } = Typography;
+
+const PRICING_KEYS = [
+  'ModelRatio',
+  'ModelPrice',
+  'CompletionRatio',
+  'CacheRatio',
+  'ImageRatio',
+  'AudioRatio',
+  'AudioCompletionRatio',
+  'GroupRatio',
+];
+
+const STATUS_TAGS = {
+  scheduled: { color: 'orange', text: '计划中' },
+  applied: { color: 'green', text: '已生效' },
+  cancelled: { color: 'grey', text: '已取消' },
+};
+
+const formatValue = (value) =>
+  value === null || value === undefined ? '-' : value;
+
+const toTimestamp = (date) =>
+  date ? Math.floor(new Date(date).getTime() / 1000) : 0;
+
+export default function PricingVersions(props) {
+  const { t } = useTranslation();
+  const [versions, setVersions] = useState([]);
+  const [total, setTotal] = useState(0);
+  const [page, setPage] = useState(1);
+  const [detail, setDetail] = useState(null);
+  const [scheduleVisible, setScheduleVisible] = useState(false);
+  const [scheduleName, setScheduleName] = useState('');
+  const [scheduleTime, setScheduleTime] = useState(null);
+  const [scheduleKeys, setScheduleKeys] = useState([]);
+  const [scheduleContent, setScheduleContent] = useState({});
+  const [submitting, setSubmitting] = useState(false);
+  const [lookupTime, setLookupTime] = useState(null);
+  const [lookupModel, setLookupModel] = useState('');
+  const [lookupResult, setLookupResult] = useState(null);
+  const pageSize = 10;
+
+  const loadVersions = async (p = page) => {
+    const res = await API.get(
+      `/api/pricing_version/?p=${p}&page_size=${pageSize}`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setVersions(data.items || []);
+      setTotal(data.total || 0);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadVersions(page);
+  }, [page]);
+
+  const refreshAll = () => {
+    loadVersions(1);
+    setPage(1);
+    props.refresh && props.refresh();
+  };
+
+  const openDetail = async (record) => {
+    const res = await API.get(`/api/pricing_version/${record.id}`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setDetail(data);
+    } else {
+      showError(message);
+    }
+  };
+
+  const restoreVersion = async (record) => {
+    const res = await API.post(`/api/pricing_version/${record.id}/restore`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('已恢复至该版本'));
+      refreshAll();
+    } else {
+      showError(message);
+    }
+  };
+
+  const cancelVersion = async (record) => {
+    const res = await API.delete(`/api/pricing_version/${record.id}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('已取消'));
+      loadVersions(page);
+    } else {
+      showError(message);
+    }
+  };
+
+  const openSchedule = () => {
+    setScheduleName('');
+    setScheduleTime(null);
+    setScheduleKeys([]);
+    setScheduleContent({});
+    setScheduleVisible(true);
+  };
+
+  const onScheduleKeysChange = (keys) => {
+    const content = {};
+    keys.forEach((key) => {
+      content[key] =
+        scheduleContent[key] !== undefined
+          ? scheduleContent[key]
+          : props.options[key] || '{}';
+    });
+    setScheduleKeys(keys);
+    setScheduleContent(content);
+  };
+
+  const submitSchedule = async () => {
+    if (scheduleKeys.length === 0) {
+      showError(t('至少需要修改一项计价配置'));
+      return;
+    }
+    for (const key of scheduleKeys) {
+      try {
+        JSON.parse(scheduleContent[key]);
+      } catch (e) {
+        showError(t('{{key}} 不是合法的 JSON', { key }));
+        return;
+      }
+    }
+    setSubmitting(true);
+    try {
+      const res = await API.post('/api/pricing_version/', {
+        name: scheduleName,
+        effective_at: toTimestamp(scheduleTime),
+        content: scheduleContent,
+      });
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t('保存成功'));
+        setScheduleVisible(false);
+        refreshAll();
+      } else {
+        showError(message);
+      }
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const lookupPricing = async () => {
+    const res = await API.get(
+      `/api/pricing_version/at?timestamp=${toTimestamp(lookupTime)}&model=${encodeURIComponent(lookupModel.trim())}`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setLookupResult(data);
+    } else {
+      setLookupResult(null);
+      showError(message);
+    }
+  };
+
+  const columns = [
+    {
+      title: t('版本'),
+      dataIndex: 'id',
+      render: (id) => `#${id}`,
+    },
+    {
+      title: t('名称'),
+      dataIndex: 'name',
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'status',
+      render: (text) => {
+        const tag = STATUS_TAGS[text] || { color: 'grey', text };
+        return <Tag color={tag.color}>{t(tag.text)}</Tag>;
+      },
+    },
+    {
+      title: t('计划生效时间'),
+      dataIndex: 'effective_at',
+      render: (text) => timestamp2string(text),
+    },
+    {
+      title: t('实际生效时间'),
+      dataIndex: 'applied_at',
+      render: (text) => (text ? timestamp2string(text) : '-'),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (text, record) => (
+        <Space>
+          <Button size='small' onClick={() => openDetail(record)}>
+            {t('详情')}
+          </Button>
+          {record.status === 'applied' && (
+            <Popconfirm
+              title={t('确定恢复至该版本的定价？')}
+              onConfirm={() => restoreVersion(record)}
+            >
+              <Button size='small' type='warning'>
+                {t('恢复')}
+              </Button>
+            </Popconfirm>
+          )}
+          {record.status === 'scheduled' && (
+            <Popconfirm
+              title={t('确定取消该计划调价？')}
+              onConfirm={() => cancelVersion(record)}
+            >
+              <Button size='small' type='danger'>
+                {t('取消')}
+              </Button>
+            </Popconfirm>
+          )}
+        </Space>
+      ),
+    },
+  ];
+
+  const changeColumns = [
+    {
+      title: t('配置项'),
+      dataIndex: 'key',
+    },
+    {
+      title: t('模型/分组'),
+      dataIndex: 'name',
+    },
+    {
+      title: t('原值'),
+      dataIndex: 'old_value',
+      render: (value) => formatValue(value),
+    },
+    {
+      title: t('新值'),
+      dataIndex: 'new_value',
+      render: (value) =>
+        value === null || value === undefined ? (
+          <Text type='tertiary'>{t('已移除')}</Text>
+        ) : (
+          value
+        ),
+    },
+  ];
+
+  const lookupRows = lookupResult
+    ? PRICING_KEYS.flatMap((key) =>
+        Object.entries(lookupResult.values[key] || {}).map(
+          ([name, value]) => ({ key, name, value }),
+        ),
+      )
+    : [];
+
+  return (
+    <>
+      <Space className='mb-3'>
+        <Button type='primary' onClick={openSchedule}>
+          {t('计划调价')}
+        </Button>
+        <Button onClick={() => loadVersions(page)}>{t('刷新')}</Button>
+      </Space>
+      <Table
+        columns={columns}
+        dataSource={versions}
+        rowKey='id'
+        size='small'
+        pagination={{
+          currentPage: page,
+          pageSize,
+          total,
+          onPageChange: setPage,
+        }}
+      />
+      <Typography.Title heading={6} className='mt-4 mb-2'>
+        {t('历史定价查询')}
+      </Typography.Title>
+      <Space className='mb-3'>
+        <DatePicker
+          type='dateTime'
+          placeholder={t('留空表示当前')}
+          value={lookupTime}
+          onChange={setLookupTime}
+        />
+        <Input
+          placeholder={t('模型名称（可选）')}
+          value={lookupModel}
+          onChange={setLookupModel}
+          showClear
+        />
+        <Button onClick={lookupPricing}>{t('查询')}</Button>
+      </Space>
+      {lookupResult && (
+        <>
+          <div className='mb-2'>
+            <Text type='tertiary'>
+              {t('生效版本')}: #{lookupResult.version.id}{' '}
+              {lookupResult.version.name} (
+              {timestamp2string(lookupResult.version.applied_at)})
+            </Text>
+          </div>
+          <Table
+            columns={[
+              { title: t('配置项'), dataIndex: 'key' },
+              { title: t('模型/分组'), dataIndex: 'name' },
+              { title: t('值'), dataIndex: 'value' },
+            ]}
+            dataSource={lookupRows}
+            rowKey={(record) => `${record.key}|${record.name}`}
+            size='small'
+            pagination={{ pageSize }}
+          />
+        </>
+      )}
+      <Modal
+        title={
+          detail
+            ? `${t('定价版本')} #${detail.version.id} ${detail.version.name}`
+            : ''
+        }
+        visible={!!detail}
+        onCancel={() => setDetail(null)}
+        footer={null}
+        width={800}
+      >
+        {detail && (
+          <>
+            <Text type='tertiary' size='small'>
+              {detail.version.status === 'applied'
+                ? t('与上一个生效版本相比的变更')
+                : t('与当前定价相比，生效后将产生的变更')}
+            </Text>
+            <Table
+              className='mt-2'
+              columns={changeColumns}
+              dataSource={detail.changes}
+              rowKey={(record) => `${record.key}|${record.name}`}
+              size='small'
+              pagination={{ pageSize }}
+            />
+          </>
+        )}
+      </Modal>
+      <Modal
+        title={t('计划调价')}
+        visible={scheduleVisible}
+        onCancel={() => setScheduleVisible(false)}
+        onOk={submitSchedule}
+        okButtonProps={{ loading: submitting }}
+        width={800}
+      >
+        <Banner
+          type='info'
+          className='mb-3'
+          description={t(
+            '选中的配置项在生效时间到达后整体替换为下方内容，未选中的配置项保持不变；生效时间留空表示立即生效',
+          )}
+        />
+        <Space vertical align='start' style={{ width: '100%' }}>
+          <Input
+            placeholder={t('版本名称')}
+            value={scheduleName}
+            onChange={setScheduleName}
+          />
+          <DatePicker
+            type='dateTime'
+            placeholder={t('生效时间')}
+            value={scheduleTime}
+            onChange={setScheduleTime}
+          />
+          <Select
+            multiple
+            style={{ width: '100%' }}
+            placeholder={t('选择要修改的配置项')}
+            value={scheduleKeys}
+            onChange={onScheduleKeysChange}
+            optionList={PRICING_KEYS.map((key) => ({
+              label: key,
+              value: key,
+            }))}
+          />
+          {scheduleKeys.map((key) => (
+            <div key={key} style={{ width: '100%' }}>
+              <Text strong>{key}</Text>
+              <TextArea
+                autosize={{ minRows: 4, maxRows: 12 }}
+                value={scheduleContent[key]}
+                onChange={(value) =>
+                  setScheduleContent({ ...scheduleContent, [key]: value })
+                }
+              />
+            </div>
+          ))}
+        </Space>
+      </Modal>
+    </>
+  );
+}
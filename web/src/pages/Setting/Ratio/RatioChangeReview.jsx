@@ -0,0 +1,425 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  Button,
+  Input,
+  Modal,
+  Space,
+  Table,
+  Tabs,
+  TabPane,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import {
+  API,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../../helpers';
+
+const { Text } = Typography;
+
+const STATUS_TAGS = {
+  pending: { color: 'orange', text: '待审核' },
+  approved: { color: 'green', text: '已通过' },
+  rejected: { color: 'red', text: '已拒绝' },
+  superseded: { color: 'grey', text: '已失效' },
+};
+
+const SOURCE_TAGS = {
+  auto: { color: 'blue', text: '自动应用' },
+  approved: { color: 'green', text: '审核通过' },
+};
+
+const parseChanges = (value) => {
+  try {
+    return JSON.parse(value || '[]');
+  } catch (e) {
+    return [];
+  }
+};
+
+const changeKey = (item) => `${item.model}|${item.ratio_type}`;
+
+const formatValue = (value) =>
+  value === null || value === undefined ? '-' : value;
+
+export default function RatioChangeReview() {
+  const { t } = useTranslation();
+  const [changeSets, setChangeSets] = useState([]);
+  const [changeSetTotal, setChangeSetTotal] = useState(0);
+  const [changeSetPage, setChangeSetPage] = useState(1);
+  const [logs, setLogs] = useState([]);
+  const [logTotal, setLogTotal] = useState(0);
+  const [logPage, setLogPage] = useState(1);
+  const [logModel, setLogModel] = useState('');
+  const [reviewing, setReviewing] = useState(null);
+  const [selectedKeys, setSelectedKeys] = useState([]);
+  const [remark, setRemark] = useState('');
+  const [submitting, setSubmitting] = useState(false);
+  const pageSize = 10;
+
+  const loadChangeSets = async (page = changeSetPage) => {
+    const res = await API.get(
+      `/api/ratio_sync/changes?p=${page}&page_size=${pageSize}`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setChangeSets(data.items || []);
+      setChangeSetTotal(data.total || 0);
+    } else {
+      showError(message);
+    }
+  };
+
+  const loadLogs = async (page = logPage) => {
+    const res = await API.get(
+      `/api/ratio_sync/logs?p=${page}&page_size=${pageSize}&model_name=${encodeURIComponent(logModel)}`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setLogs(data.items || []);
+      setLogTotal(data.total || 0);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadChangeSets(changeSetPage);
+  }, [changeSetPage]);
+
+  useEffect(() => {
+    loadLogs(logPage);
+  }, [logPage]);
+
+  const openReview = (record) => {
+    const items = parseChanges(record.changes);
+    setReviewing({ ...record, items });
+    setSelectedKeys(
+      items
+        .filter((item) => !item.applied && !item.exceeds_limit)
+        .map(changeKey),
+    );
+    setRemark('');
+  };
+
+  const submitReview = async (action) => {
+    if (action === 'approve' && selectedKeys.length === 0) {
+      showError(t('请至少选择一项变更'));
+      return;
+    }
+    setSubmitting(true);
+    try {
+      const payload = { remark };
+      if (action === 'approve') {
+        payload.keys = selectedKeys;
+      }
+      const res = await API.post(
+        `/api/ratio_sync/changes/${reviewing.id}/${action}`,
+        payload,
+      );
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(action === 'approve' ? t('变更已应用') : t('已拒绝'));
+        setReviewing(null);
+        loadChangeSets(changeSetPage);
+        loadLogs(1);
+        setLogPage(1);
+      } else {
+        showError(message);
+      }
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const itemColumns = [
+    {
+      title: t('模型'),
+      dataIndex: 'model',
+    },
+    {
+      title: t('类型'),
+      dataIndex: 'ratio_type',
+    },
+    {
+      title: t('当前值'),
+      dataIndex: 'current',
+      render: (value) => formatValue(value),
+    },
+    {
+      title: t('建议值'),
+      dataIndex: 'proposed',
+    },
+    {
+      title: t('变动幅度'),
+      dataIndex: 'change_percent',
+      render: (value, record) =>
+        record.current === null ? (
+          <Tag color='blue' size='small'>
+            {t('新增')}
+          </Tag>
+        ) : (
+          <Text type={record.exceeds_limit ? 'danger' : undefined}>
+            {value}%
+          </Text>
+        ),
+    },
+    {
+      title: t('上游'),
+      dataIndex: 'upstream',
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'applied',
+      render: (applied, record) =>
+        applied ? (
+          <Tag color='green' size='small'>
+            {t('已应用')}
+          </Tag>
+        ) : record.exceeds_limit ? (
+          <Tag color='red' size='small'>
+            {t('超出变动限制')}
+          </Tag>
+        ) : (
+          '-'
+        ),
+    },
+  ];
+
+  const changeSetColumns = [
+    {
+      title: t('时间'),
+      dataIndex: 'created_at',
+      render: (text) => timestamp2string(text),
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'status',
+      render: (text) => {
+        const tag = STATUS_TAGS[text] || { color: 'grey', text };
+        return <Tag color={tag.color}>{t(tag.text)}</Tag>;
+      },
+    },
+    {
+      title: t('上游'),
+      dataIndex: 'source',
+    },
+    {
+      title: t('变更'),
+      dataIndex: 'change_count',
+      render: (count, record) => {
+        const applied = parseChanges(record.changes).filter(
+          (item) => item.applied,
+        ).length;
+        return t('{{applied}} / {{count}} 项已应用', { applied, count });
+      },
+    },
+    {
+      title: t('备注'),
+      dataIndex: 'remark',
+      render: (text) =>
+        text ? (
+          <Text ellipsis={{ showTooltip: true }} style={{ maxWidth: 200 }}>
+            {text}
+          </Text>
+        ) : (
+          '-'
+        ),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (text, record) => (
+        <Button size='small' onClick={() => openReview(record)}>
+          {record.status === 'pending' ? t('审核') : t('查看')}
+        </Button>
+      ),
+    },
+  ];
+
+  const logColumns = [
+    {
+      title: t('时间'),
+      dataIndex: 'created_at',
+      render: (text) => timestamp2string(text),
+    },
+    {
+      title: t('模型'),
+      dataIndex: 'model_name',
+    },
+    {
+      title: t('类型'),
+      dataIndex: 'ratio_type',
+    },
+    {
+      title: t('原值'),
+      dataIndex: 'old_value',
+      render: (value) => formatValue(value),
+    },
+    {
+      title: t('新值'),
+      dataIndex: 'new_value',
+      render: (value) =>
+        value === null || value === undefined ? (
+          <Text type='tertiary'>{t('已移除')}</Text>
+        ) : (
+          value
+        ),
+    },
+    {
+      title: t('来源'),
+      dataIndex: 'source',
+      render: (text, record) => {
+        const tag = SOURCE_TAGS[text] || { color: 'grey', text };
+        return (
+          <Space>
+            <Tag color={tag.color}>{t(tag.text)}</Tag>
+            <Text type='tertiary' size='small'>
+              {record.upstream}
+            </Text>
+          </Space>
+        );
+      },
+    },
+    {
+      title: t('变更集'),
+      dataIndex: 'change_set_id',
+      render: (id) => `#${id}`,
+    },
+  ];
+
+  const isPending = reviewing && reviewing.status === 'pending';
+
+  return (
+    <>
+      <Tabs type='line'>
+        <TabPane tab={t('变更审核')} itemKey='changes'>
+          <Table
+            columns={changeSetColumns}
+            dataSource={changeSets}
+            rowKey='id'
+            size='small'
+            pagination={{
+              currentPage: changeSetPage,
+              pageSize,
+              total: changeSetTotal,
+              onPageChange: setChangeSetPage,
+            }}
+          />
+        </TabPane>
+        <TabPane tab={t('审计日志')} itemKey='logs'>
+          <Space className='mb-3'>
+            <Input
+              placeholder={t('按模型名称筛选')}
+              value={logModel}
+              onChange={setLogModel}
+              showClear
+            />
+            <Button
+              onClick={() => {
+                setLogPage(1);
+                loadLogs(1);
+              }}
+            >
+              {t('查询')}
+            </Button>
+          </Space>
+          <Table
+            columns={logColumns}
+            dataSource={logs}
+            rowKey='id'
+            size='small'
+            pagination={{
+              currentPage: logPage,
+              pageSize,
+              total: logTotal,
+              onPageChange: setLogPage,
+            }}
+          />
+        </TabPane>
+      </Tabs>
+      <Modal
+        title={t('倍率变更集') + (reviewing ? ` #${reviewing.id}` : '')}
+        visible={!!reviewing}
+        onCancel={() => setReviewing(null)}
+        width={1000}
+        footer={
+          isPending ? (
+            <Space>
+              <Button
+                type='danger'
+                loading={submitting}
+                onClick={() => submitReview('reject')}
+              >
+                {t('全部拒绝')}
+              </Button>
+              <Button
+                type='primary'
+                theme='solid'
+                loading={submitting}
+                onClick={() => submitReview('approve')}
+              >
+                {t('应用所选变更')}
+              </Button>
+            </Space>
+          ) : null
+        }
+      >
+        {isPending && (
+          <div className='mb-3'>
+            <Text type='tertiary' size='small'>
+              {t(
+                '勾选需要应用的变更，未勾选的变更将被丢弃；设置模型价格会移除该模型的倍率，设置倍率会移除该模型的价格',
+              )}
+            </Text>
+            <Input
+              className='mt-2'
+              placeholder={t('备注（可选）')}
+              value={remark}
+              onChange={setRemark}
+            />
+          </div>
+        )}
+        <Table
+          columns={itemColumns}
+          dataSource={reviewing ? reviewing.items : []}
+          rowKey={changeKey}
+          size='small'
+          pagination={{ pageSize }}
+          rowSelection={
+            isPending
+              ? {
+                  selectedRowKeys: selectedKeys,
+                  onChange: (keys) => setSelectedKeys(keys),
+                  getCheckboxProps: (record) => ({
+                    disabled: record.applied,
+                  }),
+                }
+              : undefined
+          }
+        />
+      </Modal>
+    </>
+  );
+}
@@ -0,0 +1,310 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+import React, { useEffect, useState, useRef } from 'react';
+import { Button, Col, Form, Row, Space, Spin } from '@douyinfe/semi-ui';
+import {
+  compareObjects,
+  API,
+  showError,
+  showSuccess,
+  showWarning,
+  toBoolean,
+  verifyJSON,
+} from '../../../helpers';
+import { useTranslation } from 'react-i18next';
+
+const parseList = (value) => {
+  try {
+    const list = JSON.parse(value || '[]');
+    return Array.isArray(list) ? list : [];
+  } catch (e) {
+    return [];
+  }
+};
+
+export default function RatioSyncScheduleSettings(props) {
+  const { t } = useTranslation();
+
+  const [loading, setLoading] = useState(false);
+  const [running, setRunning] = useState(false);
+  const [inputs, setInputs] = useState({
+    'ratio_sync_setting.enabled': false,
+    'ratio_sync_setting.interval_minutes': 1440,
+    'ratio_sync_setting.upstreams': '[]',
+    'ratio_sync_setting.max_change_percent': 50,
+    'ratio_sync_setting.auto_apply_vendors': '[]',
+    'ratio_sync_setting.auto_apply_new_models': false,
+    'ratio_sync_setting.notify': true,
+    'ratio_sync_setting.retention_days': 90,
+  });
+  const refForm = useRef();
+  const [inputsRow, setInputsRow] = useState(inputs);
+
+  function onSubmit() {
+    const updateArray = compareObjects(inputs, inputsRow);
+    if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    const upstreamsKey = 'ratio_sync_setting.upstreams';
+    if (
+      updateArray.some((item) => item.key === upstreamsKey) &&
+      !verifyJSON(inputs[upstreamsKey])
+    ) {
+      return showError(t('上游倍率来源不是合法的 JSON 字符串'));
+    }
+    const requestQueue = updateArray.map((item) => {
+      let value = '';
+      if (typeof inputs[item.key] === 'boolean') {
+        value = String(inputs[item.key]);
+      } else {
+        value = inputs[item.key];
+      }
+      return API.put('/api/option/', {
+        key: item.key,
+        value,
+      });
+    });
+    setLoading(true);
+    Promise.all(requestQueue)
+      .then((res) => {
+        if (requestQueue.length === 1) {
+          if (res.includes(undefined)) return;
+        } else if (requestQueue.length > 1) {
+          if (res.includes(undefined))
+            return showError(t('部分保存失败，请重试'));
+        }
+        showSuccess(t('保存成功'));
+        props.refresh();
+      })
+      .catch(() => {
+        showError(t('保存失败，请重试'));
+      })
+      .finally(() => {
+        setLoading(false);
+      });
+  }
+
+  const runNow = async () => {
+    setRunning(true);
+    try {
+      const res = await API.post('/api/ratio_sync/run');
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t('已在后台开始同步，完成后可在变更审核中查看'));
+      } else {
+        showError(message);
+      }
+    } finally {
+      setRunning(false);
+    }
+  };
+
+  useEffect(() => {
+    const currentInputs = { ...inputs };
+    for (let key in props.options) {
+      if (!Object.keys(inputs).includes(key)) continue;
+      if (typeof inputs[key] === 'boolean') {
+        currentInputs[key] = toBoolean(props.options[key]);
+      } else if (typeof inputs[key] === 'number') {
+        currentInputs[key] = Number(props.options[key]);
+      } else {
+        currentInputs[key] = props.options[key];
+      }
+    }
+    setInputs(currentInputs);
+    setInputsRow(structuredClone(currentInputs));
+    refForm.current.setValues({
+      ...currentInputs,
+      'ratio_sync_setting.auto_apply_vendors': parseList(
+        currentInputs['ratio_sync_setting.auto_apply_vendors'],
+      ),
+    });
+  }, [props.options]);
+
+  return (
+    <>
+      <Spin spinning={loading}>
+        <Form
+          getFormApi={(formAPI) => (refForm.current = formAPI)}
+          style={{ marginBottom: 15 }}
+        >
+          <Form.Section text={t('定时同步设置')}>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'ratio_sync_setting.enabled'}
+                  label={t('启用定时同步')}
+                  extraText={t(
+                    '定时拉取上游倍率，与本地不同的项生成待审核的变更集',
+                  )}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'ratio_sync_setting.enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('同步间隔')}
+                  step={60}
+                  min={10}
+                  suffix={t('分钟')}
+                  field={'ratio_sync_setting.interval_minutes'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'ratio_sync_setting.interval_minutes': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'ratio_sync_setting.notify'}
+                  label={t('变更通知')}
+                  extraText={t('产生新的变更或同步失败时通知管理员')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'ratio_sync_setting.notify': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('最大变动幅度')}
+                  step={5}
+                  min={0}
+                  suffix='%'
+                  extraText={t(
+                    '相对本地值变动超过该幅度的变更只能人工审核，0 表示不限制',
+                  )}
+                  field={'ratio_sync_setting.max_change_percent'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'ratio_sync_setting.max_change_percent': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'ratio_sync_setting.auto_apply_new_models'}
+                  label={t('自动应用新增倍率')}
+                  extraText={t('本地尚未配置的倍率直接应用，无需审核')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'ratio_sync_setting.auto_apply_new_models': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('变更集保留天数')}
+                  step={1}
+                  min={0}
+                  suffix={t('天')}
+                  extraText={t('只清理已处理的变更集，审计日志不会清理')}
+                  field={'ratio_sync_setting.retention_days'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'ratio_sync_setting.retention_days': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                <Form.TagInput
+                  field={'ratio_sync_setting.auto_apply_vendors'}
+                  label={t('自动应用的供应商')}
+                  placeholder={t('输入供应商名称后回车')}
+                  extraText={t(
+                    '按模型管理中的供应商匹配，这些供应商的模型变更在未超出变动幅度时自动应用',
+                  )}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'ratio_sync_setting.auto_apply_vendors':
+                        JSON.stringify(value),
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                <Form.TextArea
+                  label={t('上游倍率来源')}
+                  extraText={t(
+                    '按优先级排列，同一模型取第一个给出可信数值的上游',
+                  )}
+                  placeholder={
+                    '[{"name": "upstream", "base_url": "https://example.com", "endpoint": "/api/ratio_config"}]'
+                  }
+                  field={'ratio_sync_setting.upstreams'}
+                  autosize={{ minRows: 4, maxRows: 12 }}
+                  trigger='blur'
+                  stopValidateWithError
+                  rules={[
+                    {
+                      validator: (rule, value) => verifyJSON(value),
+                      message: t('不是合法的 JSON 字符串'),
+                    },
+                  ]}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'ratio_sync_setting.upstreams': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Space>
+                <Button size='default' onClick={onSubmit}>
+                  {t('保存同步设置')}
+                </Button>
+                <Button size='default' loading={running} onClick={runNow}>
+                  {t('立即同步')}
+                </Button>
+              </Space>
+            </Row>
+          </Form.Section>
+        </Form>
+      </Spin>
+    </>
+  );
+}
@@ -0,0 +1,229 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState } from 'react';
+import { Card, Empty, Spin, Tag, Tooltip, Typography } from '@douyinfe/semi-ui';
+import {
+  IllustrationConstruction,
+  IllustrationConstructionDark,
+} from '@douyinfe/semi-illustrations';
+import { useTranslation } from 'react-i18next';
+import { API, timestamp2string } from '../../helpers';
+
+const { Title, Text, Paragraph } = Typography;
+
+const STATUS_META = {
+  operational: { color: 'green', text: '正常', bar: 'bg-green-500' },
+  degraded: { color: 'orange', text: '性能下降', bar: 'bg-orange-400' },
+  down: { color: 'red', text: '不可用', bar: 'bg-red-500' },
+  maintenance: { color: 'blue', text: '维护中', bar: 'bg-blue-500' },
+  unknown: { color: 'grey', text: '暂无数据', bar: 'bg-gray-300' },
+};
+
+const OVERALL_TEXT = {
+  operational: '所有服务运行正常',
+  degraded: '部分服务性能下降',
+  down: '部分服务不可用',
+  maintenance: '服务维护中',
+  unknown: '暂无数据',
+};
+
+const INCIDENT_STATUS_TEXT = {
+  investigating: '调查中',
+  identified: '已定位',
+  monitoring: '观察中',
+  resolved: '已解决',
+};
+
+const uptimeBarClass = (uptime) => {
+  if (uptime < 0) return STATUS_META.unknown.bar;
+  if (uptime >= 99) return STATUS_META.operational.bar;
+  if (uptime >= 90) return STATUS_META.degraded.bar;
+  return STATUS_META.down.bar;
+};
+
+const Status = () => {
+  const { t } = useTranslation();
+  const [loading, setLoading] = useState(true);
+  const [page, setPage] = useState(null);
+  const [error, setError] = useState('');
+
+  const formatUptime = (uptime) => (uptime < 0 ? '-' : `${uptime}%`);
+
+  const loadStatus = async () => {
+    try {
+      const res = await API.get('/api/status/page');
+      const { success, message, data } = res.data;
+      if (success) {
+        setPage(data);
+        setError('');
+      } else {
+        setError(message);
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  useEffect(() => {
+    loadStatus();
+    const timer = setInterval(loadStatus, 60000);
+    return () => clearInterval(timer);
+  }, []);
+
+  const renderHistory = (history) => {
+    const today = Math.floor(Date.now() / 1000 / 86400) * 86400;
+    return (
+      <div className='flex gap-[2px] mt-2'>
+        {history.map((uptime, index) => {
+          const day = today - (history.length - 1 - index) * 86400;
+          return (
+            <Tooltip
+              key={index}
+              content={`${timestamp2string(day).slice(0, 10)} ${formatUptime(uptime)}`}
+            >
+              <div
+                className={`flex-1 h-6 rounded-sm ${uptimeBarClass(uptime)}`}
+              />
+            </Tooltip>
+          );
+        })}
+      </div>
+    );
+  };
+
+  const renderComponents = (title, components) => {
+    if (!components || components.length === 0) return null;
+    return (
+      <Card className='!rounded-2xl mb-4' title={title}>
+        {components.map((component) => {
+          const meta = STATUS_META[component.status] || STATUS_META.unknown;
+          return (
+            <div key={component.name} className='mb-5 last:mb-0'>
+              <div className='flex flex-wrap items-center justify-between gap-2'>
+                <Text strong>{component.name}</Text>
+                <div className='flex flex-wrap items-center gap-2'>
+                  {component.latency_ms > 0 && (
+                    <Text type='tertiary' size='small'>
+                      {t('平均延迟')} {component.latency_ms} ms
+                    </Text>
+                  )}
+                  <Text type='tertiary' size='small'>
+                    24h {formatUptime(component.uptime_24h)} · 7d{' '}
+                    {formatUptime(component.uptime_7d)} · 30d{' '}
+                    {formatUptime(component.uptime_30d)}
+                  </Text>
+                  <Tag color={meta.color}>{t(meta.text)}</Tag>
+                </div>
+              </div>
+              {renderHistory(component.history || [])}
+            </div>
+          );
+        })}
+      </Card>
+    );
+  };
+
+  const renderIncident = (incident) => {
+    const isMaintenance = incident.type === 'maintenance';
+    const components = [incident.affected_models, incident.affected_groups]
+      .filter(Boolean)
+      .join(',');
+    return (
+      <div key={incident.id} className='mb-4 last:mb-0'>
+        <div className='flex flex-wrap items-center gap-2'>
+          <Tag color={isMaintenance ? 'blue' : 'red'}>
+            {isMaintenance ? t('维护') : t('故障')}
+          </Tag>
+          <Text strong>{incident.title}</Text>
+          {!isMaintenance && (
+            <Tag color={incident.status === 'resolved' ? 'green' : 'orange'}>
+              {t(INCIDENT_STATUS_TEXT[incident.status] || incident.status)}
+            </Tag>
+          )}
+        </div>
+        {incident.content && (
+          <Paragraph className='mt-1 whitespace-pre-wrap'>
+            {incident.content}
+          </Paragraph>
+        )}
+        <Text type='tertiary' size='small'>
+          {timestamp2string(incident.start_time)}
+          {incident.end_time
+            ? ` ~ ${timestamp2string(incident.end_time)}`
+            : ''}
+          {components ? ` · ${t('影响范围')}: ${components}` : ''}
+        </Text>
+      </div>
+    );
+  };
+
+  if (loading) {
+    return (
+      <div className='mt-[60px] flex justify-center py-20'>
+        <Spin size='large' />
+      </div>
+    );
+  }
+
+  if (!page) {
+    return (
+      <div className='mt-[60px] flex justify-center py-20'>
+        <Empty
+          image={
+            <IllustrationConstruction style={{ width: 150, height: 150 }} />
+          }
+          darkModeImage={
+            <IllustrationConstructionDark style={{ width: 150, height: 150 }} />
+          }
+          description={error || t('状态页未启用')}
+        />
+      </div>
+    );
+  }
+
+  const overall = STATUS_META[page.status] || STATUS_META.unknown;
+
+  return (
+    <div className='mt-[60px] px-4 py-6 max-w-4xl mx-auto'>
+      <Card className='!rounded-2xl mb-4'>
+        <div className='flex flex-wrap items-center justify-between gap-2'>
+          <Title heading={4} style={{ margin: 0 }}>
+            {t(OVERALL_TEXT[page.status] || OVERALL_TEXT.unknown)}
+          </Title>
+          <Tag color={overall.color} size='large'>
+            {t(overall.text)}
+          </Tag>
+        </div>
+        <Text type='tertiary' size='small'>
+          {t('更新于')} {timestamp2string(page.updated_at)}
+        </Text>
+      </Card>
+      {page.incidents && page.incidents.length > 0 && (
+        <Card className='!rounded-2xl mb-4' title={t('故障与维护公告')}>
+          {page.incidents.map(renderIncident)}
+        </Card>
+      )}
+      {renderComponents(t('模型'), page.models)}
+      {renderComponents(t('分组'), page.groups)}
+    </div>
+  );
+};
+
+export default Status;